@@ -0,0 +1,198 @@
+package apis
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Các loại item trong activity stream của một user
+const (
+	ActivityPost     = "post"
+	ActivityComment  = "comment"
+	ActivityReaction = "reaction"
+)
+
+// ActivityItem is one entry of a user's activity stream; fields not used by its type are omitted
+type ActivityItem struct {
+	Type         string `json:"type"` // post, comment or reaction
+	CreatedAt    string `json:"created_at"`
+	PostID       int    `json:"post_id"` // post được tạo, được comment hoặc được react
+	CommentID    int    `json:"comment_id,omitempty"`
+	Content      string `json:"content,omitempty"`
+	ReactionType string `json:"reaction_type,omitempty"`
+}
+
+// ActivityResponse represents response for GET /users/{user_id}/activity
+type ActivityResponse struct {
+	Activity   []ActivityItem `json:"activity"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Limit      int            `json:"limit"` // limit đã áp dụng
+}
+
+// @Summary Get User Activity
+// @Description Posts, comments and reactions of a user merged into one stream, newest first.
+// @Description Only activity on posts the requester can see is listed; a private profile is only
+// @Description shown to its owner and followers
+// @Tags feeds
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param after query string false "Opaque cursor from next_cursor"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Param Authorization header string false "Bearer token"
+// @Success 200 {object} ActivityResponse
+// @Failure 400 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /users/{user_id}/activity [get]
+func (h *FeedsHandler) GetUserActivity(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Posts) {
+		return
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil || userID <= 0 {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	viewerID, _ := UserIDFromContext(r.Context())
+
+	profile, ok := h.Profiles.visibleProfile(viewerID, userID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if profile.IsPrivate && !h.Profiles.canViewPrivate(viewerID, userID) {
+		writeJSONError(w, http.StatusForbidden, "This profile is private")
+		return
+	}
+
+	var after *ActivityItem
+	if s := r.URL.Query().Get("after"); s != "" {
+		c, err := decodeActivityCursor(s)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		after = &c
+	}
+	_, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	posts, err := h.Posts.ListUserPosts(r.Context(), userID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load activity")
+		return
+	}
+	items := []ActivityItem{}
+	for _, p := range posts {
+		items = append(items, ActivityItem{Type: ActivityPost, CreatedAt: p.CreatedAt, PostID: p.PostID, Content: p.Content})
+	}
+	items = append(items, h.Comments.activityOf(userID)...)
+	items = append(items, h.Reactions.activityOf(userID)...)
+	sort.Slice(items, func(i, j int) bool { return activityBefore(items[i], items[j]) })
+
+	// mỗi item gắn với một post: chỉ giữ item trên post người xem được thấy, mỗi post kiểm tra một lần
+	visible := make(map[int]bool)
+	page := []ActivityItem{}
+	for _, item := range items {
+		if after != nil && !activityBefore(*after, item) {
+			continue
+		}
+		canSee, checked := visible[item.PostID]
+		if !checked {
+			canSee = h.activityPostVisible(r.Context(), viewerID, item.PostID)
+			visible[item.PostID] = canSee
+		}
+		if !canSee {
+			continue
+		}
+		page = append(page, item)
+		if len(page) == limit {
+			break
+		}
+	}
+
+	nextCursor := ""
+	if len(page) == limit {
+		nextCursor = encodeActivityCursor(page[len(page)-1])
+	}
+	writeJSON(w, http.StatusOK, ActivityResponse{Activity: page, NextCursor: nextCursor, Limit: limit})
+}
+
+// activityPostVisible reports whether viewerID may see postID: it exists, is not deleted,
+// its visibility allows it and neither side blocked the other
+func (h *FeedsHandler) activityPostVisible(ctx context.Context, viewerID, postID int) bool {
+	p, err := h.Posts.GetPost(ctx, postID)
+	if err != nil || p.IsDeleted {
+		return false
+	}
+	return canSeePost(h.Follows, viewerID, p) && !h.Blocks.isBlocked(viewerID, p.UserID)
+}
+
+// activityID identifies an item within its type: the comment for comments, otherwise the post
+func activityID(item ActivityItem) int {
+	if item.Type == ActivityComment {
+		return item.CommentID
+	}
+	return item.PostID
+}
+
+// activityBefore orders the stream newest first; items of the same second are
+// ordered by type and then by ID so the cursor always points at one place
+func activityBefore(a, b ActivityItem) bool {
+	ta, _ := time.Parse(time.RFC3339, a.CreatedAt)
+	tb, _ := time.Parse(time.RFC3339, b.CreatedAt)
+	if !ta.Equal(tb) {
+		return ta.After(tb)
+	}
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	return activityID(a) > activityID(b)
+}
+
+// encodeActivityCursor builds an opaque cursor pointing at item
+func encodeActivityCursor(item ActivityItem) string {
+	raw := item.CreatedAt + "|" + item.Type + "|" + strconv.Itoa(activityID(item))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeActivityCursor parses a cursor produced by encodeActivityCursor
+func decodeActivityCursor(s string) (ActivityItem, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ActivityItem{}, err
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return ActivityItem{}, errors.New("malformed cursor")
+	}
+	if _, err := time.Parse(time.RFC3339, parts[0]); err != nil {
+		return ActivityItem{}, err
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return ActivityItem{}, err
+	}
+
+	item := ActivityItem{CreatedAt: parts[0], Type: parts[1]}
+	switch item.Type {
+	case ActivityComment:
+		item.CommentID = id
+	case ActivityPost, ActivityReaction:
+		item.PostID = id
+	default:
+		return ActivityItem{}, errors.New("unknown activity type")
+	}
+	return item, nil
+}
@@ -0,0 +1,88 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// activity đọc GET /users/{user_id}/activity?query
+func (a *testApp) activity(token string, userID int, query string) ActivityResponse {
+	a.t.Helper()
+	var resp ActivityResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/activity?%s", userID, query), token, nil), &resp)
+	return resp
+}
+
+// activityKinds tóm tắt stream thành "type:post_id" theo thứ tự
+func activityKinds(items []ActivityItem) []string {
+	kinds := make([]string, 0, len(items))
+	for _, item := range items {
+		kinds = append(kinds, fmt.Sprintf("%s:%d", item.Type, item.PostID))
+	}
+	return kinds
+}
+
+func TestUserActivityMergedNewestFirst(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+
+	postAt := func(d time.Duration, content string) int {
+		id, err := a.store.CreatePost(t.Context(), Post{UserID: aliceID, Content: content, CreatedAt: formatRFC3339(time.Now().Add(-d)), Status: PostPublished})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	oldest := postAt(3*time.Hour, "first")
+	bobPost := a.createPost(bob, map[string]any{"content": "bob's"}, "")
+	commentID := a.comment(alice, bobPost, 0, "nice")
+	a.backdateComment(commentID, 2*time.Hour)
+	newer := postAt(time.Hour, "second")
+	a.expect(http.StatusCreated, "POST", postPath(bobPost, "/reactions"), alice, map[string]string{"reaction_type": "love"})
+
+	want := []string{
+		fmt.Sprintf("reaction:%d", bobPost),
+		fmt.Sprintf("post:%d", newer),
+		fmt.Sprintf("comment:%d", bobPost),
+		fmt.Sprintf("post:%d", oldest),
+	}
+	stream := a.activity(carol, aliceID, "")
+	if got := activityKinds(stream.Activity); !slices.Equal(got, want) {
+		t.Fatalf("activity = %v, want %v", got, want)
+	}
+	if c := stream.Activity[2]; c.CommentID != commentID || c.Content != "nice" {
+		t.Fatalf("comment item = %+v", c)
+	}
+	if r := stream.Activity[0]; r.ReactionType != "love" {
+		t.Fatalf("reaction item = %+v", r)
+	}
+
+	// cursor đi hết stream, không lặp và không sót
+	var paged []string
+	query := "limit=3"
+	for range 3 {
+		page := a.activity(carol, aliceID, query)
+		paged = append(paged, activityKinds(page.Activity)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query = "limit=3&after=" + page.NextCursor
+	}
+	if !slices.Equal(paged, want) {
+		t.Fatalf("paged activity = %v, want %v", paged, want)
+	}
+
+	// comment và reaction trên post đã xoá không còn hiện
+	a.expect(http.StatusNoContent, "DELETE", postPath(bobPost, ""), bob, nil)
+	if got := activityKinds(a.activity(carol, aliceID, "").Activity); !slices.Equal(got, []string{want[1], want[3]}) {
+		t.Fatalf("activity after delete = %v", got)
+	}
+
+	a.expect(http.StatusNotFound, "GET", "/users/999/activity", carol, nil)
+	a.expect(http.StatusBadRequest, "GET", fmt.Sprintf("/users/%d/activity?after=not-a-cursor", aliceID), carol, nil)
+}
@@ -0,0 +1,240 @@
+package apis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// testApp nối các handler giống main.go nhưng dùng MemoryStore và thư mục upload tạm
+type testApp struct {
+	t        *testing.T
+	router   *mux.Router
+	tokens   *TokenService
+	store    Store
+	auth     *AuthHandler
+	profiles *ProfileHandler
+	posts    *PostsHandler
+	follows  *FollowsHandler
+	blocks   *BlocksHandler
+	reacts   *ReactionsHandler
+	comments *CommentsHandler
+	reports  *ReportsHandler
+	media    *MediaHandler
+	feeds    *FeedsHandler
+	notifs   *NotificationHandler
+	outbox   *verificationOutbox
+}
+
+// verificationOutbox giữ token xác nhận cuối cùng gửi tới mỗi email thay vì ghi log
+type verificationOutbox struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// SendVerification implements VerificationSender
+func (o *verificationOutbox) SendVerification(email, token string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.tokens[email] = token
+}
+
+// token trả về token gửi gần nhất tới email
+func (o *verificationOutbox) token(email string) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.tokens[email]
+}
+
+// newTestApp dựng app với store nil thì dùng MemoryStore
+func newTestApp(t *testing.T, store Store) *testApp {
+	t.Helper()
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	a := &testApp{t: t, router: mux.NewRouter(), store: store}
+	a.tokens = NewTokenService([]byte("test-secret"))
+	a.tokens.Admins = make(map[int]bool)
+
+	a.profiles = NewProfileHandler()
+	a.profiles.Tokens = a.tokens
+	a.profiles.Posts = store
+	a.profiles.RegisterRoutes(a.router)
+
+	a.auth = NewAuthHandler(a.tokens)
+	a.tokens.Accounts = a.auth
+	a.auth.Profiles = a.profiles
+	a.profiles.Auth = a.auth
+	a.auth.Posts = store
+	a.outbox = &verificationOutbox{tokens: make(map[string]string)}
+	a.auth.Verifications = a.outbox
+	a.auth.RegisterRoutes(a.router)
+
+	a.posts = NewPostsHandler(store)
+	a.posts.Tokens = a.tokens
+	a.posts.RegisterRoutes(a.router)
+
+	a.notifs = NewNotificationHandler()
+	a.notifs.Tokens = a.tokens
+	a.notifs.RegisterRoutes(a.router)
+
+	a.follows = NewFollowsHandler()
+	a.follows.Tokens = a.tokens
+	a.follows.Notifications = a.notifs
+	a.follows.Profiles = a.profiles
+	a.profiles.Follows = a.follows
+	a.posts.Follows = a.follows
+	a.follows.RegisterRoutes(a.router)
+
+	a.blocks = NewBlocksHandler()
+	a.blocks.Tokens = a.tokens
+	a.blocks.Follows = a.follows
+	a.follows.Blocks = a.blocks
+	a.profiles.Blocks = a.blocks
+	a.posts.Blocks = a.blocks
+	a.blocks.RegisterRoutes(a.router)
+
+	a.reacts = NewReactionsHandler()
+	a.reacts.Tokens = a.tokens
+	a.reacts.Posts = store
+	a.reacts.Notifications = a.notifs
+	a.reacts.Blocks = a.blocks
+	a.reacts.Follows = a.follows
+	a.reacts.Profiles = a.profiles
+	a.posts.Reactions = a.reacts
+	a.reacts.RegisterRoutes(a.router)
+
+	a.comments = NewCommentsHandler()
+	a.comments.Tokens = a.tokens
+	a.comments.Posts = store
+	a.comments.Notifications = a.notifs
+	a.comments.Profiles = a.profiles
+	a.comments.Blocks = a.blocks
+	a.comments.Follows = a.follows
+	a.posts.Comments = a.comments
+	a.auth.Comments = a.comments
+	a.comments.RegisterRoutes(a.router)
+
+	a.reports = NewReportsHandler()
+	a.reports.Tokens = a.tokens
+	a.reports.Posts = store
+	a.reports.Comments = a.comments
+	a.reports.Follows = a.follows
+	a.reports.RegisterRoutes(a.router)
+
+	a.media = NewMediaHandler(store, t.TempDir())
+	a.media.Tokens = a.tokens
+	a.media.Profiles = a.profiles
+	a.media.Follows = a.follows
+	a.media.Blocks = a.blocks
+	a.posts.Media = a.media
+	a.media.RegisterRoutes(a.router)
+	a.media.RegisterUploadRoutes(a.router)
+
+	a.feeds = NewFeedsHandler(store, a.follows, a.reacts, a.comments)
+	a.feeds.Tokens = a.tokens
+	a.feeds.Blocks = a.blocks
+	a.feeds.Profiles = a.profiles
+	a.feeds.Media = a.media
+	a.posts.Feeds = a.feeds
+	a.follows.Feeds = a.feeds
+	a.reacts.Feeds = a.feeds
+	a.feeds.RegisterRoutes(a.router)
+
+	// như main.go: nạp dữ liệu store đã có, MemoryStore mới thì không có gì
+	for _, loader := range []interface{ Load(context.Context) error }{a.auth, a.profiles, a.comments, a.media} {
+		if err := loader.Load(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+// do gửi request tới router; body không phải []byte thì được encode JSON
+func (a *testApp) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var rd io.Reader
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		rd = bytes.NewReader(b)
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		rd = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, rd)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect gửi request và dừng test nếu status khác want
+func (a *testApp) expect(want int, method, path, token string, body any) *httptest.ResponseRecorder {
+	a.t.Helper()
+	rec := a.do(method, path, token, body)
+	if rec.Code != want {
+		a.t.Fatalf("%s %s: status %d, want %d, body %s", method, path, rec.Code, want, rec.Body.String())
+	}
+	return rec
+}
+
+// register tạo user qua POST /register và trả về user_id cùng token
+func (a *testApp) register(username string) (int, string) {
+	a.t.Helper()
+	rec := a.expect(http.StatusOK, "POST", "/register", "", map[string]string{
+		"username": username,
+		"email":    username + "@example.com",
+		"password": "password1",
+	})
+	var resp struct {
+		UserID int    `json:"user_id"`
+		Token  string `json:"token"`
+	}
+	decodeBody(a.t, rec, &resp)
+	return resp.UserID, resp.Token
+}
+
+// createPost tạo post qua POST /posts; query là phần sau dấu ? (vd "status=draft")
+func (a *testApp) createPost(token string, body map[string]any, query string) int {
+	a.t.Helper()
+	path := "/posts"
+	if query != "" {
+		path += "?" + query
+	}
+	rec := a.expect(http.StatusCreated, "POST", path, token, body)
+	var resp struct {
+		PostID int `json:"post_id"`
+	}
+	decodeBody(a.t, rec, &resp)
+	return resp.PostID
+}
+
+// decodeBody đọc body JSON của response vào v
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+}
+
+// postPath dựng đường dẫn /posts/{post_id} cộng phần đuôi
+func postPath(postID int, suffix string) string {
+	return fmt.Sprintf("/posts/%d%s", postID, suffix)
+}
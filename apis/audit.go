@@ -0,0 +1,132 @@
+package apis
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// Các action auth được ghi vào audit log
+const (
+	AuditLogin          = "login"
+	AuditPasswordChange = "password_change"
+	AuditAccountDelete  = "account_delete"
+	AuditAccountRecover = "account_recover"
+)
+
+// AuditActions lists the actions GET /admin/audit can filter on
+var AuditActions = []string{AuditLogin, AuditPasswordChange, AuditAccountDelete, AuditAccountRecover}
+
+// maxAuditEntries giới hạn số entry AuditLog giữ trong bộ nhớ; đầy thì bỏ entry cũ nhất
+const maxAuditEntries = 10000
+
+// AuditEntry records one security-sensitive auth action; it never holds passwords
+type AuditEntry struct {
+	Action  string `json:"action"`
+	UserID  int    `json:"user_id"` // 0 khi login sai với tài khoản không tồn tại
+	IP      string `json:"ip"`
+	At      string `json:"at"`
+	Success bool   `json:"success"`
+}
+
+// AuditSink receives audit entries; implementations must be safe for concurrent use
+type AuditSink interface {
+	Record(e AuditEntry)
+}
+
+// AuditEntriesResponse represents response for GET /admin/audit
+type AuditEntriesResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	PageMeta
+}
+
+// AuditLog is an in-memory AuditSink that admins can read through GET /admin/audit
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry // cũ nhất trước
+	Tokens  *TokenService
+}
+
+// NewAuditLog constructor
+func NewAuditLog() *AuditLog {
+	return &AuditLog{}
+}
+
+// RegisterRoutes register audit routes
+func (l *AuditLog) RegisterRoutes(router *mux.Router) {
+	router.Handle("/admin/audit", l.Tokens.RequireAdmin(http.HandlerFunc(l.ListAudit))).Methods("GET")
+}
+
+// Record implements AuditSink
+func (l *AuditLog) Record(e AuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) >= maxAuditEntries {
+		l.entries = slices.Delete(l.entries, 0, len(l.entries)-maxAuditEntries+1)
+	}
+	l.entries = append(l.entries, e)
+}
+
+// @Summary List Audit Entries
+// @Description Logins, password changes, account deletions and recoveries, newest first; admin only
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param action query string false "Only this action" Enums(login, password_change, account_delete, account_recover)
+// @Param user_id query int false "Only entries of this user"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Success 200 {object} AuditEntriesResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Router /admin/audit [get]
+func (l *AuditLog) ListAudit(w http.ResponseWriter, r *http.Request) {
+	action := r.URL.Query().Get("action")
+	if action != "" && !slices.Contains(AuditActions, action) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid action, valid actions: "+strings.Join(AuditActions, ", "))
+		return
+	}
+	userID, ok := optionalIDParam(w, r, "user_id")
+	if !ok {
+		return
+	}
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	l.mu.Lock()
+	entries := []AuditEntry{}
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		e := l.entries[i]
+		if (action == "" || e.Action == action) && (userID == 0 || e.UserID == userID) {
+			entries = append(entries, e)
+		}
+	}
+	l.mu.Unlock()
+
+	start, end := pageBounds(len(entries), offset, limit)
+	writeJSON(w, http.StatusOK, AuditEntriesResponse{
+		Entries:  entries[start:end],
+		PageMeta: newPageMeta(len(entries), start, end, limit),
+	})
+}
+
+// audit records an auth action on h.Audit; does nothing when no sink is configured
+func (h *AuthHandler) audit(r *http.Request, action string, userID int, success bool) {
+	if h.Audit == nil {
+		return
+	}
+	h.Audit.Record(AuditEntry{
+		Action:  action,
+		UserID:  userID,
+		IP:      clientIP(r),
+		At:      nowRFC3339(),
+		Success: success,
+	})
+}
@@ -0,0 +1,79 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// withAudit gắn một AuditLog vào auth handler và trả về nó cùng token admin
+func (a *testApp) withAudit() (*AuditLog, string) {
+	a.t.Helper()
+	log := NewAuditLog()
+	log.Tokens = a.tokens
+	log.RegisterRoutes(a.router)
+	a.auth.Audit = log
+	adminID, admin := a.register("admin")
+	a.tokens.Admins[adminID] = true
+	return log, admin
+}
+
+// auditEntries đọc GET /admin/audit?query
+func (a *testApp) auditEntries(admin, query string) []AuditEntry {
+	a.t.Helper()
+	var resp AuditEntriesResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", "/admin/audit?"+query, admin, nil), &resp)
+	return resp.Entries
+}
+
+func TestAuditAuthActions(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, admin := a.withAudit()
+	aliceID, alice := a.register("alice")
+
+	if code := a.login("alice", "wrong-password"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status %d", code)
+	}
+	if code := a.login("nobody", "password1"); code != http.StatusUnauthorized {
+		t.Fatalf("unknown user: status %d", code)
+	}
+	a.expect(http.StatusOK, "PUT", "/me/password", alice, ChangePasswordRequest{OldPassword: "password1", NewPassword: "password2"})
+
+	logins := a.auditEntries(admin, "action=login")
+	if len(logins) != 2 {
+		t.Fatalf("login entries = %+v", logins)
+	}
+	// mới nhất trước: user không tồn tại ghi user_id 0
+	if e := logins[0]; e.UserID != 0 || e.Success {
+		t.Fatalf("unknown user entry = %+v", e)
+	}
+	if e := logins[1]; e.UserID != aliceID || e.Success || e.IP == "" || e.At == "" {
+		t.Fatalf("failed login entry = %+v", e)
+	}
+
+	changes := a.auditEntries(admin, fmt.Sprintf("action=password_change&user_id=%d", aliceID))
+	if len(changes) != 1 || !changes[0].Success || changes[0].UserID != aliceID {
+		t.Fatalf("password change entries = %+v", changes)
+	}
+
+	body := a.expect(http.StatusOK, "GET", "/admin/audit", admin, nil).Body.String()
+	for _, secret := range []string{"wrong-password", "password1", "password2"} {
+		if strings.Contains(body, secret) {
+			t.Fatalf("audit log contains %q: %s", secret, body)
+		}
+	}
+
+	a.expect(http.StatusForbidden, "GET", "/admin/audit", alice, nil)
+	a.expect(http.StatusBadRequest, "GET", "/admin/audit?action=logout", admin, nil)
+}
+
+func TestAuditLogDropsOldest(t *testing.T) {
+	log := NewAuditLog()
+	for i := range maxAuditEntries + 5 {
+		log.Record(AuditEntry{Action: AuditLogin, UserID: i + 1})
+	}
+	if len(log.entries) != maxAuditEntries || log.entries[0].UserID != 6 {
+		t.Fatalf("kept %d entries starting at user %d", len(log.entries), log.entries[0].UserID)
+	}
+}
@@ -1,12 +1,22 @@
 package apis
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"net/mail"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // User struct
@@ -14,15 +24,143 @@ type User struct {
 	ID        int
 	Username  string
 	Email     string
-	Password  string
+	Password  string // bcrypt hash
+	CreatedAt time.Time
 	IsDeleted bool
+	DeletedAt time.Time
+
+	EmailVerified bool // xác nhận qua GET /auth/verify
 }
 
+// AccountRecoveryWindow là thời gian sau khi xoá tài khoản mà user còn khôi phục được
+const AccountRecoveryWindow = 30 * 24 * time.Hour
+
+// Cách xử lý posts/comments khi xoá tài khoản (DELETE /me?content=...)
+const (
+	DeletedContentKeep      = "keep"      // giữ nguyên, mặc định
+	DeletedContentAnonymize = "anonymize" // bỏ liên kết tới user, không khôi phục được
+	DeletedContentDelete    = "delete"    // soft delete, tác giả còn restore được trong thời hạn
+)
+
+// deletedUserName thay cho username trên comment của tài khoản đã ẩn danh
+const deletedUserName = "[deleted user]"
+
 // AuthHandler chứa tất cả users
 type AuthHandler struct {
-	Users map[string]User // key = username hoặc email
+	mu            sync.Mutex
+	nextID        int
+	Users         map[int]*User  // key = user_id
+	usernameIndex map[string]int // username (lowercase) -> user_id
+	emailIndex    map[string]int // email (lowercase) -> user_id
+	Tokens        *TokenService
+	Profiles      *ProfileHandler  // nếu có, tạo profile khi đăng ký
+	Posts         Store            // lưu users; DELETE /me?content=anonymize|delete xử lý posts của user
+	Comments      *CommentsHandler // như Posts, cho comments; nil thì bỏ qua
+	Audit         AuditSink        // ghi login, đổi mật khẩu, xoá và khôi phục tài khoản; nil thì bỏ qua
+
+	Verifications VerificationSender // gửi token xác nhận email; nil thì ghi ra log
+	// RequireVerified chặn login (403) tới khi email được xác nhận; lần bị chặn gửi lại token mới,
+	// tối đa một lần mỗi VerificationResendInterval
+	RequireVerified bool
+	verifications   map[string]emailVerification // token -> user chờ xác nhận
+
+	lockout loginLockout // chống đoán mật khẩu cho login và recover
+}
+
+// NewAuthHandler khởi tạo AuthHandler rỗng
+func NewAuthHandler(tokens *TokenService) *AuthHandler {
+	return &AuthHandler{
+		Users:         make(map[int]*User),
+		usernameIndex: make(map[string]int),
+		emailIndex:    make(map[string]int),
+		Tokens:        tokens,
+	}
+}
+
+// TokenService ký và xác thực JWT bằng HMAC secret
+type TokenService struct {
+	secret []byte
+	TTL    time.Duration
+	Admins map[int]bool // user_id được gọi các route /admin
+	// Accounts, nếu có, được hỏi ở mỗi request: token của user không tồn tại hoặc đã xoá bị từ chối
+	Accounts AccountChecker
 }
 
+// AccountChecker cho biết user ID còn là tài khoản đang hoạt động hay không
+type AccountChecker interface {
+	AccountActive(userID int) bool
+}
+
+// NewTokenService khởi tạo TokenService, token mặc định hết hạn sau 24h
+func NewTokenService(secret []byte) *TokenService {
+	return &TokenService{
+		secret: secret,
+		TTL:    24 * time.Hour,
+	}
+}
+
+// Issue tạo token chứa user ID và thời điểm hết hạn
+func (s *TokenService) Issue(userID int) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.Itoa(userID),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(s.TTL)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
+
+// Parse kiểm tra chữ ký, thời hạn và trả về user ID trong token
+func (s *TokenService) Parse(token string) (int, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(claims.Subject)
+}
+
+// activeUser trả về user ID trong token nếu token hợp lệ và tài khoản còn hoạt động
+func (s *TokenService) activeUser(token string) (int, error) {
+	userID, err := s.Parse(token)
+	if err != nil {
+		return 0, err
+	}
+	if s.Accounts != nil && !s.Accounts.AccountActive(userID) {
+		return 0, errInactiveAccount
+	}
+	return userID, nil
+}
+
+// errInactiveAccount là lỗi của token hợp lệ thuộc tài khoản đã xoá hoặc không tồn tại
+var errInactiveAccount = errors.New("account deleted or unknown")
+
+// AccountResponse là thông tin tài khoản trả về cho chính chủ, không có password
+type AccountResponse struct {
+	UserID        int    `json:"user_id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// AdminUser là một tài khoản trong danh sách của admin; không bao giờ có password
+type AdminUser struct {
+	AccountResponse
+	IsDeleted bool   `json:"is_deleted"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+}
+
+// AdminUsersResponse là một trang của GET /admin/users
+type AdminUsersResponse struct {
+	Users []AdminUser `json:"users"`
+	PageMeta
+}
+
+// minPasswordLength là độ dài tối thiểu của mật khẩu
+const minPasswordLength = 8
+
 // Request structs
 type RegisterRequest struct {
 	Username string `json:"username"`
@@ -44,75 +182,230 @@ type ChangePasswordRequest struct {
 func (h *AuthHandler) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/register", h.Register).Methods("POST")
 	r.HandleFunc("/login", h.Login).Methods("POST")
-	r.HandleFunc("/me/password", h.ChangePassword).Methods("PUT")
-	r.HandleFunc("/me", h.DeleteAccount).Methods("DELETE")
+	r.HandleFunc("/auth/recover", h.RecoverAccount).Methods("POST")
+	r.HandleFunc("/auth/verify", h.VerifyEmail).Methods("GET")
+	r.Handle("/me/password", h.Tokens.RequireAuth(http.HandlerFunc(h.ChangePassword))).Methods("PUT")
+	r.Handle("/me", h.Tokens.RequireAuth(http.HandlerFunc(h.GetMe))).Methods("GET")
+	r.Handle("/me", h.Tokens.RequireAuth(http.HandlerFunc(h.DeleteAccount))).Methods("DELETE")
+	r.Handle("/admin/users", h.Tokens.RequireAdmin(http.HandlerFunc(h.ListUsers))).Methods("GET")
 }
 
 // Register godoc
 // @Summary Register a new user
-// @Description Creates a new account
+// @Description Creates a new account. The username is trimmed and must be 3-30 letters, digits, '.' or '_';
+// @Description it keeps its casing for display but must be unique ignoring case.
+// @Description A verification token for GET /auth/verify is sent to the email; when verification is
+// @Description required no JWT is returned until the email is verified
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param body body RegisterRequest true "Register data"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
+// @Failure 400 {object} APIError
+// @Failure 409 {object} APIError
 // @Router /register [post]
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" || req.Email == "" || req.Password == "" {
-		http.Error(w, `{"error":"Invalid data"}`, http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || req.Email == "" || req.Password == "" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid data")
+		return
+	}
+	if !usernamePattern.MatchString(req.Username) {
+		writeJSONError(w, http.StatusBadRequest, "Username must be 3-30 letters, digits, '.' or '_'")
+		return
+	}
+	if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+		writeJSONError(w, http.StatusBadRequest, "Invalid email")
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
 		return
 	}
 
-	newID := len(h.Users) + 1
-	user := User{
-		ID:       newID,
-		Username: req.Username,
-		Email:    req.Email,
-		Password: req.Password,
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot hash password")
+		return
 	}
 
-	if h.Users == nil {
-		h.Users = make(map[string]User)
+	h.mu.Lock()
+	// login nhận cả username lẫn email nên không cho hai giá trị trùng nhau ở hai index;
+	// index không phân biệt hoa thường nên "Alice" và "alice" là cùng một username
+	if _, taken := h.userByLogin(req.Username); taken {
+		h.mu.Unlock()
+		writeJSONError(w, http.StatusConflict, "Username already taken")
+		return
+	}
+	if _, taken := h.userByLogin(req.Email); taken {
+		h.mu.Unlock()
+		writeJSONError(w, http.StatusConflict, "Email already registered")
+		return
+	}
+
+	h.nextID++
+	newID := h.nextID
+	user := &User{
+		ID:        newID,
+		Username:  req.Username,
+		Email:     req.Email,
+		Password:  hash,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := h.saveUser(r.Context(), *user); err != nil {
+		h.mu.Unlock()
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save user")
+		return
+	}
+	h.addUser(user)
+	if err := h.Profiles.createProfile(r.Context(), *user); err != nil {
+		log.Println("profile for user", newID, ":", err)
+	}
+	created := *user
+	h.mu.Unlock()
+
+	// tài khoản đã tạo xong; gửi token lỗi thì user vẫn nhận token mới khi login
+	if err := h.issueVerification(created); err != nil {
+		log.Println("verification token for user", newID, ":", err)
+	}
+	// chưa xác nhận thì chưa được login, nên cũng không cấp JWT
+	if h.RequireVerified {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"user_id": newID,
+			"message": "Check your email to verify the account before logging in",
+		})
+		return
+	}
+
+	token, err := h.Tokens.Issue(newID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot issue token")
+		return
 	}
-	h.Users[strings.ToLower(req.Username)] = user
-	h.Users[strings.ToLower(req.Email)] = user
 
 	resp := map[string]interface{}{
 		"user_id": newID,
-		"token":   "fake-jwt-token-" + time.Now().Format("150405"),
+		"token":   token,
 	}
-	json.NewEncoder(w).Encode(resp)
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // Login godoc
 // @Summary Login user
-// @Description Login using username or email
+// @Description Login using username or email. After 5 failed attempts on an account (or 20 from one IP)
+// @Description further attempts get 429 for 15 minutes
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param body body LoginRequest true "Login data"
 // @Success 200 {object} map[string]string
-// @Failure 401 {object} map[string]string
+// @Failure 401 {object} APIError
+// @Failure 429 {object} APIError
 // @Router /login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid data"}`, http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
 
-	user, exists := h.Users[strings.ToLower(req.Login)]
-	if !exists || user.Password != req.Password || user.IsDeleted {
-		http.Error(w, `{"error":"Invalid credentials"}`, http.StatusUnauthorized)
+	user, ok := h.checkCredentials(w, r, req, AuditLogin)
+	if !ok {
+		return
+	}
+	if user.IsDeleted {
+		h.audit(r, AuditLogin, user.ID, false)
+		writeJSONError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+	if h.RequireVerified && !user.EmailVerified {
+		h.audit(r, AuditLogin, user.ID, false)
+		sent, err := h.resendVerification(user)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Cannot create verification token")
+			return
+		}
+		if !sent {
+			writeJSONError(w, http.StatusForbidden, "Email not verified, use the verification link sent recently")
+			return
+		}
+		writeJSONError(w, http.StatusForbidden, "Email not verified, a new verification link was sent")
 		return
 	}
 
+	token, err := h.Tokens.Issue(user.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot issue token")
+		return
+	}
+	h.audit(r, AuditLogin, user.ID, true)
+
 	resp := map[string]string{
-		"token": "fake-jwt-token-" + time.Now().Format("150405"),
+		"token": token,
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RecoverAccount godoc
+// @Summary Recover a deleted account
+// @Description Reactivate a soft-deleted account with its credentials within 30 days of deletion
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body LoginRequest true "Login data"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 410 {object} APIError
+// @Failure 429 {object} APIError
+// @Router /auth/recover [post]
+func (h *AuthHandler) RecoverAccount(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	user, ok := h.checkCredentials(w, r, req, AuditAccountRecover)
+	if !ok {
+		return
+	}
+	if !user.IsDeleted {
+		writeJSONError(w, http.StatusBadRequest, "Account is not deleted")
+		return
 	}
-	json.NewEncoder(w).Encode(resp)
+	if time.Since(user.DeletedAt) > AccountRecoveryWindow {
+		h.audit(r, AuditAccountRecover, user.ID, false)
+		writeJSONError(w, http.StatusGone, "Recovery window has expired")
+		return
+	}
+
+	h.mu.Lock()
+	err := h.updateUser(r.Context(), user.ID, func(u *User) {
+		u.IsDeleted = false
+		u.DeletedAt = time.Time{}
+	})
+	h.mu.Unlock()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save user")
+		return
+	}
+	h.audit(r, AuditAccountRecover, user.ID, true)
+
+	token, err := h.Tokens.Issue(user.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot issue token")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Account recovered",
+		"token":   token,
+	})
 }
 
 // ChangePassword godoc
@@ -121,50 +414,389 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 // @Tags auth
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param body body ChangePasswordRequest true "Password data"
 // @Success 200 {object} map[string]string
-// @Failure 403 {object} map[string]string
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
 // @Router /me/password [put]
 func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
-	// Demo: giả sử user hiện tại là "alice"
-	currentUser, exists := h.Users["alice"]
+	userID, _ := UserIDFromContext(r.Context())
+	h.mu.Lock()
+	currentUser, exists := h.userByID(userID)
+	h.mu.Unlock()
 	if !exists {
-		http.Error(w, `{"error":"Invalid old password"}`, http.StatusForbidden)
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	var req ChangePasswordRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid data"}`, http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
 
-	if currentUser.Password != req.OldPassword {
-		http.Error(w, `{"error":"Invalid old password"}`, http.StatusForbidden)
+	if !checkPassword(currentUser.Password, req.OldPassword) {
+		h.audit(r, AuditPasswordChange, userID, false)
+		writeJSONError(w, http.StatusForbidden, "Invalid old password")
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
 		return
 	}
 
-	currentUser.Password = req.NewPassword
-	h.Users["alice"] = currentUser
-	json.NewEncoder(w).Encode(map[string]string{"message": "Password updated"})
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot hash password")
+		return
+	}
+
+	h.mu.Lock()
+	err = h.updateUser(r.Context(), userID, func(u *User) { u.Password = hash })
+	h.mu.Unlock()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save user")
+		return
+	}
+	h.audit(r, AuditPasswordChange, userID, true)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Password updated"})
+}
+
+// GetMe godoc
+// @Summary Get current account
+// @Description Get ID, username and email of the authenticated user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} AccountResponse
+// @Failure 401 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /me [get]
+func (h *AuthHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, _ := UserIDFromContext(r.Context())
+
+	h.mu.Lock()
+	currentUser, exists := h.userByID(userID)
+	h.mu.Unlock()
+	if !exists {
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if currentUser.IsDeleted {
+		writeJSONError(w, http.StatusNotFound, "Account deleted")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, AccountResponse{
+		UserID:        currentUser.ID,
+		Username:      currentUser.Username,
+		Email:         currentUser.Email,
+		EmailVerified: currentUser.EmailVerified,
+	})
+}
+
+// ListUsers godoc
+// @Summary List accounts (admin)
+// @Description List accounts ordered by user_id; soft-deleted accounts only with include_deleted=true
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param include_deleted query bool false "Include soft-deleted accounts"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Success 200 {object} AdminUsersResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Router /admin/users [get]
+func (h *AuthHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	includeDeleted := r.URL.Query().Get("include_deleted") == "true"
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	users := []AdminUser{}
+	h.mu.Lock()
+	for _, u := range h.Users {
+		if u.IsDeleted && !includeDeleted {
+			continue
+		}
+		au := AdminUser{
+			AccountResponse: AccountResponse{UserID: u.ID, Username: u.Username, Email: u.Email, EmailVerified: u.EmailVerified},
+			IsDeleted:       u.IsDeleted,
+		}
+		if u.IsDeleted {
+			au.DeletedAt = formatRFC3339(u.DeletedAt)
+		}
+		users = append(users, au)
+	}
+	h.mu.Unlock()
+
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+	start, end := pageBounds(len(users), offset, limit)
+	writeJSON(w, http.StatusOK, AdminUsersResponse{
+		Users:    users[start:end],
+		PageMeta: newPageMeta(len(users), start, end, limit),
+	})
 }
 
 // DeleteAccount godoc
 // @Summary Soft delete current account
-// @Description Mark account as deleted
+// @Description Mark account as deleted. content decides what happens to the user's posts and comments:
+// @Description keep (default) leaves them, anonymize sets their user_id to 0 and shows comments as
+// @Description "[deleted user]" (recovering the account does not undo this), delete soft-deletes them all
 // @Tags auth
 // @Produce json
-// @Success 200 {object} map[string]string
-// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Param content query string false "keep (default), anonymize or delete" Enums(keep, anonymize, delete)
+// @Success 204 "No Content"
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
 // @Router /me [delete]
 func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
-	currentUser, exists := h.Users["alice"]
+	userID, _ := UserIDFromContext(r.Context())
+
+	mode := r.URL.Query().Get("content")
+	if mode == "" {
+		mode = DeletedContentKeep
+	}
+	if mode != DeletedContentKeep && mode != DeletedContentAnonymize && mode != DeletedContentDelete {
+		writeJSONError(w, http.StatusBadRequest, "Invalid content, use keep, anonymize or delete")
+		return
+	}
+
+	h.mu.Lock()
+	_, exists := h.Users[userID]
+	h.mu.Unlock()
 	if !exists {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusForbidden)
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	currentUser.IsDeleted = true
-	h.Users["alice"] = currentUser
-	json.NewEncoder(w).Encode(map[string]string{"message": "Account soft deleted"})
+	// xử lý nội dung trước: nếu lỗi thì tài khoản chưa bị xoá và user gọi lại được
+	if mode != DeletedContentKeep {
+		if err := h.cleanupContent(r.Context(), userID, mode); err != nil {
+			h.audit(r, AuditAccountDelete, userID, false)
+			writeJSONError(w, http.StatusInternalServerError, "Cannot update posts")
+			return
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := h.updateUser(r.Context(), userID, func(u *User) {
+		u.IsDeleted = true
+		u.DeletedAt = time.Now()
+	})
+	if errors.Is(err, errUserNotFound) {
+		writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if err != nil {
+		h.audit(r, AuditAccountDelete, userID, false)
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save user")
+		return
+	}
+	h.audit(r, AuditAccountDelete, userID, true)
+	writeNoContent(w)
+}
+
+// cleanupContent anonymizes or soft-deletes every post and comment of userID;
+// the caller must not hold h.mu
+func (h *AuthHandler) cleanupContent(ctx context.Context, userID int, mode string) error {
+	if h.Posts != nil {
+		posts, err := h.Posts.ListUserPosts(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, p := range posts {
+			switch {
+			case mode == DeletedContentAnonymize:
+				p.UserID = 0
+				err = h.Posts.UpdatePost(ctx, p)
+			case !p.IsDeleted:
+				err = h.Posts.SoftDeletePost(ctx, p.PostID)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if mode == DeletedContentAnonymize {
+		return h.Comments.anonymizeUser(ctx, userID)
+	}
+	return h.Comments.deleteUserComments(ctx, userID)
+}
+
+// AccountActive cho TokenService biết userID có tồn tại và chưa bị xoá
+func (h *AuthHandler) AccountActive(userID int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	u, ok := h.Users[userID]
+	return ok && !u.IsDeleted
+}
+
+// userByID trả về bản copy của user theo ID; caller phải giữ h.mu
+func (h *AuthHandler) userByID(id int) (User, bool) {
+	u, ok := h.Users[id]
+	if !ok {
+		return User{}, false
+	}
+	return *u, true
+}
+
+// checkCredentials kiểm tra login/password qua lockout; trả về false khi đã ghi response lỗi
+// (429 khi đang bị khoá, 401 khi sai) và ghi lần thất bại vào audit log dưới tên action
+func (h *AuthHandler) checkCredentials(w http.ResponseWriter, r *http.Request, req LoginRequest, action string) (User, bool) {
+	h.mu.Lock()
+	user, exists := h.userByLogin(req.Login)
+	h.mu.Unlock()
+
+	ip := clientIP(r)
+	account := lockoutAccount(user, exists, req.Login)
+	if wait := h.lockout.retryAfter(account, ip); wait > 0 {
+		h.audit(r, action, user.ID, false)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeJSONError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+		return User{}, false
+	}
+
+	if !exists || !checkPassword(user.Password, req.Password) {
+		h.audit(r, action, user.ID, false)
+		h.lockout.fail(account, ip)
+		writeJSONError(w, http.StatusUnauthorized, "Invalid credentials")
+		return User{}, false
+	}
+	h.lockout.succeed(account)
+	return user, true
+}
+
+// userByLogin tìm user theo username trước, không có mới tìm theo email (không phân biệt hoa thường,
+// bỏ khoảng trắng hai đầu);
+// Register từ chối giá trị đã có ở một trong hai index nên hai cách tra không thể ra hai user khác nhau.
+// Caller phải giữ h.mu
+func (h *AuthHandler) userByLogin(login string) (User, bool) {
+	key := normalizeLogin(login)
+	if id, ok := h.usernameIndex[key]; ok {
+		return h.userByID(id)
+	}
+	if id, ok := h.emailIndex[key]; ok {
+		return h.userByID(id)
+	}
+	return User{}, false
+}
+
+// normalizeLogin là dạng của login dùng để tra usernameIndex và emailIndex
+func normalizeLogin(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
+
+// Lỗi khi đổi username qua renameUser
+var (
+	errUsernameTaken = errors.New("username already taken")
+	errUserNotFound  = errors.New("user not found")
+)
+
+// renameUser đổi username của userID, cập nhật usernameIndex và profile trong cùng một lần giữ h.mu
+// để hai request đổi sang cùng một tên không thể cùng thành công. Như Register, tên mới
+// không được trùng username hay email của user khác (không phân biệt hoa thường)
+func (h *AuthHandler) renameUser(ctx context.Context, userID int, username string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	user, ok := h.Users[userID]
+	if !ok || user.IsDeleted {
+		return errUserNotFound
+	}
+	if other, taken := h.userByLogin(username); taken && other.ID != userID {
+		return errUsernameTaken
+	}
+	oldName := user.Username
+	if err := h.updateUser(ctx, userID, func(u *User) { u.Username = username }); err != nil {
+		return err
+	}
+	delete(h.usernameIndex, strings.ToLower(oldName))
+	h.usernameIndex[strings.ToLower(username)] = userID
+	return h.Profiles.setUsername(ctx, userID, username)
+}
+
+// saveUser ghi u xuống Posts; không có store thì user chỉ nằm trong bộ nhớ
+func (h *AuthHandler) saveUser(ctx context.Context, u User) error {
+	if h.Posts == nil {
+		return nil
+	}
+	return h.Posts.SaveUser(ctx, u)
+}
+
+// updateUser áp dụng change lên bản copy của user, lưu xuống store rồi mới cập nhật bộ nhớ
+// để lỗi ghi không làm hai bên lệch nhau; caller phải giữ h.mu và không được đổi username/email
+// qua đây nếu không tự cập nhật index
+func (h *AuthHandler) updateUser(ctx context.Context, userID int, change func(*User)) error {
+	user, ok := h.Users[userID]
+	if !ok {
+		return errUserNotFound
+	}
+	updated := *user
+	change(&updated)
+	if err := h.saveUser(ctx, updated); err != nil {
+		return err
+	}
+	*user = updated
+	return nil
+}
+
+// Load nạp các user đã lưu trong Posts và cấp user_id tiếp theo sau ID lớn nhất, để người đăng ký
+// mới không nhận lại ID (và post) của user cũ. Gọi một lần lúc khởi động, trước khi phục vụ request
+func (h *AuthHandler) Load(ctx context.Context) error {
+	if h.Posts == nil {
+		return nil
+	}
+	users, err := h.Posts.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := range users {
+		h.addUser(&users[i])
+		h.nextID = max(h.nextID, users[i].ID)
+	}
+	return nil
+}
+
+// addUser lưu user mới và cập nhật hai index; caller phải giữ h.mu
+func (h *AuthHandler) addUser(user *User) {
+	if h.Users == nil {
+		h.Users = make(map[int]*User)
+	}
+	if h.usernameIndex == nil {
+		h.usernameIndex = make(map[string]int)
+	}
+	if h.emailIndex == nil {
+		h.emailIndex = make(map[string]int)
+	}
+	h.Users[user.ID] = user
+	h.usernameIndex[strings.ToLower(user.Username)] = user.ID
+	h.emailIndex[strings.ToLower(user.Email)] = user.ID
+}
+
+// hashPassword băm mật khẩu bằng bcrypt
+func hashPassword(plain string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword so sánh mật khẩu với bcrypt hash
+func checkPassword(hash, plain string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) == nil
 }
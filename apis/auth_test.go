@@ -0,0 +1,459 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenIssueAndParse(t *testing.T) {
+	tokens := NewTokenService([]byte("test-secret"))
+	token, err := tokens.Issue(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := tokens.Parse(token); err != nil || id != 42 {
+		t.Fatalf("Parse = %d, %v, want 42", id, err)
+	}
+
+	// sửa một ký tự của chữ ký
+	tampered := []byte(token)
+	if tampered[len(tampered)-2] == 'A' {
+		tampered[len(tampered)-2] = 'B'
+	} else {
+		tampered[len(tampered)-2] = 'A'
+	}
+	if _, err := tokens.Parse(string(tampered)); err == nil {
+		t.Fatal("tampered token accepted")
+	}
+	if _, err := NewTokenService([]byte("other-secret")).Parse(token); err == nil {
+		t.Fatal("token signed with another secret accepted")
+	}
+
+	expired := NewTokenService([]byte("test-secret"))
+	expired.TTL = -time.Minute
+	token, err = expired.Issue(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.Parse(token); err == nil {
+		t.Fatal("expired token accepted")
+	}
+}
+
+func TestRegisterAndLoginIssueTokens(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, token := a.register("alice")
+	if id, err := a.tokens.Parse(token); err != nil || id != aliceID {
+		t.Fatalf("register token: %d, %v, want %d", id, err, aliceID)
+	}
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	decodeBody(t, a.expect(http.StatusOK, "POST", "/login", "", LoginRequest{Login: "alice", Password: "password1"}), &resp)
+	if id, err := a.tokens.Parse(resp.Token); err != nil || id != aliceID {
+		t.Fatalf("login token: %d, %v, want %d", id, err, aliceID)
+	}
+}
+
+// login gửi POST /login và trả về status
+func (a *testApp) login(login, password string) int {
+	a.t.Helper()
+	return a.do("POST", "/login", "", LoginRequest{Login: login, Password: password}).Code
+}
+
+func TestPasswordHashedAndChanged(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+
+	user, _ := a.auth.userByID(aliceID)
+	if user.Password == "password1" || !checkPassword(user.Password, "password1") {
+		t.Fatalf("stored password %q is not a bcrypt hash of the password", user.Password)
+	}
+	if code := a.login("alice", "password1"); code != http.StatusOK {
+		t.Fatalf("correct login: status %d", code)
+	}
+	if code := a.login("alice", "wrong-password"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status %d", code)
+	}
+
+	a.expect(http.StatusForbidden, "PUT", "/me/password", alice, ChangePasswordRequest{OldPassword: "wrong-password", NewPassword: "password2"})
+	a.expect(http.StatusOK, "PUT", "/me/password", alice, ChangePasswordRequest{OldPassword: "password1", NewPassword: "password2"})
+	if code := a.login("alice", "password1"); code != http.StatusUnauthorized {
+		t.Fatalf("old password after change: status %d", code)
+	}
+	if code := a.login("alice", "password2"); code != http.StatusOK {
+		t.Fatalf("new password after change: status %d", code)
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	a := newTestApp(t, nil)
+	register := func(username, email, password string) int {
+		return a.do("POST", "/register", "", map[string]string{
+			"username": username, "email": email, "password": password,
+		}).Code
+	}
+
+	for name, tc := range map[string]struct {
+		username, email, password string
+		status                    int
+	}{
+		"malformed email": {"alice", "not-an-email", "password1", http.StatusBadRequest},
+		"display name":    {"alice", "Alice <alice@example.com>", "password1", http.StatusBadRequest},
+		"short password":  {"alice", "alice@example.com", "short", http.StatusBadRequest},
+		"missing field":   {"", "alice@example.com", "password1", http.StatusBadRequest},
+	} {
+		if code := register(tc.username, tc.email, tc.password); code != tc.status {
+			t.Fatalf("%s: status %d, want %d", name, code, tc.status)
+		}
+	}
+
+	if code := register("alice", "alice@example.com", "password1"); code != http.StatusOK {
+		t.Fatalf("valid registration: status %d", code)
+	}
+	// username không phân biệt hoa thường; email trùng cũng không ghi đè tài khoản cũ
+	if code := register("ALICE", "other@example.com", "password1"); code != http.StatusConflict {
+		t.Fatalf("duplicate username: status %d", code)
+	}
+	if code := register("alice2", "alice@example.com", "password2"); code != http.StatusConflict {
+		t.Fatalf("duplicate email: status %d", code)
+	}
+	if code := a.login("alice@example.com", "password1"); code != http.StatusOK {
+		t.Fatalf("original account after duplicates: login status %d", code)
+	}
+}
+
+func TestGetMe(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+
+	rec := a.expect(http.StatusOK, "GET", "/me", alice, nil)
+	if strings.Contains(rec.Body.String(), "password") {
+		t.Fatalf("GET /me leaks the password hash: %s", rec.Body.String())
+	}
+	var me AccountResponse
+	decodeBody(t, rec, &me)
+	if me.UserID != aliceID || me.Username != "alice" || me.Email != "alice@example.com" {
+		t.Fatalf("GET /me = %+v", me)
+	}
+
+	a.expect(http.StatusUnauthorized, "GET", "/me", "", nil)
+
+	// token cũ bị từ chối ngay khi tài khoản bị xoá
+	a.expect(http.StatusNoContent, "DELETE", "/me", alice, nil)
+	a.expect(http.StatusUnauthorized, "GET", "/me", alice, nil)
+}
+
+func TestDeletedAccountTokenRejected(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "before delete"}, "")
+
+	a.expect(http.StatusNoContent, "DELETE", "/me", alice, nil)
+	a.expect(http.StatusUnauthorized, "POST", "/posts", alice, map[string]any{"content": "after delete"})
+	a.expect(http.StatusUnauthorized, "PUT", "/me/password", alice, ChangePasswordRequest{OldPassword: "password1", NewPassword: "password2"})
+	a.expect(http.StatusUnauthorized, "DELETE", "/me", alice, nil)
+	// route OptionalAuth coi token của tài khoản đã xoá như request ẩn danh
+	a.expect(http.StatusOK, "GET", postPath(postID, ""), alice, nil)
+
+	// token ký đúng nhưng user không tồn tại
+	ghost, err := a.tokens.Issue(999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.expect(http.StatusUnauthorized, "GET", "/me", ghost, nil)
+
+	// khôi phục tài khoản thì đăng nhập và dùng token mới được
+	rec := a.expect(http.StatusOK, "POST", "/auth/recover", "", LoginRequest{Login: "alice", Password: "password1"})
+	var resp map[string]string
+	decodeBody(t, rec, &resp)
+	a.expect(http.StatusOK, "GET", "/me", resp["token"], nil)
+}
+
+func TestChangePasswordAndDeleteAffectCaller(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.register("alice")
+	bobID, bob := a.register("bob")
+
+	a.expect(http.StatusUnauthorized, "PUT", "/me/password", "", ChangePasswordRequest{OldPassword: "password1", NewPassword: "password2"})
+	a.expect(http.StatusUnauthorized, "DELETE", "/me", "", nil)
+
+	a.expect(http.StatusOK, "PUT", "/me/password", bob, ChangePasswordRequest{OldPassword: "password1", NewPassword: "password2"})
+	if code := a.login("alice", "password1"); code != http.StatusOK {
+		t.Fatalf("alice after bob's change: login status %d", code)
+	}
+	for _, login := range []string{"bob", "bob@example.com"} {
+		if code := a.login(login, "password2"); code != http.StatusOK {
+			t.Fatalf("bob by %s after change: login status %d", login, code)
+		}
+	}
+
+	a.expect(http.StatusNoContent, "DELETE", "/me", bob, nil)
+	if user, _ := a.auth.userByID(bobID); !user.IsDeleted {
+		t.Fatal("bob's account not deleted")
+	}
+	if code := a.login("alice", "password1"); code != http.StatusOK {
+		t.Fatalf("alice after bob's delete: login status %d", code)
+	}
+}
+
+func TestUserIndexesStayInSync(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("Alice")
+
+	a.auth.mu.Lock()
+	byName, byEmail := a.auth.usernameIndex["alice"], a.auth.emailIndex["alice@example.com"]
+	a.auth.mu.Unlock()
+	if byName != aliceID || byEmail != aliceID {
+		t.Fatalf("indexes = %d, %d, want %d", byName, byEmail, aliceID)
+	}
+
+	// chỉ có một bản ghi user nên đổi mật khẩu có hiệu lực với mọi cách login
+	a.expect(http.StatusOK, "PUT", "/me/password", alice, ChangePasswordRequest{OldPassword: "password1", NewPassword: "password2"})
+	for _, login := range []string{"Alice", "alice", "alice@example.com", "ALICE@EXAMPLE.COM"} {
+		if code := a.login(login, "password2"); code != http.StatusOK {
+			t.Fatalf("login %q with new password: status %d", login, code)
+		}
+		if code := a.login(login, "password1"); code != http.StatusUnauthorized {
+			t.Fatalf("login %q with old password: status %d", login, code)
+		}
+	}
+}
+
+func TestRecoverAccount(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	recoverAccount := func(login, password string) *httptest.ResponseRecorder {
+		return a.do("POST", "/auth/recover", "", LoginRequest{Login: login, Password: password})
+	}
+
+	if rec := recoverAccount("alice", "password1"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("recover active account: status %d", rec.Code)
+	}
+	a.expect(http.StatusNoContent, "DELETE", "/me", alice, nil)
+	if code := a.login("alice", "password1"); code == http.StatusOK {
+		t.Fatal("deleted account can log in")
+	}
+	if rec := recoverAccount("alice", "wrong-password"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status %d", rec.Code)
+	}
+
+	rec := recoverAccount("alice", "password1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("recover: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	decodeBody(t, rec, &resp)
+	if id, err := a.tokens.Parse(resp.Token); err != nil || id != aliceID {
+		t.Fatalf("recovery token: %d, %v", id, err)
+	}
+	if code := a.login("alice", "password1"); code != http.StatusOK {
+		t.Fatalf("login after recovery: status %d", code)
+	}
+
+	// quá AccountRecoveryWindow thì tài khoản không khôi phục được nữa
+	a.expect(http.StatusNoContent, "DELETE", "/me", bob, nil)
+	a.auth.mu.Lock()
+	a.auth.Users[bobID].DeletedAt = time.Now().Add(-AccountRecoveryWindow - time.Hour)
+	a.auth.mu.Unlock()
+	if rec := recoverAccount("bob", "password1"); rec.Code != http.StatusGone {
+		t.Fatalf("expired recovery: status %d", rec.Code)
+	}
+}
+
+func TestLoginByUsernameOrEmail(t *testing.T) {
+	// user cũ có username dạng email, tạo trước khi usernamePattern cấm '@'
+	store := NewMemoryStore()
+	hash, err := hashPassword("legacy-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := User{ID: 1, Username: "carol@example.com", Email: "carol@old.example.com", Password: hash, CreatedAt: time.Now().UTC()}
+	if err := store.SaveUser(t.Context(), legacy); err != nil {
+		t.Fatal(err)
+	}
+	a := newTestApp(t, store)
+	a.register("alice")
+
+	for _, login := range []string{"alice", " Alice ", "alice@example.com"} {
+		if code := a.login(login, "password1"); code != http.StatusOK {
+			t.Fatalf("login %q: status %d", login, code)
+		}
+	}
+	if code := a.login("carol@example.com", "legacy-pass"); code != http.StatusOK {
+		t.Fatalf("login by email-shaped username: status %d", code)
+	}
+
+	register := func(username, email string) int {
+		return a.do("POST", "/register", "", map[string]string{
+			"username": username, "email": email, "password": "password1",
+		}).Code
+	}
+	// email trùng với username của user khác thì login sẽ mơ hồ nên bị từ chối
+	if code := register("carol", "carol@example.com"); code != http.StatusConflict {
+		t.Fatalf("email colliding with a username: status %d", code)
+	}
+	if code := register("bob@example.com", "bob@example.com"); code != http.StatusBadRequest {
+		t.Fatalf("email-shaped username: status %d", code)
+	}
+	if code := a.login("carol@example.com", "legacy-pass"); code != http.StatusOK {
+		t.Fatalf("legacy user after rejected registration: status %d", code)
+	}
+}
+
+func TestAdminListUsers(t *testing.T) {
+	a := newTestApp(t, nil)
+	adminID, admin := a.register("admin")
+	a.tokens.Admins[adminID] = true
+	aliceID, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	a.expect(http.StatusNoContent, "DELETE", "/me", bob, nil)
+
+	a.expect(http.StatusUnauthorized, "GET", "/admin/users", "", nil)
+	a.expect(http.StatusForbidden, "GET", "/admin/users", alice, nil)
+
+	list := func(query string) AdminUsersResponse {
+		rec := a.expect(http.StatusOK, "GET", "/admin/users?"+query, admin, nil)
+		if strings.Contains(rec.Body.String(), "password") {
+			t.Fatalf("GET /admin/users leaks passwords: %s", rec.Body.String())
+		}
+		var resp AdminUsersResponse
+		decodeBody(t, rec, &resp)
+		return resp
+	}
+	ids := func(users []AdminUser) []int {
+		var ids []int
+		for _, u := range users {
+			ids = append(ids, u.UserID)
+		}
+		return ids
+	}
+
+	if got := list(""); !slices.Equal(ids(got.Users), []int{adminID, aliceID}) || got.Total != 2 {
+		t.Fatalf("active users = %+v", got)
+	}
+	all := list("include_deleted=true")
+	if !slices.Equal(ids(all.Users), []int{adminID, aliceID, bobID}) {
+		t.Fatalf("with deleted = %+v", all)
+	}
+	if deleted := all.Users[2]; !deleted.IsDeleted || deleted.DeletedAt == "" || all.Users[1].IsDeleted {
+		t.Fatalf("deleted flags = %+v", all.Users)
+	}
+	if page := list("include_deleted=true&offset=1&limit=1"); !slices.Equal(ids(page.Users), []int{aliceID}) || !page.HasMore || page.Total != 3 {
+		t.Fatalf("page = %+v", page)
+	}
+}
+
+func TestDeleteAccountContentModes(t *testing.T) {
+	for mode, want := range map[string]struct {
+		userID   int // user_id còn lại trên post và comment, -1 là của bob
+		deleted  bool
+		username string
+	}{
+		"":                      {userID: -1, username: "bob"},
+		DeletedContentKeep:      {userID: -1, username: "bob"},
+		DeletedContentAnonymize: {userID: 0, username: deletedUserName},
+		DeletedContentDelete:    {userID: -1, deleted: true, username: "bob"},
+	} {
+		t.Run("content="+mode, func(t *testing.T) {
+			a := newTestApp(t, nil)
+			_, alice := a.register("alice")
+			bobID, bob := a.register("bob")
+			alicePost := a.createPost(alice, map[string]any{"content": "hello"}, "")
+			bobPost := a.createPost(bob, map[string]any{"content": "bye"}, "")
+			commentID := a.comment(bob, alicePost, 0, "nice")
+
+			a.expect(http.StatusNoContent, "DELETE", "/me?content="+mode, bob, nil)
+			if user, _ := a.auth.userByID(bobID); !user.IsDeleted {
+				t.Fatal("bob's account not deleted")
+			}
+			wantUserID := want.userID
+			if wantUserID < 0 {
+				wantUserID = bobID
+			}
+
+			post, err := a.store.GetPost(t.Context(), bobPost)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if post.UserID != wantUserID || post.IsDeleted != want.deleted {
+				t.Fatalf("bob's post = %+v", post)
+			}
+
+			a.comments.mu.Lock()
+			postID, i, _ := a.comments.find(commentID)
+			c := a.comments.comments[postID][i]
+			a.comments.mu.Unlock()
+			if c.UserID != wantUserID || c.IsDeleted != want.deleted || c.Username != want.username {
+				t.Fatalf("bob's comment = %+v", c)
+			}
+
+			// nội dung của người khác không bị đụng tới
+			if p, _ := a.store.GetPost(t.Context(), alicePost); p.IsDeleted || p.UserID == 0 {
+				t.Fatalf("alice's post = %+v", p)
+			}
+		})
+	}
+}
+
+func TestDeleteAccountInvalidContent(t *testing.T) {
+	a := newTestApp(t, nil)
+	bobID, bob := a.register("bob")
+	a.expect(http.StatusBadRequest, "DELETE", "/me?content=purge", bob, nil)
+	if user, _ := a.auth.userByID(bobID); user.IsDeleted {
+		t.Fatal("account deleted despite invalid content")
+	}
+}
+
+func TestRegisterNormalizesUsername(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.profiles.DefaultAvatar = "https://cdn.example.com/default.png"
+	register := func(username, email string) *httptest.ResponseRecorder {
+		return a.do("POST", "/register", "", map[string]string{
+			"username": username, "email": email, "password": "password1",
+		})
+	}
+
+	rec := register("  Alice_1 ", "alice@example.com")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("padded username: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		UserID int `json:"user_id"`
+	}
+	decodeBody(t, rec, &resp)
+	var profile UserProfile
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d", resp.UserID), "", nil), &profile)
+	// bỏ khoảng trắng nhưng giữ cách viết hoa để hiển thị
+	if profile.Username != "Alice_1" || profile.Avatar != a.profiles.DefaultAvatar {
+		t.Fatalf("profile = %+v", profile)
+	}
+	for _, login := range []string{"alice_1", "ALICE_1", " Alice_1 "} {
+		if code := a.login(login, "password1"); code != http.StatusOK {
+			t.Fatalf("login %q: status %d", login, code)
+		}
+	}
+
+	for username, status := range map[string]int{
+		"ALICE_1":               http.StatusConflict,
+		" alice_1":              http.StatusConflict,
+		"al":                    http.StatusBadRequest,
+		"al ice":                http.StatusBadRequest,
+		"alice!":                http.StatusBadRequest,
+		strings.Repeat("a", 31): http.StatusBadRequest,
+		"   ":                   http.StatusBadRequest,
+	} {
+		if rec := register(username, "other@example.com"); rec.Code != status {
+			t.Fatalf("username %q: status %d, want %d", username, rec.Code, status)
+		}
+	}
+}
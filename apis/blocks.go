@@ -0,0 +1,120 @@
+package apis
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// BlockResponse represents a generic block response
+type BlockResponse struct {
+	Message string `json:"message,omitempty"`
+}
+
+// BlocksHandler handles block endpoints; a block hides content and
+// rejects interactions in both directions
+type BlocksHandler struct {
+	mu      sync.Mutex
+	blocked map[int]map[int]bool // blocker user_id -> blocked user_id
+	Tokens  *TokenService
+
+	Follows *FollowsHandler // both follow edges are removed on block
+}
+
+// NewBlocksHandler constructor
+func NewBlocksHandler() *BlocksHandler {
+	return &BlocksHandler{
+		blocked: make(map[int]map[int]bool),
+	}
+}
+
+// RegisterRoutes register block routes
+func (h *BlocksHandler) RegisterRoutes(router *mux.Router) {
+	router.Handle("/users/{target_user_id}/block", h.Tokens.RequireAuth(http.HandlerFunc(h.BlockUser))).Methods("POST")
+	router.Handle("/users/{target_user_id}/block", h.Tokens.RequireAuth(http.HandlerFunc(h.UnblockUser))).Methods("DELETE")
+}
+
+// @Summary Block User
+// @Description Block a user: both stop following each other and can no longer see or interact with each other's content
+// @Tags blocks
+// @Produce json
+// @Param target_user_id path int true "Target User ID"
+// @Security BearerAuth
+// @Success 201 {object} BlockResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Router /users/{target_user_id}/block [post]
+func (h *BlocksHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.Atoi(mux.Vars(r)["target_user_id"])
+	if err != nil || targetID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	currentID, _ := UserIDFromContext(r.Context())
+	if targetID == currentID {
+		writeJSONError(w, http.StatusBadRequest, "Cannot block yourself")
+		return
+	}
+
+	h.mu.Lock()
+	if h.blocked[currentID][targetID] {
+		h.mu.Unlock()
+		writeJSONError(w, http.StatusBadRequest, "Already blocked")
+		return
+	}
+	if h.blocked == nil {
+		h.blocked = make(map[int]map[int]bool)
+	}
+	if h.blocked[currentID] == nil {
+		h.blocked[currentID] = make(map[int]bool)
+	}
+	h.blocked[currentID][targetID] = true
+	h.mu.Unlock()
+
+	// nhả lock trước khi gọi sang follows để không giữ hai lock cùng lúc
+	h.Follows.unfollow(currentID, targetID)
+	h.Follows.unfollow(targetID, currentID)
+
+	writeJSON(w, http.StatusCreated, BlockResponse{Message: "Blocked"})
+}
+
+// @Summary Unblock User
+// @Description Remove a block; follows removed by the block are not restored
+// @Tags blocks
+// @Produce json
+// @Param target_user_id path int true "Target User ID"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /users/{target_user_id}/block [delete]
+func (h *BlocksHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	targetID, _ := strconv.Atoi(mux.Vars(r)["target_user_id"])
+	currentID, _ := UserIDFromContext(r.Context())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.blocked[currentID][targetID] {
+		writeJSONError(w, http.StatusNotFound, "User is not blocked")
+		return
+	}
+	delete(h.blocked[currentID], targetID)
+	if len(h.blocked[currentID]) == 0 {
+		delete(h.blocked, currentID)
+	}
+	writeNoContent(w)
+}
+
+// isBlocked reports whether either user blocked the other; a nil handler blocks nothing
+func (h *BlocksHandler) isBlocked(a, b int) bool {
+	if h == nil || a == 0 || b == 0 {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.blocked[a][b] || h.blocked[b][a]
+}
@@ -0,0 +1,48 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestBlockUser(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.feeds.CacheTTL = 0
+	aliceID, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", bobID), alice, nil)
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	bobsPost := a.createPost(bob, map[string]any{"content": "hi"}, "")
+
+	a.expect(http.StatusBadRequest, "POST", fmt.Sprintf("/users/%d/block", aliceID), alice, nil)
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/block", bobID), alice, nil)
+	a.expect(http.StatusBadRequest, "POST", fmt.Sprintf("/users/%d/block", bobID), alice, nil)
+
+	// follow bị gỡ cả hai chiều và không follow lại được
+	for userID, token := range map[int]string{aliceID: alice, bobID: bob} {
+		var counts FollowCountsResponse
+		decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/follow/counts", userID), token, nil), &counts)
+		if counts != (FollowCountsResponse{}) {
+			t.Fatalf("user %d counts after block = %+v", userID, counts)
+		}
+	}
+	a.expect(http.StatusForbidden, "POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+
+	// bob không thấy và không tương tác được với post của alice
+	a.expect(http.StatusNotFound, "GET", postPath(postID, ""), bob, nil)
+	a.expect(http.StatusNotFound, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+	a.expect(http.StatusNotFound, "POST", postPath(postID, "/comments"), bob, map[string]string{"content": "hey"})
+	a.expect(http.StatusNotFound, "GET", fmt.Sprintf("/users/%d", aliceID), bob, nil)
+	if got := feedPostIDs(a.feed(bob, fmt.Sprintf("author_id=%d", aliceID)).Feeds); len(got) != 0 {
+		t.Fatalf("bob's feed shows alice's posts %v", got)
+	}
+	// và ngược lại
+	a.expect(http.StatusNotFound, "GET", postPath(bobsPost, ""), alice, nil)
+	a.expect(http.StatusNotFound, "POST", postPath(bobsPost, "/reactions"), alice, map[string]string{"reaction_type": "like"})
+
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/users/%d/block", bobID), alice, nil)
+	a.expect(http.StatusOK, "GET", postPath(postID, ""), bob, nil)
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+}
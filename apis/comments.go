@@ -1,10 +1,16 @@
 package apis
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -12,54 +18,103 @@ import (
 // Comment represents a comment
 type Comment struct {
 	CommentID int    `json:"comment_id"`
+	ParentID  int    `json:"parent_comment_id,omitempty"`
 	UserID    int    `json:"user_id"`
 	Username  string `json:"username"`
 	Avatar    string `json:"avatar,omitempty"`
 	Content   string `json:"content"`
 	CreatedAt string `json:"createdAt"`
 	UpdatedAt string `json:"updatedAt"`
+	Edited    bool   `json:"edited"` // UpdatedAt differs from CreatedAt
 	IsDeleted bool   `json:"isDeleted"`
+	DeletedAt string `json:"-"`
+	DeletedBy int    `json:"-"` // tác giả post khi comment bị gỡ để kiểm duyệt
 }
 
+// CommentRestoreWindow là thời gian sau khi soft delete mà tác giả còn khôi phục được comment
+const CommentRestoreWindow = 30 * 24 * time.Hour
+
+// DefaultCommentEditWindow là thời gian sau khi tạo mà comment còn sửa được, khi không cấu hình
+const DefaultCommentEditWindow = 15 * time.Minute
+
 // CommentRequest represents request body for creating/updating comment
 type CommentRequest struct {
-	Content string `json:"content"`
+	Content  string `json:"content"`
+	ParentID int    `json:"parent_comment_id,omitempty"`
 }
 
 // CommentResponse represents generic response
 type CommentResponse struct {
 	CommentID int    `json:"comment_id,omitempty"`
 	Message   string `json:"message,omitempty"`
-	Error     string `json:"error,omitempty"`
 }
 
 // GetCommentsResponse represents response for GET comments
 type GetCommentsResponse struct {
 	Comments []Comment `json:"comments"`
-	Total    int       `json:"total"`
+	PageMeta
+}
+
+// defaultCommentsLimit is the page size of comment lists when limit is not given
+const defaultCommentsLimit = 50
+
+// commentsPage builds one page of comments from the sort and offset/limit query
+// params; comments are in insertion order, which stays the tiebreaker for both sorts.
+// Writes 400 and returns false for a bad sort or limit
+func commentsPage(w http.ResponseWriter, r *http.Request, comments []Comment) (GetCommentsResponse, bool) {
+	switch r.URL.Query().Get("sort") {
+	case "", "oldest":
+	case "newest":
+		comments = slices.Clone(comments)
+		slices.Reverse(comments)
+	default:
+		writeJSONError(w, http.StatusBadRequest, "Invalid sort, use oldest or newest")
+		return GetCommentsResponse{}, false
+	}
+
+	offset, limit, ok := parsePaginationDefault(w, r, defaultCommentsLimit)
+	if !ok {
+		return GetCommentsResponse{}, false
+	}
+	start, end := pageBounds(len(comments), offset, limit)
+	return GetCommentsResponse{
+		Comments: comments[start:end],
+		PageMeta: newPageMeta(len(comments), start, end, limit),
+	}, true
 }
 
 // CommentsHandler handles comment endpoints
 type CommentsHandler struct {
 	mu       sync.Mutex
 	comments map[int][]Comment // post_id -> list of comments
-	nextID   int
+	ids      IDGenerator
+	Tokens   *TokenService
+
+	Posts         Store                // stores comments and finds who to notify
+	Notifications *NotificationHandler // optional
+	Profiles      *ProfileHandler      // resolves @username mentions and comment authors
+	Blocks        *BlocksHandler       // optional
+	Follows       *FollowsHandler      // post followers-only chỉ follower mới thấy, optional
+	Moderator     Moderator            // optional, checks new and edited content
+	EditWindow    time.Duration        // comments can be edited this long after creation; 0 = no limit
 }
 
 // NewCommentsHandler constructor
 func NewCommentsHandler() *CommentsHandler {
 	return &CommentsHandler{
-		comments: make(map[int][]Comment),
-		nextID:   1,
+		comments:  make(map[int][]Comment),
+		Moderator: NopModerator{},
 	}
 }
 
 // RegisterRoutes register routes
 func (h *CommentsHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/posts/{post_id}/comments", h.GetComments).Methods("GET")
-	router.HandleFunc("/posts/{post_id}/comments", h.CreateComment).Methods("POST")
-	router.HandleFunc("/comments/{comment_id}", h.UpdateComment).Methods("PUT")
-	router.HandleFunc("/comments/{comment_id}", h.DeleteComment).Methods("DELETE")
+	router.Handle("/posts/{post_id}/comments", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetComments))).Methods("GET")
+	router.Handle("/posts/{post_id}/comments", h.Tokens.RequireAuth(http.HandlerFunc(h.CreateComment))).Methods("POST")
+	router.Handle("/comments/{comment_id}/replies", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetReplies))).Methods("GET")
+	router.Handle("/comments/{comment_id}", h.Tokens.RequireAuth(http.HandlerFunc(h.UpdateComment))).Methods("PUT")
+	router.Handle("/comments/{comment_id}", h.Tokens.RequireAuth(http.HandlerFunc(h.DeleteComment))).Methods("DELETE")
+	router.Handle("/comments/{comment_id}/restore", h.Tokens.RequireAuth(http.HandlerFunc(h.RestoreComment))).Methods("POST")
 }
 
 // @Summary Get Comments
@@ -68,161 +123,626 @@ func (h *CommentsHandler) RegisterRoutes(router *mux.Router) {
 // @Accept json
 // @Produce json
 // @Param post_id path int true "Post ID"
+// @Param include_deleted query bool false "Show deleted comments as [deleted] tombstones"
+// @Param sort query string false "oldest (default) or newest" Enums(oldest, newest)
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (default 50, max 100)"
 // @Param Authorization header string false "Bearer token"
 // @Success 200 {object} GetCommentsResponse
-// @Failure 404 {object} CommentResponse
+// @Failure 400 {object} APIError
+// @Failure 404 {object} APIError
 // @Router /posts/{post_id}/comments [get]
 func (h *CommentsHandler) GetComments(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID, _ := strconv.Atoi(vars["post_id"])
 
+	viewerID, _ := UserIDFromContext(r.Context())
+	if status, msg := h.postStatus(r.Context(), viewerID, postID); status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	comments, ok := h.comments[postID]
-	if !ok {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(CommentResponse{Error: "Post not found"})
+	// có store thì post đã được kiểm tra ở trên, chỉ là chưa có comment nào
+	if !ok && h.Posts == nil {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
 		return
 	}
 
-	resp := GetCommentsResponse{
-		Comments: comments,
-		Total:    len(comments),
+	visible := visibleComments(h.unblocked(r, comments), r.URL.Query().Get("include_deleted") == "true")
+	resp, ok := commentsPage(w, r, visible)
+	if !ok {
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	h.fillAuthors(resp.Comments)
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // @Summary Create Comment
-// @Description Create a new comment for a post
+// @Description Create a new comment for a post, or a reply when parent_comment_id is set.
+// @Description Users tagged as @username get a mention notification
 // @Tags comments
 // @Accept json
 // @Produce json
 // @Param post_id path int true "Post ID"
-// @Param Authorization header string true "Bearer token"
+// @Security BearerAuth
 // @Param body body CommentRequest true "Comment body"
 // @Success 201 {object} CommentResponse
-// @Failure 400 {object} CommentResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 422 {object} APIError
 // @Router /posts/{post_id}/comments [post]
 func (h *CommentsHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID, _ := strconv.Atoi(vars["post_id"])
 
 	var req CommentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Content == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(CommentResponse{Error: "Invalid content"})
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.Content == "" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid content")
+		return
+	}
+	content, reason := cleanContent(req.Content, maxCommentLength)
+	if reason != "" {
+		writeJSONError(w, http.StatusBadRequest, reason)
+		return
+	}
+	if ok, reason := moderate(r.Context(), h.Moderator, content); !ok {
+		writeJSONError(w, http.StatusUnprocessableEntity, reason)
+		return
+	}
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+	if status, msg := h.postStatus(r.Context(), currentUserID, postID); status != 0 {
+		writeJSONError(w, status, msg)
 		return
 	}
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if req.ParentID != 0 {
+		// comment cha phải thuộc cùng post
+		parentPostID, i, ok := h.find(req.ParentID)
+		if !ok || parentPostID != postID {
+			writeJSONError(w, http.StatusBadRequest, "Parent comment not found on this post")
+			return
+		}
+		if h.comments[postID][i].IsDeleted {
+			writeJSONError(w, http.StatusBadRequest, "Cannot reply to a deleted comment")
+			return
+		}
+		if h.Blocks.isBlocked(currentUserID, h.comments[postID][i].UserID) {
+			writeJSONError(w, http.StatusForbidden, "Cannot reply to this comment")
+			return
+		}
+	}
+
+	now := nowRFC3339()
+	username, avatar := h.author(currentUserID)
 	comment := Comment{
-		CommentID: h.nextID,
-		UserID:    1, // giả lập user
-		Username:  "user1",
-		Content:   req.Content,
-		CreatedAt: "2025-08-15T00:00:00Z",
-		UpdatedAt: "2025-08-15T00:00:00Z",
+		CommentID: h.ids.Next(),
+		ParentID:  req.ParentID,
+		UserID:    currentUserID,
+		Username:  username,
+		Avatar:    avatar,
+		Content:   content,
+		CreatedAt: now,
+		UpdatedAt: now,
 		IsDeleted: false,
 	}
-	h.nextID++
 
+	if err := h.saveComment(r.Context(), postID, comment); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save comment")
+		return
+	}
+	if h.comments == nil {
+		h.comments = make(map[int][]Comment)
+	}
 	h.comments[postID] = append(h.comments[postID], comment)
+	h.Notifications.notify(postAuthor(r.Context(), h.Posts, postID), currentUserID, NotifComment, postID)
+	h.notifyMentions(content, currentUserID, postID)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(CommentResponse{
+	writeJSON(w, http.StatusCreated, CommentResponse{
 		CommentID: comment.CommentID,
 		Message:   "Comment created",
 	})
 }
 
+// @Summary Get Replies
+// @Description Get direct replies to a comment
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param comment_id path int true "Comment ID"
+// @Param include_deleted query bool false "Show deleted replies as [deleted] tombstones"
+// @Param sort query string false "oldest (default) or newest" Enums(oldest, newest)
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (default 50, max 100)"
+// @Param Authorization header string false "Bearer token"
+// @Success 200 {object} GetCommentsResponse
+// @Failure 400 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /comments/{comment_id}/replies [get]
+func (h *CommentsHandler) GetReplies(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	commentID, _ := strconv.Atoi(vars["comment_id"])
+
+	h.mu.Lock()
+	postID, _, ok := h.find(commentID)
+	h.mu.Unlock()
+	// comment của post không được xem thì coi như không tồn tại
+	viewerID, _ := UserIDFromContext(r.Context())
+	if status, _ := h.postStatus(r.Context(), viewerID, postID); !ok || status != 0 {
+		writeJSONError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	replies := []Comment{}
+	for _, c := range h.comments[postID] {
+		if c.ParentID == commentID {
+			replies = append(replies, c)
+		}
+	}
+
+	replies = visibleComments(h.unblocked(r, replies), r.URL.Query().Get("include_deleted") == "true")
+	resp, ok := commentsPage(w, r, replies)
+	if !ok {
+		return
+	}
+	h.fillAuthors(resp.Comments)
+	writeJSON(w, http.StatusOK, resp)
+}
+
 // @Summary Update Comment
-// @Description Update a comment
+// @Description Update a comment; only its author can, and only within the edit window after it was created
 // @Tags comments
 // @Accept json
 // @Produce json
 // @Param comment_id path int true "Comment ID"
-// @Param Authorization header string true "Bearer token"
+// @Security BearerAuth
 // @Param body body CommentRequest true "Comment body"
 // @Success 200 {object} CommentResponse
-// @Failure 403 {object} CommentResponse
-// @Failure 404 {object} CommentResponse
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 422 {object} APIError
 // @Router /comments/{comment_id} [put]
 func (h *CommentsHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	commentID, _ := strconv.Atoi(vars["comment_id"])
 
 	var req CommentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Content == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(CommentResponse{Error: "Invalid content"})
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.Content == "" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid content")
 		return
 	}
+	content, reason := cleanContent(req.Content, maxCommentLength)
+	if reason != "" {
+		writeJSONError(w, http.StatusBadRequest, reason)
+		return
+	}
+	if ok, reason := moderate(r.Context(), h.Moderator, content); !ok {
+		writeJSONError(w, http.StatusUnprocessableEntity, reason)
+		return
+	}
+
+	currentUserID, _ := UserIDFromContext(r.Context())
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	found := false
-	for postID, commentList := range h.comments {
-		for i, c := range commentList {
-			if c.CommentID == commentID {
-				// giả lập check quyền
-				c.Content = req.Content
-				c.UpdatedAt = "2025-08-15T01:00:00Z"
-				h.comments[postID][i] = c
-				found = true
-				break
-			}
+	postID, i, ok := h.find(commentID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	c := h.comments[postID][i]
+	if c.UserID != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Not the author of this comment")
+		return
+	}
+	if h.EditWindow > 0 {
+		if createdAt, err := time.Parse(time.RFC3339, c.CreatedAt); err == nil && time.Since(createdAt) > h.EditWindow {
+			writeJSONError(w, http.StatusForbidden, fmt.Sprintf("Comments can only be edited within %s of posting", shortDuration(h.EditWindow)))
+			return
 		}
 	}
 
-	if !found {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(CommentResponse{Error: "Comment not found"})
+	c.Content = content
+	c.UpdatedAt = nowRFC3339()
+	c.Edited = c.UpdatedAt != c.CreatedAt
+	if err := h.saveComment(r.Context(), postID, c); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save comment")
 		return
 	}
+	h.comments[postID][i] = c
 
-	json.NewEncoder(w).Encode(CommentResponse{Message: "Comment updated"})
+	writeJSON(w, http.StatusOK, CommentResponse{Message: "Comment updated"})
 }
 
 // @Summary Delete Comment
-// @Description Soft delete a comment
+// @Description Soft delete a comment; allowed for its author and for the author of the post it is on
 // @Tags comments
 // @Accept json
 // @Produce json
 // @Param comment_id path int true "Comment ID"
-// @Param Authorization header string true "Bearer token"
-// @Success 200 {object} CommentResponse
-// @Failure 403 {object} CommentResponse
-// @Failure 404 {object} CommentResponse
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
 // @Router /comments/{comment_id} [delete]
 func (h *CommentsHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	commentID, _ := strconv.Atoi(vars["comment_id"])
 
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	postID, i, ok := h.find(commentID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	// tác giả post được gỡ mọi comment trên post của mình
+	if h.comments[postID][i].UserID != currentUserID && postAuthor(r.Context(), h.Posts, postID) != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Not the author of this comment or its post")
+		return
+	}
+
+	c := h.comments[postID][i]
+	c.IsDeleted = true
+	c.DeletedAt = nowRFC3339()
+	c.DeletedBy = currentUserID
+	if err := h.saveComment(r.Context(), postID, c); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save comment")
+		return
+	}
+	h.comments[postID][i] = c
+
+	writeNoContent(w)
+}
+
+// @Summary Restore Comment
+// @Description Undo a soft delete within 30 days; restoring a comment that is not deleted is a no-op.
+// @Description A comment removed by the post author cannot be restored by its writer
+// @Tags comments
+// @Produce json
+// @Param comment_id path int true "Comment ID"
+// @Security BearerAuth
+// @Success 200 {object} CommentResponse
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 410 {object} APIError
+// @Router /comments/{comment_id}/restore [post]
+func (h *CommentsHandler) RestoreComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	commentID, _ := strconv.Atoi(vars["comment_id"])
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	found := false
+	postID, i, ok := h.find(commentID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	c := h.comments[postID][i]
+	if c.UserID != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Not the author of this comment")
+		return
+	}
+	if !c.IsDeleted {
+		writeJSON(w, http.StatusOK, CommentResponse{Message: "Comment is not deleted"})
+		return
+	}
+	// comment bị tác giả post gỡ thì người viết không tự khôi phục được
+	if c.DeletedBy != 0 && c.DeletedBy != c.UserID {
+		writeJSONError(w, http.StatusForbidden, "Comment was removed by the post author")
+		return
+	}
+
+	// DeletedAt rỗng (xoá trước khi có field này) thì vẫn cho khôi phục
+	if deletedAt, err := time.Parse(time.RFC3339, c.DeletedAt); err == nil && time.Since(deletedAt) > CommentRestoreWindow {
+		writeJSONError(w, http.StatusGone, "Restore window has expired")
+		return
+	}
+
+	c.IsDeleted = false
+	c.DeletedAt = ""
+	c.DeletedBy = 0
+	if err := h.saveComment(r.Context(), postID, c); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save comment")
+		return
+	}
+	h.comments[postID][i] = c
+	writeJSON(w, http.StatusOK, CommentResponse{Message: "Comment restored"})
+}
+
+// activityOf lists the comments userID wrote that are not deleted, for GET /users/{user_id}/activity;
+// a nil handler has none
+func (h *CommentsHandler) activityOf(userID int) []ActivityItem {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var items []ActivityItem
+	for postID, comments := range h.comments {
+		for _, c := range comments {
+			if c.UserID == userID && !c.IsDeleted {
+				items = append(items, ActivityItem{Type: ActivityComment, CreatedAt: c.CreatedAt, PostID: postID, CommentID: c.CommentID, Content: c.Content})
+			}
+		}
+	}
+	return items
+}
+
+// anonymizeUser detaches every comment of userID from the account (DELETE /me?content=anonymize);
+// a nil handler does nothing
+func (h *CommentsHandler) anonymizeUser(ctx context.Context, userID int) error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for postID, comments := range h.comments {
+		for i, c := range comments {
+			if c.UserID != userID {
+				continue
+			}
+			c.UserID = 0
+			c.Username = deletedUserName
+			c.Avatar = ""
+			if err := h.saveComment(ctx, postID, c); err != nil {
+				return err
+			}
+			comments[i] = c
+		}
+	}
+	return nil
+}
+
+// deleteUserComments soft-deletes every comment of userID (DELETE /me?content=delete);
+// a nil handler does nothing
+func (h *CommentsHandler) deleteUserComments(ctx context.Context, userID int) error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := nowRFC3339()
+	for postID, comments := range h.comments {
+		for i, c := range comments {
+			if c.UserID != userID || c.IsDeleted {
+				continue
+			}
+			c.IsDeleted = true
+			c.DeletedAt = now
+			c.DeletedBy = userID
+			if err := h.saveComment(ctx, postID, c); err != nil {
+				return err
+			}
+			comments[i] = c
+		}
+	}
+	return nil
+}
+
+// saveComment writes c to the store; without one comments only live in memory. Callers hold h.mu
+// and update h.comments only once this succeeds
+func (h *CommentsHandler) saveComment(ctx context.Context, postID int, c Comment) error {
+	if h.Posts == nil {
+		return nil
+	}
+	return h.Posts.SaveComment(ctx, postID, c)
+}
+
+// Load reads the comments saved in Posts and continues comment IDs after the highest one;
+// call it once at startup, before serving requests
+func (h *CommentsHandler) Load(ctx context.Context) error {
+	if h.Posts == nil {
+		return nil
+	}
+	comments, err := h.Posts.ListComments(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.comments == nil {
+		h.comments = make(map[int][]Comment)
+	}
+	for postID, list := range comments {
+		for _, c := range list {
+			h.ids.Observe(c.CommentID)
+		}
+		h.comments[postID] = append(h.comments[postID], list...)
+	}
+	return nil
+}
+
+// shortDuration drops the zero tails of Duration.String, e.g. 15m0s -> 15m, 1h0m0s -> 1h
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// mentionPattern matches @username not preceded by a username character, so emails are skipped
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.])@([A-Za-z0-9_.]{3,30})`)
+
+// extractMentions returns the distinct lowercase handles mentioned in content
+func extractMentions(content string) []string {
+	seen := map[string]bool{}
+	handles := []string{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		// "@bob." ở cuối câu
+		handle := strings.ToLower(strings.TrimRight(m[1], "."))
+		if len(handle) < 3 || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+	}
+	return handles
+}
+
+// notifyMentions sends a mention notification to every known user tagged in content;
+// unknown handles are ignored and notify already skips the author
+func (h *CommentsHandler) notifyMentions(content string, authorID, postID int) {
+	notified := map[int]bool{}
+	for _, handle := range extractMentions(content) {
+		userID, ok := h.Profiles.userIDByUsername(handle)
+		if !ok || notified[userID] || h.Blocks.isBlocked(authorID, userID) {
+			continue
+		}
+		notified[userID] = true
+		h.Notifications.notify(userID, authorID, NotifMention, postID)
+	}
+}
+
+// postStatus checks postID in the posts store: 0 when viewerID can see and comment on it,
+// otherwise the status and message to reply with. Missing, soft-deleted, hidden from viewerID
+// or blocked posts are all 404. Without a store every ID passes
+func (h *CommentsHandler) postStatus(ctx context.Context, viewerID, postID int) (int, string) {
+	if h.Posts == nil {
+		return 0, ""
+	}
+	post, err := h.Posts.GetPost(ctx, postID)
+	if err != nil && !errors.Is(err, ErrPostNotFound) {
+		return http.StatusInternalServerError, "Cannot load post"
+	}
+	if err != nil || post.IsDeleted || !canSeePost(h.Follows, viewerID, post) || h.Blocks.isBlocked(viewerID, post.UserID) {
+		return http.StatusNotFound, "Post not found"
+	}
+	return 0, ""
+}
+
+// author returns the current username and avatar of userID, falling back to a placeholder name
+func (h *CommentsHandler) author(userID int) (username, avatar string) {
+	if h.Profiles != nil {
+		if p, ok := h.Profiles.profile(userID); ok {
+			return p.Username, p.Avatar
+		}
+	}
+	return "user" + strconv.Itoa(userID), ""
+}
+
+// fillAuthors refreshes username and avatar from the profiles so renames show on old comments;
+// anonymized comments (user_id 0) keep their placeholder
+func (h *CommentsHandler) fillAuthors(comments []Comment) {
+	for i := range comments {
+		if comments[i].UserID != 0 {
+			comments[i].Username, comments[i].Avatar = h.author(comments[i].UserID)
+		}
+	}
+}
+
+// unblocked drops comments written by users in a block with the requester
+func (h *CommentsHandler) unblocked(r *http.Request, comments []Comment) []Comment {
+	viewerID, ok := UserIDFromContext(r.Context())
+	if !ok || h.Blocks == nil {
+		return comments
+	}
+	kept := []Comment{}
+	for _, c := range comments {
+		if !h.Blocks.isBlocked(viewerID, c.UserID) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// deletedTombstone replaces the content of deleted comments in moderation views
+const deletedTombstone = "[deleted]"
+
+// visibleComments drops deleted comments, or masks them as tombstones when includeDeleted is set
+func visibleComments(comments []Comment, includeDeleted bool) []Comment {
+	visible := []Comment{}
+	for _, c := range comments {
+		if c.IsDeleted {
+			if !includeDeleted {
+				continue
+			}
+			c.Content = deletedTombstone
+		}
+		visible = append(visible, c)
+	}
+	return visible
+}
+
+// find locates a comment by ID; the caller must hold h.mu
+func (h *CommentsHandler) find(commentID int) (postID, index int, ok bool) {
 	for postID, commentList := range h.comments {
 		for i, c := range commentList {
 			if c.CommentID == commentID {
-				c.IsDeleted = true
-				h.comments[postID][i] = c
-				found = true
-				break
+				return postID, i, true
 			}
 		}
 	}
+	return 0, 0, false
+}
 
-	if !found {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(CommentResponse{Error: "Comment not found"})
-		return
+// commentAuthor returns who wrote a comment that is not deleted; a nil handler knows no comments
+func (h *CommentsHandler) commentAuthor(commentID int) (int, bool) {
+	if h == nil {
+		return 0, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	postID, i, ok := h.find(commentID)
+	if !ok || h.comments[postID][i].IsDeleted {
+		return 0, false
+	}
+	return h.comments[postID][i].UserID, true
+}
+
+// commentCount returns the number of non-deleted comments on a post
+func (h *CommentsHandler) commentCount(postID int) int {
+	if h == nil {
+		return 0
 	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	json.NewEncoder(w).Encode(CommentResponse{Message: "Comment soft deleted"})
+	count := 0
+	for _, c := range h.comments[postID] {
+		if !c.IsDeleted {
+			count++
+		}
+	}
+	return count
 }
@@ -0,0 +1,342 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommentsHiddenPost(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	private := a.createPost(alice, map[string]any{"content": "secret", "visibility": "private"}, "")
+	rec := a.expect(http.StatusCreated, "POST", postPath(private, "/comments"), alice, map[string]string{"content": "note to self"})
+	var created CommentResponse
+	decodeBody(t, rec, &created)
+
+	a.expect(http.StatusNotFound, "GET", postPath(private, "/comments"), bob, nil)
+	a.expect(http.StatusNotFound, "GET", postPath(private, "/comments"), "", nil)
+	a.expect(http.StatusNotFound, "POST", postPath(private, "/comments"), bob, map[string]string{"content": "hi"})
+	a.expect(http.StatusNotFound, "GET", "/comments/1/replies", bob, nil)
+	a.expect(http.StatusOK, "GET", "/comments/1/replies", alice, nil)
+
+	var page GetCommentsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(private, "/comments"), alice, nil), &page)
+	if page.Total != 1 || page.Comments[0].CommentID != created.CommentID {
+		t.Fatalf("author sees %+v", page)
+	}
+}
+
+func TestCreateCommentMissingOrDeletedPost(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+
+	a.expect(http.StatusNotFound, "POST", postPath(42, "/comments"), bob, map[string]string{"content": "hi"})
+
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	// post chưa có comment vẫn trả về trang rỗng
+	a.expect(http.StatusOK, "GET", postPath(postID, "/comments"), "", nil)
+	a.expect(http.StatusNoContent, "DELETE", postPath(postID, ""), alice, nil)
+	a.expect(http.StatusNotFound, "POST", postPath(postID, "/comments"), bob, map[string]string{"content": "hi"})
+	a.expect(http.StatusNotFound, "GET", postPath(postID, "/comments"), bob, nil)
+}
+
+func TestCommentAuthorFromProfile(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	a.expect(http.StatusOK, "PATCH", "/me", bob, map[string]string{"avatar": "https://cdn.example.com/bob.png"})
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/comments"), bob, map[string]string{"content": "hi"})
+
+	var page GetCommentsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/comments"), "", nil), &page)
+	if c := page.Comments[0]; c.Username != "bob" || c.Avatar != "https://cdn.example.com/bob.png" {
+		t.Fatalf("comment author = %q %q", c.Username, c.Avatar)
+	}
+
+	// đổi tên thì comment cũ hiện tên mới
+	a.expect(http.StatusOK, "PATCH", "/me", bob, map[string]string{"username": "robert"})
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/comments"), "", nil), &page)
+	if c := page.Comments[0]; c.Username != "robert" {
+		t.Fatalf("comment author after rename = %q", c.Username)
+	}
+}
+
+// comment tạo comment (hoặc reply khi parentID khác 0) và trả về comment_id
+func (a *testApp) comment(token string, postID, parentID int, content string) int {
+	a.t.Helper()
+	var resp CommentResponse
+	decodeBody(a.t, a.expect(http.StatusCreated, "POST", postPath(postID, "/comments"), token, CommentRequest{Content: content, ParentID: parentID}), &resp)
+	return resp.CommentID
+}
+
+// commentPage đọc GET path (danh sách comment hoặc replies) của token
+func (a *testApp) commentPage(token, path string) GetCommentsResponse {
+	a.t.Helper()
+	var page GetCommentsResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", path, token, nil), &page)
+	return page
+}
+
+func TestCommentReplies(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	other := a.createPost(alice, map[string]any{"content": "other"}, "")
+
+	parent := a.comment(bob, postID, 0, "first")
+	reply := a.comment(alice, postID, parent, "reply")
+	a.comment(bob, postID, reply, "nested")
+
+	replies := a.commentPage("", fmt.Sprintf("/comments/%d/replies", parent))
+	if replies.Total != 1 || replies.Comments[0].CommentID != reply || replies.Comments[0].ParentID != parent {
+		t.Fatalf("replies = %+v", replies)
+	}
+
+	a.expect(http.StatusBadRequest, "POST", postPath(other, "/comments"), bob, CommentRequest{Content: "cross", ParentID: parent})
+	a.expect(http.StatusBadRequest, "POST", postPath(postID, "/comments"), bob, CommentRequest{Content: "ghost", ParentID: 999})
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/comments/%d", parent), bob, nil)
+	a.expect(http.StatusBadRequest, "POST", postPath(postID, "/comments"), alice, CommentRequest{Content: "late", ParentID: parent})
+	a.expect(http.StatusNotFound, "GET", "/comments/999/replies", "", nil)
+}
+
+func TestCommentAuthorship(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	commentID := a.comment(bob, postID, 0, "mine")
+	path := fmt.Sprintf("/comments/%d", commentID)
+
+	a.expect(http.StatusForbidden, "PUT", path, carol, CommentRequest{Content: "hijack"})
+	a.expect(http.StatusForbidden, "DELETE", path, carol, nil)
+	a.expect(http.StatusNotFound, "PUT", "/comments/999", bob, CommentRequest{Content: "ghost"})
+	a.expect(http.StatusNotFound, "DELETE", "/comments/999", bob, nil)
+
+	a.expect(http.StatusOK, "PUT", path, bob, CommentRequest{Content: "edited"})
+	if page := a.commentPage("", postPath(postID, "/comments")); page.Comments[0].Content != "edited" {
+		t.Fatalf("comment = %+v", page.Comments[0])
+	}
+	a.expect(http.StatusNoContent, "DELETE", path, bob, nil)
+}
+
+func TestPostAuthorDeletesComment(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	spam := a.comment(bob, postID, 0, "spam")
+	own := a.comment(bob, postID, 0, "mine")
+	kept := a.comment(bob, postID, 0, "keep")
+
+	// người không liên quan bị từ chối, tác giả post và tác giả comment thì được
+	a.expect(http.StatusForbidden, "DELETE", fmt.Sprintf("/comments/%d", kept), carol, nil)
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/comments/%d", spam), alice, nil)
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/comments/%d", own), bob, nil)
+
+	page := a.commentPage("", postPath(postID, "/comments"))
+	if page.Total != 1 || page.Comments[0].CommentID != kept {
+		t.Fatalf("comments = %+v", page.Comments)
+	}
+}
+
+func TestDeletedCommentsHidden(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	a.comment(bob, postID, 0, "keep")
+	gone := a.comment(bob, postID, 0, "regret")
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/comments/%d", gone), bob, nil)
+
+	page := a.commentPage("", postPath(postID, "/comments"))
+	if page.Total != 1 || len(page.Comments) != 1 || page.Comments[0].Content != "keep" {
+		t.Fatalf("default page = %+v", page)
+	}
+
+	page = a.commentPage("", postPath(postID, "/comments?include_deleted=true"))
+	if page.Total != 2 || page.Comments[1].Content != deletedTombstone || !page.Comments[1].IsDeleted {
+		t.Fatalf("page with include_deleted = %+v", page)
+	}
+}
+
+// backdateComment lùi created_at/updated_at của comment về trước một khoảng d
+func (a *testApp) backdateComment(commentID int, d time.Duration) {
+	a.t.Helper()
+	a.comments.mu.Lock()
+	defer a.comments.mu.Unlock()
+	postID, i, ok := a.comments.find(commentID)
+	if !ok {
+		a.t.Fatalf("comment %d not found", commentID)
+	}
+	c := &a.comments.comments[postID][i]
+	c.CreatedAt = formatRFC3339(time.Now().Add(-d))
+	c.UpdatedAt = c.CreatedAt
+}
+
+func TestCommentTimestamps(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	commentID := a.comment(alice, postID, 0, "first")
+
+	c := a.commentPage("", postPath(postID, "/comments")).Comments[0]
+	created, err := time.Parse(time.RFC3339, c.CreatedAt)
+	if err != nil || time.Since(created) > time.Minute || c.UpdatedAt != c.CreatedAt || c.Edited {
+		t.Fatalf("new comment = %+v", c)
+	}
+
+	// thay cho sleep: timestamp chỉ tới giây
+	a.backdateComment(commentID, 2*time.Second)
+	a.expect(http.StatusOK, "PUT", fmt.Sprintf("/comments/%d", commentID), alice, CommentRequest{Content: "second"})
+	c = a.commentPage("", postPath(postID, "/comments")).Comments[0]
+	if c.UpdatedAt <= c.CreatedAt || !c.Edited {
+		t.Fatalf("edited comment = %+v", c)
+	}
+}
+
+func TestCommentEditWindow(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	a.comments.EditWindow = 10 * time.Minute
+
+	fresh := a.comment(bob, postID, 0, "fresh")
+	a.backdateComment(fresh, 5*time.Minute)
+	a.expect(http.StatusOK, "PUT", fmt.Sprintf("/comments/%d", fresh), bob, CommentRequest{Content: "fixed typo"})
+
+	old := a.comment(bob, postID, 0, "old")
+	a.backdateComment(old, 11*time.Minute)
+	var apiErr APIError
+	decodeBody(t, a.expect(http.StatusForbidden, "PUT", fmt.Sprintf("/comments/%d", old), bob, CommentRequest{Content: "rewrite"}), &apiErr)
+	if !strings.Contains(apiErr.Error, "10m") {
+		t.Fatalf("error = %q", apiErr.Error)
+	}
+	// kiểm tra tác giả vẫn chạy trước
+	a.expect(http.StatusForbidden, "PUT", fmt.Sprintf("/comments/%d", fresh), alice, CommentRequest{Content: "hijack"})
+
+	// 0 là không giới hạn
+	a.comments.EditWindow = 0
+	a.backdateComment(old, 365*24*time.Hour)
+	a.expect(http.StatusOK, "PUT", fmt.Sprintf("/comments/%d", old), bob, CommentRequest{Content: "rewrite"})
+}
+
+func TestCommentMentionsNotify(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	carolID, carol := a.register("carol")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+
+	// alice còn nhận notification comment vì là tác giả post nên chỉ đếm mention
+	a.comment(bob, postID, 0, "@alice and @Carol look, @carol again, @bob is me, @nobody")
+	for userID, token := range map[int]string{aliceID: alice, carolID: carol} {
+		got := a.notifications(token, "type="+NotifMention)
+		if got.Total != 1 || got.Notifications[0].SourceUserID != bobID || got.Notifications[0].PostID != postID {
+			t.Fatalf("user %d mentions = %+v", userID, got.Notifications)
+		}
+	}
+	if got := a.notifications(bob, ""); got.Total != 0 {
+		t.Fatalf("self-mention notified bob: %+v", got.Notifications)
+	}
+
+	a.comment(bob, postID, 0, "email me at bob@alice.com, @ghost")
+	if got := a.notifications(alice, "type="+NotifMention); got.Total != 1 {
+		t.Fatalf("unknown handles notified alice: %+v", got.Notifications)
+	}
+}
+
+// commentIDs lấy comment_id theo thứ tự trả về
+func commentIDs(comments []Comment) []int {
+	ids := []int{}
+	for _, c := range comments {
+		ids = append(ids, c.CommentID)
+	}
+	return ids
+}
+
+func TestCommentsPaging(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	var ids []int
+	for i := range 5 {
+		ids = append(ids, a.comment(bob, postID, 0, fmt.Sprint("comment ", i)))
+	}
+	gone := ids[2]
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/comments/%d", gone), bob, nil)
+	live := slices.Delete(slices.Clone(ids), 2, 3)
+
+	page := a.commentPage("", postPath(postID, "/comments"))
+	if page.Limit != defaultCommentsLimit || page.Total != 4 || !slices.Equal(commentIDs(page.Comments), live) {
+		t.Fatalf("default page = %+v", page)
+	}
+
+	// hai trang nối lại đúng bằng danh sách đầy đủ, không lặp không sót
+	for sort, want := range map[string][]int{
+		"oldest": live,
+		"newest": {live[3], live[2], live[1], live[0]},
+	} {
+		first := a.commentPage("", postPath(postID, "/comments?limit=2&sort="+sort))
+		second := a.commentPage("", postPath(postID, "/comments?limit=2&offset=2&sort="+sort))
+		got := append(commentIDs(first.Comments), commentIDs(second.Comments)...)
+		if !slices.Equal(got, want) {
+			t.Fatalf("sort=%s pages = %v, want %v", sort, got, want)
+		}
+		if first.Total != 4 || second.Total != 4 || !first.HasMore || second.HasMore {
+			t.Fatalf("sort=%s meta = %+v, %+v", sort, first.PageMeta, second.PageMeta)
+		}
+	}
+	a.expect(http.StatusBadRequest, "GET", postPath(postID, "/comments?sort=top"), "", nil)
+}
+
+func TestRestoreComment(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	restorePath := func(id int) string { return fmt.Sprintf("/comments/%d/restore", id) }
+
+	oops := a.comment(bob, postID, 0, "oops")
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/comments/%d", oops), bob, nil)
+	a.comments.mu.Lock()
+	pid, i, _ := a.comments.find(oops)
+	deleted := a.comments.comments[pid][i]
+	a.comments.mu.Unlock()
+	if !deleted.IsDeleted || deleted.DeletedAt == "" {
+		t.Fatalf("deleted comment = %+v", deleted)
+	}
+
+	a.expect(http.StatusForbidden, "POST", restorePath(oops), carol, nil)
+	a.expect(http.StatusNotFound, "POST", restorePath(99), bob, nil)
+	a.expect(http.StatusOK, "POST", restorePath(oops), bob, nil)
+	if page := a.commentPage("", postPath(postID, "/comments")); page.Total != 1 || page.Comments[0].CommentID != oops {
+		t.Fatalf("comments after restore = %+v", page)
+	}
+
+	// tác giả post gỡ comment thì người viết không tự khôi phục được
+	removed := a.comment(bob, postID, 0, "rude")
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/comments/%d", removed), alice, nil)
+	a.expect(http.StatusForbidden, "POST", restorePath(removed), bob, nil)
+
+	// quá CommentRestoreWindow thì trả 410
+	old := a.comment(bob, postID, 0, "old")
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/comments/%d", old), bob, nil)
+	a.comments.mu.Lock()
+	pid, i, _ = a.comments.find(old)
+	a.comments.comments[pid][i].DeletedAt = formatRFC3339(time.Now().Add(-CommentRestoreWindow - time.Hour))
+	a.comments.mu.Unlock()
+	a.expect(http.StatusGone, "POST", restorePath(old), bob, nil)
+}
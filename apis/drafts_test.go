@@ -0,0 +1,86 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDraftHiddenFromInteractions(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	draft := a.createPost(alice, map[string]any{"content": "not yet"}, "status=draft")
+
+	for _, token := range []string{"", bob} {
+		a.expect(http.StatusNotFound, "GET", postPath(draft, ""), token, nil)
+		a.expect(http.StatusNotFound, "GET", postPath(draft, "/reactions"), token, nil)
+		a.expect(http.StatusNotFound, "GET", postPath(draft, "/reactions/like"), token, nil)
+		a.expect(http.StatusNotFound, "GET", postPath(draft, "/comments"), token, nil)
+	}
+	a.expect(http.StatusNotFound, "POST", postPath(draft, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+	a.expect(http.StatusNotFound, "POST", postPath(draft, "/like/toggle"), bob, nil)
+	a.expect(http.StatusNotFound, "POST", postPath(draft, "/comments"), bob, map[string]string{"content": "first"})
+	a.expect(http.StatusNotFound, "POST", postPath(draft, "/report"), bob, map[string]string{"reason": "spam"})
+
+	// tác giả vẫn tương tác được với draft của mình
+	a.expect(http.StatusOK, "GET", postPath(draft, "/reactions"), alice, nil)
+	a.expect(http.StatusCreated, "POST", postPath(draft, "/comments"), alice, map[string]string{"content": "todo"})
+
+	// publish xong thì ai cũng thấy
+	a.expect(http.StatusOK, "POST", postPath(draft, "/publish"), alice, nil)
+	a.expect(http.StatusOK, "GET", postPath(draft, "/comments"), "", nil)
+	a.expect(http.StatusCreated, "POST", postPath(draft, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+}
+
+func TestPublishDraft(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.feeds.CacheTTL = 0
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+	published := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	draft := a.createPost(alice, map[string]any{"content": "not yet"}, "status=draft")
+
+	// draft chỉ tác giả thấy, ở mọi danh sách
+	if got := postIDs(a.userPosts(aliceID, "").Posts); !slices.Equal(got, []int{published}) {
+		t.Fatalf("user posts for guests = %v", got)
+	}
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Equal(got, []int{published}) {
+		t.Fatalf("bob's feed = %v", got)
+	}
+	if got := postIDs(a.listPosts(bob, "").Posts); slices.Contains(got, draft) {
+		t.Fatalf("post list for bob = %v", got)
+	}
+	if detail := a.postDetail(alice, draft); detail.Status != PostDraft {
+		t.Fatalf("author's draft = %+v", detail)
+	}
+
+	// backdate để thấy createdAt được đặt lại khi publish
+	old := formatRFC3339(time.Now().Add(-time.Hour))
+	p, _ := a.store.GetPost(t.Context(), draft)
+	p.CreatedAt = old
+	if err := a.store.UpdatePost(t.Context(), p); err != nil {
+		t.Fatal(err)
+	}
+
+	a.expect(http.StatusForbidden, "POST", postPath(draft, "/publish"), bob, nil)
+	a.expect(http.StatusNotFound, "POST", postPath(999, "/publish"), alice, nil)
+	a.expect(http.StatusOK, "POST", postPath(draft, "/publish"), alice, nil)
+
+	detail := a.postDetail(bob, draft)
+	if detail.Status != PostPublished || detail.CreatedAt <= old {
+		t.Fatalf("published post = %+v", detail)
+	}
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Equal(got, []int{draft, published}) {
+		t.Fatalf("bob's feed after publish = %v", got)
+	}
+	if got := postIDs(a.userPosts(aliceID, "").Posts); !slices.Equal(got, []int{draft, published}) {
+		t.Fatalf("user posts after publish = %v", got)
+	}
+
+	// publish lại là no-op
+	a.expect(http.StatusOK, "POST", postPath(draft, "/publish"), alice, nil)
+}
@@ -1,9 +1,14 @@
 package apis
 
 import (
-	"encoding/json"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,103 +17,439 @@ import (
 
 // FeedItem represents a feed post
 type FeedItem struct {
-	PostID       int      `json:"post_id"`
-	UserID       int      `json:"user_id"`
-	Username     string   `json:"username"`
-	Avatar       string   `json:"avatar,omitempty"`
-	Content      string   `json:"content"`
-	MediaURLs    []string `json:"media_urls,omitempty"`
-	CreatedAt    string   `json:"created_at"`
-	LikeCount    int      `json:"like_count"`
-	CommentCount int      `json:"comment_count"`
-	IsLiked      bool     `json:"is_liked"`
+	PostID         int            `json:"post_id"`
+	UserID         int            `json:"user_id"`
+	Username       string         `json:"username"`
+	Avatar         string         `json:"avatar,omitempty"`
+	Content        string         `json:"content"`
+	MediaURLs      []string       `json:"media_urls,omitempty"`
+	CreatedAt      string         `json:"created_at"`
+	LikeCount      int            `json:"like_count"`      // chỉ tính reaction "like"
+	ReactionCounts map[string]int `json:"reaction_counts"` // reaction_type -> số lượng
+	CommentCount   int            `json:"comment_count"`
+	IsLiked        bool           `json:"is_liked"`
 }
 
 // FeedResponse represents the response of feeds
 type FeedResponse struct {
 	Feeds      []FeedItem `json:"feeds"`
 	NextCursor string     `json:"next_cursor,omitempty"`
-	Error      string     `json:"error,omitempty"`
+	Limit      int        `json:"limit"` // limit đã áp dụng
 }
 
-// FeedsHandler handles news feed endpoints
+// Thứ tự feed: recent là mới nhất trước, top là theo điểm tương tác
+const (
+	FeedRankRecent = "recent"
+	FeedRankTop    = "top"
+)
+
+// defaultFeedLimit là số post một trang feed khi không có limit
+const defaultFeedLimit = 10
+
+// maxSeenBatch giới hạn số post ID trong một lần POST /feeds/seen
+const maxSeenBatch = 500
+
+// topFeedWindow giới hạn rank=top ở các post gần đây để post cũ nhiều tương tác không nằm mãi trên đầu
+const topFeedWindow = 7 * 24 * time.Hour
+
+// FeedsHandler dựng news feed từ post, follow, reaction và comment
 type FeedsHandler struct {
+	Posts     Store
+	Follows   *FollowsHandler
+	Reactions *ReactionsHandler
+	Comments  *CommentsHandler
+	Tokens    *TokenService
+	Blocks    *BlocksHandler  // optional
+	Profiles  *ProfileHandler // username/avatar của tác giả; GET /users/{user_id}/activity: user tồn tại và quyền xem profile private
+	Media     *MediaHandler   // media_urls của post, optional
+
+	// CacheTTL là thời gian cache trang đầu feed của mỗi user; <= 0 thì tắt cache
+	CacheTTL time.Duration
+	cache    feedCache
+
 	mu    sync.Mutex
-	feeds []FeedItem
+	seen  map[int]map[int]bool // user_id -> post_id đã hiển thị
+	muted map[int][]string     // user_id -> muted words (lowercase)
 }
 
 // NewFeedsHandler constructor
-func NewFeedsHandler() *FeedsHandler {
+func NewFeedsHandler(posts Store, follows *FollowsHandler, reactions *ReactionsHandler, comments *CommentsHandler) *FeedsHandler {
 	return &FeedsHandler{
-		feeds: make([]FeedItem, 0),
+		Posts:     posts,
+		Follows:   follows,
+		Reactions: reactions,
+		Comments:  comments,
+		CacheTTL:  DefaultFeedCacheTTL,
+		seen:      make(map[int]map[int]bool),
+		muted:     make(map[int][]string),
 	}
 }
 
 // RegisterRoutes register feed routes
 func (h *FeedsHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/feeds", h.GetNewsFeed).Methods("GET")
+	router.Handle("/feeds", h.Tokens.RequireAuth(http.HandlerFunc(h.GetNewsFeed))).Methods("GET")
+	router.Handle("/feeds/seen", h.Tokens.RequireAuth(http.HandlerFunc(h.MarkSeen))).Methods("POST")
+	router.Handle("/me/muted-words", h.Tokens.RequireAuth(http.HandlerFunc(h.GetMutedWords))).Methods("GET")
+	router.Handle("/me/muted-words", h.Tokens.RequireAuth(http.HandlerFunc(h.SetMutedWords))).Methods("PUT")
+	router.Handle("/users/{user_id}/activity", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetUserActivity))).Methods("GET")
 }
 
 // @Summary Get My News Feed
-// @Description Get news feed posts
+// @Description Get news feed posts; since is only supported with rank=recent.
+// @Description Posts containing one of the user's muted words (PUT /me/muted-words) are left out.
+// @Description The first page is cached for a few seconds, so like and comment counts there may lag
 // @Tags feeds
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Bearer token"
-// @Param before query string false "Timestamp cursor (optional)"
-// @Param limit query int false "Number of posts to return"
+// @Security BearerAuth
+// @Param before query string false "Opaque cursor from next_cursor (optional)"
+// @Param since query string false "Opaque cursor of the newest item the client has; returns only newer items (pull-to-refresh)"
+// @Param limit query int false "Number of posts to return (default 10, max 100)"
+// @Param exclude_seen query bool false "Skip posts marked with POST /feeds/seen"
+// @Param rank query string false "recent (default, newest first) or top (likes*2 + comments, posts of the last 7 days)" Enums(recent, top)
+// @Param author_id query int false "Only posts of this user (any author the requester can see, not just followed ones)"
 // @Success 200 {object} FeedResponse
-// @Failure 401 {object} FeedResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
 // @Router /feeds [get]
 func (h *FeedsHandler) GetNewsFeed(w http.ResponseWriter, r *http.Request) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	if !requireStore(w, h.Posts) {
+		return
+	}
 
-	// Giả lập userID = 1
-	//currentUserID := 1
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	// author_id lọc feed theo một tác giả; không có post nào xem được thì trả feed rỗng
+	authorID, ok := optionalIDParam(w, r, "author_id")
+	if !ok {
+		return
+	}
+	_, limit, ok := parsePaginationDefault(w, r, defaultFeedLimit)
+	if !ok {
+		return
+	}
 
 	// Lấy query param
 	beforeStr := r.URL.Query().Get("before")
-	limitStr := r.URL.Query().Get("limit")
+	sinceStr := r.URL.Query().Get("since")
 
-	limit := 10
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
-			limit = l
-		}
+	if beforeStr != "" && sinceStr != "" {
+		writeJSONError(w, http.StatusBadRequest, "Use either before or since, not both")
+		return
 	}
 
-	var beforeTime time.Time
+	rank := r.URL.Query().Get("rank")
+	if rank == "" {
+		rank = FeedRankRecent
+	}
+	if rank != FeedRankRecent && rank != FeedRankTop {
+		writeJSONError(w, http.StatusBadRequest, "Invalid rank, use recent or top")
+		return
+	}
+	if rank == FeedRankTop && sinceStr != "" {
+		writeJSONError(w, http.StatusBadRequest, "since is not supported with rank=top")
+		return
+	}
+
+	var cursor, since *feedCursor
 	if beforeStr != "" {
-		t, err := strconv.ParseInt(beforeStr, 10, 64)
-		if err == nil {
-			beforeTime = time.Unix(t, 0)
+		c, err := decodeFeedCursor(beforeStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		cursor = &c
+	}
+	if sinceStr != "" {
+		c, err := decodeFeedCursor(sinceStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid cursor")
+			return
 		}
-	} else {
-		beforeTime = time.Now()
+		since = &c
+	}
+
+	excludeSeen := r.URL.Query().Get("exclude_seen") == "true"
+
+	// chỉ cache trang đầu của feed đầy đủ; trang theo cursor hay theo author luôn build lại
+	cacheKey := feedCacheKey{Limit: limit, Rank: rank, ExcludeSeen: excludeSeen}
+	cacheable := h.CacheTTL > 0 && cursor == nil && since == nil && authorID == 0
+	if cacheable {
+		if resp, ok := h.cache.get(currentUserID, cacheKey); ok {
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+	}
+	gen := h.cache.generation()
+
+	authors := h.feedAuthors(currentUserID, authorID)
+	feeds, err := h.buildFeed(r.Context(), currentUserID, authors)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load feed")
+		return
 	}
+	if rank == FeedRankTop {
+		feeds = rankTop(feeds, time.Now().Add(-topFeedWindow))
+	}
+	muted := WordListModerator{Words: h.mutedWords(currentUserID)}
 
-	// Lọc feed theo before timestamp
+	// Lọc feed theo cursor (created_at, post_id)
 	result := []FeedItem{}
-	for _, f := range h.feeds {
-		created, _ := time.Parse(time.RFC3339, f.CreatedAt)
-		if created.Before(beforeTime) || beforeStr == "" {
-			result = append(result, f)
-			if len(result) >= limit {
-				break
+	for _, f := range feeds {
+		if excludeSeen && h.isSeen(currentUserID, f.PostID) {
+			continue
+		}
+		if len(muted.Words) > 0 {
+			if ok, _ := muted.Check(r.Context(), f.Content); !ok {
+				continue
 			}
 		}
+		if cursor != nil && !cursor.after(f, rank) {
+			continue
+		}
+		// feed sắp mới nhất trước nên gặp item cũ hơn since là dừng
+		if since != nil && !since.newer(f) {
+			break
+		}
+		result = append(result, f)
+		if len(result) >= limit {
+			break
+		}
 	}
 
 	nextCursor := ""
 	if len(result) > 0 {
-		last := result[len(result)-1]
-		t, _ := time.Parse(time.RFC3339, last.CreatedAt)
-		nextCursor = strconv.FormatInt(t.Unix(), 10)
+		nextCursor = encodeFeedCursor(result[len(result)-1])
 	}
 
-	json.NewEncoder(w).Encode(FeedResponse{
+	resp := FeedResponse{
 		Feeds:      result,
 		NextCursor: nextCursor,
+		Limit:      limit,
+	}
+	if cacheable {
+		h.cache.put(currentUserID, cacheKey, authors, resp, h.CacheTTL, gen)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// @Summary Mark Feed Posts Seen
+// @Description Record post IDs the client has displayed; GET /feeds?exclude_seen=true skips them
+// @Tags feeds
+// @Accept json
+// @Security BearerAuth
+// @Param body body []int true "Post IDs"
+// @Success 204 "No Content"
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Router /feeds/seen [post]
+func (h *FeedsHandler) MarkSeen(w http.ResponseWriter, r *http.Request) {
+	var postIDs []int
+	if err := decodeJSON(w, r, &postIDs, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(postIDs) > maxSeenBatch {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d post IDs per request", maxSeenBatch))
+		return
+	}
+	for _, id := range postIDs {
+		if id <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid post ID")
+			return
+		}
+	}
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	h.mu.Lock()
+	if h.seen == nil {
+		h.seen = make(map[int]map[int]bool)
+	}
+	if h.seen[currentUserID] == nil {
+		h.seen[currentUserID] = make(map[int]bool)
+	}
+	for _, id := range postIDs {
+		h.seen[currentUserID][id] = true
+	}
+	h.mu.Unlock()
+	h.invalidateFeed(currentUserID)
+
+	writeNoContent(w)
+}
+
+// isSeen cho biết userID đã đánh dấu postID là đã xem chưa
+func (h *FeedsHandler) isSeen(userID, postID int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.seen[userID][postID]
+}
+
+// feedAuthors trả về những người có post nằm trong feed của userID: userID và mọi người
+// userID follow, hoặc chỉ authorID khi authorID khác 0
+func (h *FeedsHandler) feedAuthors(userID, authorID int) []int {
+	if authorID != 0 {
+		return []int{authorID}
+	}
+	return append([]int{userID}, h.Follows.followingIDs(userID)...)
+}
+
+// buildFeed gom các post của authorIDs mà userID được thấy, mới nhất trước;
+// mỗi post chỉ xuất hiện một lần dù nhiều nguồn cùng trả về
+func (h *FeedsHandler) buildFeed(ctx context.Context, userID int, authorIDs []int) ([]FeedItem, error) {
+	feeds := []FeedItem{}
+	for _, authorID := range authorIDs {
+		posts, err := h.Posts.ListUserPosts(ctx, authorID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range posts {
+			if p.IsDeleted || !canSeePost(h.Follows, userID, p) || h.Blocks.isBlocked(userID, p.UserID) {
+				continue
+			}
+			feeds = append(feeds, h.toFeedItem(p, userID))
+		}
+	}
+
+	sort.Slice(feeds, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339, feeds[i].CreatedAt)
+		tj, _ := time.Parse(time.RFC3339, feeds[j].CreatedAt)
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return feeds[i].PostID > feeds[j].PostID
+	})
+	return dedupeFeed(feeds), nil
+}
+
+// dedupeFeed bỏ các post_id lặp, giữ lần xuất hiện đầu tiên (mới nhất); chạy trước
+// limit để một trang không bị thiếu item vì trùng lặp
+func dedupeFeed(feeds []FeedItem) []FeedItem {
+	seen := make(map[int]bool, len(feeds))
+	unique := feeds[:0]
+	for _, f := range feeds {
+		if seen[f.PostID] {
+			continue
+		}
+		seen[f.PostID] = true
+		unique = append(unique, f)
+	}
+	return unique
+}
+
+// feedScore là điểm tương tác dùng cho rank=top
+func feedScore(f FeedItem) int {
+	return f.LikeCount*2 + f.CommentCount
+}
+
+// rankTop giữ các item tạo từ cutoff trở đi, điểm cao nhất trước; bằng điểm thì
+// post_id mới hơn đứng trước để thứ tự ổn định giữa các request
+func rankTop(feeds []FeedItem, cutoff time.Time) []FeedItem {
+	ranked := []FeedItem{}
+	for _, f := range feeds {
+		if t, err := time.Parse(time.RFC3339, f.CreatedAt); err == nil && !t.Before(cutoff) {
+			ranked = append(ranked, f)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		si, sj := feedScore(ranked[i]), feedScore(ranked[j])
+		if si != sj {
+			return si > sj
+		}
+		return ranked[i].PostID > ranked[j].PostID
 	})
+	return ranked
+}
+
+// toFeedItem bổ sung dữ liệu tương tác cho post theo góc nhìn của viewerID
+func (h *FeedsHandler) toFeedItem(p Post, viewerID int) FeedItem {
+	reactions := h.Reactions.reactionsFor(p.PostID)
+	counts := make(map[string]int)
+	for _, react := range reactions {
+		counts[react]++
+	}
+
+	item := FeedItem{
+		PostID:         p.PostID,
+		UserID:         p.UserID,
+		Content:        p.Content,
+		MediaURLs:      h.Media.urls(p.MediaIDs),
+		CreatedAt:      p.CreatedAt,
+		LikeCount:      counts["like"],
+		ReactionCounts: counts,
+		CommentCount:   h.Comments.commentCount(p.PostID),
+		IsLiked:        reactions[viewerID] == "like",
+	}
+	if h.Profiles != nil {
+		if author, ok := h.Profiles.profile(p.UserID); ok {
+			item.Username, item.Avatar = author.Username, author.Avatar
+		}
+	}
+	return item
+}
+
+// feedCursor đánh dấu item feed cuối cùng client đã nhận
+type feedCursor struct {
+	CreatedAt time.Time
+	PostID    int
+	Score     int // feedScore lúc tạo cursor, dùng cho rank=top
+}
+
+// encodeFeedCursor tạo cursor opaque trỏ tới item
+func encodeFeedCursor(item FeedItem) string {
+	raw := item.CreatedAt + "|" + strconv.Itoa(item.PostID) + "|" + strconv.Itoa(feedScore(item))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeFeedCursor đọc cursor do encodeFeedCursor tạo ra
+func decodeFeedCursor(s string) (feedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return feedCursor{}, err
+	}
+	// cursor cũ chỉ có created_at|post_id
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 2 && len(parts) != 3 {
+		return feedCursor{}, errors.New("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return feedCursor{}, err
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return feedCursor{}, err
+	}
+	c := feedCursor{CreatedAt: t, PostID: id}
+	if len(parts) == 3 {
+		if c.Score, err = strconv.Atoi(parts[2]); err != nil {
+			return feedCursor{}, err
+		}
+	}
+	return c, nil
+}
+
+// newer cho biết item có được tạo sau cursor không (bằng thời gian thì so post_id)
+func (c feedCursor) newer(item FeedItem) bool {
+	t, _ := time.Parse(time.RFC3339, item.CreatedAt)
+	if !t.Equal(c.CreatedAt) {
+		return t.After(c.CreatedAt)
+	}
+	return item.PostID > c.PostID
+}
+
+// after cho biết item có đứng sau cursor theo thứ tự của rank không
+func (c feedCursor) after(item FeedItem, rank string) bool {
+	if rank == FeedRankTop {
+		if s := feedScore(item); s != c.Score {
+			return s < c.Score
+		}
+		return item.PostID < c.PostID
+	}
+	t, _ := time.Parse(time.RFC3339, item.CreatedAt)
+	if !t.Equal(c.CreatedAt) {
+		return t.Before(c.CreatedAt)
+	}
+	return item.PostID < c.PostID
 }
@@ -0,0 +1,287 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFeedItemAuthorAndMedia(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]string{"avatar": "https://cdn.example.com/alice.png"})
+	postID := a.createPost(alice, map[string]any{"content": "look"}, "")
+	a.uploadImage(alice, postID)
+	a.expect(http.StatusCreated, "POST", "/users/1/follow", bob, nil)
+
+	var feed FeedResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/feeds", bob, nil), &feed)
+	if len(feed.Feeds) != 1 {
+		t.Fatalf("feed = %+v", feed)
+	}
+	item := feed.Feeds[0]
+	if item.Username != "alice" || item.Avatar != "https://cdn.example.com/alice.png" {
+		t.Fatalf("author = %q %q", item.Username, item.Avatar)
+	}
+	if len(item.MediaURLs) != 1 || item.MediaURLs[0] == "" {
+		t.Fatalf("media_urls = %v", item.MediaURLs)
+	}
+}
+
+// feed đọc GET /feeds?query của token
+func (a *testApp) feed(token, query string) FeedResponse {
+	a.t.Helper()
+	var resp FeedResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", "/feeds?"+query, token, nil), &resp)
+	return resp
+}
+
+// feedPostIDs lấy post_id của từng item theo thứ tự
+func feedPostIDs(items []FeedItem) []int {
+	ids := make([]int, 0, len(items))
+	for _, f := range items {
+		ids = append(ids, f.PostID)
+	}
+	return ids
+}
+
+func TestFeedFromFollows(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.feeds.CacheTTL = 0
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	a.expect(http.StatusCreated, "POST", "/users/1/follow", bob, nil)
+
+	followed := a.createPost(alice, map[string]any{"content": "from alice"}, "")
+	a.createPost(carol, map[string]any{"content": "from carol"}, "")
+	own := a.createPost(bob, map[string]any{"content": "from bob"}, "")
+	a.expect(http.StatusCreated, "POST", postPath(followed, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+	a.expect(http.StatusCreated, "POST", postPath(followed, "/comments"), carol, map[string]string{"content": "hi"})
+
+	feed := a.feed(bob, "")
+	if got := feedPostIDs(feed.Feeds); !slices.Equal(got, []int{own, followed}) {
+		t.Fatalf("feed = %v, want %v", got, []int{own, followed})
+	}
+	item := feed.Feeds[1]
+	if item.LikeCount != 1 || item.CommentCount != 1 || !item.IsLiked {
+		t.Fatalf("followed item = %+v", item)
+	}
+	if feed.Feeds[0].IsLiked {
+		t.Fatalf("own post marked liked: %+v", feed.Feeds[0])
+	}
+}
+
+func TestFeedCursorSameTimestamp(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	// mọi post cùng một created_at: cursor phải dựa thêm vào post_id
+	for i := 0; i < 5; i++ {
+		_, err := a.store.CreatePost(t.Context(), Post{UserID: aliceID, Content: "tick", CreatedAt: "2026-01-02T03:04:05Z", Status: PostPublished})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []int
+	query := "limit=2"
+	for page := 0; page < 5; page++ {
+		feed := a.feed(alice, query)
+		if len(feed.Feeds) == 0 {
+			break
+		}
+		got = append(got, feedPostIDs(feed.Feeds)...)
+		query = "limit=2&before=" + feed.NextCursor
+	}
+	if want := []int{5, 4, 3, 2, 1}; !slices.Equal(got, want) {
+		t.Fatalf("paged feed = %v, want %v", got, want)
+	}
+	a.expect(http.StatusBadRequest, "GET", "/feeds?before=not-a-cursor", alice, nil)
+}
+
+func TestFeedSince(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.feeds.CacheTTL = 0
+	_, alice := a.register("alice")
+	a.createPost(alice, map[string]any{"content": "old"}, "")
+	a.createPost(alice, map[string]any{"content": "seen"}, "")
+
+	// limit=1 nên next_cursor trỏ vào item mới nhất client đang có
+	newest := a.feed(alice, "limit=1").NextCursor
+	third := a.createPost(alice, map[string]any{"content": "new"}, "")
+	fourth := a.createPost(alice, map[string]any{"content": "newer"}, "")
+
+	if got := feedPostIDs(a.feed(alice, "since="+newest).Feeds); !slices.Equal(got, []int{fourth, third}) {
+		t.Fatalf("since feed = %v, want %v", got, []int{fourth, third})
+	}
+	latest := a.feed(alice, "limit=1").NextCursor
+	if got := a.feed(alice, "since="+latest).Feeds; len(got) != 0 {
+		t.Fatalf("nothing newer, got %v", feedPostIDs(got))
+	}
+
+	a.expect(http.StatusBadRequest, "GET", "/feeds?since="+newest+"&before="+newest, alice, nil)
+	a.expect(http.StatusBadRequest, "GET", "/feeds?since=not-a-cursor", alice, nil)
+	a.expect(http.StatusBadRequest, "GET", "/feeds?rank=top&since="+newest, alice, nil)
+}
+
+func TestFeedRank(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.feeds.CacheTTL = 0
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	a.expect(http.StatusCreated, "POST", "/users/1/follow", bob, nil)
+
+	liked := a.createPost(alice, map[string]any{"content": "liked"}, "")
+	commented := a.createPost(alice, map[string]any{"content": "commented"}, "")
+	quiet := a.createPost(alice, map[string]any{"content": "quiet"}, "")
+	alsoQuiet := a.createPost(alice, map[string]any{"content": "also quiet"}, "")
+	a.expect(http.StatusCreated, "POST", postPath(liked, "/reactions"), carol, map[string]string{"reaction_type": "like"})
+	a.expect(http.StatusCreated, "POST", postPath(commented, "/comments"), carol, map[string]string{"content": "hi"})
+
+	recent := []int{alsoQuiet, quiet, commented, liked}
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Equal(got, recent) {
+		t.Fatalf("default feed = %v, want %v", got, recent)
+	}
+	if got := feedPostIDs(a.feed(bob, "rank=recent").Feeds); !slices.Equal(got, recent) {
+		t.Fatalf("rank=recent = %v, want %v", got, recent)
+	}
+	// like tính 2 điểm, comment 1 điểm; bằng điểm thì post_id lớn hơn trước
+	top := []int{liked, commented, alsoQuiet, quiet}
+	if got := feedPostIDs(a.feed(bob, "rank=top").Feeds); !slices.Equal(got, top) {
+		t.Fatalf("rank=top = %v, want %v", got, top)
+	}
+	a.expect(http.StatusBadRequest, "GET", "/feeds?rank=popular", bob, nil)
+}
+
+func TestRankTopWindow(t *testing.T) {
+	now := time.Now()
+	old := FeedItem{PostID: 1, LikeCount: 10, CreatedAt: formatRFC3339(now.Add(-2 * topFeedWindow))}
+	fresh := FeedItem{PostID: 2, CommentCount: 1, CreatedAt: formatRFC3339(now)}
+	if got := feedPostIDs(rankTop([]FeedItem{fresh, old}, now.Add(-topFeedWindow))); !slices.Equal(got, []int{2}) {
+		t.Fatalf("rankTop = %v, want only the post inside the window", got)
+	}
+}
+
+func TestFeedExcludeSeen(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	a.expect(http.StatusCreated, "POST", "/users/1/follow", bob, nil)
+	first := a.createPost(alice, map[string]any{"content": "one"}, "")
+	second := a.createPost(alice, map[string]any{"content": "two"}, "")
+	third := a.createPost(alice, map[string]any{"content": "three"}, "")
+
+	// lấy feed trước để cache có dữ liệu: đánh dấu đã xem phải làm mới cache
+	if got := feedPostIDs(a.feed(bob, "exclude_seen=true").Feeds); !slices.Equal(got, []int{third, second, first}) {
+		t.Fatalf("feed before seen = %v", got)
+	}
+	a.expect(http.StatusNoContent, "POST", "/feeds/seen", bob, []int{third, first})
+
+	if got := feedPostIDs(a.feed(bob, "exclude_seen=true").Feeds); !slices.Equal(got, []int{second}) {
+		t.Fatalf("exclude_seen = %v, want %v", got, []int{second})
+	}
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Equal(got, []int{third, second, first}) {
+		t.Fatalf("feed without flag = %v", got)
+	}
+	// đã xem là theo từng user
+	if got := feedPostIDs(a.feed(alice, "exclude_seen=true").Feeds); !slices.Equal(got, []int{third, second, first}) {
+		t.Fatalf("alice's feed = %v", got)
+	}
+
+	a.expect(http.StatusUnauthorized, "POST", "/feeds/seen", "", []int{second})
+	a.expect(http.StatusBadRequest, "POST", "/feeds/seen", bob, []int{0})
+	a.expect(http.StatusBadRequest, "POST", "/feeds/seen", bob, make([]int, maxSeenBatch+1))
+}
+
+func TestFeedReactionCounts(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	_, dave := a.register("dave")
+	postID := a.createPost(alice, map[string]any{"content": "react"}, "")
+	quiet := a.createPost(alice, map[string]any{"content": "nobody"}, "")
+	for token, typ := range map[string]string{bob: "like", carol: "like", dave: "love"} {
+		a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), token, map[string]string{"reaction_type": typ})
+	}
+
+	items := a.feed(alice, "").Feeds
+	if len(items) != 2 || items[1].PostID != postID {
+		t.Fatalf("feed = %v", feedPostIDs(items))
+	}
+	item := items[1]
+	if item.LikeCount != 2 || len(item.ReactionCounts) != 2 || item.ReactionCounts["like"] != 2 || item.ReactionCounts["love"] != 1 {
+		t.Fatalf("item = like %d, reactions %v", item.LikeCount, item.ReactionCounts)
+	}
+	if items[0].PostID != quiet || items[0].LikeCount != 0 || len(items[0].ReactionCounts) != 0 {
+		t.Fatalf("quiet item = %+v", items[0])
+	}
+}
+
+func TestFeedDeduplicatesPosts(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.feeds.CacheTTL = 0
+	bobID, bob := a.register("bob")
+	first := a.createPost(bob, map[string]any{"content": "one"}, "")
+	second := a.createPost(bob, map[string]any{"content": "two"}, "")
+	third := a.createPost(bob, map[string]any{"content": "three"}, "")
+
+	// cạnh tự follow: bài của bob đến từ cả nguồn "của mình" lẫn nguồn "đang follow"
+	a.follows.mu.Lock()
+	a.follows.following[bobID] = map[int]Follow{bobID: {UserID: bobID, Username: "bob"}}
+	a.follows.mu.Unlock()
+
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Equal(got, []int{third, second, first}) {
+		t.Fatalf("feed = %v, want each post once", got)
+	}
+	// limit tính trên tập đã lọc trùng
+	if got := feedPostIDs(a.feed(bob, "limit=2").Feeds); !slices.Equal(got, []int{third, second}) {
+		t.Fatalf("feed with limit=2 = %v", got)
+	}
+}
+
+func TestFeedAuthorFilter(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+	carolID, carol := a.register("carol")
+	_, dave := a.register("dave")
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", carolID), bob, nil)
+
+	first := a.createPost(alice, map[string]any{"content": "one"}, "")
+	second := a.createPost(alice, map[string]any{"content": "two"}, "")
+	a.createPost(alice, map[string]any{"content": "mine", "visibility": "private"}, "")
+	a.createPost(carol, map[string]any{"content": "carol"}, "")
+	a.expect(http.StatusCreated, "POST", postPath(first, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+	a.expect(http.StatusCreated, "POST", postPath(first, "/comments"), carol, map[string]string{"content": "hi"})
+
+	// bob không follow alice nhưng vẫn xem được feed theo tác giả của alice; trang đầu có cache
+	a.feed(bob, "")
+	byAlice := fmt.Sprintf("author_id=%d", aliceID)
+	feed := a.feed(bob, byAlice)
+	if got := feedPostIDs(feed.Feeds); !slices.Equal(got, []int{second, first}) {
+		t.Fatalf("alice's feed = %v, want %v", got, []int{second, first})
+	}
+	if item := feed.Feeds[1]; item.LikeCount != 1 || item.CommentCount != 1 || !item.IsLiked || item.Username != "alice" {
+		t.Fatalf("item = %+v", item)
+	}
+
+	page := a.feed(bob, byAlice+"&limit=1")
+	if got := feedPostIDs(a.feed(bob, byAlice+"&limit=1&before="+page.NextCursor).Feeds); !slices.Equal(got, []int{first}) {
+		t.Fatalf("second page = %v", got)
+	}
+
+	// bị chặn hoặc user không có post: feed rỗng, không 404
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/block", aliceID), dave, nil)
+	if got := a.feed(dave, byAlice).Feeds; len(got) != 0 {
+		t.Fatalf("blocked author's feed = %v", feedPostIDs(got))
+	}
+	if got := a.feed(bob, "author_id=999").Feeds; len(got) != 0 {
+		t.Fatalf("unknown author's feed = %v", feedPostIDs(got))
+	}
+	a.expect(http.StatusBadRequest, "GET", "/feeds?author_id=abc", bob, nil)
+}
@@ -0,0 +1,113 @@
+package apis
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultFeedCacheTTL là thời gian giữ trang đầu feed của một user khi không cấu hình;
+// ngắn vì số like/comment trong feed không làm cache bị xoá
+const DefaultFeedCacheTTL = 10 * time.Second
+
+// feedCacheKey là các tham số quyết định nội dung trang đầu feed
+type feedCacheKey struct {
+	Limit       int
+	Rank        string
+	ExcludeSeen bool
+}
+
+// cachedFeed là trang đầu feed của một user cùng thời điểm hết hạn
+type cachedFeed struct {
+	key     feedCacheKey
+	authors []int // user và những người user follow lúc build, dùng để xoá theo tác giả
+	resp    FeedResponse
+	expires time.Time
+}
+
+// feedCache là cache có TTL của trang đầu feed theo user_id, mỗi user một trang;
+// giá trị zero dùng được ngay
+type feedCache struct {
+	mu      sync.Mutex
+	entries map[int]cachedFeed
+	gen     uint64 // tăng mỗi lần invalidate
+}
+
+// get trả về trang đã cache của userID nếu được build cho key và chưa hết hạn
+func (c *feedCache) get(userID int, key feedCacheKey) (FeedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[userID]
+	if !ok || e.key != key {
+		return FeedResponse{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, userID)
+		return FeedResponse{}, false
+	}
+	return e.resp, true
+}
+
+// generation trả về giá trị để truyền cho put; lấy trước khi build trang
+func (c *feedCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gen
+}
+
+// put lưu resp trong ttl, thay trang đã cache của userID với tham số khác.
+// Không làm gì nếu đã có invalidate từ sau gen, vì resp có thể đã cũ
+func (c *feedCache) put(userID int, key feedCacheKey, authors []int, resp FeedResponse, ttl time.Duration, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gen != gen {
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[int]cachedFeed)
+	}
+	c.entries[userID] = cachedFeed{key: key, authors: authors, resp: resp, expires: time.Now().Add(ttl)}
+}
+
+// invalidate bỏ trang của userID để lần đọc sau build lại
+func (c *feedCache) invalidate(userID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, userID)
+	c.gen++
+}
+
+// invalidateAuthor bỏ mọi trang được build từ post của authorID
+func (c *feedCache) invalidateAuthor(authorID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for userID, e := range c.entries {
+		if slices.Contains(e.authors, authorID) {
+			delete(c.entries, userID)
+		}
+	}
+	c.gen++
+}
+
+// invalidateFeed bỏ trang feed đã cache của userID, vd sau khi user follow ai đó;
+// handler nil thì không có gì để bỏ
+func (h *FeedsHandler) invalidateFeed(userID int) {
+	if h == nil {
+		return
+	}
+	h.cache.invalidate(userID)
+}
+
+// invalidateAuthor bỏ các trang feed đã cache có thể chứa post của authorID;
+// gọi sau khi authorID tạo, sửa hoặc xoá post
+func (h *FeedsHandler) invalidateAuthor(authorID int) {
+	if h == nil {
+		return
+	}
+	h.cache.invalidateAuthor(authorID)
+}
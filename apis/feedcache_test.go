@@ -0,0 +1,84 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// postBehindCache tạo post thẳng trong store, không qua handler nên không xoá cache feed
+func (a *testApp) postBehindCache(userID int, content string) int {
+	a.t.Helper()
+	id, err := a.store.CreatePost(a.t.Context(), Post{UserID: userID, Content: content, CreatedAt: nowRFC3339(), Status: PostPublished})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return id
+}
+
+func TestFeedCache(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.feeds.CacheTTL = time.Minute
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+	carolID, carol := a.register("carol")
+	daveID, _ := a.register("dave")
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+
+	first := a.createPost(alice, map[string]any{"content": "one"}, "")
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Equal(got, []int{first}) {
+		t.Fatalf("first read = %v", got)
+	}
+
+	// lần đọc thứ hai trong TTL lấy từ cache
+	hidden := a.postBehindCache(aliceID, "behind")
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Equal(got, []int{first}) {
+		t.Fatalf("cached read = %v, want the cached page", got)
+	}
+
+	// post của người bob không follow không xoá cache của bob
+	a.createPost(carol, map[string]any{"content": "carol"}, "")
+	if got := feedPostIDs(a.feed(bob, "").Feeds); slices.Contains(got, hidden) {
+		t.Fatalf("unrelated post invalidated bob's feed: %v", got)
+	}
+
+	// post mới của người bob follow xoá cache
+	second := a.createPost(alice, map[string]any{"content": "two"}, "")
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Equal(got, []int{second, hidden, first}) {
+		t.Fatalf("after followed post = %v", got)
+	}
+
+	// follow thêm cũng xoá cache
+	carolPost := a.postBehindCache(carolID, "carol again")
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", carolID), bob, nil)
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Contains(got, carolPost) {
+		t.Fatalf("after follow = %v", got)
+	}
+
+	// CacheTTL = 0 tắt cache
+	a.feeds.CacheTTL = 0
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", daveID), bob, nil)
+	a.feed(bob, "")
+	davePost := a.postBehindCache(daveID, "uncached")
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Contains(got, davePost) {
+		t.Fatalf("with cache disabled = %v", got)
+	}
+}
+
+func TestFeedCacheExpires(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.feeds.CacheTTL = 20 * time.Millisecond
+	aliceID, alice := a.register("alice")
+
+	a.feed(alice, "")
+	postID := a.postBehindCache(aliceID, "later")
+	if got := a.feed(alice, "").Feeds; len(got) != 0 {
+		t.Fatalf("read within TTL = %v", feedPostIDs(got))
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := feedPostIDs(a.feed(alice, "").Feeds); !slices.Equal(got, []int{postID}) {
+		t.Fatalf("read after TTL = %v", got)
+	}
+}
@@ -1,8 +1,9 @@
 package apis
 
 import (
-	"encoding/json"
+	"encoding/base64"
 	"net/http"
+	"sort"
 	"strconv"
 	"sync"
 
@@ -22,32 +23,64 @@ type FollowResponse struct {
 	Following []Follow `json:"following,omitempty"`
 	Total     int      `json:"total,omitempty"`
 	Message   string   `json:"message,omitempty"`
-	Error     string   `json:"error,omitempty"`
+}
+
+// FollowersResponse is one page of followers; empty lists are kept so Total 0 is explicit
+type FollowersResponse struct {
+	Followers  []Follow `json:"followers"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	PageMeta
+}
+
+// FollowingResponse is one page of followed users
+type FollowingResponse struct {
+	Following  []Follow `json:"following"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	PageMeta
+}
+
+// FollowStatusResponse tells whether the current user follows a target
+type FollowStatusResponse struct {
+	Following bool `json:"following"`
+}
+
+// FollowCountsResponse holds only the sizes of a user's follow lists
+type FollowCountsResponse struct {
+	Followers int `json:"followers"`
+	Following int `json:"following"`
 }
 
 // FollowsHandler handles follow endpoints
 type FollowsHandler struct {
 	mu        sync.Mutex
-	followers map[int][]Follow // key = user_id
-	following map[int][]Follow // key = user_id
+	followers map[int]map[int]Follow // target -> follower -> edge
+	following map[int]map[int]Follow // follower -> target -> edge
+	Tokens    *TokenService
+
+	Notifications *NotificationHandler // optional
+	Profiles      *ProfileHandler      // known users; without it only users with follow entries exist
+	Blocks        *BlocksHandler       // blocked pairs cannot follow each other
+	Feeds         *FeedsHandler        // feed đã cache của follower phải build lại khi follow đổi, optional
 }
 
 // NewFollowsHandler constructor
 func NewFollowsHandler() *FollowsHandler {
 	return &FollowsHandler{
-		followers: make(map[int][]Follow),
-		following: make(map[int][]Follow),
+		followers: make(map[int]map[int]Follow),
+		following: make(map[int]map[int]Follow),
 	}
 }
 
 // RegisterRoutes register routes
 func (h *FollowsHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/me/followers", h.GetMyFollowers).Methods("GET")
-	router.HandleFunc("/me/following", h.GetMyFollowing).Methods("GET")
+	router.Handle("/me/followers", h.Tokens.RequireAuth(http.HandlerFunc(h.GetMyFollowers))).Methods("GET")
+	router.Handle("/me/following", h.Tokens.RequireAuth(http.HandlerFunc(h.GetMyFollowing))).Methods("GET")
 	router.HandleFunc("/users/{user_id}/followers", h.GetFollowers).Methods("GET")
 	router.HandleFunc("/users/{user_id}/following", h.GetFollowing).Methods("GET")
-	router.HandleFunc("/users/{target_user_id}/follow", h.FollowUser).Methods("POST")
-	router.HandleFunc("/users/{target_user_id}/follow", h.UnfollowUser).Methods("DELETE")
+	router.HandleFunc("/users/{user_id}/follow/counts", h.GetFollowCounts).Methods("GET")
+	router.Handle("/users/{target_user_id}/follow/status", h.Tokens.RequireAuth(http.HandlerFunc(h.GetFollowStatus))).Methods("GET")
+	router.Handle("/users/{target_user_id}/follow", h.Tokens.RequireAuth(http.HandlerFunc(h.FollowUser))).Methods("POST")
+	router.Handle("/users/{target_user_id}/follow", h.Tokens.RequireAuth(http.HandlerFunc(h.UnfollowUser))).Methods("DELETE")
 }
 
 // @Summary Get My Followers
@@ -55,15 +88,17 @@ func (h *FollowsHandler) RegisterRoutes(router *mux.Router) {
 // @Tags follows
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Bearer token"
-// @Param offset query int false "Offset"
-// @Param limit query int false "Limit"
-// @Success 200 {object} FollowResponse
-// @Failure 401 {object} FollowResponse
+// @Security BearerAuth
+// @Param after query string false "Opaque cursor from next_cursor"
+// @Param offset query int false "Offset (deprecated, use after)"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Success 200 {object} FollowersResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
 // @Router /me/followers [get]
 func (h *FollowsHandler) GetMyFollowers(w http.ResponseWriter, r *http.Request) {
-	// TODO: implement: giả lập userID = 1
-	h.GetFollowersByUserID(w, 1)
+	userID, _ := UserIDFromContext(r.Context())
+	h.GetFollowersByUserID(w, r, userID)
 }
 
 // @Summary Get My Following
@@ -71,15 +106,17 @@ func (h *FollowsHandler) GetMyFollowers(w http.ResponseWriter, r *http.Request)
 // @Tags follows
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Bearer token"
-// @Param offset query int false "Offset"
-// @Param limit query int false "Limit"
-// @Success 200 {object} FollowResponse
-// @Failure 401 {object} FollowResponse
+// @Security BearerAuth
+// @Param after query string false "Opaque cursor from next_cursor"
+// @Param offset query int false "Offset (deprecated, use after)"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Success 200 {object} FollowingResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
 // @Router /me/following [get]
 func (h *FollowsHandler) GetMyFollowing(w http.ResponseWriter, r *http.Request) {
-	// TODO: implement: giả lập userID = 1
-	h.GetFollowingByUserID(w, 1)
+	userID, _ := UserIDFromContext(r.Context())
+	h.GetFollowingByUserID(w, r, userID)
 }
 
 // @Summary Get Followers
@@ -89,13 +126,17 @@ func (h *FollowsHandler) GetMyFollowing(w http.ResponseWriter, r *http.Request)
 // @Produce json
 // @Param user_id path int true "User ID"
 // @Param Authorization header string false "Bearer token"
-// @Success 200 {object} FollowResponse
-// @Failure 404 {object} FollowResponse
+// @Param after query string false "Opaque cursor from next_cursor"
+// @Param offset query int false "Offset (deprecated, use after)"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Success 200 {object} FollowersResponse
+// @Failure 400 {object} APIError
+// @Failure 404 {object} APIError
 // @Router /users/{user_id}/followers [get]
 func (h *FollowsHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, _ := strconv.Atoi(vars["user_id"])
-	h.GetFollowersByUserID(w, userID)
+	h.GetFollowersByUserID(w, r, userID)
 }
 
 // @Summary Get Following
@@ -105,82 +146,279 @@ func (h *FollowsHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param user_id path int true "User ID"
 // @Param Authorization header string false "Bearer token"
-// @Success 200 {object} FollowResponse
-// @Failure 404 {object} FollowResponse
+// @Param after query string false "Opaque cursor from next_cursor"
+// @Param offset query int false "Offset (deprecated, use after)"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Success 200 {object} FollowingResponse
+// @Failure 400 {object} APIError
+// @Failure 404 {object} APIError
 // @Router /users/{user_id}/following [get]
 func (h *FollowsHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, _ := strconv.Atoi(vars["user_id"])
-	h.GetFollowingByUserID(w, userID)
+	h.GetFollowingByUserID(w, r, userID)
 }
 
-func (h *FollowsHandler) GetFollowersByUserID(w http.ResponseWriter, userID int) {
+// GetFollowersByUserID writes one page of a user's followers
+func (h *FollowsHandler) GetFollowersByUserID(w http.ResponseWriter, r *http.Request, userID int) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	followers, ok := h.followers[userID]
+	if !ok && !h.userExists(userID) {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	page, ok := followsPage(w, r, sortedFollows(followers))
 	if !ok {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(FollowResponse{Error: "User not found"})
 		return
 	}
-	json.NewEncoder(w).Encode(FollowResponse{
-		Followers: followers,
-		Total:     len(followers),
+	writeJSON(w, http.StatusOK, FollowersResponse{
+		Followers:  page.items,
+		NextCursor: page.nextCursor,
+		PageMeta:   page.meta,
 	})
 }
 
-func (h *FollowsHandler) GetFollowingByUserID(w http.ResponseWriter, userID int) {
+// GetFollowingByUserID writes one page of the users a user follows
+func (h *FollowsHandler) GetFollowingByUserID(w http.ResponseWriter, r *http.Request, userID int) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	following, ok := h.following[userID]
+	if !ok && !h.userExists(userID) {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	page, ok := followsPage(w, r, sortedFollows(following))
 	if !ok {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(FollowResponse{Error: "User not found"})
 		return
 	}
-	json.NewEncoder(w).Encode(FollowResponse{
-		Following: following,
-		Total:     len(following),
+	writeJSON(w, http.StatusOK, FollowingResponse{
+		Following:  page.items,
+		NextCursor: page.nextCursor,
+		PageMeta:   page.meta,
 	})
 }
 
+// followsPageResult is one page of a follow list
+type followsPageResult struct {
+	items      []Follow
+	nextCursor string
+	meta       PageMeta
+}
+
+// followsPage cuts one page from a list sorted by user_id. With ?after=<cursor> the page
+// starts right after that user_id, so follows added or removed between requests
+// never shift it; otherwise offset is used. Writes 400 and returns false for a bad cursor
+func followsPage(w http.ResponseWriter, r *http.Request, sorted []Follow) (followsPageResult, bool) {
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return followsPageResult{}, false
+	}
+	if after := r.URL.Query().Get("after"); after != "" {
+		afterID, err := decodeFollowsCursor(after)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid cursor")
+			return followsPageResult{}, false
+		}
+		offset = sort.Search(len(sorted), func(i int) bool { return sorted[i].UserID > afterID })
+	}
+
+	start, end := pageBounds(len(sorted), offset, limit)
+	page := followsPageResult{
+		items: sorted[start:end],
+		meta:  newPageMeta(len(sorted), start, end, limit),
+	}
+	if page.meta.HasMore && end > start {
+		page.nextCursor = encodeFollowsCursor(sorted[end-1].UserID)
+	}
+	return page, true
+}
+
+// encodeFollowsCursor builds an opaque cursor pointing at userID
+func encodeFollowsCursor(userID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(userID)))
+}
+
+// decodeFollowsCursor parses a cursor produced by encodeFollowsCursor
+func decodeFollowsCursor(s string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(raw))
+}
+
+// @Summary Get Follow Counts
+// @Description Get the number of followers and followed users without the lists
+// @Tags follows
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Success 200 {object} FollowCountsResponse
+// @Failure 404 {object} APIError
+// @Router /users/{user_id}/follow/counts [get]
+func (h *FollowsHandler) GetFollowCounts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, _ := strconv.Atoi(vars["user_id"])
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	followers, hasFollowers := h.followers[userID]
+	following, hasFollowing := h.following[userID]
+	if !hasFollowers && !hasFollowing && !h.userExists(userID) {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, FollowCountsResponse{
+		Followers: len(followers),
+		Following: len(following),
+	})
+}
+
+// @Summary Get Follow Status
+// @Description Check whether the current user follows a user
+// @Tags follows
+// @Accept json
+// @Produce json
+// @Param target_user_id path int true "Target User ID"
+// @Security BearerAuth
+// @Success 200 {object} FollowStatusResponse
+// @Failure 401 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /users/{target_user_id}/follow/status [get]
+func (h *FollowsHandler) GetFollowStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	targetID, _ := strconv.Atoi(vars["target_user_id"])
+
+	currentID, _ := UserIDFromContext(r.Context())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, hasFollowers := h.followers[targetID]
+	_, hasFollowing := h.following[targetID]
+	if !hasFollowers && !hasFollowing && !h.userExists(targetID) {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	_, following := h.following[currentID][targetID]
+	writeJSON(w, http.StatusOK, FollowStatusResponse{Following: following})
+}
+
+// followCounts returns how many users follow userID and how many it follows; 0, 0 on a nil handler
+func (h *FollowsHandler) followCounts(userID int) (followers, following int) {
+	if h == nil {
+		return 0, 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.followers[userID]), len(h.following[userID])
+}
+
+// isFollowing reports whether followerID follows targetID
+func (h *FollowsHandler) isFollowing(followerID, targetID int) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, ok := h.following[followerID][targetID]
+	return ok
+}
+
+// followEntry builds a Follow from the user's profile, falling back to a placeholder name
+func (h *FollowsHandler) followEntry(userID int) Follow {
+	if h.Profiles != nil {
+		if p, ok := h.Profiles.profile(userID); ok {
+			return Follow{UserID: userID, Username: p.Username, Avatar: p.Avatar}
+		}
+	}
+	return Follow{UserID: userID, Username: "user" + strconv.Itoa(userID)}
+}
+
+// userExists asks the profile store whether userID is a real user
+func (h *FollowsHandler) userExists(userID int) bool {
+	if h.Profiles == nil {
+		return false
+	}
+	_, ok := h.Profiles.profile(userID)
+	return ok
+}
+
+// sortedFollows lists the edges ordered by UserID so pages are stable
+func sortedFollows(edges map[int]Follow) []Follow {
+	sorted := make([]Follow, 0, len(edges))
+	for _, f := range edges {
+		sorted = append(sorted, f)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].UserID < sorted[j].UserID
+	})
+	return sorted
+}
+
 // @Summary Follow User
 // @Description Follow a user
 // @Tags follows
 // @Accept json
 // @Produce json
 // @Param target_user_id path int true "Target User ID"
-// @Param Authorization header string true "Bearer token"
+// @Security BearerAuth
 // @Success 201 {object} FollowResponse
-// @Failure 400 {object} FollowResponse
+// @Failure 400 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
 // @Router /users/{target_user_id}/follow [post]
 func (h *FollowsHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	targetID, _ := strconv.Atoi(vars["target_user_id"])
 
-	// TODO: giả lập userID = 1
-	currentID := 1
+	currentID, _ := UserIDFromContext(r.Context())
+
+	if targetID == currentID {
+		writeJSONError(w, http.StatusBadRequest, "Cannot follow yourself")
+		return
+	}
+	if !h.userExists(targetID) {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if h.Blocks.isBlocked(currentID, targetID) {
+		writeJSONError(w, http.StatusForbidden, "Cannot follow this user")
+		return
+	}
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	// kiểm tra đã follow chưa
-	for _, u := range h.following[currentID] {
-		if u.UserID == targetID {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(FollowResponse{Error: "Already following"})
-			return
-		}
+	// kiểm tra đã follow chưa; check và ghi cùng một lần giữ lock nên request trùng chỉ tạo một edge
+	if _, ok := h.following[currentID][targetID]; ok {
+		writeJSONError(w, http.StatusBadRequest, "Already following")
+		return
 	}
 
-	user := Follow{UserID: targetID, Username: "user" + strconv.Itoa(targetID)}
-	h.following[currentID] = append(h.following[currentID], user)
-	h.followers[targetID] = append(h.followers[targetID], Follow{UserID: currentID, Username: "user1"})
+	if h.following == nil {
+		h.following = make(map[int]map[int]Follow)
+		h.followers = make(map[int]map[int]Follow)
+	}
+	if h.following[currentID] == nil {
+		h.following[currentID] = make(map[int]Follow)
+	}
+	if h.followers[targetID] == nil {
+		h.followers[targetID] = make(map[int]Follow)
+	}
+	h.following[currentID][targetID] = h.followEntry(targetID)
+	h.followers[targetID][currentID] = h.followEntry(currentID)
+	h.Feeds.invalidateFeed(currentID)
+	h.Notifications.notify(targetID, currentID, NotifFollow, 0)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(FollowResponse{Message: "Followed"})
+	writeJSON(w, http.StatusCreated, FollowResponse{Message: "Followed"})
 }
 
 // @Summary Unfollow User
@@ -189,43 +427,53 @@ func (h *FollowsHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param target_user_id path int true "Target User ID"
-// @Param Authorization header string true "Bearer token"
-// @Success 200 {object} FollowResponse
-// @Failure 403 {object} FollowResponse
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 403 {object} APIError
 // @Router /users/{target_user_id}/follow [delete]
 func (h *FollowsHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	targetID, _ := strconv.Atoi(vars["target_user_id"])
 
-	currentID := 1
+	currentID, _ := UserIDFromContext(r.Context())
 
+	if !h.unfollow(currentID, targetID) {
+		writeJSONError(w, http.StatusForbidden, "Unauthorized")
+		return
+	}
+
+	writeNoContent(w)
+}
+
+// unfollow removes the followerID -> targetID edge from both maps and reports
+// whether it existed; a nil handler has nothing to remove
+func (h *FollowsHandler) unfollow(followerID, targetID int) bool {
+	if h == nil {
+		return false
+	}
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	followingList := h.following[currentID]
-	found := false
-	for i, u := range followingList {
-		if u.UserID == targetID {
-			// remove from slice
-			h.following[currentID] = append(followingList[:i], followingList[i+1:]...)
-			found = true
-			break
-		}
-	}
-	if !found {
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(FollowResponse{Error: "Unauthorized"})
-		return
+	if _, ok := h.following[followerID][targetID]; !ok {
+		return false
 	}
+	delete(h.following[followerID], targetID)
+	delete(h.followers[targetID], followerID)
+	h.Feeds.invalidateFeed(followerID)
+	return true
+}
 
-	// remove from followers of target
-	followerList := h.followers[targetID]
-	for i, u := range followerList {
-		if u.UserID == currentID {
-			h.followers[targetID] = append(followerList[:i], followerList[i+1:]...)
-			break
-		}
+// followingIDs returns the IDs of users that userID follows
+func (h *FollowsHandler) followingIDs(userID int) []int {
+	if h == nil {
+		return nil
 	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	json.NewEncoder(w).Encode(FollowResponse{Message: "Unfollowed"})
+	ids := make([]int, 0, len(h.following[userID]))
+	for id := range h.following[userID] {
+		ids = append(ids, id)
+	}
+	return ids
 }
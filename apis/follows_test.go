@@ -0,0 +1,240 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestFollowUser(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	a.register("bob")
+
+	a.expect(http.StatusNotFound, "POST", "/users/99/follow", alice, nil)
+	a.expect(http.StatusBadRequest, "POST", "/users/1/follow", alice, nil)
+	a.expect(http.StatusCreated, "POST", "/users/2/follow", alice, nil)
+	a.expect(http.StatusBadRequest, "POST", "/users/2/follow", alice, nil)
+
+	var counts FollowCountsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/users/2/follow/counts", "", nil), &counts)
+	if counts.Followers != 1 {
+		t.Fatalf("counts = %+v", counts)
+	}
+	// follow user không tồn tại không để lại edge nào
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/users/1/follow/counts", "", nil), &counts)
+	if counts.Following != 1 {
+		t.Fatalf("counts = %+v", counts)
+	}
+}
+
+// followIDs trả về user_id của từng edge theo thứ tự
+func followIDs(follows []Follow) []int {
+	ids := make([]int, len(follows))
+	for i, f := range follows {
+		ids[i] = f.UserID
+	}
+	return ids
+}
+
+func TestFollowersPaging(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	// follow theo thứ tự ngược để chắc danh sách được sắp theo user_id
+	for _, name := range []string{"erin", "dave", "carol", "bob"} {
+		id, token := a.register(name)
+		a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), token, nil)
+		a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", id), alice, nil)
+	}
+
+	var followers FollowersResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/followers?offset=1&limit=2", aliceID), "", nil), &followers)
+	if got := followIDs(followers.Followers); !slices.Equal(got, []int{3, 4}) {
+		t.Fatalf("followers page = %v, want [3 4]", got)
+	}
+	if followers.Total != 4 || !followers.HasMore {
+		t.Fatalf("followers meta = %+v, want total 4 with more", followers.PageMeta)
+	}
+
+	var following FollowingResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/following?offset=3&limit=2", aliceID), "", nil), &following)
+	if got := followIDs(following.Following); !slices.Equal(got, []int{5}) {
+		t.Fatalf("following page = %v, want [5]", got)
+	}
+	if following.Total != 4 || following.HasMore {
+		t.Fatalf("following meta = %+v, want total 4 without more", following.PageMeta)
+	}
+
+	// offset vượt quá danh sách trả trang rỗng, không panic
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/followers?offset=10", aliceID), "", nil), &followers)
+	if len(followers.Followers) != 0 || followers.Total != 4 {
+		t.Fatalf("followers past end = %+v", followers)
+	}
+}
+
+func TestFollowStatus(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	bobID, _ := a.register("bob")
+	carolID, _ := a.register("carol")
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", bobID), alice, nil)
+
+	var status FollowStatusResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/follow/status", bobID), alice, nil), &status)
+	if !status.Following {
+		t.Fatal("alice should follow bob")
+	}
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/follow/status", carolID), alice, nil), &status)
+	if status.Following {
+		t.Fatal("alice should not follow carol")
+	}
+	a.expect(http.StatusNotFound, "GET", "/users/99/follow/status", alice, nil)
+}
+
+func TestFollowRecordsUsernames(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	bobID, _ := a.register("bob")
+
+	a.expect(http.StatusBadRequest, "POST", fmt.Sprintf("/users/%d/follow", aliceID), alice, nil)
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", bobID), alice, nil)
+
+	var following FollowingResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/following", aliceID), "", nil), &following)
+	if len(following.Following) != 1 || following.Following[0].Username != "bob" {
+		t.Fatalf("alice following = %+v, want bob only", following.Following)
+	}
+	var followers FollowersResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/followers", bobID), "", nil), &followers)
+	if len(followers.Followers) != 1 || followers.Followers[0].Username != "alice" {
+		t.Fatalf("bob followers = %+v, want alice only", followers.Followers)
+	}
+}
+
+func TestFollowCountsTrackFollowAndUnfollow(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	_, carol := a.register("carol")
+
+	counts := func(userID int) FollowCountsResponse {
+		var c FollowCountsResponse
+		decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/follow/counts", userID), "", nil), &c)
+		return c
+	}
+	if c := counts(aliceID); c != (FollowCountsResponse{}) {
+		t.Fatalf("new user counts = %+v", c)
+	}
+
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), carol, nil)
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", bobID), alice, nil)
+	if c := counts(aliceID); c != (FollowCountsResponse{Followers: 2, Following: 1}) {
+		t.Fatalf("alice counts = %+v", c)
+	}
+
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+	if c := counts(aliceID); c != (FollowCountsResponse{Followers: 1, Following: 1}) {
+		t.Fatalf("alice counts after unfollow = %+v", c)
+	}
+	if c := counts(bobID); c != (FollowCountsResponse{Followers: 1}) {
+		t.Fatalf("bob counts after unfollow = %+v", c)
+	}
+	a.expect(http.StatusNotFound, "GET", "/users/99/follow/counts", "", nil)
+}
+
+func TestFollowersCursorStable(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	tokens := map[int]string{}
+	for _, name := range []string{"bob", "carol", "dave", "erin", "frank"} {
+		id, token := a.register(name)
+		tokens[id] = token
+	}
+	for id := 3; id <= 6; id++ {
+		a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), tokens[id], nil)
+		a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", id), alice, nil)
+	}
+	followers := func(query string) FollowersResponse {
+		var resp FollowersResponse
+		decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/followers?%s", aliceID, query), "", nil), &resp)
+		return resp
+	}
+	following := func(query string) FollowingResponse {
+		var resp FollowingResponse
+		decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/following?%s", aliceID, query), "", nil), &resp)
+		return resp
+	}
+
+	first := followers("limit=2")
+	firstFollowing := following("limit=2")
+	if got := followIDs(first.Followers); !slices.Equal(got, []int{3, 4}) || first.NextCursor == "" {
+		t.Fatalf("first page = %v, cursor %q", got, first.NextCursor)
+	}
+
+	// bob (id 2) follow chen vào trước cursor: offset bị lệch, cursor thì không
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), tokens[2], nil)
+	a.expect(http.StatusCreated, "POST", "/users/2/follow", alice, nil)
+	if got := followIDs(followers("offset=2&limit=2").Followers); !slices.Equal(got, []int{4, 5}) {
+		t.Fatalf("offset page after insert = %v", got)
+	}
+	second := followers("limit=2&after=" + first.NextCursor)
+	if got := followIDs(second.Followers); !slices.Equal(got, []int{5, 6}) {
+		t.Fatalf("cursor page after insert = %v, want [5 6]", got)
+	}
+	if second.NextCursor != "" {
+		t.Fatalf("last page has next_cursor %q", second.NextCursor)
+	}
+	secondFollowing := following("limit=2&after=" + firstFollowing.NextCursor)
+	if got := followIDs(secondFollowing.Following); !slices.Equal(got, []int{5, 6}) {
+		t.Fatalf("following cursor page = %v, want [5 6]", got)
+	}
+
+	a.expect(http.StatusBadRequest, "GET", fmt.Sprintf("/users/%d/followers?after=garbage", aliceID), "", nil)
+}
+
+func TestConcurrentFollowMakesOneEdge(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, _ := a.register("alice")
+	_, bob := a.register("bob")
+
+	const attempts = 20
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- a.do("POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	created := 0
+	for code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusBadRequest:
+		default:
+			t.Fatalf("unexpected status %d", code)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d follows succeeded, want 1", created)
+	}
+
+	var followers FollowersResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/followers", aliceID), "", nil), &followers)
+	if followers.Total != 1 || len(followers.Followers) != 1 {
+		t.Fatalf("followers = %+v", followers)
+	}
+	var counts FollowCountsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/users/2/follow/counts", "", nil), &counts)
+	if counts.Following != 1 {
+		t.Fatalf("bob's counts = %+v", counts)
+	}
+}
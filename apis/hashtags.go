@@ -0,0 +1,135 @@
+package apis
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+// hashtagPattern matches #tag not preceded by a word character or "&", so
+// "a#b" and HTML entities such as "&#39;" left by the sanitizer are skipped
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&])#([\p{L}\p{N}_]{1,100})`)
+
+// extractHashtags returns the distinct lowercase tags (without "#") in content;
+// tags made only of digits such as "#1" are ignored
+func extractHashtags(content string) []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, m := range hashtagPattern.FindAllStringSubmatch(content, -1) {
+		tag := strings.ToLower(m[1])
+		if seen[tag] || strings.IndexFunc(tag, unicode.IsLetter) < 0 {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// GetHashtagPosts godoc
+// @Summary List posts by hashtag
+// @Description Posts whose content contains #tag, newest first; page with before=<last post_id>.
+// @Description The tag is case-insensitive and may include the leading "#"
+// @Tags posts
+// @Produce json
+// @Param tag path string true "Hashtag"
+// @Param before query int false "Only posts with post_id lower than this"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Param Authorization header string false "Bearer token"
+// @Success 200 {object} PostsResponse
+// @Failure 400 {object} APIError
+// @Router /hashtags/{tag}/posts [get]
+func (h *PostsHandler) GetHashtagPosts(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(mux.Vars(r)["tag"], "#"))
+	if tag == "" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid tag")
+		return
+	}
+	h.writePostsPage(w, r, PostFilter{Tag: tag})
+}
+
+// DefaultTrendingWindow là khoảng thời gian tính hashtag trending khi không cấu hình
+const DefaultTrendingWindow = 24 * time.Hour
+
+// defaultTrendingLimit là số hashtag trả về khi không có limit
+const defaultTrendingLimit = 10
+
+// TrendingHashtag is a tag with the number of recent posts using it
+type TrendingHashtag struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TrendingHashtagsResponse represents response for GET /hashtags/trending
+type TrendingHashtagsResponse struct {
+	Hashtags []TrendingHashtag `json:"hashtags"`
+	Since    string            `json:"since"` // chỉ đếm post tạo từ thời điểm này
+	Limit    int               `json:"limit"` // limit đã áp dụng
+}
+
+// GetTrendingHashtags godoc
+// @Summary Trending hashtags
+// @Description Tags used by the most public, published posts in the trending window (24h by default),
+// @Description most used first; tags with the same count are sorted alphabetically
+// @Tags posts
+// @Produce json
+// @Param limit query int false "Limit (default 10, max 100)"
+// @Success 200 {object} TrendingHashtagsResponse
+// @Failure 400 {object} APIError
+// @Router /hashtags/trending [get]
+func (h *PostsHandler) GetTrendingHashtags(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+	_, limit, ok := parsePaginationDefault(w, r, defaultTrendingLimit)
+	if !ok {
+		return
+	}
+
+	window := h.TrendingWindow
+	if window <= 0 {
+		window = DefaultTrendingWindow
+	}
+	since := time.Now().Add(-window)
+
+	posts, err := h.Store.ListPosts(r.Context(), PostFilter{})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load posts")
+		return
+	}
+
+	// chỉ post public đã publish: trending là công khai, không được lộ tag của post hạn chế
+	counts := map[string]int{}
+	for _, p := range posts {
+		if p.IsDeleted || p.Status == PostDraft || (p.Visibility != "" && p.Visibility != VisibilityPublic) {
+			continue
+		}
+		if createdAt, err := time.Parse(time.RFC3339, p.CreatedAt); err != nil || createdAt.Before(since) {
+			continue
+		}
+		for _, tag := range p.Tags {
+			counts[tag]++
+		}
+	}
+
+	trending := make([]TrendingHashtag, 0, len(counts))
+	for tag, n := range counts {
+		trending = append(trending, TrendingHashtag{Tag: tag, Count: n})
+	}
+	sort.Slice(trending, func(i, j int) bool {
+		if trending[i].Count != trending[j].Count {
+			return trending[i].Count > trending[j].Count
+		}
+		return trending[i].Tag < trending[j].Tag
+	})
+	if len(trending) > limit {
+		trending = trending[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, TrendingHashtagsResponse{Hashtags: trending, Since: formatRFC3339(since), Limit: limit})
+}
@@ -0,0 +1,109 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	for content, want := range map[string][]string{
+		"#Go #golang":             {"go", "golang"},
+		"#go and #GO again":       {"go"},
+		"a#b, &#39; and #1":       nil,
+		"(#tiếng_việt) #v2":       {"tiếng_việt", "v2"},
+		"no tags here":            nil,
+		"#go.#rust! #go_lang-ish": {"go", "rust", "go_lang"},
+	} {
+		if got := extractHashtags(content); !reflect.DeepEqual(got, want) {
+			t.Errorf("extractHashtags(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+// hashtagPostIDs trả về post_id của trang GET /hashtags/{tag}/posts
+func (a *testApp) hashtagPostIDs(token, tag, query string) []int {
+	a.t.Helper()
+	var page PostsResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", "/hashtags/"+tag+"/posts?"+query, token, nil), &page)
+	ids := []int{}
+	for _, p := range page.Posts {
+		ids = append(ids, p.PostID)
+	}
+	return ids
+}
+
+func TestGetHashtagPosts(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+
+	tagged := a.createPost(alice, map[string]any{"content": "hello #Go #golang"}, "")
+	other := a.createPost(bob, map[string]any{"content": "#rust only"}, "")
+	newer := a.createPost(bob, map[string]any{"content": "more #go"}, "")
+	hidden := a.createPost(bob, map[string]any{"content": "secret #go", "visibility": "private"}, "")
+
+	for _, tag := range []string{"go", "GO", "%23go"} {
+		if got, want := a.hashtagPostIDs("", tag, ""), []int{newer, tagged}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("tag %q = %v, want %v", tag, got, want)
+		}
+	}
+	if got, want := a.hashtagPostIDs("", "golang", ""), []int{tagged}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("tag golang = %v, want %v", got, want)
+	}
+	// post private chỉ chủ post thấy
+	if got, want := a.hashtagPostIDs(bob, "go", ""), []int{hidden, newer, tagged}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("tag go for bob = %v, want %v", got, want)
+	}
+	if got, want := a.hashtagPostIDs(bob, "go", fmt.Sprintf("limit=1&before=%d", hidden)), []int{newer}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("tag go before %d = %v, want %v", hidden, got, want)
+	}
+
+	// sửa nội dung thì tag được tính lại
+	a.expect(http.StatusOK, "PATCH", postPath(other, ""), bob, map[string]string{"content": "now #go"})
+	if got := a.hashtagPostIDs("", "rust", ""); len(got) != 0 {
+		t.Fatalf("tag rust after edit = %v", got)
+	}
+	if got, want := a.hashtagPostIDs("", "go", ""), []int{newer, other, tagged}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("tag go after edit = %v, want %v", got, want)
+	}
+	a.expect(http.StatusBadRequest, "GET", "/hashtags/go/posts?before=0", "", nil)
+}
+
+func TestTrendingHashtags(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.posts.TrendingWindow = time.Hour
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+
+	a.createPost(alice, map[string]any{"content": "#go #rust"}, "")
+	a.createPost(bob, map[string]any{"content": "#go #zig"}, "")
+	a.createPost(bob, map[string]any{"content": "#GO #rust #api"}, "")
+	// không được tính: ngoài cửa sổ, đã xoá, không public, draft
+	if _, err := a.store.CreatePost(t.Context(), Post{
+		UserID: aliceID, Content: "#zig #old", Tags: []string{"zig", "old"},
+		CreatedAt: formatRFC3339(time.Now().Add(-2 * time.Hour)), Status: PostPublished,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	deleted := a.createPost(alice, map[string]any{"content": "#zig #gone"}, "")
+	a.expect(http.StatusNoContent, "DELETE", postPath(deleted, ""), alice, nil)
+	a.createPost(alice, map[string]any{"content": "#zig #secret", "visibility": "followers"}, "")
+	a.createPost(alice, map[string]any{"content": "#zig #later"}, "status=draft")
+
+	var resp TrendingHashtagsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/hashtags/trending", "", nil), &resp)
+	// cùng số post thì theo thứ tự chữ cái
+	want := []TrendingHashtag{{"go", 3}, {"rust", 2}, {"api", 1}, {"zig", 1}}
+	if !reflect.DeepEqual(resp.Hashtags, want) || resp.Limit != defaultTrendingLimit {
+		t.Fatalf("trending = %+v, want %+v", resp, want)
+	}
+
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/hashtags/trending?limit=2", "", nil), &resp)
+	if !reflect.DeepEqual(resp.Hashtags, want[:2]) {
+		t.Fatalf("trending limit=2 = %+v", resp.Hashtags)
+	}
+	a.expect(http.StatusBadRequest, "GET", "/hashtags/trending?limit=0", "", nil)
+}
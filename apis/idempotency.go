@@ -0,0 +1,76 @@
+package apis
+
+import (
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader lets clients retry a create safely
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Thời gian giữ key và độ dài tối đa của key
+const (
+	IdempotencyKeyTTL       = 24 * time.Hour
+	maxIdempotencyKeyLength = 255
+)
+
+type idempotencyKey struct {
+	userID int
+	key    string
+}
+
+// idempotencyEntry: resultID = 0 nghĩa là request đầu tiên vẫn đang xử lý
+type idempotencyEntry struct {
+	resultID  int
+	expiresAt time.Time
+}
+
+// idempotencyCache maps (user, key) to the ID created by the first request
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[idempotencyKey]idempotencyEntry
+}
+
+// reserve returns the ID already created for the key, or claims the key for the
+// caller; inProgress is true while another request holds the claim
+func (c *idempotencyCache) reserve(userID int, key string) (resultID int, inProgress bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	k := idempotencyKey{userID, key}
+	if e, ok := c.entries[k]; ok && now.Before(e.expiresAt) {
+		return e.resultID, e.resultID == 0
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[idempotencyKey]idempotencyEntry)
+	}
+	// dọn key hết hạn khi có key mới để map không phình mãi
+	for old, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, old)
+		}
+	}
+	c.entries[k] = idempotencyEntry{expiresAt: now.Add(IdempotencyKeyTTL)}
+	return 0, false
+}
+
+// complete records the created ID for a reserved key
+func (c *idempotencyCache) complete(userID int, key string, resultID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[idempotencyKey{userID, key}] = idempotencyEntry{
+		resultID:  resultID,
+		expiresAt: time.Now().Add(IdempotencyKeyTTL),
+	}
+}
+
+// release drops a reservation whose request failed so the client can retry
+func (c *idempotencyCache) release(userID int, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, idempotencyKey{userID, key})
+}
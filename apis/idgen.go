@@ -0,0 +1,26 @@
+package apis
+
+import "sync/atomic"
+
+// IDGenerator hands out increasing IDs starting at 1. It does not need the
+// owner's mutex, so ID assignment stays race-free however that lock is scoped;
+// the zero value is ready to use
+type IDGenerator struct {
+	last atomic.Int64
+}
+
+// Next returns a new ID, never the same one twice
+func (g *IDGenerator) Next() int {
+	return int(g.last.Add(1))
+}
+
+// Observe makes sure Next never returns id or anything lower, e.g. after loading
+// records that already have IDs
+func (g *IDGenerator) Observe(id int) {
+	for {
+		last := g.last.Load()
+		if int64(id) <= last || g.last.CompareAndSwap(last, int64(id)) {
+			return
+		}
+	}
+}
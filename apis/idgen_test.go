@@ -0,0 +1,46 @@
+package apis
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestIDGeneratorConcurrentNext(t *testing.T) {
+	var g IDGenerator
+	const workers, perWorker = 50, 200
+
+	ids := make(chan int, workers*perWorker)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for range perWorker {
+				ids <- g.Next()
+			}
+		})
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]bool, workers*perWorker)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("ID %d handed out twice", id)
+		}
+		seen[id] = true
+	}
+	// không trùng và không hở: đúng 1..n
+	for id := 1; id <= workers*perWorker; id++ {
+		if !seen[id] {
+			t.Fatalf("ID %d never handed out", id)
+		}
+	}
+}
+
+func TestIDGeneratorObserve(t *testing.T) {
+	var g IDGenerator
+	g.Observe(10)
+	g.Observe(3) // thấp hơn: không kéo lùi
+	if id := g.Next(); id != 11 {
+		t.Fatalf("Next after Observe(10) = %d, want 11", id)
+	}
+}
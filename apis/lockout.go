@@ -0,0 +1,107 @@
+package apis
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ngưỡng khoá đăng nhập: theo tài khoản và theo IP (cao hơn vì nhiều người có thể dùng chung IP)
+const (
+	maxAccountLoginFailures = 5
+	maxIPLoginFailures      = 20
+	loginLockoutDuration    = 15 * time.Minute
+)
+
+// loginAttempts đếm số lần sai liên tiếp của một tài khoản hoặc một IP
+type loginAttempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// loginLockout locks an account or IP for loginLockoutDuration after too many
+// consecutive failed logins; failures older than the cooldown are forgotten
+type loginLockout struct {
+	mu       sync.Mutex
+	attempts map[string]*loginAttempts // "account:<account>" hoặc "ip:<addr>"
+}
+
+func accountKey(account string) string { return "account:" + account }
+func ipKey(ip string) string           { return "ip:" + ip }
+
+// lockoutAccount names the counter of a login attempt: the user ID when the login
+// resolves to an account, so username and email share one counter, otherwise the
+// login normalized the way userByLogin looks it up
+func lockoutAccount(user User, exists bool, login string) string {
+	if exists {
+		return "user:" + strconv.Itoa(user.ID)
+	}
+	return "login:" + normalizeLogin(login)
+}
+
+// retryAfter returns how long account and ip are still locked, 0 when they are not
+func (l *loginLockout) retryAfter(account, ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{accountKey(account), ipKey(ip)} {
+		if a, ok := l.attempts[key]; ok && now.Before(a.lockedUntil) {
+			wait = max(wait, a.lockedUntil.Sub(now))
+		}
+	}
+	return wait
+}
+
+// fail records a failed login for both the account and the IP
+func (l *loginLockout) fail(account, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.attempts == nil {
+		l.attempts = make(map[string]*loginAttempts)
+	}
+	l.prune(now)
+
+	l.record(accountKey(account), maxAccountLoginFailures, now)
+	l.record(ipKey(ip), maxIPLoginFailures, now)
+}
+
+// record counts one failure for key and locks it once limit is reached; caller holds l.mu
+func (l *loginLockout) record(key string, limit int, now time.Time) {
+	a, ok := l.attempts[key]
+	if !ok {
+		a = &loginAttempts{}
+		l.attempts[key] = a
+	}
+	if now.Sub(a.lastFailure) > loginLockoutDuration {
+		*a = loginAttempts{}
+	}
+	a.failures++
+	a.lastFailure = now
+	if a.failures >= limit {
+		a.lockedUntil = now.Add(loginLockoutDuration)
+		a.failures = 0
+	}
+}
+
+// succeed clears the failures of an account after a correct password; the IP
+// counter is left to expire so one valid account cannot unlock guessing on others
+func (l *loginLockout) succeed(account string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, accountKey(account))
+}
+
+// prune drops entries that are neither locked nor recently failed; caller holds l.mu
+func (l *loginLockout) prune(now time.Time) {
+	for key, a := range l.attempts {
+		if now.After(a.lockedUntil) && now.Sub(a.lastFailure) > loginLockoutDuration {
+			delete(l.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLoginLockout(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, _ := a.register("alice")
+	a.register("bob")
+
+	// đăng nhập đúng trước khi đủ ngưỡng thì đếm lại từ đầu
+	for range maxAccountLoginFailures - 1 {
+		if code := a.login("alice", "wrong-password"); code != http.StatusUnauthorized {
+			t.Fatalf("wrong password: status %d", code)
+		}
+	}
+	if code := a.login("alice", "password1"); code != http.StatusOK {
+		t.Fatalf("correct password before the limit: status %d", code)
+	}
+	for i := range maxAccountLoginFailures {
+		if code := a.login("ALICE", "wrong-password"); code != http.StatusUnauthorized {
+			t.Fatalf("failure %d after reset: status %d", i+1, code)
+		}
+	}
+
+	rec := a.do("POST", "/login", "", LoginRequest{Login: "alice", Password: "password1"})
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("locked account: status %d", rec.Code)
+	}
+	if secs, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || secs <= 0 || secs > int(loginLockoutDuration.Seconds()) {
+		t.Fatalf("Retry-After = %q", rec.Header().Get("Retry-After"))
+	}
+	// recover dùng chung lockout
+	a.expect(http.StatusTooManyRequests, "POST", "/auth/recover", "", LoginRequest{Login: "alice", Password: "password1"})
+	if code := a.login("bob", "password1"); code != http.StatusOK {
+		t.Fatalf("other account while alice is locked: status %d", code)
+	}
+
+	// hết thời gian khoá thì đăng nhập lại được
+	a.auth.lockout.mu.Lock()
+	a.auth.lockout.attempts[accountKey(lockoutAccount(User{ID: aliceID}, true, "alice"))].lockedUntil = time.Now().Add(-time.Second)
+	a.auth.lockout.mu.Unlock()
+	if code := a.login("alice", "password1"); code != http.StatusOK {
+		t.Fatalf("after the lockout expired: status %d", code)
+	}
+	if code := a.login("alice", "wrong-password"); code != http.StatusUnauthorized {
+		t.Fatalf("first failure after success: status %d", code)
+	}
+}
+
+func TestLoginLockoutSharedAcrossLoginForms(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.register("bob")
+
+	// username, username có khoảng trắng và email (khác hoa thường) cùng về một bộ đếm
+	logins := []string{"bob", " bob", "bob ", "bob@example.com", "BOB@example.com"}
+	for i := range maxAccountLoginFailures {
+		if code := a.login(logins[i%len(logins)], "wrong-password"); code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d", i+1, code)
+		}
+	}
+	for _, login := range logins {
+		a.expect(http.StatusTooManyRequests, "POST", "/login", "", LoginRequest{Login: login, Password: "password1"})
+	}
+
+	// login không khớp tài khoản nào cũng được chuẩn hoá trước khi đếm
+	var l loginLockout
+	for i := range maxAccountLoginFailures {
+		l.fail(lockoutAccount(User{}, false, []string{"ghost", " Ghost", "GHOST "}[i%3]), fmt.Sprint("10.0.0.", i))
+	}
+	if l.retryAfter(lockoutAccount(User{}, false, "ghost"), "10.0.1.1") <= 0 {
+		t.Fatal("unknown login variants counted separately")
+	}
+}
+
+func TestLoginLockoutPerIP(t *testing.T) {
+	var l loginLockout
+	for i := range maxIPLoginFailures {
+		l.fail(fmt.Sprint("user", i), "10.0.0.1")
+	}
+	if l.retryAfter("someone-else", "10.0.0.1") <= 0 {
+		t.Fatal("IP not locked after spraying many accounts")
+	}
+	if l.retryAfter("someone-else", "10.0.0.2") != 0 {
+		t.Fatal("other IP locked")
+	}
+	// đăng nhập đúng chỉ mở khoá tài khoản, không mở khoá IP
+	l.succeed("user0")
+	if l.retryAfter("user0", "10.0.0.1") <= 0 {
+		t.Fatal("success unlocked the IP")
+	}
+}
+
+func TestLoginLockoutPrunes(t *testing.T) {
+	var l loginLockout
+	l.fail("alice", "10.0.0.1")
+	old := time.Now().Add(-2 * loginLockoutDuration)
+	l.mu.Lock()
+	for _, a := range l.attempts {
+		a.lastFailure = old
+	}
+	l.mu.Unlock()
+
+	l.fail("bob", "10.0.0.2")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.attempts[accountKey("alice")]; ok {
+		t.Fatal("stale account entry kept")
+	}
+	if _, ok := l.attempts[ipKey("10.0.0.1")]; ok {
+		t.Fatal("stale IP entry kept")
+	}
+	if len(l.attempts) != 2 {
+		t.Fatalf("attempts = %v", l.attempts)
+	}
+}
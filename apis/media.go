@@ -1,13 +1,25 @@
 package apis
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
+	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/gorilla/mux"
@@ -15,114 +27,1008 @@ import (
 
 // Media represents an uploaded media
 type Media struct {
-	ID     int    `json:"media_id"`
-	Type   string `json:"type"`
-	PostID int    `json:"post_id"`
-	URL    string `json:"url"`
+	ID       int    `json:"media_id"`
+	Type     string `json:"type"`
+	PostID   int    `json:"post_id"`
+	UserID   int    `json:"user_id"`
+	Filename string `json:"filename"` // original name, sanitized
+	URL      string `json:"url"`
+
+	ThumbnailURL string `json:"thumbnail_url,omitempty"` // images only, set once ready
+	Status       string `json:"status"`                  // processing, ready or failed
+	CreatedAt    string `json:"created_at"`
+
+	path      string // đường dẫn trên đĩa, không trả về cho client
+	thumbPath string
 }
 
 // MediaResponse represents response for media operations
 type MediaResponse struct {
 	MediaID int    `json:"media_id,omitempty"`
 	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+}
+
+// BatchUploadError reports why one file of a batch upload was rejected
+type BatchUploadError struct {
+	Index    int    `json:"index"` // position of the file part in the request
+	Filename string `json:"filename"`
+	Error    string `json:"error"`
+}
+
+// BatchUploadResponse represents response for POST /media/batch
+type BatchUploadResponse struct {
+	Media  []Media            `json:"media"`
+	Errors []BatchUploadError `json:"errors"`
+}
+
+// UploadError describes why an uploaded file was rejected
+type UploadError struct {
+	Status  int
+	Message string
+}
+
+func (e *UploadError) Error() string { return e.Message }
+
+// writeUploadError writes the response for an error returned by storeFile
+func writeUploadError(w http.ResponseWriter, err error) {
+	var ue *UploadError
+	if errors.As(err, &ue) {
+		writeJSONError(w, ue.Status, ue.Message)
+		return
+	}
+	writeJSONError(w, http.StatusInternalServerError, "Cannot save file")
+}
+
+// maxBatchFiles caps the number of files in one batch upload
+const maxBatchFiles = 10
+
+// maxImagePixels caps width*height of uploaded images, checked from the header
+// before anything is decoded so a small file cannot expand into gigabytes
+const maxImagePixels = 40_000_000
+
+// AvatarRules limits the dimensions of avatar images (zero values disable a check)
+type AvatarRules struct {
+	MaxWidth      int
+	MaxHeight     int
+	RequireSquare bool
 }
 
 // MediaHandler handles media endpoints
 type MediaHandler struct {
-	mu     sync.Mutex
-	nextID int
-	medias []Media
+	mu          sync.Mutex
+	ids         IDGenerator
+	medias      []Media
+	AvatarRules AvatarRules
+	UploadDir   string // where files are written on disk
+	MaxFileSize int64  // per-file byte cap, also bounds the request body
+	BaseURL     string // public prefix of media URLs, e.g. "http://localhost:8080"
+	Posts       Store  // stores media records and the posts they are attached to
+	Tokens      *TokenService
+	Profiles    *ProfileHandler // POST /me/avatar cập nhật avatar của profile
+	Follows     *FollowsHandler // media của post followers-only chỉ follower mới thấy, optional
+	Blocks      *BlocksHandler  // ẩn media giữa hai user đã chặn nhau, optional
+	queue       *mediaQueue     // nil thì sinh thumbnail ngay trong request
 }
 
 // NewMediaHandler constructor
-func NewMediaHandler() *MediaHandler {
+func NewMediaHandler(posts Store, uploadDir string) *MediaHandler {
 	return &MediaHandler{
-		nextID: 1,
-		medias: make([]Media, 0),
+		Posts:       posts,
+		medias:      make([]Media, 0),
+		UploadDir:   uploadDir,
+		MaxFileSize: 10 << 20,
 	}
 }
 
-// RegisterRoutes registers media routes
+// RegisterRoutes registers media routes except uploads, see RegisterUploadRoutes
 func (h *MediaHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/media", h.UploadMedia).Methods("POST")
+	router.Handle("/media/{media_id}", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetMedia))).Methods("GET")
+	router.Handle("/media/{media_id}/file", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetMediaFile))).Methods("GET")
+	router.Handle("/media/{media_id}", h.Tokens.RequireAuth(http.HandlerFunc(h.DeleteMedia))).Methods("DELETE")
+	router.Handle("/posts/{post_id}/media", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetPostMedia))).Methods("GET")
+	router.Handle("/admin/media/cleanup", h.Tokens.RequireAdmin(http.HandlerFunc(h.CleanupMedia))).Methods("POST")
+}
+
+// RegisterUploadRoutes registers the upload routes, usually on a rate-limited subrouter
+func (h *MediaHandler) RegisterUploadRoutes(router *mux.Router) {
+	router.Handle("/media", h.Tokens.RequireAuth(http.HandlerFunc(h.UploadMedia))).Methods("POST")
+	router.Handle("/media/batch", h.Tokens.RequireAuth(http.HandlerFunc(h.UploadMediaBatch))).Methods("POST")
+	router.Handle("/me/avatar", h.Tokens.RequireAuth(http.HandlerFunc(h.UploadAvatar))).Methods("POST")
 }
 
 // @Summary Upload Media
-// @Description Upload an image or video file associated with a post
+// @Description Upload an image or video file associated with a post. Images get a thumbnail (max 320px)
+// @Description in the background: the media starts as "processing" and GET /media/{media_id} shows
+// @Description "ready" (with thumbnail_url) or "failed" once done
 // @Tags media
 // @Accept multipart/form-data
 // @Produce json
-// @Param Authorization header string true "Bearer token"
-// @Param type formData string true "Media type: image or video"
+// @Security BearerAuth
+// @Param type formData string true "Media type: image, video or avatar"
 // @Param file formData file true "Media file"
-// @Param post_id formData int true "ID of the associated post"
+// @Param post_id formData int false "ID of the associated post (not used for avatar)"
 // @Success 201 {object} MediaResponse
-// @Failure 400 {object} MediaResponse
-// @Failure 404 {object} MediaResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 413 {object} APIError
+// @Failure 422 {object} APIError
+// @Failure 503 {object} APIError
 // @Router /media [post]
 func (h *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	if !requireStore(w, h.Posts) {
+		return
+	}
+
+	userID, _ := UserIDFromContext(r.Context())
+
+	// chừa 1 MB cho các field khác của form
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxFileSize+1<<20)
+	if !h.parseForm(w, r) {
+		return
+	}
+
+	mediaType := r.FormValue("type")
+	if mediaType != "image" && mediaType != "video" && mediaType != "avatar" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid media type")
+		return
+	}
+
+	// avatar không gắn với post nào
+	var post Post
+	if mediaType != "avatar" {
+		var ok bool
+		if post, ok = h.ownPost(r.Context(), w, r.FormValue("post_id"), userID); !ok {
+			return
+		}
+	}
+
+	_, fh, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "File is required")
+		return
+	}
 
-	err := r.ParseMultipartForm(10 << 20) // 10 MB max
+	media, jobs, err := h.storeFile(mediaType, fh, h.ids.Next())
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(MediaResponse{Error: "Invalid form data"})
+		writeUploadError(w, err)
+		return
+	}
+	media.PostID = post.PostID
+	media.UserID = userID
+	stored := []Media{media}
+	if !h.addMedia(r.Context(), w, post.PostID, stored, jobs) {
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, MediaResponse{
+		MediaID: stored[0].ID,
+		Message: "Media uploaded",
+	})
+}
+
+// @Summary Upload Avatar
+// @Description Upload an image and make it the current user's profile avatar; a thumbnail (max 320px)
+// @Description is generated in the background, see the status field
+// @Tags media
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "Avatar image"
+// @Success 201 {object} Media
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 413 {object} APIError
+// @Failure 422 {object} APIError
+// @Failure 503 {object} APIError
+// @Router /me/avatar [post]
+func (h *MediaHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, _ := UserIDFromContext(r.Context())
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxFileSize+1<<20)
+	if !h.parseForm(w, r) {
+		return
+	}
+	_, fh, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "File is required")
+		return
+	}
+	media, jobs, err := h.storeFile("avatar", fh, h.ids.Next())
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	media.UserID = userID
+	stored := []Media{media}
+	if !h.addMedia(r.Context(), w, 0, stored, jobs) {
+		return
+	}
+	media = stored[0]
+
+	// addMedia đã nhả lock, không giữ hai lock cùng lúc khi gọi sang profile
+	if err := h.Profiles.setAvatar(r.Context(), userID, media.URL); err != nil {
+		h.mu.Lock()
+		h.medias = slices.DeleteFunc(h.medias, func(m Media) bool { return m.ID == media.ID })
+		if err := h.deleteSavedMedia(r.Context(), media.ID); err != nil {
+			log.Println("roll back avatar media", media.ID, ":", err)
+		}
+		h.mu.Unlock()
+		removeMediaFiles(media)
+		if errors.Is(err, errUserNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Profile not found")
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, "Cannot save profile")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, media)
+}
+
+// @Summary Upload Media Batch
+// @Description Upload several image or video files (repeat the file field) to one post.
+// @Description Files that fail validation are listed in errors; with all_or_nothing=true any failure discards the whole batch.
+// @Description Image thumbnails are generated in the background like POST /media
+// @Tags media
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param type formData string true "Media type: image or video"
+// @Param file formData file true "Media files"
+// @Param post_id formData int true "ID of the associated post"
+// @Param all_or_nothing query bool false "Reject the whole batch if any file fails"
+// @Success 201 {object} BatchUploadResponse
+// @Failure 400 {object} BatchUploadResponse
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 413 {object} APIError
+// @Failure 503 {object} APIError
+// @Router /media/batch [post]
+func (h *MediaHandler) UploadMediaBatch(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Posts) {
+		return
+	}
+
+	userID, _ := UserIDFromContext(r.Context())
+	allOrNothing := r.URL.Query().Get("all_or_nothing") == "true"
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxFileSize*maxBatchFiles+1<<20)
+	if !h.parseForm(w, r) {
 		return
 	}
 
 	mediaType := r.FormValue("type")
 	if mediaType != "image" && mediaType != "video" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(MediaResponse{Error: "Invalid media type"})
+		writeJSONError(w, http.StatusBadRequest, "Invalid media type")
+		return
+	}
+	post, ok := h.ownPost(r.Context(), w, r.FormValue("post_id"), userID)
+	if !ok {
+		return
+	}
+
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "File is required")
+		return
+	}
+	if len(files) > maxBatchFiles {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d files per batch", maxBatchFiles))
 		return
 	}
 
-	postIDStr := r.FormValue("post_id")
+	resp := BatchUploadResponse{Media: []Media{}, Errors: []BatchUploadError{}}
+	var jobs []thumbnailJob
+	for i, fh := range files {
+		media, mediaJobs, err := h.storeFile(mediaType, fh, h.ids.Next())
+		if err != nil {
+			resp.Errors = append(resp.Errors, BatchUploadError{Index: i, Filename: sanitizeFilename(fh.Filename), Error: err.Error()})
+			continue
+		}
+		media.PostID = post.PostID
+		media.UserID = userID
+		resp.Media = append(resp.Media, media)
+		jobs = append(jobs, mediaJobs...)
+	}
+
+	// rollback: xoá các file đã lưu, chưa có gì được gắn vào post
+	if len(resp.Media) == 0 || (allOrNothing && len(resp.Errors) > 0) {
+		for _, m := range resp.Media {
+			removeMediaFiles(m)
+		}
+		resp.Media = []Media{}
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+	if !h.addMedia(r.Context(), w, post.PostID, resp.Media, jobs) {
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// addMedia records freshly stored media and links them to postID (0 for an avatar).
+// Thumbnails are queued, or made first when no workers were started. Only this last
+// step holds h.mu: enqueueing under it keeps workers from finishing before the media
+// are recorded, and the post is re-read under it so concurrent uploads to one post
+// keep each other's media_ids. On failure it removes the files, writes the error
+// response and returns false; media is updated in place with thumbnail results
+func (h *MediaHandler) addMedia(ctx context.Context, w http.ResponseWriter, postID int, media []Media, jobs []thumbnailJob) bool {
+	h.mu.Lock()
+	queue := h.queue
+	h.mu.Unlock()
+	if queue == nil {
+		h.makeThumbnails(media, jobs)
+	}
+
+	removeAll := func() {
+		for _, m := range media {
+			removeMediaFiles(m)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, m := range media {
+		if err := h.saveMedia(ctx, m); err != nil {
+			for _, saved := range media[:i] {
+				h.deleteSavedMedia(ctx, saved.ID)
+			}
+			removeAll()
+			writeJSONError(w, http.StatusInternalServerError, "Cannot save media")
+			return false
+		}
+	}
+	removeSaved := func() {
+		for _, m := range media {
+			if err := h.deleteSavedMedia(ctx, m.ID); err != nil {
+				log.Println("roll back media", m.ID, ":", err)
+			}
+		}
+	}
+	if queue != nil && !queue.enqueue(jobs) {
+		removeSaved()
+		removeAll()
+		writeQueueFull(w)
+		return false
+	}
+	if postID != 0 {
+		post, err := h.Posts.GetPost(ctx, postID)
+		if err == nil {
+			for _, m := range media {
+				post.MediaIDs = append(post.MediaIDs, m.ID)
+			}
+			err = h.Posts.UpdatePost(ctx, post)
+		}
+		if err != nil {
+			removeSaved()
+			removeAll()
+			writeJSONError(w, http.StatusInternalServerError, "Cannot link media to post")
+			return false
+		}
+	}
+	h.medias = append(h.medias, media...)
+	return true
+}
+
+// parseForm parses the multipart body and writes the error response on failure
+func (h *MediaHandler) parseForm(w http.ResponseWriter, r *http.Request) bool {
+	err := r.ParseMultipartForm(10 << 20) // 10 MB in memory, the rest spills to disk
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Upload too large, max file size is %d bytes", h.MaxFileSize))
+		return false
+	}
+	writeJSONError(w, http.StatusBadRequest, "Malformed multipart form data")
+	return false
+}
+
+// writeQueueFull rejects an upload while the processing queue has no room for it
+func writeQueueFull(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "5")
+	writeJSONError(w, http.StatusServiceUnavailable, "Media processing queue is full, try again later")
+}
+
+// ownPost loads the post media is attached to and checks userID wrote it;
+// writes the error response when it returns false
+func (h *MediaHandler) ownPost(ctx context.Context, w http.ResponseWriter, postIDStr string, userID int) (Post, bool) {
 	postID, err := strconv.Atoi(postIDStr)
 	if err != nil || postID <= 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(MediaResponse{Error: "Post not found"})
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return Post{}, false
+	}
+	post, err := h.Posts.GetPost(ctx, postID)
+	if err != nil && !errors.Is(err, ErrPostNotFound) {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return Post{}, false
+	}
+	if err != nil || post.IsDeleted {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return Post{}, false
+	}
+	if post.UserID != userID {
+		writeJSONError(w, http.StatusForbidden, "Not the author of this post")
+		return Post{}, false
+	}
+	return post, true
+}
+
+// storeFile validates one uploaded file and writes it to UploadDir as media id; images
+// come back "processing" with the job that makes their thumbnail, to pass to
+// addMedia. Errors are *UploadError. It does not touch h.mu
+func (h *MediaHandler) storeFile(mediaType string, fh *multipart.FileHeader, id int) (Media, []thumbnailJob, error) {
+	if fh.Size > h.MaxFileSize {
+		return Media{}, nil, &UploadError{http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("File exceeds max size of %d bytes", h.MaxFileSize)}
+	}
+
+	file, err := fh.Open()
+	if err != nil {
+		return Media{}, nil, &UploadError{http.StatusInternalServerError, "Cannot read file"}
+	}
+	defer file.Close()
+
+	// kiểm tra nội dung thật của file, không tin field type
+	sniffed, err := sniffContentType(file)
+	if err != nil {
+		return Media{}, nil, &UploadError{http.StatusInternalServerError, "Cannot read file"}
+	}
+	if !matchesMediaType(mediaType, sniffed) {
+		return Media{}, nil, &UploadError{http.StatusBadRequest,
+			fmt.Sprintf("File content %s does not match media type %s", sniffed, mediaType)}
+	}
+
+	// ảnh (kể cả avatar) chỉ đọc header ở đây; decode cả ảnh để sinh thumbnail là việc của
+	// worker, sau khi kích thước đã được kiểm tra. Video thì bỏ qua
+	isImage := mediaType == "image" || mediaType == "avatar"
+	var imgFormat string
+	if isImage {
+		var cfg image.Config
+		cfg, imgFormat, err = image.DecodeConfig(file)
+		if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+			return Media{}, nil, &UploadError{http.StatusBadRequest, "Unsupported or corrupt image"}
+		}
+		if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+			return Media{}, nil, &UploadError{http.StatusUnprocessableEntity,
+				fmt.Sprintf("Image exceeds %d pixels", maxImagePixels)}
+		}
+		if mediaType == "avatar" {
+			if msg := h.AvatarRules.check(cfg.Width, cfg.Height); msg != "" {
+				return Media{}, nil, &UploadError{http.StatusUnprocessableEntity, msg}
+			}
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return Media{}, nil, &UploadError{http.StatusInternalServerError, "Cannot read file"}
+		}
+	}
+
+	// Save file to disk (in UploadDir); tên file do server sinh, không dùng tên client gửi
+	os.MkdirAll(h.UploadDir, os.ModePerm)
+	dstPath, err := h.storagePath(id, sniffed)
+	if err != nil {
+		return Media{}, nil, &UploadError{http.StatusInternalServerError, "Cannot save file"}
+	}
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return Media{}, nil, &UploadError{http.StatusInternalServerError, "Cannot save file"}
+	}
+	_, err = io.Copy(dst, file)
+	dst.Close()
+	if err != nil {
+		os.Remove(dstPath)
+		return Media{}, nil, &UploadError{http.StatusInternalServerError, "Cannot save file"}
+	}
+
+	media := Media{
+		ID:        id,
+		Type:      mediaType,
+		Filename:  sanitizeFilename(fh.Filename),
+		URL:       h.fileURL(dstPath),
+		Status:    MediaReady,
+		CreatedAt: nowRFC3339(),
+		path:      dstPath,
+	}
+	if !isImage {
+		return media, nil, nil
+	}
+	media.Status = MediaProcessing
+	return media, []thumbnailJob{{
+		mediaID: id,
+		src:     dstPath,
+		format:  imgFormat,
+		path:    thumbnailPath(dstPath, imgFormat),
+	}}, nil
+}
+
+// removeMediaFiles deletes a media's file and thumbnail from disk
+func removeMediaFiles(m Media) error {
+	if m.thumbPath != "" {
+		os.Remove(m.thumbPath)
+	}
+	if err := os.Remove(m.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// @Summary Get Media
+// @Description Get metadata of an uploaded media; status turns from "processing" to "ready" or "failed" once its thumbnail is done.
+// @Description Media of a post the requester may not see is 404. url and thumbnail_url point at /uploads/,
+// @Description which serves files without access checks: the random file names are the only protection
+// @Tags media
+// @Produce json
+// @Param media_id path int true "Media ID"
+// @Param Authorization header string false "Bearer token"
+// @Success 200 {object} Media
+// @Failure 404 {object} APIError
+// @Router /media/{media_id} [get]
+func (h *MediaHandler) GetMedia(w http.ResponseWriter, r *http.Request) {
+	media, ok := h.lookup(mux.Vars(r)["media_id"])
+	if !ok || !h.visible(r, media) {
+		writeJSONError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, media)
+}
+
+// @Summary Get Post Media
+// @Description List the media uploaded for a post, in upload order; 404 when the requester may not see the post.
+// @Description Like GET /media/{media_id}, the /uploads/ URLs are served without access checks
+// @Tags media
+// @Produce json
+// @Param post_id path int true "Post ID"
+// @Param Authorization header string false "Bearer token"
+// @Success 200 {array} Media
+// @Failure 404 {object} APIError
+// @Router /posts/{post_id}/media [get]
+func (h *MediaHandler) GetPostMedia(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Posts) {
 		return
 	}
 
-	file, handler, err := r.FormFile("file")
+	postID, err := strconv.Atoi(mux.Vars(r)["post_id"])
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(MediaResponse{Error: "File is required"})
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	post, err := h.Posts.GetPost(r.Context(), postID)
+	viewerID, _ := UserIDFromContext(r.Context())
+	if errors.Is(err, ErrPostNotFound) || (err == nil && !h.canSeePost(viewerID, post)) {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return
+	}
+
+	// medias được append theo thứ tự upload nên không cần sort
+	result := []Media{}
+	h.mu.Lock()
+	for _, m := range h.medias {
+		if m.PostID == postID {
+			result = append(result, m)
+		}
+	}
+	h.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// @Summary Get Media File
+// @Description Stream the bytes of an uploaded media; supports Range requests for seeking.
+// @Description Media of a post the requester may not see is 404
+// @Tags media
+// @Produce octet-stream
+// @Param media_id path int true "Media ID"
+// @Param Authorization header string false "Bearer token"
+// @Param Range header string false "Byte range, e.g. bytes=0-99"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Failure 416 {string} string "Range not satisfiable"
+// @Failure 404 {object} APIError
+// @Router /media/{media_id}/file [get]
+func (h *MediaHandler) GetMediaFile(w http.ResponseWriter, r *http.Request) {
+	media, ok := h.lookup(mux.Vars(r)["media_id"])
+	if !ok || !h.visible(r, media) {
+		writeJSONError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	file, err := os.Open(media.path)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Media file not found")
 		return
 	}
 	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Media file not found")
+		return
+	}
 
-	// Save file to disk (in ./uploads/)
-	uploadDir := "./uploads"
-	os.MkdirAll(uploadDir, os.ModePerm)
-	filename := fmt.Sprintf("%d_%s", h.nextID, filepath.Base(handler.Filename))
-	dstPath := filepath.Join(uploadDir, filename)
+	// ServeContent xử lý Range (206, Accept-Ranges) để video tua được, và If-Modified-Since theo modtime
+	w.Header().Set("Content-Type", contentType(file, media.path))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
 
-	dst, err := os.Create(dstPath)
+// visible reports whether the requester may see m: avatars and their own uploads always,
+// otherwise only while the post m belongs to is visible to them. Store errors hide the media
+func (h *MediaHandler) visible(r *http.Request, m Media) bool {
+	viewerID, _ := UserIDFromContext(r.Context())
+	if m.PostID == 0 || (viewerID != 0 && m.UserID == viewerID) {
+		return true
+	}
+	if h.Posts == nil {
+		return true
+	}
+	post, err := h.Posts.GetPost(r.Context(), m.PostID)
+	return err == nil && h.canSeePost(viewerID, post)
+}
+
+// canSeePost reports whether viewerID can see post: not deleted, visible and no block with the author
+func (h *MediaHandler) canSeePost(viewerID int, post Post) bool {
+	return !post.IsDeleted && canSeePost(h.Follows, viewerID, post) && !h.Blocks.isBlocked(viewerID, post.UserID)
+}
+
+// @Summary Delete Media
+// @Description Delete an uploaded media and its file (owner only)
+// @Tags media
+// @Produce json
+// @Security BearerAuth
+// @Param media_id path int true "Media ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /media/{media_id} [delete]
+func (h *MediaHandler) DeleteMedia(w http.ResponseWriter, r *http.Request) {
+	userID, _ := UserIDFromContext(r.Context())
+	mediaID, err := strconv.Atoi(mux.Vars(r)["media_id"])
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(MediaResponse{Error: "Cannot save file"})
+		writeJSONError(w, http.StatusNotFound, "Media not found")
 		return
 	}
-	defer dst.Close()
-	io.Copy(dst, file)
 
-	// Save media info
-	media := Media{
-		ID:     h.nextID,
-		Type:   mediaType,
-		PostID: postID,
-		URL:    dstPath,
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, m := range h.medias {
+		if m.ID != mediaID {
+			continue
+		}
+		if m.UserID != userID {
+			writeJSONError(w, http.StatusForbidden, "Not allowed to delete this media")
+			return
+		}
+		if err := h.deleteSavedMedia(r.Context(), m.ID); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Cannot delete media")
+			return
+		}
+		if err := removeMediaFiles(m); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Cannot delete file")
+			return
+		}
+		h.medias = append(h.medias[:i], h.medias[i+1:]...)
+		h.unlinkFromPost(r.Context(), m)
+
+		writeNoContent(w)
+		return
 	}
-	h.medias = append(h.medias, media)
-	h.nextID++
+	writeJSONError(w, http.StatusNotFound, "Media not found")
+}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(MediaResponse{
-		MediaID: media.ID,
-		Message: "Media uploaded",
-	})
+// CleanupResponse represents response for POST /admin/media/cleanup
+type CleanupResponse struct {
+	Removed int `json:"removed"`
+}
+
+// @Summary Cleanup Orphaned Media
+// @Description Delete media (and files) whose post no longer exists or was deleted; admin only
+// @Tags media
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} CleanupResponse
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Router /admin/media/cleanup [post]
+func (h *MediaHandler) CleanupMedia(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, CleanupResponse{Removed: h.CleanupOrphans(r.Context())})
+}
+
+// CleanupOrphans removes media attached to a post that was deleted (soft or
+// permanently) and returns how many were removed. Avatars have no post and are
+// kept. Files that cannot be deleted are logged and their record kept for the
+// next run; a record whose file is already gone is dropped with a log line.
+func (h *MediaHandler) CleanupOrphans(ctx context.Context) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	kept := h.medias[:0]
+	removed := 0
+	for _, m := range h.medias {
+		if m.PostID == 0 || !h.orphaned(ctx, m) {
+			kept = append(kept, m)
+			continue
+		}
+		if _, err := os.Stat(m.path); err != nil {
+			log.Println("cleanup media", m.ID, ": file missing:", err)
+		} else if err := removeMediaFiles(m); err != nil {
+			log.Println("cleanup media", m.ID, ":", err)
+			kept = append(kept, m)
+			continue
+		}
+		// file đã xoá; record còn sót trong store chỉ trỏ tới file không còn
+		if err := h.deleteSavedMedia(ctx, m.ID); err != nil {
+			log.Println("cleanup media", m.ID, ":", err)
+		}
+		removed++
+	}
+	// xoá tham chiếu ở đuôi slice cũ
+	clear(h.medias[len(kept):])
+	h.medias = kept
+	return removed
+}
+
+// orphaned reports whether the post of m is gone or soft-deleted; store errors keep the media
+func (h *MediaHandler) orphaned(ctx context.Context, m Media) bool {
+	if h.Posts == nil {
+		return false
+	}
+	post, err := h.Posts.GetPost(ctx, m.PostID)
+	if errors.Is(err, ErrPostNotFound) {
+		return true
+	}
+	if err != nil {
+		log.Println("cleanup media", m.ID, ": load post", m.PostID, ":", err)
+		return false
+	}
+	return post.IsDeleted
+}
+
+// unlinkFromPost drops a deleted media from its post's MediaIDs
+func (h *MediaHandler) unlinkFromPost(ctx context.Context, m Media) {
+	if m.PostID == 0 || h.Posts == nil {
+		return
+	}
+	post, err := h.Posts.GetPost(ctx, m.PostID)
+	if err != nil {
+		return
+	}
+	ids := post.MediaIDs[:0:0]
+	for _, id := range post.MediaIDs {
+		if id != m.ID {
+			ids = append(ids, id)
+		}
+	}
+	post.MediaIDs = ids
+	if err := h.Posts.UpdatePost(ctx, post); err != nil {
+		log.Println("unlink media", m.ID, "from post", m.PostID, ":", err)
+	}
+}
+
+// saveMedia writes m to the store; without one media records only live in memory
+func (h *MediaHandler) saveMedia(ctx context.Context, m Media) error {
+	if h.Posts == nil {
+		return nil
+	}
+	return h.Posts.SaveMedia(ctx, m)
+}
+
+// deleteSavedMedia removes the stored record of media id
+func (h *MediaHandler) deleteSavedMedia(ctx context.Context, id int) error {
+	if h.Posts == nil {
+		return nil
+	}
+	return h.Posts.DeleteMedia(ctx, id)
+}
+
+// Load reads the media saved in Posts and continues media IDs after the highest one;
+// call it once at startup, before serving requests. Thumbnail jobs queued before a
+// restart are lost, so media still processing is marked failed
+func (h *MediaHandler) Load(ctx context.Context) error {
+	if h.Posts == nil {
+		return nil
+	}
+	media, err := h.Posts.ListMedia(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, m := range media {
+		h.ids.Observe(m.ID)
+		if m.Status == MediaProcessing {
+			m.Status = MediaFailed
+			if err := h.saveMedia(ctx, m); err != nil {
+				return err
+			}
+		}
+		h.medias = append(h.medias, m)
+	}
+	return nil
+}
+
+// ownedBy reports whether media id exists and was uploaded by userID;
+// a nil handler cannot check and accepts every ID
+func (h *MediaHandler) ownedBy(id, userID int) bool {
+	if h == nil {
+		return true
+	}
+	m, ok := h.lookup(strconv.Itoa(id))
+	return ok && m.UserID == userID
+}
+
+// urls returns the file URLs of the media in ids, in the same order, skipping IDs that
+// no longer exist; a nil handler knows no media
+func (h *MediaHandler) urls(ids []int) []string {
+	if h == nil || len(ids) == 0 {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	urls := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if i := slices.IndexFunc(h.medias, func(m Media) bool { return m.ID == id }); i >= 0 {
+			urls = append(urls, h.medias[i].URL)
+		}
+	}
+	return urls
+}
+
+// lookup finds a media by its ID string from the URL
+func (h *MediaHandler) lookup(idStr string) (Media, bool) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return Media{}, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, m := range h.medias {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Media{}, false
+}
+
+// mediaExtensions maps accepted sniffed types to the extension files are stored with
+var mediaExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+	"video/mp4":  ".mp4",
+	"video/webm": ".webm",
+	"video/avi":  ".avi",
+}
+
+// storagePath builds "<id>_<random>.<ext>" inside UploadDir and checks it cannot escape it
+func (h *MediaHandler) storagePath(id int, sniffed string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	ext, ok := mediaExtensions[sniffed]
+	if !ok {
+		ext = ".bin"
+	}
+
+	path := filepath.Join(h.UploadDir, fmt.Sprintf("%d_%s%s", id, hex.EncodeToString(suffix), ext))
+	rel, err := filepath.Rel(h.UploadDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("media path %q escapes upload dir", path)
+	}
+	return path, nil
+}
+
+// sanitizeFilename keeps the base name and replaces anything outside [A-Za-z0-9._-]
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, name)
+	safe = strings.TrimLeft(safe, ".")
+	if safe == "" {
+		return "file"
+	}
+	return safe
+}
+
+// fileURL is the public URL a stored file is served from by FileServer
+func (h *MediaHandler) fileURL(path string) string {
+	return strings.TrimSuffix(h.BaseURL, "/") + "/uploads/" + filepath.Base(path)
+}
+
+// FileServer serves the files in UploadDir; mount it under /uploads/ with http.StripPrefix.
+// It does not check who may see a file, so URLs of restricted media rely on their random names
+func (h *MediaHandler) FileServer() http.Handler {
+	return http.FileServer(noListingFS{http.Dir(h.UploadDir)})
+}
+
+// noListingFS hides directories so the file server never lists UploadDir
+type noListingFS struct {
+	fs http.FileSystem
+}
+
+// Open implements http.FileSystem
+func (nfs noListingFS) Open(name string) (http.File, error) {
+	f, err := nfs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
+// contentType guesses from the file extension, falling back to sniffing the first bytes
+func contentType(file io.ReadSeeker, path string) string {
+	if ct := mime.TypeByExtension(filepath.Ext(path)); ct != "" {
+		return ct
+	}
+	ct, _ := sniffContentType(file)
+	return ct
+}
+
+// sniffContentType detects the type from the first 512 bytes and rewinds the file
+func sniffContentType(file io.ReadSeeker) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// matchesMediaType reports whether a sniffed type belongs to the declared media type
+func matchesMediaType(mediaType, sniffed string) bool {
+	switch mediaType {
+	case "image", "avatar":
+		return strings.HasPrefix(sniffed, "image/")
+	case "video":
+		return strings.HasPrefix(sniffed, "video/")
+	}
+	return false
+}
+
+// check returns a non-empty reason when the dimensions break the rules
+func (rules AvatarRules) check(width, height int) string {
+	if rules.MaxWidth > 0 && width > rules.MaxWidth {
+		return fmt.Sprintf("Avatar width %d exceeds max %d", width, rules.MaxWidth)
+	}
+	if rules.MaxHeight > 0 && height > rules.MaxHeight {
+		return fmt.Sprintf("Avatar height %d exceeds max %d", height, rules.MaxHeight)
+	}
+	if rules.RequireSquare && width != height {
+		return "Avatar must be square"
+	}
+	return ""
 }
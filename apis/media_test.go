@@ -0,0 +1,660 @@
+package apis
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// pngBytes encode một ảnh PNG w x h
+func pngBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// upload gửi multipart tới path với các field và file data; trả về response
+func (a *testApp) upload(path, token string, fields map[string]string, files ...[]byte) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for i, data := range files {
+		fw, err := mw.CreateFormFile("file", fmt.Sprintf("f%d.png", i))
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		fw.Write(data)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// uploadImage gắn một ảnh PNG nhỏ vào postID và trả về media_id
+func (a *testApp) uploadImage(token string, postID int) int {
+	a.t.Helper()
+	rec := a.upload("/media", token, map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}, pngBytes(a.t, 4, 4))
+	if rec.Code != http.StatusCreated {
+		a.t.Fatalf("upload: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var resp MediaResponse
+	decodeBody(a.t, rec, &resp)
+	return resp.MediaID
+}
+
+func TestMediaOfHiddenPosts(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	private := a.createPost(alice, map[string]any{"content": "secret", "visibility": "private"}, "")
+	followers := a.createPost(alice, map[string]any{"content": "friends", "visibility": "followers"}, "")
+	draft := a.createPost(alice, map[string]any{"content": "later"}, "status=draft")
+
+	for _, postID := range []int{private, followers, draft} {
+		mediaID := a.uploadImage(alice, postID)
+		for _, token := range []string{"", bob} {
+			a.expect(http.StatusNotFound, "GET", postPath(postID, "/media"), token, nil)
+			a.expect(http.StatusNotFound, "GET", fmt.Sprintf("/media/%d", mediaID), token, nil)
+			a.expect(http.StatusNotFound, "GET", fmt.Sprintf("/media/%d/file", mediaID), token, nil)
+		}
+		a.expect(http.StatusOK, "GET", postPath(postID, "/media"), alice, nil)
+		a.expect(http.StatusOK, "GET", fmt.Sprintf("/media/%d/file", mediaID), alice, nil)
+	}
+
+	// follower thấy media của post followers-only
+	a.expect(http.StatusCreated, "POST", "/users/1/follow", bob, nil)
+	a.expect(http.StatusOK, "GET", postPath(followers, "/media"), bob, nil)
+	a.expect(http.StatusNotFound, "GET", postPath(private, "/media"), bob, nil)
+}
+
+func TestMediaBlockedUser(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	mediaID := a.uploadImage(alice, postID)
+
+	a.expect(http.StatusOK, "GET", fmt.Sprintf("/media/%d", mediaID), bob, nil)
+	a.expect(http.StatusCreated, "POST", "/users/1/block", bob, nil)
+	a.expect(http.StatusNotFound, "GET", fmt.Sprintf("/media/%d", mediaID), bob, nil)
+	a.expect(http.StatusNotFound, "GET", postPath(postID, "/media"), bob, nil)
+	a.expect(http.StatusOK, "GET", fmt.Sprintf("/media/%d", mediaID), "", nil)
+}
+
+// pngHeader dựng phần đầu PNG khai báo kích thước w x h mà không có dữ liệu ảnh
+func pngHeader(w, h uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], w)
+	binary.BigEndian.PutUint32(ihdr[4:], h)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // RGBA
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func TestUploadRejectsHugeImageBeforeDecoding(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+
+	rec := a.upload("/media", alice, map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}, pngHeader(100000, 100000))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, want 422, body %s", rec.Code, rec.Body.String())
+	}
+	rec = a.upload("/media", alice, map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}, []byte("\x89PNG\r\n\x1a\nnot really"))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUploadAvatarRules(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	a.media.AvatarRules = AvatarRules{MaxWidth: 8, RequireSquare: true}
+
+	if rec := a.upload("/me/avatar", alice, nil, pngBytes(t, 16, 16)); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("too wide: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := a.upload("/me/avatar", alice, nil, pngBytes(t, 8, 4)); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("not square: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	rec := a.upload("/me/avatar", alice, nil, pngBytes(t, 8, 8))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var media Media
+	decodeBody(t, rec, &media)
+	// không có worker thì thumbnail được sinh ngay từ file đã lưu
+	if media.Status != MediaReady || media.ThumbnailURL == "" {
+		t.Fatalf("media = %+v", media)
+	}
+
+	// media của post không bị giới hạn avatar
+	postID := a.createPost(alice, map[string]any{"content": "wide"}, "")
+	rec = a.upload("/media", alice, map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}, pngBytes(t, 16, 4))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("post image: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestConcurrentUploadsKeepAllMediaIDs(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "album"}, "")
+	a.media.StartProcessing(2, 50)
+
+	const uploads = 8
+	var wg sync.WaitGroup
+	codes := make([]int, uploads)
+	for i := range uploads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = a.upload("/media", alice, map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}, pngBytes(t, 4, 4)).Code
+		}()
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusCreated {
+			t.Fatalf("upload %d: status %d", i, code)
+		}
+	}
+
+	post, err := a.store.GetPost(context.Background(), postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(post.MediaIDs) != uploads {
+		t.Fatalf("post has media_ids %v, want %d", post.MediaIDs, uploads)
+	}
+	a.waitProcessed()
+}
+
+// waitProcessed chờ worker xử lý xong mọi media, để thư mục tạm không bị xoá khi còn đang ghi
+func (a *testApp) waitProcessed() {
+	a.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		a.media.mu.Lock()
+		pending := slices.ContainsFunc(a.media.medias, func(m Media) bool { return m.Status == MediaProcessing })
+		a.media.mu.Unlock()
+		if !pending {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.t.Fatal("media still processing")
+}
+
+func TestUploadRoutesRateLimited(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+
+	// router riêng chỉ có các route upload sau RateLimit, như main.go
+	a.router = mux.NewRouter()
+	limited := a.router.NewRoute().Subrouter()
+	limited.Use(RateLimit(2))
+	a.media.RegisterUploadRoutes(limited)
+
+	for i := range 2 {
+		if rec := a.upload("/me/avatar", alice, nil, pngBytes(t, 4, 4)); rec.Code != http.StatusCreated {
+			t.Fatalf("upload %d: status %d, body %s", i, rec.Code, rec.Body.String())
+		}
+	}
+	rec := a.upload("/me/avatar", alice, nil, pngBytes(t, 4, 4))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestMediaFetchAndDelete(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.media.BaseURL = "https://cdn.example.com/"
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "pic"}, "")
+	mediaID := a.uploadImage(alice, postID)
+	path := fmt.Sprintf("/media/%d", mediaID)
+
+	var media Media
+	decodeBody(t, a.expect(http.StatusOK, "GET", path, "", nil), &media)
+	if !strings.HasPrefix(media.URL, "https://cdn.example.com/uploads/") || strings.Contains(media.URL, a.media.UploadDir) {
+		t.Fatalf("url = %q", media.URL)
+	}
+	stored, _ := a.media.lookup(fmt.Sprint(mediaID))
+	if _, err := os.Stat(stored.path); err != nil {
+		t.Fatalf("uploaded file: %v", err)
+	}
+
+	a.expect(http.StatusForbidden, "DELETE", path, bob, nil)
+	a.expect(http.StatusNoContent, "DELETE", path, alice, nil)
+	a.expect(http.StatusNotFound, "GET", path, "", nil)
+	a.expect(http.StatusNotFound, "DELETE", path, alice, nil)
+	if _, err := os.Stat(stored.path); !os.IsNotExist(err) {
+		t.Fatalf("file still on disk: %v", err)
+	}
+	var post PostDetail
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, ""), "", nil), &post)
+	if len(post.MediaIDs) != 0 {
+		t.Fatalf("post still links media %v", post.MediaIDs)
+	}
+}
+
+func TestUploadChecksFileContent(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+	fields := func(mediaType string) map[string]string {
+		return map[string]string{"type": mediaType, "post_id": fmt.Sprint(postID)}
+	}
+
+	mediaID := a.uploadImage(alice, postID)
+	rec := a.do("GET", fmt.Sprintf("/media/%d/file", mediaID), "", nil)
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("stored PNG served as %q", ct)
+	}
+
+	// tên file .png nhưng nội dung là text, hoặc PNG khai là video
+	if rec := a.upload("/media", alice, fields("image"), []byte("just some text")); rec.Code != http.StatusBadRequest {
+		t.Fatalf("text as image: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := a.upload("/media", alice, fields("video"), pngBytes(t, 4, 4)); rec.Code != http.StatusBadRequest {
+		t.Fatalf("png as video: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	a.media.MaxFileSize = 64
+	if rec := a.upload("/media", alice, fields("image"), pngBytes(t, 64, 64)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+// uploadNamed như upload nhưng đặt tên file do client gửi
+func (a *testApp) uploadNamed(token string, fields map[string]string, filename string, data []byte) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/media", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUploadFilenameCannotEscapeUploadDir(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+
+	for name, want := range map[string]string{
+		"../../etc/passwd":    "passwd",
+		"foo/bar.png":         "bar.png",
+		`..\..\evil.png`:      "evil.png",
+		"we ird<name>.png":    "we_ird_name_.png",
+		"../../../.hidden.sh": "hidden.sh",
+	} {
+		rec := a.uploadNamed(alice, map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}, name, pngBytes(t, 4, 4))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s: status %d, body %s", name, rec.Code, rec.Body.String())
+		}
+		var resp MediaResponse
+		decodeBody(t, rec, &resp)
+		media, _ := a.media.lookup(fmt.Sprint(resp.MediaID))
+		if media.Filename != want {
+			t.Fatalf("%s: filename %q, want %q", name, media.Filename, want)
+		}
+		rel, err := filepath.Rel(a.media.UploadDir, media.path)
+		if err != nil || filepath.Dir(rel) != "." || !strings.HasPrefix(rel, fmt.Sprintf("%d_", media.ID)) {
+			t.Fatalf("%s: stored at %q", name, media.path)
+		}
+	}
+}
+
+func TestUploadLinksMediaToPost(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+	fields := func(postID string) map[string]string {
+		return map[string]string{"type": "image", "post_id": postID}
+	}
+
+	for _, missing := range []string{"999", "abc", ""} {
+		if rec := a.upload("/media", alice, fields(missing), pngBytes(t, 4, 4)); rec.Code != http.StatusNotFound {
+			t.Fatalf("post_id %q: status %d, body %s", missing, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := a.upload("/media", bob, fields(fmt.Sprint(postID)), pngBytes(t, 4, 4)); rec.Code != http.StatusForbidden {
+		t.Fatalf("not the author: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	first := a.uploadImage(alice, postID)
+	second := a.uploadImage(alice, postID)
+	var post PostDetail
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, ""), "", nil), &post)
+	if !slices.Equal(post.MediaIDs, []int{first, second}) {
+		t.Fatalf("media_ids = %v, want %v", post.MediaIDs, []int{first, second})
+	}
+}
+
+func TestNewMediaHandlerUploadDir(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pic"}, "")
+
+	// thư mục chưa tồn tại, upload đầu tiên phải tạo nó
+	dir := filepath.Join(t.TempDir(), "custom", "uploads")
+	media := NewMediaHandler(a.store, dir)
+	media.Tokens = a.tokens
+	a.router = mux.NewRouter()
+	media.RegisterUploadRoutes(a.router)
+
+	data := pngBytes(t, 4, 4)
+	rec := a.uploadNamed(alice, map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}, "a.png", data)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body.String())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("upload dir %s: %v, %v", dir, entries, err)
+	}
+	stored, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil || !bytes.Equal(stored, data) {
+		t.Fatalf("stored file differs from upload: %v", err)
+	}
+	if _, err := os.Stat("uploads"); err == nil {
+		t.Fatal("upload also wrote to ./uploads")
+	}
+}
+
+func TestUploadedFilesServedUnderUploads(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.router.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", a.media.FileServer())).Methods("GET", "HEAD")
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pic"}, "")
+
+	data := pngBytes(t, 4, 4)
+	rec := a.uploadNamed(alice, map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}, "a.png", data)
+	var resp MediaResponse
+	decodeBody(t, rec, &resp)
+	var media Media
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/media/%d", resp.MediaID), alice, nil), &media)
+	if !strings.HasPrefix(media.URL, "/uploads/") {
+		t.Fatalf("URL = %q, want a public /uploads/ path", media.URL)
+	}
+
+	rec = a.expect(http.StatusOK, "GET", media.URL, "", nil)
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Fatal("served file differs from upload")
+	}
+
+	// không liệt kê thư mục
+	a.expect(http.StatusNotFound, "GET", "/uploads/", "", nil)
+	a.expect(http.StatusNotFound, "GET", "/uploads/missing.png", "", nil)
+}
+
+func TestUploadMediaBatch(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "album"}, "")
+	fields := map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}
+	text := []byte("not an image")
+
+	rec := a.upload("/media/batch", alice, fields, pngBytes(t, 4, 4), text, pngBytes(t, 8, 8))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("mixed batch: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var batch BatchUploadResponse
+	decodeBody(t, rec, &batch)
+	if len(batch.Media) != 2 || len(batch.Errors) != 1 || batch.Errors[0].Index != 1 || batch.Errors[0].Filename != "f1.png" {
+		t.Fatalf("mixed batch = %+v", batch)
+	}
+	var linked []Media
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/media"), alice, nil), &linked)
+	if len(linked) != 2 {
+		t.Fatalf("post media = %+v", linked)
+	}
+	files, _ := os.ReadDir(a.media.UploadDir)
+
+	// all_or_nothing: một file lỗi thì không file nào được giữ
+	rec = a.upload("/media/batch?all_or_nothing=true", alice, fields, pngBytes(t, 4, 4), text)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("all or nothing: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	decodeBody(t, rec, &batch)
+	if len(batch.Media) != 0 || len(batch.Errors) != 1 {
+		t.Fatalf("all or nothing = %+v", batch)
+	}
+	if after, _ := os.ReadDir(a.media.UploadDir); len(after) != len(files) {
+		t.Fatalf("files on disk %d, want %d after rollback", len(after), len(files))
+	}
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/media"), alice, nil), &linked)
+	if len(linked) != 2 {
+		t.Fatalf("post media after rollback = %+v", linked)
+	}
+}
+
+func TestGetPostMedia(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	withMedia := a.createPost(alice, map[string]any{"content": "pics"}, "")
+	without := a.createPost(alice, map[string]any{"content": "text only"}, "")
+	first := a.uploadImage(alice, withMedia)
+	second := a.uploadImage(alice, withMedia)
+
+	var media []Media
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(withMedia, "/media"), "", nil), &media)
+	if len(media) != 2 || media[0].ID != first || media[1].ID != second {
+		t.Fatalf("post media = %+v, want %d then %d", media, first, second)
+	}
+	for _, m := range media {
+		if m.PostID != withMedia || !strings.HasPrefix(m.URL, "/uploads/") {
+			t.Fatalf("media = %+v", m)
+		}
+	}
+
+	rec := a.expect(http.StatusOK, "GET", postPath(without, "/media"), "", nil)
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Fatalf("post without media = %s, want []", body)
+	}
+	a.expect(http.StatusNotFound, "GET", postPath(999, "/media"), "", nil)
+}
+
+func TestUploadBodyTooLargeOrMalformed(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+	fields := map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}
+
+	// body vượt MaxFileSize + 1 MB cho các field bị MaxBytesReader chặn khi parse form
+	a.media.MaxFileSize = 1024
+	rec := a.upload("/media", alice, fields, make([]byte, 2<<20))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized body: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var apiErr APIError
+	decodeBody(t, rec, &apiErr)
+	if !strings.Contains(apiErr.Error, "1024 bytes") {
+		t.Fatalf("oversized body message = %q", apiErr.Error)
+	}
+
+	req := httptest.NewRequest("POST", "/media", strings.NewReader("--x\r\nnot a part header"))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	req.Header.Set("Authorization", "Bearer "+alice)
+	rec = httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed multipart: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	decodeBody(t, rec, &apiErr)
+	if apiErr.Error != "Malformed multipart form data" {
+		t.Fatalf("malformed multipart message = %q", apiErr.Error)
+	}
+}
+
+func TestCleanupOrphanedMedia(t *testing.T) {
+	a := newTestApp(t, nil)
+	adminID, admin := a.register("admin")
+	a.tokens.Admins[adminID] = true
+	_, alice := a.register("alice")
+	live := a.createPost(alice, map[string]any{"content": "keep"}, "")
+	gone := a.createPost(alice, map[string]any{"content": "drop"}, "")
+	missing := a.createPost(alice, map[string]any{"content": "no file"}, "")
+	kept := a.uploadedMedia(alice, live, "image", "a.png", pngBytes(t, 4, 4))
+	orphan := a.uploadedMedia(alice, gone, "image", "b.png", pngBytes(t, 4, 4))
+	noFile := a.uploadedMedia(alice, missing, "image", "c.png", pngBytes(t, 4, 4))
+	a.waitProcessed()
+	a.expect(http.StatusNoContent, "DELETE", postPath(gone, ""), alice, nil)
+	a.expect(http.StatusNoContent, "DELETE", postPath(missing, ""), alice, nil)
+	// file đã mất trước khi dọn: record vẫn bị bỏ
+	if err := os.Remove(noFile.path); err != nil {
+		t.Fatal(err)
+	}
+
+	a.expect(http.StatusForbidden, "POST", "/admin/media/cleanup", alice, nil)
+	var resp CleanupResponse
+	decodeBody(t, a.expect(http.StatusOK, "POST", "/admin/media/cleanup", admin, nil), &resp)
+	if resp.Removed != 2 {
+		t.Fatalf("removed %d, want 2", resp.Removed)
+	}
+
+	if _, err := os.Stat(orphan.path); !os.IsNotExist(err) {
+		t.Fatalf("orphaned file still on disk: %v", err)
+	}
+	for _, m := range []Media{orphan, noFile} {
+		if _, ok := a.media.lookup(fmt.Sprint(m.ID)); ok {
+			t.Fatalf("media %d still listed", m.ID)
+		}
+	}
+	if _, err := os.Stat(kept.path); err != nil {
+		t.Fatalf("live post's file removed: %v", err)
+	}
+	a.expect(http.StatusOK, "GET", fmt.Sprintf("/media/%d", kept.ID), alice, nil)
+	if stored, _ := a.store.ListMedia(t.Context()); len(stored) != 1 || stored[0].ID != kept.ID {
+		t.Fatalf("stored media after cleanup = %+v", stored)
+	}
+
+	decodeBody(t, a.expect(http.StatusOK, "POST", "/admin/media/cleanup", admin, nil), &resp)
+	if resp.Removed != 0 {
+		t.Fatalf("second run removed %d", resp.Removed)
+	}
+}
+
+func TestUploadAvatarUpdatesProfile(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+
+	rec := a.upload("/me/avatar", alice, nil, pngBytes(t, 8, 8))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var media Media
+	decodeBody(t, rec, &media)
+	if media.Type != "avatar" || media.PostID != 0 || media.UserID != aliceID || media.URL == "" {
+		t.Fatalf("media = %+v", media)
+	}
+	var profile UserProfile
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d", aliceID), "", nil), &profile)
+	if profile.Avatar != media.URL {
+		t.Fatalf("avatar = %q, want %q", profile.Avatar, media.URL)
+	}
+
+	// file text bị từ chối và avatar cũ giữ nguyên
+	if rec := a.upload("/me/avatar", alice, nil, []byte("just some text")); rec.Code != http.StatusBadRequest {
+		t.Fatalf("text file: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d", aliceID), "", nil), &profile)
+	if profile.Avatar != media.URL {
+		t.Fatalf("avatar after rejected upload = %q", profile.Avatar)
+	}
+	if rec := a.upload("/me/avatar", "", nil, pngBytes(t, 8, 8)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without token: status %d", rec.Code)
+	}
+}
+
+func TestGetMediaFileRange(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+	data := pngBytes(t, 64, 64)
+	media := a.uploadedMedia(alice, postID, "image", "big.png", data)
+	path := fmt.Sprintf("/media/%d/file", media.ID)
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		a.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("Range", "bytes=0-99")
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("range: status %d", rec.Code)
+	}
+	if got, want := rec.Header().Get("Content-Range"), fmt.Sprintf("bytes 0-99/%d", len(data)); got != want {
+		t.Fatalf("Content-Range = %q, want %q", got, want)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), data[:100]) {
+		t.Fatalf("partial body has %d bytes, not the first 100 of the file", rec.Body.Len())
+	}
+	if rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+
+	rec = a.do("GET", path, "", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Accept-Ranges") != "bytes" || !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Fatalf("full file: status %d, Accept-Ranges %q, %d bytes", rec.Code, rec.Header().Get("Accept-Ranges"), rec.Body.Len())
+	}
+	if rec := get("Range", fmt.Sprintf("bytes=%d-", len(data)+10)); rec.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("range past the end: status %d", rec.Code)
+	}
+	if rec := get("If-Modified-Since", rec.Header().Get("Last-Modified")); rec.Code != http.StatusNotModified {
+		t.Fatalf("If-Modified-Since: status %d", rec.Code)
+	}
+}
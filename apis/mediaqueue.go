@@ -0,0 +1,106 @@
+package apis
+
+import (
+	"context"
+	"log"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Trạng thái xử lý của media, xem qua GET /media/{media_id}
+const (
+	MediaProcessing = "processing" // ảnh đang chờ sinh thumbnail
+	MediaReady      = "ready"
+	MediaFailed     = "failed" // không sinh được thumbnail, file gốc vẫn dùng được
+)
+
+// Mặc định của hàng đợi xử lý media khi không cấu hình
+const (
+	DefaultMediaWorkers   = 2
+	DefaultMediaQueueSize = 100
+)
+
+// thumbnailJob is a stored image waiting for its thumbnail
+type thumbnailJob struct {
+	mediaID int
+	src     string // file gốc đã lưu, chỉ decode khi sinh thumbnail
+	format  string
+	path    string // nơi ghi thumbnail
+}
+
+// mediaQueue is a bounded queue of thumbnail jobs drained by a fixed number of workers
+type mediaQueue struct {
+	mu   sync.Mutex // giữ khi gửi để kiểm tra chỗ trống và gửi cả batch một lần
+	jobs chan thumbnailJob
+}
+
+// enqueue adds all jobs or none, so a batch is never half processed
+func (q *mediaQueue) enqueue(jobs []thumbnailJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if cap(q.jobs)-len(q.jobs) < len(jobs) {
+		return false
+	}
+	for _, job := range jobs {
+		q.jobs <- job
+	}
+	return true
+}
+
+// StartProcessing moves thumbnail generation off the upload request to workers
+// background goroutines, with room for queueSize waiting images. Without it
+// thumbnails are made while the request is served.
+func (h *MediaHandler) StartProcessing(workers, queueSize int) {
+	// một batch phải vừa hàng đợi, nếu không sẽ luôn bị từ chối
+	q := &mediaQueue{jobs: make(chan thumbnailJob, max(queueSize, maxBatchFiles))}
+	for range max(workers, 1) {
+		go h.thumbnailWorker(q.jobs)
+	}
+
+	h.mu.Lock()
+	h.queue = q
+	h.mu.Unlock()
+}
+
+// thumbnailWorker processes jobs until the channel is closed
+func (h *MediaHandler) thumbnailWorker(jobs <-chan thumbnailJob) {
+	for job := range jobs {
+		err := writeThumbnail(job.src, job.format, job.path)
+
+		h.mu.Lock()
+		i := slices.IndexFunc(h.medias, func(m Media) bool { return m.ID == job.mediaID })
+		if i >= 0 {
+			h.applyThumbnail(&h.medias[i], job, err)
+			if err := h.saveMedia(context.Background(), h.medias[i]); err != nil {
+				log.Println("save media", job.mediaID, ":", err)
+			}
+		} else if err == nil {
+			// media đã bị xoá hoặc upload bị rollback trong lúc xử lý
+			os.Remove(job.path)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// makeThumbnails makes the thumbnails of freshly stored media in the request, for when no
+// workers were started; media is not recorded yet so h.mu is not needed
+func (h *MediaHandler) makeThumbnails(media []Media, jobs []thumbnailJob) {
+	for _, job := range jobs {
+		i := slices.IndexFunc(media, func(m Media) bool { return m.ID == job.mediaID })
+		h.applyThumbnail(&media[i], job, writeThumbnail(job.src, job.format, job.path))
+	}
+}
+
+// applyThumbnail records the outcome of job on m
+func (h *MediaHandler) applyThumbnail(m *Media, job thumbnailJob, err error) {
+	if err != nil {
+		log.Println("thumbnail media", job.mediaID, ":", err)
+		m.Status = MediaFailed
+		return
+	}
+	m.Status = MediaReady
+	m.ThumbnailURL = h.fileURL(job.path)
+	m.thumbPath = job.path
+}
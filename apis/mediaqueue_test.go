@@ -0,0 +1,85 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// mediaStatus đọc GET /media/{media_id}
+func (a *testApp) mediaStatus(token string, mediaID int) Media {
+	a.t.Helper()
+	var m Media
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/media/%d", mediaID), token, nil), &m)
+	return m
+}
+
+func TestUploadProcessingThenReady(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+	fields := map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}
+
+	// hàng đợi một chỗ, chưa có worker: job nằm chờ để thấy được trạng thái processing
+	q := &mediaQueue{jobs: make(chan thumbnailJob, 1)}
+	a.media.mu.Lock()
+	a.media.queue = q
+	a.media.mu.Unlock()
+
+	rec := a.upload("/media", alice, fields, pngBytes(t, 64, 64))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var uploaded MediaResponse
+	decodeBody(t, rec, &uploaded)
+	if m := a.mediaStatus(alice, uploaded.MediaID); m.Status != MediaProcessing || m.ThumbnailURL != "" {
+		t.Fatalf("media while queued = %+v", m)
+	}
+
+	// hàng đợi đầy thì từ chối ngay, không để upload chờ
+	rec = a.upload("/media", alice, fields, pngBytes(t, 4, 4))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("full queue: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	go a.media.thumbnailWorker(q.jobs)
+	t.Cleanup(func() { close(q.jobs) })
+	a.waitProcessed()
+	m := a.mediaStatus(alice, uploaded.MediaID)
+	if m.Status != MediaReady || m.ThumbnailURL == "" {
+		t.Fatalf("media after processing = %+v", m)
+	}
+	stored, _ := a.media.lookup(fmt.Sprint(uploaded.MediaID))
+	if _, err := os.Stat(stored.thumbPath); err != nil {
+		t.Fatalf("thumbnail not written: %v", err)
+	}
+}
+
+func TestStartProcessingMakesThumbnails(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+	a.media.StartProcessing(1, 1)
+
+	// queueSize nhỏ hơn một batch vẫn được nới cho vừa maxBatchFiles
+	a.media.mu.Lock()
+	capacity := cap(a.media.queue.jobs)
+	a.media.mu.Unlock()
+	if capacity != maxBatchFiles {
+		t.Fatalf("queue capacity %d, want %d", capacity, maxBatchFiles)
+	}
+
+	id := a.uploadImage(alice, postID)
+	deadline := time.Now().Add(5 * time.Second)
+	for a.mediaStatus(alice, id).Status == MediaProcessing {
+		if time.Now().After(deadline) {
+			t.Fatal("media still processing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if m := a.mediaStatus(alice, id); m.Status != MediaReady {
+		t.Fatalf("media = %+v", m)
+	}
+}
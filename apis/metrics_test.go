@@ -0,0 +1,39 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsCountByRouteTemplate(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.router.Use(Metrics)
+	a.router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	counter := httpRequestsTotal.WithLabelValues("GET", "/posts/{post_id}", "404")
+	before := testutil.ToFloat64(counter)
+	a.expect(http.StatusNotFound, "GET", "/posts/41", "", nil)
+	a.expect(http.StatusNotFound, "GET", "/posts/42", "", nil)
+	if got := testutil.ToFloat64(counter) - before; got != 2 {
+		t.Fatalf("counter grew by %v, want 2", got)
+	}
+
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `http_requests_total{method="GET",route="/posts/{post_id}",status="404"}`) {
+		t.Fatal("/metrics does not expose the route counter")
+	}
+	// ID thật không được thành label
+	if strings.Contains(body, `route="/posts/41"`) {
+		t.Fatal("/metrics labels requests by raw path")
+	}
+	if !strings.Contains(body, `http_request_duration_seconds_bucket{method="GET",route="/posts/{post_id}",status="404"`) {
+		t.Fatal("/metrics does not expose the latency histogram")
+	}
+}
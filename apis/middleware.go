@@ -0,0 +1,484 @@
+package apis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
+)
+
+// contextKey là kiểu key của các giá trị lưu trong context của request
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	requestIDKey
+	apiVersionKey
+)
+
+// WithUserID trả về bản sao của ctx mang user ID đã xác thực
+func WithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// UserIDFromContext trả về user ID đã xác thực do RequireAuth đặt vào
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userIDKey).(int)
+	return userID, ok
+}
+
+// RequestIDHeader mang request ID ở cả request lẫn response
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength giới hạn độ dài request ID client gửi trước khi ghi vào log
+const maxRequestIDLength = 128
+
+// RequestIDFromContext trả về ID do RequestID gán, hoặc ""
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// RequestID giữ X-Request-ID của client (hoặc sinh UUID khi thiếu hay không dùng được),
+// lưu vào context và trả lại trong response
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		// đặt header trước để writeJSONError đọc lại được
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// validRequestID chỉ nhận ID ngắn gồm ký tự ASCII in được, không có khoảng trắng
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// APIVersion là phiên bản định dạng response của bản build này
+const APIVersion = "1"
+
+// supportedAPIVersions là các phiên bản client được yêu cầu qua Accept-Version;
+// handler rẽ nhánh theo APIVersionFromContext khi còn giữ định dạng cũ
+var supportedAPIVersions = map[string]bool{
+	APIVersion: true,
+}
+
+// APIVersionFromContext trả về phiên bản do Versioning chọn
+func APIVersionFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(apiVersionKey).(string); ok {
+		return v
+	}
+	return APIVersion
+}
+
+// Versioning trả 406 khi Accept-Version là phiên bản không hỗ trợ, ngược lại
+// lưu phiên bản vào context và trả lại trong header API-Version
+func Versioning(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := strings.TrimSpace(r.Header.Get("Accept-Version"))
+		if version == "" {
+			version = APIVersion
+		}
+		if !supportedAPIVersions[version] {
+			w.Header().Set("API-Version", APIVersion)
+			writeJSONError(w, http.StatusNotAcceptable, "Unsupported API version "+strconv.Quote(version))
+			return
+		}
+		w.Header().Set("API-Version", version)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey, version)))
+	})
+}
+
+// RequireAuth từ chối request không có header "Authorization: Bearer <token>" hợp lệ
+// hoặc có token của tài khoản đã xoá, và lưu user ID của token vào context của request
+func (s *TokenService) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			writeJSONError(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+
+		userID, err := s.activeUser(strings.TrimSpace(token))
+		if err != nil {
+			writeJSONError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), userID)))
+	})
+}
+
+// RequireAdmin giống RequireAuth nhưng trả thêm 403 nếu user không có trong s.Admins
+func (s *TokenService) RequireAdmin(next http.Handler) http.Handler {
+	return s.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		if !s.Admins[userID] {
+			writeJSONError(w, http.StatusForbidden, "Admin only")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// OptionalAuth lưu user ID của bearer token hợp lệ vào context nhưng, khác RequireAuth,
+// vẫn cho request ẩn danh hoặc token sai đi tiếp
+func (s *TokenService) OptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			if userID, err := s.activeUser(strings.TrimSpace(token)); err == nil {
+				r = r.WithContext(WithUserID(r.Context(), userID))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CORS cho phép client trình duyệt từ allowedOrigins gọi API và trả 204 cho
+// request preflight; danh sách rỗng thì cho mọi origin
+func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		allowed[o] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin != "" {
+				if len(allowed) == 0 {
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				} else if allowed[origin] {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Add("Vary", "Origin")
+				}
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Accept, X-Request-ID, Idempotency-Key, Accept-Version")
+				w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, ETag, API-Version")
+			}
+
+			// preflight không cần đi tới router
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NotFound là NotFoundHandler của router: trả 404 dạng JSON như mọi lỗi khác
+func NotFound() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSONError(w, http.StatusNotFound, "Route not found")
+	})
+}
+
+// MethodNotAllowed là MethodNotAllowedHandler của router: trả 405 dạng JSON với
+// header Allow liệt kê các method router có cho path của request
+func MethodNotAllowed(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowedMethods(router, r), ", "))
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	})
+}
+
+// allowedMethods thử từng method khai báo trên các route với path của r
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	seen := map[string]bool{}
+	allowed := []string{}
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, m := range methods {
+			probe := r.Clone(r.Context())
+			probe.Method = m
+			if !seen[m] && route.Match(probe, &mux.RouteMatch{}) {
+				seen[m] = true
+				allowed = append(allowed, m)
+			}
+		}
+		return nil
+	})
+	sort.Strings(allowed)
+	return allowed
+}
+
+// RequireJSON trả 415 cho request POST/PUT/PATCH có body mà Content-Type không phải
+// application/json. Route có path template nằm trong exempt (các upload multipart) được
+// cho qua; dùng với router.Use để biết route đã khớp
+func RequireJSON(exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !writesBody(r) || jsonContentType(r.Header.Get("Content-Type")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if route := mux.CurrentRoute(r); route != nil {
+				if tpl, err := route.GetPathTemplate(); err == nil && slices.Contains(exempt, tpl) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			writeJSONError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		})
+	}
+}
+
+// writesBody cho biết r có phải POST/PUT/PATCH có body (kể cả chunked) không;
+// các endpoint như POST /posts/{post_id}/like được gọi không có body
+func writesBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return r.ContentLength != 0 && r.Body != nil && r.Body != http.NoBody
+	}
+	return false
+}
+
+// jsonContentType nhận "application/json" với tham số bất kỳ, vd charset
+func jsonContentType(header string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	return err == nil && mediaType == "application/json"
+}
+
+// DefaultRequestTimeout là thời gian tối đa của một request khi không cấu hình
+const DefaultRequestTimeout = 30 * time.Second
+
+// Timeout trả 503 kèm lỗi JSON khi handler chưa xong trong thời gian d. Context của
+// handler bị huỷ khi hết hạn để query store dừng lại, và mọi thứ handler ghi sau đó bị bỏ.
+// Route có path template nằm trong exempt (upload, download file) chạy thẳng trên w, không giới hạn; d <= 0 thì tắt middleware
+func Timeout(d time.Duration, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route := mux.CurrentRoute(r); route != nil {
+				if tpl, err := route.GetPathTemplate(); err == nil && slices.Contains(exempt, tpl) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			// bắt đầu từ header đã có (X-Request-ID...) để handler vẫn đọc được
+			tw := &timeoutWriter{w: w, header: w.Header().Clone()}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				tw.flush()
+			case <-ctx.Done():
+				tw.mu.Lock()
+				tw.timedOut = true
+				tw.mu.Unlock()
+				// client ngắt kết nối thì không cần trả lời
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					writeJSONError(w, http.StatusServiceUnavailable, "Request timed out")
+				}
+			}
+		})
+	}
+}
+
+// timeoutWriter giữ response trong buffer tới khi handler xong để handler chạy quá hạn
+// không bao giờ ghi sau khi Timeout đã trả 503
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.status == 0 && !tw.timedOut {
+		tw.status = status
+	}
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
+
+// Unwrap để writeJSONError tìm được writer ProblemDetails; handler không được ghi thẳng vào đó
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// flush chép response trong buffer ra writer thật sau khi handler đã return
+func (tw *timeoutWriter) flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	dst := tw.w.Header()
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	tw.w.WriteHeader(tw.status)
+	tw.w.Write(tw.buf.Bytes())
+}
+
+// statusRecorder nhớ status code được ghi qua nó
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap để http.ResponseController tới được writer bên dưới
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Logging ghi method, path, status và latency của mỗi request thành một dòng slog
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		slog.Info("request",
+			"request_id", RequestIDFromContext(r.Context()),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"latency", time.Since(start),
+		)
+	})
+}
+
+// ipLimiter là token bucket của một IP client
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// limiterIdleTTL là thời gian giữ bucket của một IP sau request cuối cùng
+const limiterIdleTTL = 3 * time.Minute
+
+// RateLimit cho mỗi IP client perMinute request mỗi phút (burst tối đa perMinute)
+// và trả 429 kèm Retry-After khi bucket đã hết; perMinute <= 0 thì không giới hạn
+func RateLimit(perMinute int) func(http.Handler) http.Handler {
+	if perMinute <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	var mu sync.Mutex
+	limiters := make(map[string]*ipLimiter)
+	var lastPrune time.Time
+	every := rate.Every(time.Minute / time.Duration(perMinute))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r)
+			now := time.Now()
+
+			mu.Lock()
+			// dọn các IP không hoạt động để map không phình mãi, tối đa mỗi phút một lần
+			if now.Sub(lastPrune) > time.Minute {
+				for addr, l := range limiters {
+					if now.Sub(l.lastSeen) > limiterIdleTTL {
+						delete(limiters, addr)
+					}
+				}
+				lastPrune = now
+			}
+			l, ok := limiters[ip]
+			if !ok {
+				l = &ipLimiter{limiter: rate.NewLimiter(every, perMinute)}
+				limiters[ip] = l
+			}
+			l.lastSeen = now
+			res := l.limiter.ReserveN(now, 1)
+			delay := res.DelayFrom(now)
+			if delay > 0 {
+				res.CancelAt(now)
+			}
+			mu.Unlock()
+
+			if delay > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				writeJSONError(w, http.StatusTooManyRequests, "Too many requests")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP trả về phần host của RemoteAddr
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
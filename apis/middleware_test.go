@@ -0,0 +1,409 @@
+package apis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	h := CORS([]string{"https://app.example.com"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for origin, want := range map[string]string{
+		"https://app.example.com":  "https://app.example.com",
+		"https://evil.example.com": "",
+	} {
+		req := httptest.NewRequest("OPTIONS", "/posts", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s: preflight status %d", origin, rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Fatalf("%s: Access-Control-Allow-Origin = %q, want %q", origin, got, want)
+		}
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	tokens := NewTokenService([]byte("test-secret"))
+	h := tokens.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := UserIDFromContext(r.Context())
+		writeJSON(w, http.StatusOK, map[string]int{"user_id": userID})
+	}))
+	valid, err := tokens.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		header string
+		status int
+	}{
+		"valid":     {"Bearer " + valid, http.StatusOK},
+		"missing":   {"", http.StatusUnauthorized},
+		"no bearer": {valid, http.StatusUnauthorized},
+		"malformed": {"Bearer not-a-jwt", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/me", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: status %d, want %d", name, rec.Code, tc.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("%s: Content-Type = %q", name, ct)
+		}
+		if tc.status != http.StatusOK {
+			var apiErr APIError
+			decodeBody(t, rec, &apiErr)
+			if apiErr.Code != "unauthorized" {
+				t.Fatalf("%s: error = %+v", name, apiErr)
+			}
+			continue
+		}
+		var body map[string]int
+		decodeBody(t, rec, &body)
+		if body["user_id"] != 7 {
+			t.Fatalf("context user = %v, want 7", body)
+		}
+	}
+}
+
+func TestLoggingCapturesStatus(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	for path, status := range map[string]int{
+		"/created":  http.StatusCreated,
+		"/missing":  http.StatusNotFound,
+		"/implicit": 0, // handler chỉ ghi body, status mặc định là 200
+	} {
+		buf.Reset()
+		h := Logging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if status != 0 {
+				w.WriteHeader(status)
+			}
+			w.Write([]byte("ok"))
+		}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+
+		want := status
+		if want == 0 {
+			want = http.StatusOK
+		}
+		if rec.Code != want {
+			t.Fatalf("%s: response status %d, want %d", path, rec.Code, want)
+		}
+		var line struct {
+			Method string `json:"method"`
+			Path   string `json:"path"`
+			Status int    `json:"status"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatalf("%s: log line %q: %v", path, buf.String(), err)
+		}
+		if line.Method != "POST" || line.Path != path || line.Status != want {
+			t.Fatalf("%s: logged %+v, want status %d", path, line, want)
+		}
+	}
+}
+
+func TestRateLimitPerIP(t *testing.T) {
+	h := RateLimit(3)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/login", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// cùng IP, khác port vẫn chung một bucket
+	for i := range 3 {
+		if rec := send(fmt.Sprintf("10.0.0.1:%d", 4000+i)); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, rec.Code)
+		}
+	}
+	rec := send("10.0.0.1:5000")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("request 4: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var apiErr APIError
+	decodeBody(t, rec, &apiErr)
+	if apiErr.Code != "too_many_requests" {
+		t.Fatalf("error = %+v", apiErr)
+	}
+
+	if rec := send("10.0.0.2:1000"); rec.Code != http.StatusOK {
+		t.Fatalf("other IP: status %d", rec.Code)
+	}
+}
+
+func TestRateLimitDisabledAndNoJanitor(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	// perMinute <= 0 là không giới hạn, không chia cho 0
+	for _, perMinute := range []int{0, -1} {
+		h := RateLimit(perMinute)(ok)
+		for i := range 100 {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("POST", "/login", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("RateLimit(%d) request %d: status %d", perMinute, i+1, rec.Code)
+			}
+		}
+	}
+
+	// mỗi RateLimit không để lại goroutine dọn dẹp chạy mãi
+	before := runtime.NumGoroutine()
+	for range 50 {
+		RateLimit(10)(ok).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/login", nil))
+	}
+	if after := runtime.NumGoroutine(); after > before+5 {
+		t.Fatalf("goroutines %d -> %d after 50 limiters", before, after)
+	}
+}
+
+func TestRequestIDPropagated(t *testing.T) {
+	a := newTestApp(t, nil)
+	h := RequestID(a.router)
+
+	for name, tc := range map[string]struct {
+		header   string
+		generate bool
+	}{
+		"provided":  {"req-123", false},
+		"absent":    {"", true},
+		"too long":  {strings.Repeat("x", maxRequestIDLength+1), true},
+		"has space": {"bad id", true},
+	} {
+		req := httptest.NewRequest("GET", "/posts/999", nil)
+		if tc.header != "" {
+			req.Header.Set(RequestIDHeader, tc.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if tc.generate {
+			if _, err := uuid.Parse(id); err != nil {
+				t.Fatalf("%s: generated ID %q is not a UUID", name, id)
+			}
+		} else if id != tc.header {
+			t.Fatalf("%s: echoed ID %q, want %q", name, id, tc.header)
+		}
+		// ID trong body lỗi khớp với header
+		var apiErr APIError
+		decodeBody(t, rec, &apiErr)
+		if apiErr.RequestID != id {
+			t.Fatalf("%s: error body request_id %q, header %q", name, apiErr.RequestID, id)
+		}
+	}
+}
+
+func TestVersioning(t *testing.T) {
+	h := Versioning(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"version": APIVersionFromContext(r.Context())})
+	}))
+
+	for requested, status := range map[string]int{
+		"":          http.StatusOK,
+		APIVersion:  http.StatusOK,
+		" 1 ":       http.StatusOK,
+		"2":         http.StatusNotAcceptable,
+		"v1-legacy": http.StatusNotAcceptable,
+	} {
+		req := httptest.NewRequest("GET", "/posts", nil)
+		if requested != "" {
+			req.Header.Set("Accept-Version", requested)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Fatalf("Accept-Version %q: status %d, want %d", requested, rec.Code, status)
+		}
+		if got := rec.Header().Get("API-Version"); got != APIVersion {
+			t.Fatalf("Accept-Version %q: API-Version = %q", requested, got)
+		}
+		if status == http.StatusOK {
+			var body map[string]string
+			decodeBody(t, rec, &body)
+			if body["version"] != APIVersion {
+				t.Fatalf("Accept-Version %q: version in context = %q", requested, body["version"])
+			}
+		}
+	}
+}
+
+func TestMethodNotAllowedAndNotFound(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.router.NotFoundHandler = NotFound()
+	a.router.MethodNotAllowedHandler = MethodNotAllowed(a.router)
+
+	rec := a.do("PUT", "/posts/1", "", nil)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("PUT /posts/1: status %d", rec.Code)
+	}
+	if got := rec.Header().Get("Allow"); got != "DELETE, GET, PATCH" {
+		t.Fatalf("Allow = %q", got)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("405 Content-Type = %q", ct)
+	}
+
+	rec = a.do("GET", "/no/such/route", "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown path: status %d", rec.Code)
+	}
+	var apiErr APIError
+	decodeBody(t, rec, &apiErr)
+	if apiErr.Error != "Route not found" {
+		t.Fatalf("404 body = %+v", apiErr)
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.router.Use(RequireJSON("/media"))
+	_, alice := a.register("alice")
+	body := `{"content":"hello"}`
+
+	for contentType, status := range map[string]int{
+		"application/json":                  http.StatusCreated,
+		"application/json; charset=utf-8":   http.StatusCreated,
+		"text/plain":                        http.StatusUnsupportedMediaType,
+		"application/x-www-form-urlencoded": http.StatusUnsupportedMediaType,
+		"":                                  http.StatusUnsupportedMediaType,
+	} {
+		req := httptest.NewRequest("POST", "/posts", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("Authorization", "Bearer "+alice)
+		rec := httptest.NewRecorder()
+		a.router.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Fatalf("Content-Type %q: status %d, want %d, body %s", contentType, rec.Code, status, rec.Body.String())
+		}
+	}
+
+	// không có body và upload multipart thì được miễn
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+	a.expect(http.StatusOK, "POST", postPath(postID, "/like/toggle"), alice, nil)
+	a.uploadImage(alice, postID)
+}
+
+func TestTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(200 * time.Millisecond):
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"late": "yes"})
+	})
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Fast", "1")
+		writeJSON(w, http.StatusCreated, map[string]string{"ok": "yes"})
+	})
+
+	router := mux.NewRouter()
+	router.Handle("/slow", slow)
+	router.Handle("/fast", fast)
+	router.Handle("/media", slow)
+	router.Use(Timeout(20*time.Millisecond, "/media"))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	var apiErr APIError
+	decodeBody(t, rec, &apiErr)
+	if rec.Code != http.StatusServiceUnavailable || apiErr.Error != "Request timed out" {
+		t.Fatalf("slow handler: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	// context của handler bị huỷ, phần nó ghi sau đó bị bỏ
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("handler context not cancelled")
+	}
+	if strings.Contains(rec.Body.String(), "late") {
+		t.Fatalf("late write reached the client: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/fast", nil))
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-Fast") != "1" {
+		t.Fatalf("fast handler: status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	// route upload được miễn: chạy hết dù quá thời hạn
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/media", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("exempt route: status %d", rec.Code)
+	}
+}
+
+func TestHandlersActAsAuthenticatedUser(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice") // user 1, từng là user demo mặc định
+	bobID, bob := a.register("bob")
+
+	// có token: mọi handler ghi nhận đúng user của token
+	postID := a.createPost(bob, map[string]any{"content": "from bob"}, "")
+	if detail := a.postDetail("", postID); detail.UserID != bobID {
+		t.Fatalf("post author = %d, want bob %d", detail.UserID, bobID)
+	}
+	commentID := a.comment(bob, postID, 0, "bob again")
+	if author, _ := a.comments.commentAuthor(commentID); author != bobID {
+		t.Fatalf("comment author = %d, want bob %d", author, bobID)
+	}
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), alice, map[string]string{"reaction_type": "like"})
+	if reactions := a.reacts.reactionsFor(postID); reactions[aliceID] != "like" || len(reactions) != 1 {
+		t.Fatalf("reactions = %v", reactions)
+	}
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+	if !a.follows.isFollowing(bobID, aliceID) || a.follows.isFollowing(aliceID, bobID) {
+		t.Fatal("follow recorded for the wrong user")
+	}
+	if n := a.notifications(bob, "").Notifications; len(n) != 1 || n[0].UserID != bobID {
+		t.Fatalf("bob's notifications = %+v", n)
+	}
+
+	// không có token: không có user demo nào để rơi về
+	for _, route := range []struct{ method, path string }{
+		{"POST", "/posts"},
+		{"POST", postPath(postID, "/comments")},
+		{"POST", postPath(postID, "/reactions")},
+		{"POST", fmt.Sprintf("/users/%d/follow", bobID)},
+		{"GET", "/notifications"},
+	} {
+		a.expect(http.StatusUnauthorized, route.method, route.path, "", map[string]string{"content": "x", "reaction_type": "like"})
+	}
+	private := a.createPost(alice, map[string]any{"content": "only me", "visibility": "private"}, "")
+	a.expect(http.StatusNotFound, "GET", postPath(private, ""), "", nil)
+}
@@ -0,0 +1,137 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// errTargetNotEmpty là lỗi khi database đích đã có dữ liệu mà không truyền force=true
+var errTargetNotEmpty = errors.New("target store is not empty")
+
+// storeSnapshot là toàn bộ dữ liệu của một Store, đọc qua các method List*
+type storeSnapshot struct {
+	posts     []Post
+	revisions map[int][]PostRevision // post_id -> revisions, mới nhất trước
+	users     []User
+	profiles  []UserProfile
+	comments  map[int][]Comment // post_id -> comments
+	media     []Media
+}
+
+// MigrateResponse là số bản ghi mỗi loại đã chép, trả về bởi POST /admin/migrate
+type MigrateResponse struct {
+	Posts         int `json:"posts"`
+	PostRevisions int `json:"post_revisions"`
+	Users         int `json:"users"`
+	Profiles      int `json:"profiles"`
+	Comments      int `json:"comments"`
+	Media         int `json:"media"`
+}
+
+// counts đếm số bản ghi của snapshot
+func (s storeSnapshot) counts() MigrateResponse {
+	resp := MigrateResponse{
+		Posts:    len(s.posts),
+		Users:    len(s.users),
+		Profiles: len(s.profiles),
+		Media:    len(s.media),
+	}
+	for _, revs := range s.revisions {
+		resp.PostRevisions += len(revs)
+	}
+	for _, comments := range s.comments {
+		resp.Comments += len(comments)
+	}
+	return resp
+}
+
+// snapshot đọc mọi thứ src đang giữ, kể cả post đã xoá mềm và draft
+func snapshot(ctx context.Context, src Store) (storeSnapshot, error) {
+	var snap storeSnapshot
+	var err error
+	if snap.posts, err = src.ListPosts(ctx, PostFilter{IncludeDeleted: true}); err != nil {
+		return snap, err
+	}
+	snap.revisions = make(map[int][]PostRevision)
+	for _, p := range snap.posts {
+		revs, err := src.ListPostRevisions(ctx, p.PostID)
+		if err != nil {
+			return snap, err
+		}
+		if len(revs) > 0 {
+			snap.revisions[p.PostID] = revs
+		}
+	}
+	if snap.users, err = src.ListUsers(ctx); err != nil {
+		return snap, err
+	}
+	if snap.profiles, err = src.ListProfiles(ctx); err != nil {
+		return snap, err
+	}
+	if snap.comments, err = src.ListComments(ctx); err != nil {
+		return snap, err
+	}
+	if snap.media, err = src.ListMedia(ctx); err != nil {
+		return snap, err
+	}
+	return snap, nil
+}
+
+// MigrateHandler chép dữ liệu của store đang chạy (thường là MemoryStore của bản demo)
+// sang database SQLite qua POST /admin/migrate
+type MigrateHandler struct {
+	Source Store        // store server đang dùng
+	Target *SQLiteStore // database đích, nil thì endpoint trả 501
+	Tokens *TokenService
+}
+
+// RegisterRoutes đăng ký endpoint migrate, chỉ admin gọi được
+func (h *MigrateHandler) RegisterRoutes(router *mux.Router) {
+	router.Handle("/admin/migrate", h.Tokens.RequireAdmin(http.HandlerFunc(h.Migrate))).Methods("POST")
+}
+
+// Migrate godoc
+// @Summary Migrate data to the persistent store
+// @Description Copy every post (with edit history), user, profile, comment and media record of the running
+// @Description store into the SQLite database set by MIGRATE_DB_PATH, in one transaction, keeping their IDs.
+// @Description Refuses with 409 when the database already has data unless force=true, which replaces it.
+// @Description Writes made while it runs may be missed; restart with STORE=sqlite to use the copy. Admin only
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param force query bool false "Replace the data already in the target database"
+// @Success 200 {object} MigrateResponse
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 409 {object} APIError
+// @Failure 501 {object} APIError
+// @Router /admin/migrate [post]
+func (h *MigrateHandler) Migrate(w http.ResponseWriter, r *http.Request) {
+	if h.Target == nil {
+		writeJSONError(w, http.StatusNotImplemented, "No migration target configured")
+		return
+	}
+	if !requireStore(w, h.Source) {
+		return
+	}
+	force := r.URL.Query().Get("force") == "true"
+
+	snap, err := snapshot(r.Context(), h.Source)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot read current data")
+		return
+	}
+	err = h.Target.importSnapshot(r.Context(), snap, force)
+	if errors.Is(err, errTargetNotEmpty) {
+		writeJSONError(w, http.StatusConflict, "Target database is not empty, use force=true to replace its data")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot write target database")
+		return
+	}
+	writeJSON(w, http.StatusOK, snap.counts())
+}
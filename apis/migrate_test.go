@@ -0,0 +1,82 @@
+package apis
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateMemoryToSQLite(t *testing.T) {
+	a := newTestApp(t, nil)
+	adminID, admin := a.register("admin")
+	a.tokens.Admins[adminID] = true
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+
+	published := a.createPost(alice, map[string]any{"content": "first"}, "")
+	a.createPost(alice, map[string]any{"content": "later"}, "status=draft")
+	deleted := a.createPost(bob, map[string]any{"content": "oops"}, "")
+	a.expect(http.StatusNoContent, "DELETE", postPath(deleted, ""), bob, nil)
+	a.expect(http.StatusOK, "PATCH", postPath(published, ""), alice, map[string]string{"content": "first, edited"})
+	a.expect(http.StatusCreated, "POST", postPath(published, "/comments"), bob, map[string]string{"content": "nice"})
+	a.uploadImage(alice, published)
+
+	path := filepath.Join(t.TempDir(), "app.db")
+	target, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrate := &MigrateHandler{Source: a.store, Target: target, Tokens: a.tokens}
+	migrate.RegisterRoutes(a.router)
+
+	a.expect(http.StatusForbidden, "POST", "/admin/migrate", alice, nil)
+
+	var counts MigrateResponse
+	decodeBody(t, a.expect(http.StatusOK, "POST", "/admin/migrate", admin, nil), &counts)
+	want := MigrateResponse{Posts: 3, PostRevisions: 2, Users: 3, Profiles: 3, Comments: 1, Media: 1}
+	if counts != want {
+		t.Fatalf("counts = %+v, want %+v", counts, want)
+	}
+
+	// đích đã có dữ liệu: chỉ chạy lại khi force=true, và không bị nhân đôi
+	a.expect(http.StatusConflict, "POST", "/admin/migrate", admin, nil)
+	decodeBody(t, a.expect(http.StatusOK, "POST", "/admin/migrate?force=true", admin, nil), &counts)
+	if counts != want {
+		t.Fatalf("counts with force = %+v, want %+v", counts, want)
+	}
+	target.Close()
+
+	// khởi động lại trên database đích
+	target, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	copied, err := snapshot(t.Context(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := copied.counts(); got != want {
+		t.Fatalf("target holds %+v, want %+v", got, want)
+	}
+
+	b := newTestApp(t, target)
+	b.expect(http.StatusOK, "POST", "/login", "", LoginRequest{Login: "alice", Password: "password1"})
+	var post PostDetail
+	decodeBody(t, b.expect(http.StatusOK, "GET", postPath(published, ""), "", nil), &post)
+	if post.Content != "first, edited" || len(post.MediaIDs) != 1 {
+		t.Fatalf("migrated post = %+v", post)
+	}
+	if id := b.createPost(alice, map[string]any{"content": "after"}, ""); id <= deleted {
+		t.Fatalf("new post reused ID %d", id)
+	}
+}
+
+func TestMigrateWithoutTarget(t *testing.T) {
+	a := newTestApp(t, nil)
+	adminID, admin := a.register("admin")
+	a.tokens.Admins[adminID] = true
+	(&MigrateHandler{Source: a.store, Tokens: a.tokens}).RegisterRoutes(a.router)
+
+	a.expect(http.StatusNotImplemented, "POST", "/admin/migrate", admin, nil)
+}
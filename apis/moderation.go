@@ -0,0 +1,49 @@
+package apis
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// Moderator decides whether user-written text may be published; reason is
+// shown to the client when allowed is false
+type Moderator interface {
+	Check(ctx context.Context, text string) (allowed bool, reason string)
+}
+
+// NopModerator allows everything; it is the default of every handler
+type NopModerator struct{}
+
+// Check implements Moderator
+func (NopModerator) Check(context.Context, string) (bool, string) { return true, "" }
+
+// WordListModerator rejects text containing any of Words as a whole word, ignoring case
+type WordListModerator struct {
+	Words []string
+}
+
+// Check implements Moderator
+func (m WordListModerator) Check(_ context.Context, text string) (bool, string) {
+	banned := make(map[string]bool, len(m.Words))
+	for _, w := range m.Words {
+		banned[strings.ToLower(w)] = true
+	}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if banned[w] {
+			return false, "Content contains a banned word"
+		}
+	}
+	return true, ""
+}
+
+// moderate runs m on text; a nil moderator allows everything
+func moderate(ctx context.Context, m Moderator, text string) (bool, string) {
+	if m == nil {
+		return true, ""
+	}
+	return m.Check(ctx, text)
+}
@@ -0,0 +1,52 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestWordListModerator(t *testing.T) {
+	m := WordListModerator{Words: []string{"Spam"}}
+	for text, allowed := range map[string]bool{
+		"hello world":       true,
+		"buy SPAM now":      false,
+		"spam!":             false,
+		"spammer and spamz": true, // chỉ chặn nguyên từ
+	} {
+		if ok, reason := m.Check(t.Context(), text); ok != allowed || (!ok && reason == "") {
+			t.Errorf("Check(%q) = %v, %q, want %v", text, ok, reason, allowed)
+		}
+	}
+	if ok, _ := moderate(t.Context(), nil, "spam"); !ok {
+		t.Fatal("nil moderator rejected content")
+	}
+}
+
+func TestModerationRejectsBannedContent(t *testing.T) {
+	a := newTestApp(t, nil)
+	moderator := WordListModerator{Words: []string{"spam"}}
+	a.posts.Moderator = moderator
+	a.comments.Moderator = moderator
+	_, alice := a.register("alice")
+
+	a.expect(http.StatusUnprocessableEntity, "POST", "/posts", alice, map[string]string{"content": "buy spam"})
+	postID := a.createPost(alice, map[string]any{"content": "clean post"}, "")
+	rec := a.expect(http.StatusUnprocessableEntity, "PATCH", postPath(postID, ""), alice, map[string]string{"content": "now spam"})
+	var apiErr APIError
+	decodeBody(t, rec, &apiErr)
+	if apiErr.Error != "Content contains a banned word" {
+		t.Fatalf("error = %+v", apiErr)
+	}
+	a.expect(http.StatusOK, "PATCH", postPath(postID, ""), alice, map[string]string{"content": "still clean"})
+
+	a.expect(http.StatusUnprocessableEntity, "POST", postPath(postID, "/comments"), alice, map[string]string{"content": "Spam!"})
+	commentID := a.comment(alice, postID, 0, "nice")
+	a.expect(http.StatusUnprocessableEntity, "PUT", fmt.Sprintf("/comments/%d", commentID), alice, map[string]string{"content": "spam"})
+
+	var post PostDetail
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, ""), "", nil), &post)
+	if post.Content != "still clean" {
+		t.Fatalf("content = %q", post.Content)
+	}
+}
@@ -0,0 +1,104 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Giới hạn danh sách muted words của mỗi user
+const (
+	maxMutedWords      = 100
+	maxMutedWordLength = 50
+)
+
+// @Summary Get My Muted Words
+// @Description Words whose posts are hidden from the current user's feed
+// @Tags feeds
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} string
+// @Failure 401 {object} APIError
+// @Router /me/muted-words [get]
+func (h *FeedsHandler) GetMutedWords(w http.ResponseWriter, r *http.Request) {
+	currentUserID, _ := UserIDFromContext(r.Context())
+	writeJSON(w, http.StatusOK, h.mutedWords(currentUserID))
+}
+
+// @Summary Set My Muted Words
+// @Description Replace the muted word list; GET /feeds drops posts containing any of them
+// @Description as a whole word, ignoring case. Words are letters or digits only, stored lowercase
+// @Tags feeds
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body []string true "Muted words (at most 100, each at most 50 characters)"
+// @Success 200 {array} string
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Router /me/muted-words [put]
+func (h *FeedsHandler) SetMutedWords(w http.ResponseWriter, r *http.Request) {
+	var words []string
+	if err := decodeJSON(w, r, &words, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(words) > maxMutedWords {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d muted words", maxMutedWords))
+		return
+	}
+
+	// chuẩn hoá về lowercase, bỏ trùng, giữ thứ tự client gửi
+	seen := make(map[string]bool, len(words))
+	muted := []string{}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if !validMutedWord(word) {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Muted words must be single words of letters or digits, at most %d characters", maxMutedWordLength))
+			return
+		}
+		if !seen[word] {
+			seen[word] = true
+			muted = append(muted, word)
+		}
+	}
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	h.mu.Lock()
+	if h.muted == nil {
+		h.muted = make(map[int][]string)
+	}
+	if len(muted) == 0 {
+		delete(h.muted, currentUserID)
+	} else {
+		h.muted[currentUserID] = muted
+	}
+	h.mu.Unlock()
+	h.invalidateFeed(currentUserID)
+
+	writeJSON(w, http.StatusOK, muted)
+}
+
+// validMutedWord only accepts what WordListModerator can match as a whole word
+func validMutedWord(word string) bool {
+	if word == "" || utf8.RuneCountInString(word) > maxMutedWordLength {
+		return false
+	}
+	for _, r := range word {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// mutedWords returns a copy of userID's muted words, never nil
+func (h *FeedsHandler) mutedWords(userID int) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]string{}, h.muted[userID]...)
+}
@@ -0,0 +1,63 @@
+package apis
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMutedWordsFilterFeed(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	a.expect(http.StatusCreated, "POST", "/users/1/follow", bob, nil)
+	spoiler := a.createPost(alice, map[string]any{"content": "The ENDING was wild"}, "")
+	partial := a.createPost(alice, map[string]any{"content": "no spoilers, just endings"}, "")
+	other := a.createPost(alice, map[string]any{"content": "lunch time"}, "")
+
+	var words []string
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/me/muted-words", bob, nil), &words)
+	if words == nil || len(words) != 0 {
+		t.Fatalf("initial muted words = %#v", words)
+	}
+	// lấy feed trước để có cache: đổi muted words phải làm mới cache
+	if got := feedPostIDs(a.feed(bob, "").Feeds); len(got) != 3 {
+		t.Fatalf("feed before muting = %v", got)
+	}
+
+	decodeBody(t, a.expect(http.StatusOK, "PUT", "/me/muted-words", bob, []string{" Ending ", "ending", "spoiler"}), &words)
+	if !slices.Equal(words, []string{"ending", "spoiler"}) {
+		t.Fatalf("saved muted words = %v", words)
+	}
+	// chỉ khớp nguyên từ, không phân biệt hoa thường
+	if got := feedPostIDs(a.feed(bob, "").Feeds); !slices.Equal(got, []int{other, partial}) {
+		t.Fatalf("feed with muted words = %v, want %v", got, []int{other, partial})
+	}
+	if got := feedPostIDs(a.feed(alice, "").Feeds); !slices.Equal(got, []int{other, partial, spoiler}) {
+		t.Fatalf("alice's feed = %v", got)
+	}
+
+	a.expect(http.StatusOK, "PUT", "/me/muted-words", bob, []string{})
+	if got := feedPostIDs(a.feed(bob, "").Feeds); len(got) != 3 {
+		t.Fatalf("feed after clearing = %v", got)
+	}
+}
+
+func TestMutedWordsValidated(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+
+	for name, body := range map[string][]string{
+		"too many":   slices.Repeat([]string{"word"}, maxMutedWords+1),
+		"empty":      {""},
+		"two words":  {"two words"},
+		"punctuated": {"don't"},
+		"too long":   {strings.Repeat("a", maxMutedWordLength+1)},
+	} {
+		if code := a.do("PUT", "/me/muted-words", alice, body).Code; code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, code)
+		}
+	}
+	a.expect(http.StatusUnauthorized, "PUT", "/me/muted-words", "", []string{"a"})
+}
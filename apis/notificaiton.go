@@ -1,7 +1,9 @@
 package apis
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"sync"
@@ -12,6 +14,7 @@ import (
 // Notification represents a user notification
 type Notification struct {
 	ID           int    `json:"id"`
+	UserID       int    `json:"user_id"` // recipient
 	Type         string `json:"type"`
 	SourceUserID int    `json:"source_user_id,omitempty"`
 	PostID       int    `json:"post_id,omitempty"`
@@ -19,30 +22,116 @@ type Notification struct {
 	CreatedAt    string `json:"created_at"`
 }
 
+// Notification types
+const (
+	NotifFollow   = "follow"
+	NotifReaction = "reaction"
+	NotifComment  = "comment"
+	NotifMention  = "mention"
+)
+
+// ErrInvalidNotificationType is returned by Push for a type outside the constants above
+var ErrInvalidNotificationType = errors.New("invalid notification type")
+
+// ValidNotificationType reports whether t is one of the Notif* constants
+func ValidNotificationType(t string) bool {
+	switch t {
+	case NotifFollow, NotifReaction, NotifComment, NotifMention:
+		return true
+	}
+	return false
+}
+
 // NotificationResponse represents response for list
 type NotificationResponse struct {
-	Notifications []Notification `json:"notifications,omitempty"`
-	Total         int            `json:"total,omitempty"`
-	Error         string         `json:"error,omitempty"`
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unread_count"`
+	PageMeta
+}
+
+// MarkAllReadResponse represents response for read-all
+type MarkAllReadResponse struct {
+	Updated int `json:"updated"`
+}
+
+// DeleteNotificationsResponse represents response for DELETE /notifications
+type DeleteNotificationsResponse struct {
+	Deleted int `json:"deleted"`
 }
 
 // NotificationHandler handles notifications
 type NotificationHandler struct {
 	mu            sync.Mutex
-	notifications []Notification
+	notifications map[int]*Notification // key = notification id, tra cứu O(1)
+	order         []int                 // id theo thứ tự tạo, dùng để liệt kê
+	ids           IDGenerator
+	Tokens        *TokenService
 }
 
 // NewNotificationHandler constructor
 func NewNotificationHandler() *NotificationHandler {
 	return &NotificationHandler{
-		notifications: make([]Notification, 0),
+		notifications: make(map[int]*Notification),
 	}
 }
 
 // RegisterRoutes register notification routes
 func (h *NotificationHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/notifications", h.GetNotifications).Methods("GET")
-	router.HandleFunc("/notifications/{notification_id}", h.MarkAsRead).Methods("PATCH")
+	router.Handle("/notifications", h.Tokens.RequireAuth(http.HandlerFunc(h.GetNotifications))).Methods("GET")
+	router.Handle("/notifications", h.Tokens.RequireAuth(http.HandlerFunc(h.DeleteNotifications))).Methods("DELETE")
+	// read-all phải đăng ký trước {notification_id}
+	router.Handle("/notifications/read-all", h.Tokens.RequireAuth(http.HandlerFunc(h.MarkAllAsRead))).Methods("PATCH")
+	router.Handle("/notifications/{notification_id}", h.Tokens.RequireAuth(http.HandlerFunc(h.MarkAsRead))).Methods("PATCH")
+	router.Handle("/notifications/{notification_id}", h.Tokens.RequireAuth(http.HandlerFunc(h.DeleteNotification))).Methods("DELETE")
+}
+
+// Push stores a notification, assigning its ID and CreatedAt
+func (h *NotificationHandler) Push(n Notification) (Notification, error) {
+	if !ValidNotificationType(n.Type) {
+		return Notification{}, ErrInvalidNotificationType
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.notifications == nil {
+		h.notifications = make(map[int]*Notification)
+	}
+	n.ID = h.ids.Next()
+	n.CreatedAt = nowRFC3339()
+	stored := n
+	h.notifications[n.ID] = &stored
+	h.order = append(h.order, n.ID)
+	return n, nil
+}
+
+// notify pushes a notification for userID caused by sourceID; it is a no-op on a
+// nil handler and when users act on their own content
+func (h *NotificationHandler) notify(userID, sourceID int, typ string, postID int) {
+	if h == nil || userID == 0 || userID == sourceID {
+		return
+	}
+	_, err := h.Push(Notification{
+		UserID:       userID,
+		Type:         typ,
+		SourceUserID: sourceID,
+		PostID:       postID,
+	})
+	if err != nil {
+		log.Println("notify", userID, typ, ":", err)
+	}
+}
+
+// postAuthor returns the owner of a post, or 0 when it cannot be found
+func postAuthor(ctx context.Context, posts Store, postID int) int {
+	if posts == nil {
+		return 0
+	}
+	p, err := posts.GetPost(ctx, postID)
+	if err != nil {
+		return 0
+	}
+	return p.UserID
 }
 
 // @Summary Get Notifications
@@ -50,36 +139,69 @@ func (h *NotificationHandler) RegisterRoutes(router *mux.Router) {
 // @Tags notifications
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Bearer token"
+// @Security BearerAuth
 // @Param offset query int false "Offset"
-// @Param limit query int false "Limit"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Param read query bool false "Only read (true) or unread (false) notifications"
+// @Param type query string false "Only notifications of this type" Enums(follow, reaction, comment, mention)
 // @Success 200 {object} NotificationResponse
-// @Failure 400 {object} NotificationResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
 // @Router /notifications [get]
 func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	currentUserID, _ := UserIDFromContext(r.Context())
 
-	offsetStr := r.URL.Query().Get("offset")
-	limitStr := r.URL.Query().Get("limit")
+	var readFilter *bool
+	if v := r.URL.Query().Get("read"); v != "" {
+		read, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "read must be true or false")
+			return
+		}
+		readFilter = &read
+	}
+	typeFilter := r.URL.Query().Get("type")
+	if typeFilter != "" && !ValidNotificationType(typeFilter) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid type, use follow, reaction, comment or mention")
+		return
+	}
 
-	offset, _ := strconv.Atoi(offsetStr)
-	limit := 10
-	if l, err := strconv.Atoi(limitStr); err == nil {
-		limit = l
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
 	}
 
-	total := len(h.notifications)
-	end := offset + limit
-	if end > total {
-		end = total
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// lọc trước rồi mới phân trang; unread_count luôn tính trên toàn bộ
+	mine := []Notification{}
+	unread := 0
+	for _, id := range h.order {
+		n := *h.notifications[id]
+		if n.UserID != currentUserID {
+			continue
+		}
+		if !n.Read {
+			unread++
+		}
+		if readFilter != nil && n.Read != *readFilter {
+			continue
+		}
+		if typeFilter != "" && n.Type != typeFilter {
+			continue
+		}
+		mine = append(mine, n)
 	}
 
-	result := h.notifications[offset:end]
+	total := len(mine)
+	start, end := pageBounds(total, offset, limit)
+	result := mine[start:end]
 
-	json.NewEncoder(w).Encode(NotificationResponse{
+	writeJSON(w, http.StatusOK, NotificationResponse{
 		Notifications: result,
-		Total:         total,
+		UnreadCount:   unread,
+		PageMeta:      newPageMeta(total, start, end, limit),
 	})
 }
 
@@ -89,40 +211,150 @@ func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Re
 // @Accept json
 // @Produce json
 // @Param notification_id path int true "Notification ID"
-// @Param Authorization header string true "Bearer token"
+// @Security BearerAuth
 // @Param body body map[string]bool false "Optional read body"
 // @Success 200 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 403 {object} map[string]string
+// @Failure 401 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 403 {object} APIError
 // @Router /notifications/{notification_id} [patch]
 func (h *NotificationHandler) MarkAsRead(w http.ResponseWriter, r *http.Request) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	vars := mux.Vars(r)
 	idStr := vars["notification_id"]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid notification ID"})
+		writeJSONError(w, http.StatusBadRequest, "Invalid notification ID")
 		return
 	}
 
-	for i, n := range h.notifications {
-		if n.ID == id {
-			// Giả lập current user = 1
-			if n.SourceUserID != 1 {
-				w.WriteHeader(http.StatusForbidden)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Forbidden"})
-				return
-			}
+	currentUserID, _ := UserIDFromContext(r.Context())
 
-			h.notifications[i].Read = true
-			json.NewEncoder(w).Encode(map[string]string{"message": "Notification marked as read"})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, ok := h.notifications[id]
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+	if n.UserID != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	n.Read = true
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Notification marked as read"})
+}
+
+// @Summary Mark All Notifications as Read
+// @Description Mark every notification of the current user as read and return how many changed
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} MarkAllReadResponse
+// @Failure 401 {object} APIError
+// @Router /notifications/read-all [patch]
+func (h *NotificationHandler) MarkAllAsRead(w http.ResponseWriter, r *http.Request) {
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	updated := 0
+	for _, n := range h.notifications {
+		if n.UserID == currentUserID && !n.Read {
+			n.Read = true
+			updated++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, MarkAllReadResponse{Updated: updated})
+}
+
+// @Summary Delete Notifications
+// @Description Delete every notification of the current user, or only the read (read=true) or unread (read=false) ones
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param read query bool false "Only delete read (true) or unread (false) notifications"
+// @Success 200 {object} DeleteNotificationsResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Router /notifications [delete]
+func (h *NotificationHandler) DeleteNotifications(w http.ResponseWriter, r *http.Request) {
+	var readFilter *bool
+	if v := r.URL.Query().Get("read"); v != "" {
+		read, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "read must be true or false")
 			return
 		}
+		readFilter = &read
 	}
 
-	w.WriteHeader(http.StatusNotFound)
-	json.NewEncoder(w).Encode(map[string]string{"error": "Notification not found"})
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	deleted := h.removeWhere(func(n *Notification) bool {
+		return n.UserID == currentUserID && (readFilter == nil || n.Read == *readFilter)
+	})
+
+	writeJSON(w, http.StatusOK, DeleteNotificationsResponse{Deleted: deleted})
+}
+
+// @Summary Delete Notification
+// @Description Delete one notification of the current user
+// @Tags notifications
+// @Param notification_id path int true "Notification ID"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /notifications/{notification_id} [delete]
+func (h *NotificationHandler) DeleteNotification(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["notification_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, ok := h.notifications[id]
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+	if n.UserID != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	h.removeWhere(func(n *Notification) bool { return n.ID == id })
+	writeNoContent(w)
+}
+
+// removeWhere deletes the notifications matching match from the map and the order index
+// and returns how many were removed; the caller must hold h.mu
+func (h *NotificationHandler) removeWhere(match func(*Notification) bool) int {
+	kept := h.order[:0]
+	removed := 0
+	for _, id := range h.order {
+		if match(h.notifications[id]) {
+			delete(h.notifications, id)
+			removed++
+			continue
+		}
+		kept = append(kept, id)
+	}
+	h.order = kept
+	return removed
 }
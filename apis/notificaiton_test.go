@@ -0,0 +1,316 @@
+package apis
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// notifications đọc GET /notifications?query của token
+func (a *testApp) notifications(token, query string) NotificationResponse {
+	a.t.Helper()
+	var resp NotificationResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", "/notifications?"+query, token, nil), &resp)
+	return resp
+}
+
+func TestReactionNotifiesPostAuthor(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+
+	got := a.notifications(alice, "").Notifications
+	if len(got) != 1 {
+		t.Fatalf("alice has %d notifications, want 1", len(got))
+	}
+	n := got[0]
+	if n.Type != NotifReaction || n.UserID != aliceID || n.SourceUserID != bobID || n.PostID != postID || n.Read {
+		t.Fatalf("notification = %+v", n)
+	}
+	if mine := a.notifications(bob, ""); mine.Total != 0 {
+		t.Fatalf("bob got %d notifications", mine.Total)
+	}
+}
+
+// push lưu thẳng một notification cho userID, không qua event nào
+func (a *testApp) push(userID int, typ string) Notification {
+	a.t.Helper()
+	n, err := a.notifs.Push(Notification{UserID: userID, Type: typ, SourceUserID: 99})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return n
+}
+
+func TestNotificationFilters(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	follow := a.push(aliceID, NotifFollow)
+	a.push(aliceID, NotifComment)
+	a.push(aliceID, NotifComment)
+	a.push(aliceID+1, NotifComment)
+	a.expect(http.StatusOK, "PATCH", fmt.Sprintf("/notifications/%d", follow.ID), alice, nil)
+
+	all := a.notifications(alice, "")
+	if all.Total != 3 || all.UnreadCount != 2 {
+		t.Fatalf("all: total %d, unread %d", all.Total, all.UnreadCount)
+	}
+	unread := a.notifications(alice, "read=false")
+	if unread.Total != 2 || unread.UnreadCount != 2 || slices.ContainsFunc(unread.Notifications, func(n Notification) bool { return n.Read }) {
+		t.Fatalf("unread = %+v", unread)
+	}
+	read := a.notifications(alice, "read=true")
+	if read.Total != 1 || read.Notifications[0].ID != follow.ID {
+		t.Fatalf("read = %+v", read)
+	}
+	comments := a.notifications(alice, "type=comment")
+	if comments.Total != 2 || comments.UnreadCount != 2 {
+		t.Fatalf("comments = %+v", comments)
+	}
+	if both := a.notifications(alice, "type=follow&read=false"); both.Total != 0 {
+		t.Fatalf("unread follows = %+v", both)
+	}
+	a.expect(http.StatusBadRequest, "GET", "/notifications?read=maybe", alice, nil)
+}
+
+func TestMarkAllNotificationsRead(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	bobID, _ := a.register("bob")
+	for i := 0; i < 3; i++ {
+		a.push(aliceID, NotifComment)
+	}
+	a.push(bobID, NotifComment)
+
+	var resp MarkAllReadResponse
+	decodeBody(t, a.expect(http.StatusOK, "PATCH", "/notifications/read-all", alice, nil), &resp)
+	if resp.Updated != 3 {
+		t.Fatalf("updated %d, want 3", resp.Updated)
+	}
+	if got := a.notifications(alice, "read=true"); got.Total != 3 || got.UnreadCount != 0 {
+		t.Fatalf("after read-all = %+v", got)
+	}
+	// gọi lại không còn gì để đổi
+	decodeBody(t, a.expect(http.StatusOK, "PATCH", "/notifications/read-all", alice, nil), &resp)
+	if resp.Updated != 0 {
+		t.Fatalf("second read-all updated %d", resp.Updated)
+	}
+	if n := a.notifs.notifications[4]; n.UserID != bobID || n.Read {
+		t.Fatalf("bob's notification = %+v", n)
+	}
+}
+
+func TestNotificationTypesValidated(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+
+	for _, typ := range []string{"", "folow", "Follow", "like"} {
+		if _, err := a.notifs.Push(Notification{UserID: aliceID, Type: typ}); !errors.Is(err, ErrInvalidNotificationType) {
+			t.Fatalf("Push(%q) error = %v", typ, err)
+		}
+	}
+	if got := a.notifications(alice, ""); got.Total != 0 {
+		t.Fatalf("rejected pushes stored %+v", got.Notifications)
+	}
+
+	for _, typ := range []string{NotifFollow, NotifReaction, NotifComment, NotifMention} {
+		a.push(aliceID, typ)
+	}
+	mentions := a.notifications(alice, "type="+NotifMention)
+	if mentions.Total != 1 || mentions.Notifications[0].Type != NotifMention {
+		t.Fatalf("mention filter = %+v", mentions)
+	}
+	a.expect(http.StatusBadRequest, "GET", "/notifications?type=folow", alice, nil)
+}
+
+func TestReactionChangeDoesNotNotifyAgain(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	react := func(token, typ string) {
+		a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), token, map[string]string{"reaction_type": typ})
+	}
+
+	react(bob, "like")
+	if got := a.notifications(alice, "").Total; got != 1 {
+		t.Fatalf("after first reaction: %d notifications, want 1", got)
+	}
+	react(bob, "love")
+	react(bob, "like")
+	if got := a.notifications(alice, "").Total; got != 1 {
+		t.Fatalf("after changing the reaction: %d notifications, want 1", got)
+	}
+
+	react(alice, "like")
+	if got := a.notifications(alice, "").Total; got != 1 {
+		t.Fatalf("after self-reaction: %d notifications, want 1", got)
+	}
+}
+
+func TestMarkMiddleNotificationRead(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+	var ids []int
+	for range 3 {
+		ids = append(ids, a.push(aliceID, NotifComment).ID)
+	}
+
+	a.expect(http.StatusForbidden, "PATCH", fmt.Sprintf("/notifications/%d", ids[1]), bob, nil)
+	a.expect(http.StatusNotFound, "PATCH", "/notifications/99", alice, nil)
+	a.expect(http.StatusOK, "PATCH", fmt.Sprintf("/notifications/%d", ids[1]), alice, nil)
+
+	got := a.notifications(alice, "")
+	var gotIDs []int
+	var read []bool
+	for _, n := range got.Notifications {
+		gotIDs = append(gotIDs, n.ID)
+		read = append(read, n.Read)
+	}
+	if !slices.Equal(gotIDs, ids) || !slices.Equal(read, []bool{false, true, false}) || got.UnreadCount != 2 {
+		t.Fatalf("after marking the middle one: ids %v, read %v, unread %d", gotIDs, read, got.UnreadCount)
+	}
+}
+
+// BenchmarkMarkAsRead đánh dấu notification giữa danh sách; thời gian mỗi lần không
+// tăng theo số notification đang giữ
+func BenchmarkMarkAsRead(b *testing.B) {
+	for _, size := range []int{1_000, 100_000} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			h := NewNotificationHandler()
+			h.Tokens = NewTokenService([]byte("bench-secret"))
+			router := mux.NewRouter()
+			h.RegisterRoutes(router)
+			for i := range size {
+				if _, err := h.Push(Notification{UserID: 1 + i%2, Type: NotifComment, SourceUserID: 3}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			token, err := h.Tokens.Issue(1)
+			if err != nil {
+				b.Fatal(err)
+			}
+			path := fmt.Sprintf("/notifications/%d", size/2+1)
+
+			for b.Loop() {
+				req := httptest.NewRequest("PATCH", path, nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					b.Fatalf("status %d", rec.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteNotifications(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	var ids []int
+	for range 4 {
+		ids = append(ids, a.push(aliceID, NotifComment).ID)
+	}
+	bobs := a.push(bobID, NotifComment)
+	for _, id := range ids[:2] {
+		a.expect(http.StatusOK, "PATCH", fmt.Sprintf("/notifications/%d", id), alice, nil)
+	}
+
+	// chỉ xoá notification đã đọc
+	var resp DeleteNotificationsResponse
+	decodeBody(t, a.expect(http.StatusOK, "DELETE", "/notifications?read=true", alice, nil), &resp)
+	if resp.Deleted != 2 {
+		t.Fatalf("deleted read = %d, want 2", resp.Deleted)
+	}
+	if got := a.notifications(alice, ""); got.Total != 2 || got.UnreadCount != 2 {
+		t.Fatalf("after deleting read = %+v", got)
+	}
+
+	// xoá một notification: chỉ người nhận được xoá
+	a.expect(http.StatusForbidden, "DELETE", fmt.Sprintf("/notifications/%d", ids[2]), bob, nil)
+	a.expect(http.StatusNoContent, "DELETE", fmt.Sprintf("/notifications/%d", ids[2]), alice, nil)
+	a.expect(http.StatusNotFound, "DELETE", fmt.Sprintf("/notifications/%d", ids[2]), alice, nil)
+	if got := a.notifications(alice, ""); got.Total != 1 || got.Notifications[0].ID != ids[3] {
+		t.Fatalf("after deleting one = %+v", got)
+	}
+
+	// xoá hết của alice, của bob vẫn còn
+	decodeBody(t, a.expect(http.StatusOK, "DELETE", "/notifications", alice, nil), &resp)
+	if resp.Deleted != 1 {
+		t.Fatalf("deleted all = %d, want 1", resp.Deleted)
+	}
+	if got := a.notifications(alice, ""); got.Total != 0 {
+		t.Fatalf("alice after clearing = %+v", got)
+	}
+	if got := a.notifications(bob, ""); got.Total != 1 || got.Notifications[0].ID != bobs.ID {
+		t.Fatalf("bob after alice cleared = %+v", got)
+	}
+	a.expect(http.StatusBadRequest, "DELETE", "/notifications?read=maybe", alice, nil)
+}
+
+func TestGetNotificationsReturnsCopies(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	first := a.push(aliceID, NotifFollow)
+	a.push(aliceID, NotifMention)
+
+	page := a.notifications(alice, "limit=1&offset=1")
+	page.Notifications[0].Read = true
+	page.Notifications[0].Type = NotifComment
+
+	a.notifs.mu.Lock()
+	stored := *a.notifs.notifications[first.ID]
+	count := len(a.notifs.order)
+	a.notifs.mu.Unlock()
+	if stored.Read || stored.Type != NotifFollow || count != 2 {
+		t.Fatalf("stored notification = %+v, %d stored", stored, count)
+	}
+	if again := a.notifications(alice, ""); again.UnreadCount != 2 || again.Total != 2 {
+		t.Fatalf("notifications after editing a page = %+v", again)
+	}
+}
+
+func TestGetNotificationsDuringPush(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	const pushes = 200
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range pushes {
+			a.push(aliceID, NotifFollow)
+		}
+	}()
+	// mỗi trang đọc giữa lúc đang push vẫn phải nhất quán
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		page := a.notifications(alice, "limit=50")
+		if page.UnreadCount != page.Total || len(page.Notifications) != min(page.Total, 50) {
+			t.Fatalf("inconsistent page: total %d, unread %d, %d items", page.Total, page.UnreadCount, len(page.Notifications))
+		}
+		for _, n := range page.Notifications {
+			if n.ID == 0 || n.UserID != aliceID {
+				t.Fatalf("torn notification %+v", n)
+			}
+		}
+	}
+	if page := a.notifications(alice, ""); page.Total != pushes {
+		t.Fatalf("total = %d, want %d", page.Total, pushes)
+	}
+}
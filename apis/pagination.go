@@ -0,0 +1,76 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Giới hạn mặc định và tối đa cho mọi danh sách phân trang; main có thể đổi lúc khởi động,
+// trước khi phục vụ request
+var (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// parsePagination đọc offset/limit từ query: offset âm hoặc sai thành 0, limit thiếu thành
+// DefaultPageLimit. limit sai hoặc vượt MaxPageLimit bị từ chối (400) thay vì bị cắt;
+// trả về false khi đã ghi response lỗi
+func parsePagination(w http.ResponseWriter, r *http.Request) (offset, limit int, ok bool) {
+	return parsePaginationDefault(w, r, DefaultPageLimit)
+}
+
+// parsePaginationDefault như parsePagination nhưng với limit mặc định riêng
+func parsePaginationDefault(w http.ResponseWriter, r *http.Request, defaultLimit int) (offset, limit int, ok bool) {
+	offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return offset, min(defaultLimit, MaxPageLimit), true
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 || limit > MaxPageLimit {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", MaxPageLimit))
+		return 0, 0, false
+	}
+	return offset, limit, true
+}
+
+// pageBounds kẹp offset trước rồi mới tới end, để [offset:end] không bao giờ panic
+func pageBounds(n, offset, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > n {
+		offset = n
+	}
+	end := offset + limit
+	if end > n {
+		end = n
+	}
+	if end < offset {
+		end = offset
+	}
+	return offset, end
+}
+
+// PageMeta mô tả cửa sổ offset/limit của một trang, nhúng vào các response dạng list
+type PageMeta struct {
+	Total   int  `json:"total"`
+	Offset  int  `json:"offset"`
+	Limit   int  `json:"limit"`
+	HasMore bool `json:"has_more"`
+}
+
+// newPageMeta dùng offset và end đã qua pageBounds
+func newPageMeta(total, offset, end, limit int) PageMeta {
+	return PageMeta{
+		Total:   total,
+		Offset:  offset,
+		Limit:   limit,
+		HasMore: end < total,
+	}
+}
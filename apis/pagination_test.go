@@ -0,0 +1,155 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPageMetaHasMore(t *testing.T) {
+	for _, tc := range []struct {
+		offset, limit int
+		want          PageMeta
+	}{
+		{0, 2, PageMeta{Total: 3, Offset: 0, Limit: 2, HasMore: true}},
+		{1, 2, PageMeta{Total: 3, Offset: 1, Limit: 2, HasMore: false}},
+		{0, 3, PageMeta{Total: 3, Offset: 0, Limit: 3, HasMore: false}},
+		{2, 1, PageMeta{Total: 3, Offset: 2, Limit: 1, HasMore: false}},
+		{9, 2, PageMeta{Total: 3, Offset: 3, Limit: 2, HasMore: false}},
+	} {
+		start, end := pageBounds(3, tc.offset, tc.limit)
+		if got := newPageMeta(3, start, end, tc.limit); got != tc.want {
+			t.Fatalf("offset %d limit %d: %+v, want %+v", tc.offset, tc.limit, got, tc.want)
+		}
+	}
+}
+
+func TestListResponsesHaveMore(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	for i := range 3 {
+		a.comment(alice, postID, 0, fmt.Sprint("comment ", i))
+		a.push(aliceID, NotifComment)
+		a.register(fmt.Sprint("user", i))
+	}
+
+	for _, query := range []struct {
+		offset, limit int
+		hasMore       bool
+	}{{0, 2, true}, {1, 2, false}, {0, 3, false}} {
+		page := fmt.Sprintf("offset=%d&limit=%d", query.offset, query.limit)
+		metas := map[string]PageMeta{}
+
+		var comments GetCommentsResponse
+		decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/comments?"+page), alice, nil), &comments)
+		metas["comments"] = comments.PageMeta
+		metas["notifications"] = a.notifications(alice, page).PageMeta
+		var users UsersResponse
+		// chỉ lấy user0..user2, không tính alice
+		decodeBody(t, a.expect(http.StatusOK, "GET", "/users?search=user&"+page, "", nil), &users)
+		metas["users"] = users.PageMeta
+
+		for name, meta := range metas {
+			want := PageMeta{Total: 3, Offset: query.offset, Limit: query.limit, HasMore: query.hasMore}
+			if meta != want {
+				t.Fatalf("%s %s: %+v, want %+v", name, page, meta, want)
+			}
+		}
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	for query, want := range map[string]struct {
+		offset, limit int
+		ok            bool
+	}{
+		"":                 {0, DefaultPageLimit, true},
+		"offset=5&limit=7": {5, 7, true},
+		"offset=-3":        {0, DefaultPageLimit, true},
+		"offset=abc":       {0, DefaultPageLimit, true},
+		"limit=100":        {0, MaxPageLimit, true},
+		"limit=101":        {0, 0, false},
+		"limit=0":          {0, 0, false},
+		"limit=-1":         {0, 0, false},
+		"limit=ten":        {0, 0, false},
+	} {
+		rec := httptest.NewRecorder()
+		offset, limit, ok := parsePagination(rec, httptest.NewRequest("GET", "/posts?"+query, nil))
+		if offset != want.offset || limit != want.limit || ok != want.ok {
+			t.Errorf("%q: %d, %d, %v, want %d, %d, %v", query, offset, limit, ok, want.offset, want.limit, want.ok)
+		}
+		if !ok && rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", query, rec.Code)
+		}
+	}
+
+	// limit mặc định riêng vẫn bị giới hạn bởi MaxPageLimit
+	if _, limit, _ := parsePaginationDefault(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), 500); limit != MaxPageLimit {
+		t.Fatalf("default above max: limit %d", limit)
+	}
+}
+
+func TestListHandlersUsePagination(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	for _, path := range []string{"/posts", "/users", "/users/1/followers", "/users/1/following", "/notifications"} {
+		a.expect(http.StatusBadRequest, "GET", path+"?limit=101", alice, nil)
+		a.expect(http.StatusOK, "GET", path+"?offset=-1&limit=100", alice, nil)
+	}
+}
+
+func TestEffectiveLimitEchoed(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	a.createPost(alice, map[string]any{"content": "hello"}, "")
+
+	// limit đã áp dụng của từng endpoint, đọc từ response của GET path?query, cùng limit mặc định
+	endpoints := map[string]struct {
+		defaultLimit int
+		limit        func(path string) int
+	}{
+		"/feeds": {defaultFeedLimit, func(path string) int {
+			var resp FeedResponse
+			decodeBody(t, a.expect(http.StatusOK, "GET", path, alice, nil), &resp)
+			return resp.Limit
+		}},
+		"/notifications": {DefaultPageLimit, func(path string) int {
+			var resp NotificationResponse
+			decodeBody(t, a.expect(http.StatusOK, "GET", path, alice, nil), &resp)
+			return resp.Limit
+		}},
+		"/posts": {DefaultPageLimit, func(path string) int {
+			var resp PostsResponse
+			decodeBody(t, a.expect(http.StatusOK, "GET", path, alice, nil), &resp)
+			return resp.Limit
+		}},
+		fmt.Sprintf("/users/%d/activity", aliceID): {DefaultPageLimit, func(path string) int {
+			var resp ActivityResponse
+			decodeBody(t, a.expect(http.StatusOK, "GET", path, alice, nil), &resp)
+			return resp.Limit
+		}},
+		"/hashtags/trending": {defaultTrendingLimit, func(path string) int {
+			var resp TrendingHashtagsResponse
+			decodeBody(t, a.expect(http.StatusOK, "GET", path, alice, nil), &resp)
+			return resp.Limit
+		}},
+	}
+	for path, ep := range endpoints {
+		if got := ep.limit(path); got != ep.defaultLimit {
+			t.Fatalf("%s: default limit %d, want %d", path, got, ep.defaultLimit)
+		}
+		if got := ep.limit(path + "?limit=7"); got != 7 {
+			t.Fatalf("%s?limit=7: limit %d", path, got)
+		}
+		if got := ep.limit(fmt.Sprintf("%s?limit=%d", path, MaxPageLimit)); got != MaxPageLimit {
+			t.Fatalf("%s at max: limit %d", path, got)
+		}
+		var apiErr APIError
+		decodeBody(t, a.expect(http.StatusBadRequest, "GET", fmt.Sprintf("%s?limit=%d", path, MaxPageLimit+1), alice, nil), &apiErr)
+		if apiErr.Error != fmt.Sprintf("limit must be between 1 and %d", MaxPageLimit) {
+			t.Fatalf("%s over max: error %q", path, apiErr.Error)
+		}
+	}
+}
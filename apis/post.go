@@ -1,8 +1,13 @@
 package apis
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"slices"
+	"sort"
 	"strconv"
 	"time"
 
@@ -16,204 +21,648 @@ type Post struct {
 	Content   string `json:"content"`
 	CreatedAt string `json:"createdAt"`
 	MediaIDs  []int  `json:"media_ids,omitempty"`
-	IsDeleted bool   `json:"-"`
+	// Visibility là public (mặc định), followers hoặc private
+	Visibility string `json:"visibility"`
+	// Tags là các hashtag lấy từ content, server tự điền
+	Tags []string `json:"tags,omitempty"`
+	// RepostOfID là post được repost, chỉ đặt qua POST /posts/{post_id}/repost
+	RepostOfID int `json:"repost_of_id,omitempty"`
+	// Status là draft hoặc published; rỗng (post cũ) coi như published
+	Status string `json:"status"`
+	// IsDeleted và DeletedAt chỉ xuất hiện trong ListPosts với include_deleted của admin
+	IsDeleted bool   `json:"is_deleted,omitempty"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+}
+
+// Các mức hiển thị của post
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityPrivate   = "private"
+)
+
+// Trạng thái của post; draft chỉ tác giả thấy cho tới khi publish
+const (
+	PostDraft     = "draft"
+	PostPublished = "published"
+)
+
+// validVisibility kiểm tra giá trị visibility hợp lệ
+func validVisibility(v string) bool {
+	return v == VisibilityPublic || v == VisibilityFollowers || v == VisibilityPrivate
+}
+
+// canSeePost cho biết viewerID (0 = ẩn danh) có được xem p không; draft chỉ tác giả xem được,
+// post followers-only cần follows để kiểm tra, nil thì chỉ tác giả xem được
+func canSeePost(follows *FollowsHandler, viewerID int, p Post) bool {
+	switch {
+	case p.Status == PostDraft:
+		return viewerID != 0 && viewerID == p.UserID
+	case p.Visibility == "" || p.Visibility == VisibilityPublic:
+		return true
+	case viewerID == 0:
+		return false
+	case viewerID == p.UserID:
+		return true
+	case p.Visibility == VisibilityFollowers:
+		return follows != nil && follows.isFollowing(viewerID, p.UserID)
+	}
+	return false
+}
+
+// PostRestoreWindow là thời gian sau khi soft delete mà tác giả còn khôi phục được post
+const PostRestoreWindow = 30 * 24 * time.Hour
+
+// PostDetail là Post kèm số liệu tương tác, trả về bởi GET /posts/{post_id}
+type PostDetail struct {
+	Post
+	CommentCount  int `json:"comment_count"`
+	ReactionCount int `json:"reaction_count"`
+	// MyReaction là reaction của người xem, rỗng khi ẩn danh hoặc chưa react
+	MyReaction string `json:"my_reaction,omitempty"`
+	// RepostOf là post gốc của một repost, bỏ trống khi post gốc đã xoá hoặc người xem không được thấy
+	RepostOf *Post `json:"repost_of,omitempty"`
+}
+
+// PostsResponse là một trang posts
+type PostsResponse struct {
+	Posts []Post `json:"posts"`
+	PageMeta
+}
+
+// canSee kết hợp visibility của post với danh sách chặn
+func (h *PostsHandler) canSee(viewerID int, p Post) bool {
+	return !h.Blocks.isBlocked(viewerID, p.UserID) && canSeePost(h.Follows, viewerID, p)
+}
+
+// visiblePosts giữ các post chưa xoá mà viewerID được xem, để total luôn khớp với những gì trả về
+func (h *PostsHandler) visiblePosts(viewerID int, posts []Post) []Post {
+	visible := []Post{}
+	for _, p := range posts {
+		if !p.IsDeleted && h.canSee(viewerID, p) {
+			visible = append(visible, p)
+		}
+	}
+	return visible
 }
 
 // PostsHandler quản lý posts
 type PostsHandler struct {
-	Posts map[int]Post // key = post_id
+	Store   Store
+	Tokens  *TokenService
+	Follows *FollowsHandler // dùng cho post followers-only
+	Media   *MediaHandler   // kiểm tra media_ids khi cập nhật post
+	Blocks  *BlocksHandler  // ẩn post giữa hai user đã chặn nhau
+
+	Moderator Moderator // lọc nội dung khi tạo/sửa post, nil thì cho qua hết
+
+	Comments  *CommentsHandler  // đếm comment cho GetPost
+	Reactions *ReactionsHandler // đếm reaction cho GetPost
+	Webhooks  *WebhookRegistry  // báo post.created / post.deleted cho integrator, nil thì bỏ qua
+	Feeds     *FeedsHandler     // xoá feed đã cache khi post thay đổi, optional
+
+	// TrendingWindow là khoảng thời gian GET /hashtags/trending đếm post; <= 0 thì dùng DefaultTrendingWindow
+	TrendingWindow time.Duration
+
+	idempotency idempotencyCache // Idempotency-Key của CreatePost
+}
+
+// NewPostsHandler khởi tạo PostsHandler, store nil thì dùng MemoryStore
+func NewPostsHandler(store Store) *PostsHandler {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &PostsHandler{
+		Store:     store,
+		Moderator: NopModerator{},
+	}
 }
 
 // RegisterRoutes đăng ký các endpoint posts
 func (h *PostsHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/posts/{post_id}", h.GetPost).Methods("GET")
-	router.HandleFunc("/users/{user_id}/posts", h.GetUserPosts).Methods("GET")
-	router.HandleFunc("/me/posts", h.GetOwnPosts).Methods("GET")
-	router.HandleFunc("/posts", h.CreatePost).Methods("POST")
-	router.HandleFunc("/posts/{post_id}", h.UpdatePost).Methods("PATCH")
-	router.HandleFunc("/posts/{post_id}", h.DeletePost).Methods("DELETE")
+	router.Handle("/posts", h.Tokens.OptionalAuth(http.HandlerFunc(h.ListPosts))).Methods("GET")
+	router.Handle("/posts/{post_id}", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetPost))).Methods("GET")
+	router.Handle("/hashtags/trending", http.HandlerFunc(h.GetTrendingHashtags)).Methods("GET")
+	router.Handle("/hashtags/{tag}/posts", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetHashtagPosts))).Methods("GET")
+	router.Handle("/users/{user_id}/posts", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetUserPosts))).Methods("GET")
+	router.Handle("/me/posts", h.Tokens.RequireAuth(http.HandlerFunc(h.GetOwnPosts))).Methods("GET")
+	router.Handle("/posts", h.Tokens.RequireAuth(http.HandlerFunc(h.CreatePost))).Methods("POST")
+	router.Handle("/posts/{post_id}", h.Tokens.RequireAuth(http.HandlerFunc(h.UpdatePost))).Methods("PATCH")
+	router.Handle("/posts/{post_id}", h.Tokens.RequireAuth(http.HandlerFunc(h.DeletePost))).Methods("DELETE")
+	router.Handle("/posts/{post_id}/publish", h.Tokens.RequireAuth(http.HandlerFunc(h.PublishPost))).Methods("POST")
+	router.Handle("/posts/{post_id}/repost", h.Tokens.RequireAuth(http.HandlerFunc(h.RepostPost))).Methods("POST")
+	router.Handle("/posts/{post_id}/restore", h.Tokens.RequireAuth(http.HandlerFunc(h.RestorePost))).Methods("POST")
+	router.Handle("/posts/{post_id}/history", h.Tokens.RequireAuth(http.HandlerFunc(h.GetPostHistory))).Methods("GET")
+	router.Handle("/posts/{post_id}/permanent", h.Tokens.RequireAuth(http.HandlerFunc(h.DeletePostPermanently))).Methods("DELETE")
 }
 
 // GetPost godoc
 // @Summary Get a post by ID
-// @Description Get post detail
+// @Description Get post detail with comment_count, reaction_count and the viewer's own reaction (my_reaction);
+// @Description a repost also carries the referenced post in repost_of when the viewer can see it
 // @Tags posts
 // @Produce json
 // @Param post_id path int true "Post ID"
 // @Param Authorization header string false "Bearer token"
-// @Success 200 {object} Post
-// @Failure 404 {object} map[string]string
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Success 200 {object} PostDetail
+// @Success 304 "Not Modified"
+// @Failure 404 {object} APIError
 // @Router /posts/{post_id} [get]
 func (h *PostsHandler) GetPost(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
 	vars := mux.Vars(r)
 	idStr := vars["post_id"]
 	postID, _ := strconv.Atoi(idStr)
 
-	post, exists := h.Posts[postID]
-	if !exists || post.IsDeleted {
-		http.Error(w, `{"error":"Post not found"}`, http.StatusNotFound)
+	post, err := h.Store.GetPost(r.Context(), postID)
+	if err != nil && !errors.Is(err, ErrPostNotFound) {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return
+	}
+	// post không được xem thì coi như không tồn tại
+	viewerID, _ := UserIDFromContext(r.Context())
+	if err != nil || post.IsDeleted || !h.canSee(viewerID, post) {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	w.Header().Set("Vary", "Authorization")
+	writeJSONWithETag(w, r, h.detail(r.Context(), post, viewerID))
+}
+
+// detail thêm số comment, số reaction, reaction của viewerID và post gốc của repost vào post
+func (h *PostsHandler) detail(ctx context.Context, p Post, viewerID int) PostDetail {
+	d := PostDetail{Post: p}
+	if p.RepostOfID != 0 {
+		original, err := h.Store.GetPost(ctx, p.RepostOfID)
+		if err == nil && !original.IsDeleted && h.canSee(viewerID, original) {
+			d.RepostOf = &original
+		}
+	}
+	if h.Comments != nil {
+		d.CommentCount = h.Comments.commentCount(p.PostID)
+	}
+	if h.Reactions != nil {
+		reactions := h.Reactions.reactionsFor(p.PostID)
+		d.ReactionCount = len(reactions)
+		d.MyReaction = reactions[viewerID]
+	}
+	return d
+}
+
+// ListPosts godoc
+// @Summary List posts
+// @Description Browse all posts newest first, filtered by author and content; page with before=<last post_id>
+// @Tags posts
+// @Produce json
+// @Param user_id query int false "Only posts of this user"
+// @Param q query string false "Content contains (case-insensitive)"
+// @Param before query int false "Only posts with post_id lower than this"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Param include_deleted query bool false "Include soft-deleted posts with is_deleted set (admin only)"
+// @Param Authorization header string false "Bearer token"
+// @Success 200 {object} PostsResponse
+// @Failure 400 {object} APIError
+// @Failure 403 {object} APIError
+// @Router /posts [get]
+func (h *PostsHandler) ListPosts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// post đã xoá chỉ dành cho moderation
+	viewerID, _ := UserIDFromContext(r.Context())
+	if query.Get("include_deleted") == "true" && (h.Tokens == nil || !h.Tokens.Admins[viewerID]) {
+		writeJSONError(w, http.StatusForbidden, "include_deleted requires admin")
 		return
 	}
 
-	json.NewEncoder(w).Encode(post)
+	filter := PostFilter{
+		Query:          query.Get("q"),
+		IncludeDeleted: query.Get("include_deleted") == "true",
+	}
+	if v := query.Get("user_id"); v != "" {
+		userID, err := strconv.Atoi(v)
+		if err != nil || userID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+		filter.UserID = userID
+	}
+	h.writePostsPage(w, r, filter)
+}
+
+// writePostsPage trả về các post khớp filter mà người xem được thấy,
+// mới nhất trước, phân trang bằng query before và limit
+func (h *PostsHandler) writePostsPage(w http.ResponseWriter, r *http.Request, filter PostFilter) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
+	query := r.URL.Query()
+	before := 0
+	if v := query.Get("before"); v != "" {
+		var err error
+		before, err = strconv.Atoi(v)
+		if err != nil || before <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid before")
+			return
+		}
+	}
+	_, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	all, err := h.Store.ListPosts(r.Context(), filter)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load posts")
+		return
+	}
+	viewerID, _ := UserIDFromContext(r.Context())
+	posts := []Post{}
+	for _, p := range all {
+		if h.canSee(viewerID, p) {
+			posts = append(posts, p)
+		}
+	}
+
+	// posts đã sắp post_id giảm dần nên bỏ qua tới post đầu tiên nhỏ hơn before
+	start := 0
+	if before > 0 {
+		start = sort.Search(len(posts), func(i int) bool { return posts[i].PostID < before })
+	}
+	start, end := pageBounds(len(posts), start, limit)
+
+	writeJSON(w, http.StatusOK, PostsResponse{
+		Posts:    posts[start:end],
+		PageMeta: newPageMeta(len(posts), start, end, limit),
+	})
 }
 
 // GetUserPosts godoc
 // @Summary Get posts of a user
-// @Description Get list of posts by user_id
+// @Description Get list of posts by user_id. Deleted posts and posts the requester may not see
+// @Description are left out of both the page and total; 404 when nothing is visible
 // @Tags posts
 // @Produce json
 // @Param user_id path int true "User ID"
 // @Param offset query int false "Offset"
-// @Param limit query int false "Limit"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Param sort query string false "newest (default) or oldest"
 // @Param Authorization header string false "Bearer token"
-// @Success 200 {object} map[string]interface{}
-// @Failure 404 {object} map[string]string
+// @Success 200 {object} PostsResponse
+// @Failure 400 {object} APIError
+// @Failure 404 {object} APIError
 // @Router /users/{user_id}/posts [get]
 func (h *PostsHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
 	vars := mux.Vars(r)
 	idStr := vars["user_id"]
 	userID, _ := strconv.Atoi(idStr)
 
-	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-
-	userPosts := []Post{}
-	for _, p := range h.Posts {
-		if p.UserID == userID && !p.IsDeleted {
-			userPosts = append(userPosts, p)
-		}
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
 	}
 
-	if len(userPosts) == 0 {
-		http.Error(w, `{"error":"User not found"}`, http.StatusNotFound)
+	sortOrder := r.URL.Query().Get("sort")
+	if sortOrder != "" && sortOrder != "newest" && sortOrder != "oldest" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid sort, use newest or oldest")
 		return
 	}
 
-	end := offset + limit
-	if end > len(userPosts) {
-		end = len(userPosts)
-	}
-	if offset > len(userPosts) {
-		offset = len(userPosts)
+	posts, err := h.Store.ListUserPosts(r.Context(), userID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load posts")
+		return
 	}
 
-	resp := map[string]interface{}{
-		"posts": userPosts[offset:end],
-		"total": len(userPosts),
+	// lọc trước khi kiểm tra rỗng: user chỉ có post private trông giống user không có post
+	viewerID, _ := UserIDFromContext(r.Context())
+	userPosts := h.visiblePosts(viewerID, posts)
+	if len(userPosts) == 0 {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
 	}
-	json.NewEncoder(w).Encode(resp)
+
+	// post_id tăng dần theo thời gian tạo nên sort theo ID cho thứ tự ổn định
+	sort.Slice(userPosts, func(i, j int) bool {
+		if sortOrder == "oldest" {
+			return userPosts[i].PostID < userPosts[j].PostID
+		}
+		return userPosts[i].PostID > userPosts[j].PostID
+	})
+
+	offset, end := pageBounds(len(userPosts), offset, limit)
+
+	writeJSON(w, http.StatusOK, PostsResponse{
+		Posts:    userPosts[offset:end],
+		PageMeta: newPageMeta(len(userPosts), offset, end, limit),
+	})
 }
 
 // GetOwnPosts godoc
 // @Summary Get own posts
-// @Description Get list of posts of current user
+// @Description Get list of posts of current user, newest first; deleted posts are not counted
 // @Tags posts
 // @Produce json
 // @Param offset query int false "Offset"
-// @Param limit query int false "Limit"
-// @Param Authorization header string true "Bearer token"
-// @Success 200 {object} map[string]interface{}
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Security BearerAuth
+// @Success 200 {object} PostsResponse
+// @Failure 400 {object} APIError
 // @Router /me/posts [get]
 func (h *PostsHandler) GetOwnPosts(w http.ResponseWriter, r *http.Request) {
-	// Demo: current user = user_id 1
-	currentUserID := 1
-	r = r.WithContext(r.Context()) // for future auth middleware
+	if !requireStore(w, h.Store) {
+		return
+	}
 
-	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	currentUserID, _ := UserIDFromContext(r.Context())
 
-	userPosts := []Post{}
-	for _, p := range h.Posts {
-		if p.UserID == currentUserID && !p.IsDeleted {
-			userPosts = append(userPosts, p)
-		}
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
 	}
 
-	end := offset + limit
-	if end > len(userPosts) {
-		end = len(userPosts)
-	}
-	if offset > len(userPosts) {
-		offset = len(userPosts)
+	posts, err := h.Store.ListUserPosts(r.Context(), currentUserID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load posts")
+		return
 	}
 
-	resp := map[string]interface{}{
-		"posts": userPosts[offset:end],
-		"total": len(userPosts),
-	}
-	json.NewEncoder(w).Encode(resp)
+	userPosts := h.visiblePosts(currentUserID, posts)
+	sort.Slice(userPosts, func(i, j int) bool { return userPosts[i].PostID > userPosts[j].PostID })
+
+	offset, end := pageBounds(len(userPosts), offset, limit)
+
+	writeJSON(w, http.StatusOK, PostsResponse{
+		Posts:    userPosts[offset:end],
+		PageMeta: newPageMeta(len(userPosts), offset, end, limit),
+	})
 }
 
 // CreatePost godoc
 // @Summary Create a post
-// @Description Create a new post
+// @Description Create a new post. Retries carrying the same Idempotency-Key (per user, kept for 24h)
+// @Description return the post created by the first request instead of creating another one
 // @Tags posts
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Bearer token"
+// @Security BearerAuth
 // @Param body body Post true "Post data"
+// @Param Idempotency-Key header string false "Client-generated key for safe retries"
+// @Param validate_only query bool false "Only validate the post: 200 with valid=true or 422 with every error, nothing is saved"
+// @Param status query string false "published (default) or draft; a draft is only visible to its author until published" Enums(published, draft)
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
+// @Success 200 {object} ValidationResponse
+// @Failure 400 {object} APIError
+// @Failure 409 {object} APIError
+// @Failure 422 {object} ValidationResponse
 // @Router /posts [post]
 func (h *PostsHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
 	var req Post
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Content == "" {
-		http.Error(w, `{"error":"Invalid data"}`, http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	// status chỉ nhận qua query, bỏ qua giá trị trong body
+	req.Status = r.URL.Query().Get("status")
+	problems := h.validateNewPost(r.Context(), &req)
+
+	// dry-run: trả về mọi lỗi một lần, không lưu, không tốn ID, không đụng idempotency key
+	if r.URL.Query().Get("validate_only") == "true" {
+		if len(problems) > 0 {
+			resp := ValidationResponse{Valid: false}
+			for _, p := range problems {
+				resp.Errors = append(resp.Errors, p.msg)
+			}
+			writeJSON(w, http.StatusUnprocessableEntity, resp)
+			return
+		}
+		writeJSON(w, http.StatusOK, ValidationResponse{Valid: true})
+		return
+	}
+	if len(problems) > 0 {
+		writeJSONError(w, problems[0].status, problems[0].msg)
+		return
+	}
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLength {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key exceeds %d characters", maxIdempotencyKeyLength))
 		return
 	}
+	if key != "" {
+		postID, inProgress := h.idempotency.reserve(currentUserID, key)
+		if inProgress {
+			writeJSONError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+			return
+		}
+		if postID != 0 {
+			w.Header().Set("Idempotent-Replayed", "true")
+			writeJSON(w, http.StatusCreated, map[string]interface{}{
+				"post_id": postID,
+				"message": "Post created",
+			})
+			return
+		}
+	}
 
-	// Demo: fake ID
-	newID := len(h.Posts) + 1
+	req.UserID = currentUserID
+	req.RepostOfID = 0 // repost chỉ tạo qua /posts/{post_id}/repost
+	req.CreatedAt = nowRFC3339()
+	req.IsDeleted = false
+	req.DeletedAt = ""
+	newID, err := h.Store.CreatePost(r.Context(), req)
+	if err != nil {
+		if key != "" {
+			h.idempotency.release(currentUserID, key)
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save post")
+		return
+	}
+	if key != "" {
+		h.idempotency.complete(currentUserID, key, newID)
+	}
 	req.PostID = newID
-	req.UserID = 1 // current user
-	req.CreatedAt = time.Now().Format(time.RFC3339)
-	h.Posts[newID] = req
+	h.Feeds.invalidateAuthor(currentUserID)
+	// draft chỉ báo post.created khi được publish
+	if req.Status == PostPublished {
+		h.Webhooks.publish(EventPostCreated, req)
+	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
 		"post_id": newID,
 		"message": "Post created",
 	})
 }
 
+// PostDeletedEvent là data của webhook event post.deleted
+type PostDeletedEvent struct {
+	PostID    int  `json:"post_id"`
+	UserID    int  `json:"user_id"`
+	Permanent bool `json:"permanent"`
+}
+
+// ValidationResponse là kết quả của POST /posts?validate_only=true
+type ValidationResponse struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// postProblem là một lý do post mới bị từ chối, kèm status trả về khi không phải dry-run
+type postProblem struct {
+	status int
+	msg    string
+}
+
+// validateNewPost kiểm tra post trước khi tạo và chuẩn hoá req (làm sạch content, tags,
+// media_ids, visibility và status mặc định); trả về mọi lỗi theo thứ tự kiểm tra
+func (h *PostsHandler) validateNewPost(ctx context.Context, req *Post) []postProblem {
+	var problems []postProblem
+	if req.Content == "" {
+		problems = append(problems, postProblem{http.StatusBadRequest, "Invalid data"})
+	} else if content, reason := cleanContent(req.Content, maxPostLength); reason != "" {
+		problems = append(problems, postProblem{http.StatusBadRequest, reason})
+	} else if ok, reason := moderate(ctx, h.Moderator, content); !ok {
+		problems = append(problems, postProblem{http.StatusUnprocessableEntity, reason})
+	} else {
+		req.Content = content
+		req.Tags = extractHashtags(content)
+	}
+
+	// như UpdatePost: chỉ gắn media của chính tác giả
+	userID, _ := UserIDFromContext(ctx)
+	for _, id := range req.MediaIDs {
+		if !h.Media.ownedBy(id, userID) {
+			problems = append(problems, postProblem{http.StatusBadRequest, fmt.Sprintf("Media %d not found", id)})
+		}
+	}
+	req.MediaIDs = appendMissing(nil, req.MediaIDs)
+
+	if req.Visibility == "" {
+		req.Visibility = VisibilityPublic
+	}
+	if !validVisibility(req.Visibility) {
+		problems = append(problems, postProblem{http.StatusBadRequest, "Invalid visibility, use public, followers or private"})
+	}
+	if req.Status == "" {
+		req.Status = PostPublished
+	}
+	if req.Status != PostPublished && req.Status != PostDraft {
+		problems = append(problems, postProblem{http.StatusBadRequest, "Invalid status, use published or draft"})
+	}
+	return problems
+}
+
 // UpdatePost godoc
 // @Summary Update a post
-// @Description Update content or media_ids of a post
+// @Description Update content, media_ids or visibility (public, followers, private) of a post.
+// @Description media_mode decides how media_ids is applied; without it a non-empty media_ids replaces the list.
+// @Description Content and media changes are kept in GET /posts/{post_id}/history
 // @Tags posts
 // @Accept json
 // @Produce json
 // @Param post_id path int true "Post ID"
-// @Param Authorization header string true "Bearer token"
+// @Security BearerAuth
 // @Param body body Post true "Post update data"
+// @Param media_mode query string false "replace, append or clear" Enums(replace, append, clear)
 // @Success 200 {object} map[string]string
-// @Failure 403 {object} map[string]string
+// @Failure 400 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 422 {object} APIError
 // @Router /posts/{post_id} [patch]
 func (h *PostsHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
 	vars := mux.Vars(r)
 	idStr := vars["post_id"]
 	postID, _ := strconv.Atoi(idStr)
 
-	post, exists := h.Posts[postID]
-	if !exists || post.IsDeleted || post.UserID != 1 { // demo currentUserID=1
-		http.Error(w, `{"error":"Unauthorized or not the author"}`, http.StatusForbidden)
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	post, err := h.Store.GetPost(r.Context(), postID)
+	if err != nil && !errors.Is(err, ErrPostNotFound) {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return
+	}
+	if err != nil || post.IsDeleted || post.UserID != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Unauthorized or not the author")
 		return
 	}
 
 	var req Post
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid data"}`, http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
+	// giữ bản trước khi sửa để biết có cần ghi history không
+	before := post
 
 	if req.Content != "" {
-		post.Content = req.Content
+		content, reason := cleanContent(req.Content, maxPostLength)
+		if reason != "" {
+			writeJSONError(w, http.StatusBadRequest, reason)
+			return
+		}
+		if ok, reason := moderate(r.Context(), h.Moderator, content); !ok {
+			writeJSONError(w, http.StatusUnprocessableEntity, reason)
+			return
+		}
+		post.Content = content
+		post.Tags = extractHashtags(content)
+	}
+	mediaMode := r.URL.Query().Get("media_mode")
+	if mediaMode != "" && mediaMode != "replace" && mediaMode != "append" && mediaMode != "clear" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid media_mode, use replace, append or clear")
+		return
+	}
+	if mediaMode != "clear" {
+		for _, id := range req.MediaIDs {
+			if !h.Media.ownedBy(id, post.UserID) {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Media %d not found", id))
+				return
+			}
+		}
+	}
+	switch {
+	case mediaMode == "clear":
+		post.MediaIDs = nil
+	case mediaMode == "append":
+		post.MediaIDs = appendMissing(post.MediaIDs, req.MediaIDs)
+	case mediaMode == "replace", len(req.MediaIDs) > 0:
+		post.MediaIDs = appendMissing(nil, req.MediaIDs)
+	}
+	if req.Visibility != "" {
+		if !validVisibility(req.Visibility) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid visibility, use public, followers or private")
+			return
+		}
+		post.Visibility = req.Visibility
+	}
+	if err := h.Store.UpdatePost(r.Context(), post); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save post")
+		return
 	}
-	if req.MediaIDs != nil {
-		post.MediaIDs = req.MediaIDs
+	if post.Content != before.Content || !slices.Equal(post.MediaIDs, before.MediaIDs) {
+		h.recordEdit(r.Context(), before, post, currentUserID)
 	}
-	h.Posts[postID] = post
-	json.NewEncoder(w).Encode(map[string]string{"message": "Post updated"})
+	h.Feeds.invalidateAuthor(post.UserID)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Post updated"})
 }
 
 // DeletePost godoc
@@ -222,22 +671,216 @@ func (h *PostsHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 // @Tags posts
 // @Produce json
 // @Param post_id path int true "Post ID"
-// @Param Authorization header string true "Bearer token"
-// @Success 200 {object} map[string]string
-// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 403 {object} APIError
 // @Router /posts/{post_id} [delete]
 func (h *PostsHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	idStr := vars["post_id"]
+	postID, _ := strconv.Atoi(idStr)
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	post, err := h.Store.GetPost(r.Context(), postID)
+	if err != nil && !errors.Is(err, ErrPostNotFound) {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return
+	}
+	if err != nil || post.IsDeleted || post.UserID != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Unauthorized or not the author")
+		return
+	}
+
+	if err := h.Store.SoftDeletePost(r.Context(), postID); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot delete post")
+		return
+	}
+	h.Feeds.invalidateAuthor(post.UserID)
+	h.Webhooks.publish(EventPostDeleted, PostDeletedEvent{PostID: postID, UserID: post.UserID})
+	writeNoContent(w)
+}
+
+// RestorePost godoc
+// @Summary Restore a soft-deleted post
+// @Description Undo a soft delete within 30 days; restoring a post that is not deleted is a no-op
+// @Tags posts
+// @Produce json
+// @Param post_id path int true "Post ID"
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 410 {object} APIError
+// @Router /posts/{post_id}/restore [post]
+func (h *PostsHandler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	idStr := vars["post_id"]
+	postID, _ := strconv.Atoi(idStr)
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	post, err := h.Store.GetPost(r.Context(), postID)
+	if errors.Is(err, ErrPostNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return
+	}
+	if post.UserID != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Unauthorized or not the author")
+		return
+	}
+	if !post.IsDeleted {
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Post is not deleted"})
+		return
+	}
+
+	// DeletedAt rỗng (dữ liệu cũ) thì vẫn cho khôi phục
+	if deletedAt, err := time.Parse(time.RFC3339, post.DeletedAt); err == nil && time.Since(deletedAt) > PostRestoreWindow {
+		writeJSONError(w, http.StatusGone, "Restore window has expired")
+		return
+	}
+
+	post.IsDeleted = false
+	post.DeletedAt = ""
+	if err := h.Store.UpdatePost(r.Context(), post); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot restore post")
+		return
+	}
+	h.Feeds.invalidateAuthor(post.UserID)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Post restored"})
+}
+
+// PublishPost godoc
+// @Summary Publish a draft
+// @Description Make a draft visible to others; createdAt becomes the publish time. Publishing a published post is a no-op
+// @Tags posts
+// @Produce json
+// @Param post_id path int true "Post ID"
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /posts/{post_id}/publish [post]
+func (h *PostsHandler) PublishPost(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
+	postID, _ := strconv.Atoi(mux.Vars(r)["post_id"])
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	post, err := h.Store.GetPost(r.Context(), postID)
+	if errors.Is(err, ErrPostNotFound) || (err == nil && post.IsDeleted) {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return
+	}
+	if post.UserID != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Unauthorized or not the author")
+		return
+	}
+	if post.Status != PostDraft {
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Post is already published"})
+		return
+	}
+
+	post.Status = PostPublished
+	post.CreatedAt = nowRFC3339()
+	if err := h.Store.UpdatePost(r.Context(), post); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot publish post")
+		return
+	}
+	h.Feeds.invalidateAuthor(post.UserID)
+	h.Webhooks.publish(EventPostCreated, post)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Post published"})
+}
+
+// DeletePostPermanently godoc
+// @Summary Permanently delete a post
+// @Description Remove a post from the store, including soft-deleted ones
+// @Tags posts
+// @Produce json
+// @Param post_id path int true "Post ID"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /posts/{post_id}/permanent [delete]
+func (h *PostsHandler) DeletePostPermanently(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
 	vars := mux.Vars(r)
 	idStr := vars["post_id"]
 	postID, _ := strconv.Atoi(idStr)
 
-	post, exists := h.Posts[postID]
-	if !exists || post.IsDeleted || post.UserID != 1 { // demo currentUserID=1
-		http.Error(w, `{"error":"Unauthorized or not the author"}`, http.StatusForbidden)
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	post, err := h.Store.GetPost(r.Context(), postID)
+	if errors.Is(err, ErrPostNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return
+	}
+	if post.UserID != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Unauthorized or not the author")
+		return
+	}
+
+	if err := h.Store.DeletePost(r.Context(), postID); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot delete post")
 		return
 	}
+	h.Feeds.invalidateAuthor(post.UserID)
+	// post đã soft delete thì post.deleted đã được gửi rồi
+	if !post.IsDeleted {
+		h.Webhooks.publish(EventPostDeleted, PostDeletedEvent{PostID: postID, UserID: post.UserID, Permanent: true})
+	}
+	writeNoContent(w)
+}
 
-	post.IsDeleted = true
-	h.Posts[postID] = post
-	json.NewEncoder(w).Encode(map[string]string{"message": "Post soft deleted"})
+// appendMissing thêm các id chưa có trong ids, giữ nguyên thứ tự
+func appendMissing(ids, add []int) []int {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, id := range add {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// PurgeDeleted xoá hẳn các post đã soft delete quá olderThan, trả về số post đã xoá
+func (h *PostsHandler) PurgeDeleted(ctx context.Context, olderThan time.Duration) int {
+	if h.Store == nil {
+		return 0
+	}
+	removed, err := h.Store.PurgeDeleted(ctx, time.Now().Add(-olderThan))
+	if err != nil {
+		log.Println("purge deleted posts:", err)
+	}
+	return removed
 }
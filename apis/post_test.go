@@ -0,0 +1,600 @@
+package apis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestConcurrentCreatePost(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+
+	const posts = 100
+	var wg sync.WaitGroup
+	codes := make(chan int, posts)
+	for i := 0; i < posts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes <- a.do("POST", "/posts", alice, map[string]any{"content": fmt.Sprintf("post %d", i)}).Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusCreated {
+			t.Fatalf("concurrent create: status %d", code)
+		}
+	}
+
+	stored, err := a.store.ListPosts(t.Context(), PostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != posts {
+		t.Fatalf("stored %d posts, want %d", len(stored), posts)
+	}
+}
+
+func TestPostIDsNeverReused(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+
+	seen := make(map[int]bool)
+	for i := 0; i < 3; i++ {
+		id := a.createPost(alice, map[string]any{"content": "temp"}, "")
+		if seen[id] {
+			t.Fatalf("post ID %d reused", id)
+		}
+		seen[id] = true
+		// xoá mềm rồi xoá hẳn, ID vẫn không được cấp lại
+		a.expect(http.StatusNoContent, "DELETE", postPath(id, ""), alice, nil)
+		a.expect(http.StatusNoContent, "DELETE", postPath(id, "/permanent"), alice, nil)
+	}
+	if id := a.createPost(alice, map[string]any{"content": "again"}, ""); seen[id] {
+		t.Fatalf("post ID %d reused", id)
+	}
+}
+
+func TestDeletePostPermanently(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "bye"}, "")
+
+	a.expect(http.StatusForbidden, "DELETE", postPath(postID, "/permanent"), bob, nil)
+	a.expect(http.StatusOK, "GET", postPath(postID, ""), "", nil)
+
+	a.expect(http.StatusNoContent, "DELETE", postPath(postID, "/permanent"), alice, nil)
+	a.expect(http.StatusNotFound, "GET", postPath(postID, ""), "", nil)
+	a.expect(http.StatusNotFound, "DELETE", postPath(postID, "/permanent"), alice, nil)
+}
+
+func TestPurgeDeletedPosts(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	live := a.createPost(alice, map[string]any{"content": "keep"}, "")
+	for i := 0; i < 2; i++ {
+		id := a.createPost(alice, map[string]any{"content": "gone"}, "")
+		a.expect(http.StatusNoContent, "DELETE", postPath(id, ""), alice, nil)
+		// post xoá mềm vẫn bị ẩn trước khi purge
+		a.expect(http.StatusNotFound, "GET", postPath(id, ""), alice, nil)
+	}
+
+	if n := a.posts.PurgeDeleted(t.Context(), time.Hour); n != 0 {
+		t.Fatalf("purged %d posts deleted just now", n)
+	}
+	// thời hạn âm: mọi post đã xoá đều quá hạn
+	if n := a.posts.PurgeDeleted(t.Context(), -time.Hour); n != 2 {
+		t.Fatalf("purged %d posts, want 2", n)
+	}
+	all, err := a.store.ListPosts(t.Context(), PostFilter{IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].PostID != live {
+		t.Fatalf("posts after purge = %+v", all)
+	}
+
+	var page PostsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/users/1/posts", "", nil), &page)
+	if page.Total != 1 {
+		t.Fatalf("user posts total = %d, want 1", page.Total)
+	}
+}
+
+// userPosts đọc GET /users/{user_id}/posts với query cho trước
+func (a *testApp) userPosts(userID int, query string) PostsResponse {
+	a.t.Helper()
+	var page PostsResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/posts?%s", userID, query), "", nil), &page)
+	return page
+}
+
+// postDetail đọc GET /posts/{post_id} với token, rỗng là khách
+func (a *testApp) postDetail(token string, postID int) PostDetail {
+	a.t.Helper()
+	var detail PostDetail
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", postPath(postID, ""), token, nil), &detail)
+	return detail
+}
+
+// postIDs lấy post_id của từng post theo thứ tự
+func postIDs(posts []Post) []int {
+	ids := make([]int, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.PostID)
+	}
+	return ids
+}
+
+func TestUserPostsPaging(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	for i := 0; i < 5; i++ {
+		a.createPost(alice, map[string]any{"content": fmt.Sprintf("post %d", i)}, "")
+	}
+
+	first := a.userPosts(aliceID, "limit=3")
+	second := a.userPosts(aliceID, "offset=3&limit=3")
+	got := append(postIDs(first.Posts), postIDs(second.Posts)...)
+	if want := []int{5, 4, 3, 2, 1}; !slices.Equal(got, want) {
+		t.Fatalf("newest pages = %v, want %v", got, want)
+	}
+	if first.Total != 5 || !first.HasMore || second.HasMore {
+		t.Fatalf("page meta = %+v then %+v", first.PageMeta, second.PageMeta)
+	}
+
+	if got := postIDs(a.userPosts(aliceID, "sort=oldest&limit=2").Posts); !slices.Equal(got, []int{1, 2}) {
+		t.Fatalf("oldest page = %v", got)
+	}
+	// offset âm coi như 0
+	if got := postIDs(a.userPosts(aliceID, "offset=-5&limit=2").Posts); !slices.Equal(got, []int{5, 4}) {
+		t.Fatalf("negative offset page = %v", got)
+	}
+	if page := a.userPosts(aliceID, ""); page.Limit != DefaultPageLimit {
+		t.Fatalf("default limit = %d", page.Limit)
+	}
+	a.expect(http.StatusBadRequest, "GET", fmt.Sprintf("/users/%d/posts?sort=random", aliceID), "", nil)
+}
+
+func TestUserPostsOffsetPastEnd(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	a.createPost(alice, map[string]any{"content": "only"}, "")
+
+	for _, query := range []string{"offset=1000", "offset=1000&limit=1"} {
+		page := a.userPosts(aliceID, query)
+		if len(page.Posts) != 0 || page.Total != 1 || page.HasMore {
+			t.Fatalf("%s: page = %+v", query, page)
+		}
+	}
+	// limit=0 bị parsePagination từ chối, nhưng pageBounds vẫn không được panic với nó
+	if start, end := pageBounds(1, 1000, 0); start != 1 || end != 1 {
+		t.Fatalf("pageBounds(1, 1000, 0) = %d, %d", start, end)
+	}
+}
+
+// listPosts gọi GET /posts?query với token và trả về trang kết quả
+func (a *testApp) listPosts(token, query string) PostsResponse {
+	a.t.Helper()
+	var page PostsResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", "/posts?"+query, token, nil), &page)
+	return page
+}
+
+func TestListPostsFilters(t *testing.T) {
+	a := newTestApp(t, nil)
+	adminID, admin := a.register("admin")
+	a.tokens.Admins[adminID] = true
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+
+	first := a.createPost(alice, map[string]any{"content": "Hello Go"}, "")
+	second := a.createPost(bob, map[string]any{"content": "go away"}, "")
+	third := a.createPost(alice, map[string]any{"content": "lunch"}, "")
+	gone := a.createPost(alice, map[string]any{"content": "go delete me"}, "")
+	a.expect(http.StatusNoContent, "DELETE", postPath(gone, ""), alice, nil)
+
+	for query, want := range map[string][]int{
+		"":                                      {third, second, first},
+		"q=GO":                                  {second, first},
+		fmt.Sprintf("user_id=%d", aliceID):      {third, first},
+		fmt.Sprintf("user_id=%d&q=go", aliceID): {first},
+		fmt.Sprintf("before=%d&limit=1", third): {second},
+	} {
+		page := a.listPosts("", query)
+		if got := postIDs(page.Posts); !slices.Equal(got, want) {
+			t.Fatalf("%q: %v, want %v", query, got, want)
+		}
+	}
+	if page := a.listPosts("", "limit=1"); page.Total != 3 || !page.HasMore {
+		t.Fatalf("page meta = %+v", page.PageMeta)
+	}
+
+	// post đã xoá chỉ admin thấy, và phải yêu cầu tường minh
+	a.expect(http.StatusForbidden, "GET", "/posts?include_deleted=true", alice, nil)
+	if got := postIDs(a.listPosts(admin, "include_deleted=true&q=go").Posts); !slices.Equal(got, []int{gone, second, first}) {
+		t.Fatalf("admin with include_deleted: %v", got)
+	}
+	a.expect(http.StatusBadRequest, "GET", "/posts?user_id=abc", "", nil)
+	a.expect(http.StatusBadRequest, "GET", "/posts?before=-1", "", nil)
+}
+
+func TestPostVisibility(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.feeds.CacheTTL = 0
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+
+	public := a.createPost(alice, map[string]any{"content": "everyone"}, "")
+	followers := a.createPost(alice, map[string]any{"content": "friends", "visibility": "followers"}, "")
+	private := a.createPost(alice, map[string]any{"content": "me", "visibility": "private"}, "")
+
+	for name, tc := range map[string]struct {
+		token string
+		sees  []int
+	}{
+		"author":    {alice, []int{private, followers, public}},
+		"follower":  {bob, []int{followers, public}},
+		"stranger":  {carol, []int{public}},
+		"anonymous": {"", []int{public}},
+	} {
+		for _, postID := range []int{public, followers, private} {
+			want := http.StatusNotFound
+			if slices.Contains(tc.sees, postID) {
+				want = http.StatusOK
+			}
+			a.expect(want, "GET", postPath(postID, ""), tc.token, nil)
+		}
+		var page PostsResponse
+		decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/posts", aliceID), tc.token, nil), &page)
+		if got := postIDs(page.Posts); !slices.Equal(got, tc.sees) {
+			t.Fatalf("%s: user posts %v, want %v", name, got, tc.sees)
+		}
+		// feed chỉ có post của chính mình và người mình follow
+		if name == "author" || name == "follower" {
+			if got := feedPostIDs(a.feed(tc.token, "").Feeds); !slices.Equal(got, tc.sees) {
+				t.Fatalf("%s: feed %v, want %v", name, got, tc.sees)
+			}
+		}
+	}
+
+	// đổi visibility bằng PATCH có hiệu lực ngay
+	a.expect(http.StatusOK, "PATCH", postPath(private, ""), alice, map[string]string{"visibility": "public"})
+	a.expect(http.StatusOK, "GET", postPath(private, ""), carol, nil)
+	a.expect(http.StatusBadRequest, "PATCH", postPath(private, ""), alice, map[string]string{"visibility": "friends"})
+}
+
+func TestUpdatePostMediaModes(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "album"}, "")
+	first := a.uploadImage(alice, postID)
+	other := a.createPost(alice, map[string]any{"content": "other"}, "")
+	second, third := a.uploadImage(alice, other), a.uploadImage(alice, other)
+	bobsPost := a.createPost(bob, map[string]any{"content": "bob"}, "")
+	bobs := a.uploadImage(bob, bobsPost)
+
+	mediaIDs := func() []int {
+		var post PostDetail
+		decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, ""), alice, nil), &post)
+		return post.MediaIDs
+	}
+	for _, step := range []struct {
+		query string
+		ids   []int
+		want  []int
+	}{
+		{"?media_mode=append", []int{second, first}, []int{first, second}},
+		{"?media_mode=replace", []int{third}, []int{third}},
+		{"", []int{}, []int{third}}, // không có media_mode thì mảng rỗng giữ nguyên
+		{"", []int{first, third}, []int{first, third}},
+		{"?media_mode=clear", nil, nil},
+	} {
+		a.expect(http.StatusOK, "PATCH", postPath(postID, step.query), alice, map[string]any{"media_ids": step.ids})
+		if got := mediaIDs(); !slices.Equal(got, step.want) {
+			t.Fatalf("PATCH%s %v: media %v, want %v", step.query, step.ids, got, step.want)
+		}
+	}
+
+	a.expect(http.StatusBadRequest, "PATCH", postPath(postID, "?media_mode=append"), alice, map[string]any{"media_ids": []int{bobs}})
+	a.expect(http.StatusBadRequest, "PATCH", postPath(postID, "?media_mode=replace"), alice, map[string]any{"media_ids": []int{999}})
+	a.expect(http.StatusBadRequest, "PATCH", postPath(postID, "?media_mode=merge"), alice, map[string]any{"media_ids": []int{first}})
+}
+
+func TestCreatePostMediaOwnership(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	albumID := a.createPost(alice, map[string]any{"content": "album"}, "")
+	mine := a.uploadImage(alice, albumID)
+	bobsPost := a.createPost(bob, map[string]any{"content": "bob"}, "")
+	bobs := a.uploadImage(bob, bobsPost)
+
+	a.expect(http.StatusBadRequest, "POST", "/posts", alice, map[string]any{"content": "stolen", "media_ids": []int{bobs}})
+	a.expect(http.StatusBadRequest, "POST", "/posts", alice, map[string]any{"content": "missing", "media_ids": []int{mine, 999}})
+	rec := a.expect(http.StatusUnprocessableEntity, "POST", "/posts?validate_only=true", alice, map[string]any{"content": "stolen", "media_ids": []int{bobs}})
+	var resp ValidationResponse
+	decodeBody(t, rec, &resp)
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0], strconv.Itoa(bobs)) {
+		t.Fatalf("validate_only errors = %v", resp.Errors)
+	}
+
+	postID := a.createPost(alice, map[string]any{"content": "reuse", "media_ids": []int{mine, mine}}, "")
+	if got := a.postDetail(alice, postID).MediaIDs; !slices.Equal(got, []int{mine}) {
+		t.Fatalf("media_ids = %v, want [%d]", got, mine)
+	}
+}
+
+func TestRestorePost(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "oops"}, "")
+
+	// chưa xoá: no-op
+	a.expect(http.StatusOK, "POST", postPath(postID, "/restore"), alice, nil)
+
+	a.expect(http.StatusNoContent, "DELETE", postPath(postID, ""), alice, nil)
+	a.expect(http.StatusNotFound, "GET", postPath(postID, ""), alice, nil)
+	a.expect(http.StatusForbidden, "POST", postPath(postID, "/restore"), bob, nil)
+	a.expect(http.StatusOK, "POST", postPath(postID, "/restore"), alice, nil)
+	var post PostDetail
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, ""), bob, nil), &post)
+	if post.IsDeleted || post.DeletedAt != "" {
+		t.Fatalf("restored post = %+v", post.Post)
+	}
+
+	// xoá quá PostRestoreWindow thì không khôi phục được nữa
+	a.expect(http.StatusNoContent, "DELETE", postPath(postID, ""), alice, nil)
+	stored, err := a.store.GetPost(t.Context(), postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored.DeletedAt = formatRFC3339(time.Now().Add(-PostRestoreWindow - time.Hour))
+	if err := a.store.UpdatePost(t.Context(), stored); err != nil {
+		t.Fatal(err)
+	}
+	a.expect(http.StatusGone, "POST", postPath(postID, "/restore"), alice, nil)
+	a.expect(http.StatusNotFound, "POST", postPath(999, "/restore"), alice, nil)
+}
+
+// createPostWithKey gửi POST /posts kèm Idempotency-Key và trả về response
+func (a *testApp) createPostWithKey(token, key, content string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	body, _ := json.Marshal(map[string]string{"content": content})
+	req := httptest.NewRequest("POST", "/posts", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(IdempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCreatePostIdempotencyKey(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	postID := func(rec *httptest.ResponseRecorder) int {
+		t.Helper()
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status %d, body %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			PostID int `json:"post_id"`
+		}
+		decodeBody(t, rec, &resp)
+		return resp.PostID
+	}
+
+	first := postID(a.createPostWithKey(alice, "k1", "hello"))
+	retry := a.createPostWithKey(alice, "k1", "hello")
+	if id := postID(retry); id != first || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry created post %d, want replay of %d", id, first)
+	}
+	if id := postID(a.createPostWithKey(alice, "k2", "hello")); id == first {
+		t.Fatal("a different key reused the post")
+	}
+	// key tách theo user
+	if id := postID(a.createPostWithKey(bob, "k1", "hello")); id == first {
+		t.Fatal("bob's key k1 replayed alice's post")
+	}
+	if got := a.userPosts(aliceID, "").Total; got != 2 {
+		t.Fatalf("alice has %d posts, want 2", got)
+	}
+
+	// key đang được request khác giữ thì trả 409, key hết hạn thì tạo post mới
+	a.posts.idempotency.reserve(bobID, "busy")
+	if rec := a.createPostWithKey(bob, "busy", "hello"); rec.Code != http.StatusConflict {
+		t.Fatalf("in-progress key: status %d", rec.Code)
+	}
+	a.posts.idempotency.mu.Lock()
+	a.posts.idempotency.entries[idempotencyKey{aliceID, "k1"}] = idempotencyEntry{resultID: first, expiresAt: time.Now().Add(-time.Second)}
+	a.posts.idempotency.mu.Unlock()
+	if id := postID(a.createPostWithKey(alice, "k1", "hello")); id == first {
+		t.Fatal("expired key replayed the old post")
+	}
+	if rec := a.createPostWithKey(alice, strings.Repeat("k", maxIdempotencyKeyLength+1), "hello"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("long key: status %d", rec.Code)
+	}
+}
+
+func TestGetPostEngagementCounts(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+
+	first := a.comment(bob, postID, 0, "one")
+	a.comment(carol, postID, first, "reply")
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "love"})
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), carol, map[string]string{"reaction_type": "like"})
+
+	for token, mine := range map[string]string{bob: "love", carol: "like", alice: "", "": ""} {
+		var post PostDetail
+		decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, ""), token, nil), &post)
+		if post.CommentCount != 2 || post.ReactionCount != 2 || post.MyReaction != mine {
+			t.Fatalf("post detail = %+v, want 2 comments, 2 reactions, my reaction %q", post, mine)
+		}
+		if post.Content != "hello" || post.PostID != postID {
+			t.Fatalf("post fields = %+v", post.Post)
+		}
+	}
+}
+
+func TestCreatePostValidateOnly(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.posts.Moderator = WordListModerator{Words: []string{"spam"}}
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	a.expect(http.StatusCreated, "POST", "/users/1/follow", bob, nil)
+
+	var resp ValidationResponse
+	decodeBody(t, a.expect(http.StatusOK, "POST", "/posts?validate_only=true", alice, map[string]string{"content": "hello #go"}), &resp)
+	if !resp.Valid || len(resp.Errors) != 0 {
+		t.Fatalf("valid dry run = %+v", resp)
+	}
+
+	// mọi lỗi được trả về cùng lúc
+	decodeBody(t, a.expect(http.StatusUnprocessableEntity, "POST", "/posts?validate_only=true&status=later", alice,
+		map[string]string{"content": "buy spam", "visibility": "friends"}), &resp)
+	want := []string{
+		"Content contains a banned word",
+		"Invalid visibility, use public, followers or private",
+		"Invalid status, use published or draft",
+	}
+	if resp.Valid || !slices.Equal(resp.Errors, want) {
+		t.Fatalf("invalid dry run = %+v", resp)
+	}
+
+	// dry run không lưu, không tốn ID, không giữ idempotency key và không vào feed
+	if page := a.listPosts(alice, ""); page.Total != 0 {
+		t.Fatalf("dry run saved posts: %+v", page.Posts)
+	}
+	if got := feedPostIDs(a.feed(bob, "").Feeds); len(got) != 0 {
+		t.Fatalf("bob's feed after dry run = %v", got)
+	}
+	req := httptest.NewRequest("POST", "/posts?validate_only=true", strings.NewReader(`{"content":"first"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+alice)
+	req.Header.Set(IdempotencyKeyHeader, "k1")
+	a.router.ServeHTTP(httptest.NewRecorder(), req)
+	rec := a.createPostWithKey(alice, "k1", "first")
+	if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("create after dry run: status %d, replayed %q", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+	}
+	var created struct {
+		PostID int `json:"post_id"`
+	}
+	decodeBody(t, rec, &created)
+	if created.PostID != 1 {
+		t.Fatalf("first real post got ID %d, want 1", created.PostID)
+	}
+}
+
+func TestUserPostsTotalCountsVisible(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+	a.createPost(alice, map[string]any{"content": "one"}, "")
+	a.createPost(alice, map[string]any{"content": "friends", "visibility": "followers"}, "")
+	a.createPost(alice, map[string]any{"content": "me", "visibility": "private"}, "")
+	a.createPost(alice, map[string]any{"content": "two"}, "")
+	gone := a.createPost(alice, map[string]any{"content": "gone"}, "")
+	a.expect(http.StatusNoContent, "DELETE", postPath(gone, ""), alice, nil)
+
+	// limit=1 để total không thể chỉ là độ dài trang
+	for name, tc := range map[string]struct {
+		token string
+		total int
+	}{
+		"author":    {alice, 4},
+		"follower":  {bob, 3},
+		"stranger":  {carol, 2},
+		"anonymous": {"", 2},
+	} {
+		var page PostsResponse
+		decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/posts?limit=1", aliceID), tc.token, nil), &page)
+		var all PostsResponse
+		decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/posts?limit=100", aliceID), tc.token, nil), &all)
+		if page.Total != tc.total || len(page.Posts) != 1 || !page.HasMore || len(all.Posts) != tc.total {
+			t.Fatalf("%s: total %d, page of %d, %d visible, want total %d", name, page.Total, len(page.Posts), len(all.Posts), tc.total)
+		}
+	}
+
+	var own PostsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/me/posts?limit=1", alice, nil), &own)
+	if own.Total != 4 {
+		t.Fatalf("own posts total = %d, want 4", own.Total)
+	}
+}
+
+// serveAs gọi thẳng handler như user userID, không qua router và middleware
+func serveAs(t *testing.T, h http.HandlerFunc, userID int, method, path string, vars map[string]string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req.WithContext(WithUserID(req.Context(), userID)), vars)
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+func TestZeroValueHandlers(t *testing.T) {
+	// thiếu store: 500 có body JSON, không panic
+	rec := serveAs(t, (&PostsHandler{}).CreatePost, 1, "POST", "/posts", nil, `{"content":"hi"}`)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("CreatePost without store: status %d", rec.Code)
+	}
+	var apiErr APIError
+	decodeBody(t, rec, &apiErr)
+	if apiErr.Error != "Post store is not configured" {
+		t.Fatalf("error = %+v", apiErr)
+	}
+
+	// có store nhưng không qua constructor: các map được tạo khi cần
+	store := NewMemoryStore()
+	posts := &PostsHandler{Store: store}
+	rec = serveAs(t, posts.CreatePost, 1, "POST", "/posts", nil, `{"content":"hi"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreatePost: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	req := httptest.NewRequest("POST", "/posts", strings.NewReader(`{"content":"again"}`))
+	req.Header.Set(IdempotencyKeyHeader, "k1")
+	req = req.WithContext(WithUserID(req.Context(), 1))
+	rec = httptest.NewRecorder()
+	posts.CreatePost(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreatePost with Idempotency-Key: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	comments := &CommentsHandler{Posts: store}
+	rec = serveAs(t, comments.CreateComment, 2, "POST", "/posts/1/comments", map[string]string{"post_id": "1"}, `{"content":"nice"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateComment: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serveAs(t, (&BlocksHandler{}).BlockUser, 1, "POST", "/users/2/block", map[string]string{"target_user_id": "2"}, "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("BlockUser: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
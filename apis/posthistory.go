@@ -0,0 +1,149 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// maxPostRevisions giới hạn số revision giữ cho mỗi post; đầy thì bỏ revision cũ nhất
+const maxPostRevisions = 50
+
+// PostRevision là content và media của post ngay sau một lần sửa; version 1 là bản gốc lúc tạo
+type PostRevision struct {
+	Version  int    `json:"version"` // tăng dần từ 1, không đổi khi revision cũ bị bỏ
+	Content  string `json:"content"`
+	MediaIDs []int  `json:"media_ids,omitempty"`
+	EditedAt string `json:"edited_at"`
+	EditorID int    `json:"editor_id"`
+}
+
+// PostHistoryResponse là response của GET /posts/{post_id}/history
+type PostHistoryResponse struct {
+	PostID    int            `json:"post_id"`
+	Revisions []PostRevision `json:"revisions"` // mới nhất trước
+}
+
+// postHistory là log chỉ ghi thêm các lần sửa post theo post_id, dùng cho MemoryStore;
+// giá trị zero dùng được ngay
+type postHistory struct {
+	mu        sync.Mutex
+	revisions map[int][]PostRevision // post_id -> revisions, cũ nhất trước
+}
+
+// record ghi thêm rev cho postID với version kế tiếp
+func (c *postHistory) record(postID int, rev PostRevision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.revisions == nil {
+		c.revisions = make(map[int][]PostRevision)
+	}
+	revs := c.revisions[postID]
+	version := 1
+	if len(revs) > 0 {
+		version = revs[len(revs)-1].Version + 1
+	}
+	if len(revs) >= maxPostRevisions {
+		revs = slices.Delete(revs, 0, len(revs)-maxPostRevisions+1)
+	}
+	rev.Version = version
+	rev.MediaIDs = slices.Clone(rev.MediaIDs)
+	c.revisions[postID] = append(revs, rev)
+}
+
+// list trả về các revision của postID, mới nhất trước
+func (c *postHistory) list(postID int) []PostRevision {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	revs := slices.Clone(c.revisions[postID])
+	slices.Reverse(revs)
+	if revs == nil {
+		revs = []PostRevision{}
+	}
+	return revs
+}
+
+// forget bỏ lịch sử của post đã bị xoá hẳn
+func (c *postHistory) forget(postID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.revisions, postID)
+}
+
+// recordEdit ghi trạng thái của post sau khi editorID sửa content/media. Lần sửa đầu tiên
+// thì ghi thêm bản trước khi sửa làm version 1 để lịch sử giữ được nội dung gốc
+func (h *PostsHandler) recordEdit(ctx context.Context, before, after Post, editorID int) {
+	revs, err := h.Store.ListPostRevisions(ctx, after.PostID)
+	if err == nil && len(revs) == 0 {
+		err = h.Store.AddPostRevision(ctx, before.PostID, PostRevision{
+			Content:  before.Content,
+			MediaIDs: before.MediaIDs,
+			EditedAt: before.CreatedAt,
+			EditorID: before.UserID,
+		})
+	}
+	if err == nil {
+		err = h.Store.AddPostRevision(ctx, after.PostID, PostRevision{
+			Content:  after.Content,
+			MediaIDs: after.MediaIDs,
+			EditedAt: nowRFC3339(),
+			EditorID: editorID,
+		})
+	}
+	if err != nil {
+		// post đã lưu rồi, thiếu một revision không đáng để trả lỗi
+		log.Println("record post history:", err)
+	}
+}
+
+// GetPostHistory godoc
+// @Summary Get the edit history of a post
+// @Description Content and media of the original post (version 1) and after each edit, newest first;
+// @Description only the author can see it. Visibility changes are not recorded and only the last 50 versions are kept
+// @Tags posts
+// @Produce json
+// @Param post_id path int true "Post ID"
+// @Security BearerAuth
+// @Success 200 {object} PostHistoryResponse
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /posts/{post_id}/history [get]
+func (h *PostsHandler) GetPostHistory(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
+	postID, _ := strconv.Atoi(mux.Vars(r)["post_id"])
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	post, err := h.Store.GetPost(r.Context(), postID)
+	if errors.Is(err, ErrPostNotFound) || (err == nil && post.IsDeleted) {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return
+	}
+	if post.UserID != currentUserID {
+		writeJSONError(w, http.StatusForbidden, "Unauthorized or not the author")
+		return
+	}
+
+	revs, err := h.Store.ListPostRevisions(r.Context(), postID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post history")
+		return
+	}
+	writeJSON(w, http.StatusOK, PostHistoryResponse{PostID: postID, Revisions: revs})
+}
@@ -0,0 +1,121 @@
+package apis
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+// editPost sửa content của post và ghi lại history qua PATCH /posts/{post_id}
+func (a *testApp) editPost(token string, postID int, content string) {
+	a.t.Helper()
+	a.expect(http.StatusOK, "PATCH", postPath(postID, ""), token, map[string]string{"content": content})
+}
+
+// history đọc GET /posts/{post_id}/history của tác giả
+func (a *testApp) history(token string, postID int) []PostRevision {
+	a.t.Helper()
+	var resp PostHistoryResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", postPath(postID, "/history"), token, nil), &resp)
+	return resp.Revisions
+}
+
+func TestPostHistoryVersions(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "first"}, "")
+
+	if revs := a.history(alice, postID); len(revs) != 0 {
+		t.Fatalf("unedited post has %d revisions", len(revs))
+	}
+
+	a.editPost(alice, postID, "second")
+	a.editPost(alice, postID, "third")
+	// chỉ đổi visibility thì không ghi revision
+	a.expect(http.StatusOK, "PATCH", postPath(postID, ""), alice, map[string]string{"visibility": "followers"})
+
+	revs := a.history(alice, postID)
+	want := []string{"third", "second", "first"}
+	if len(revs) != len(want) {
+		t.Fatalf("got %d revisions, want %d: %+v", len(revs), len(want), revs)
+	}
+	for i, rev := range revs {
+		if rev.Content != want[i] || rev.Version != len(want)-i || rev.EditorID != aliceID {
+			t.Fatalf("revision %d = %+v, want version %d with %q", i, rev, len(want)-i, want[i])
+		}
+	}
+}
+
+func TestPostHistoryAuthorOnly(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "first"}, "")
+	a.editPost(alice, postID, "second")
+
+	a.expect(http.StatusUnauthorized, "GET", postPath(postID, "/history"), "", nil)
+	a.expect(http.StatusForbidden, "GET", postPath(postID, "/history"), bob, nil)
+	a.expect(http.StatusForbidden, "PATCH", postPath(postID, ""), bob, map[string]string{"content": "hijack"})
+	if revs := a.history(alice, postID); len(revs) != 2 || revs[0].Content != "second" {
+		t.Fatalf("revisions after bob's edit = %+v", revs)
+	}
+}
+
+func TestPostHistoryPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newTestApp(t, store)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "first"}, "")
+	a.editPost(alice, postID, "second")
+	store.Close()
+
+	// mở lại database như khi server khởi động lại
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	revs, err := store.ListPostRevisions(t.Context(), postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[0].Content != "second" || revs[1].Content != "first" || revs[1].Version != 1 {
+		t.Fatalf("revisions after reopen = %+v", revs)
+	}
+
+	if err := store.DeletePost(t.Context(), postID); err != nil {
+		t.Fatal(err)
+	}
+	if revs, _ := store.ListPostRevisions(t.Context(), postID); len(revs) != 0 {
+		t.Fatalf("permanently deleted post kept %d revisions", len(revs))
+	}
+}
+
+func TestPostRevisionsCapped(t *testing.T) {
+	stores := map[string]Store{"memory": NewMemoryStore()}
+	sqlite, err := NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+	stores["sqlite"] = sqlite
+
+	for name, store := range stores {
+		for i := 0; i < maxPostRevisions+5; i++ {
+			if err := store.AddPostRevision(t.Context(), 1, PostRevision{Content: "v", EditedAt: nowRFC3339()}); err != nil {
+				t.Fatal(name, err)
+			}
+		}
+		revs, err := store.ListPostRevisions(t.Context(), 1)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if len(revs) != maxPostRevisions || revs[0].Version != maxPostRevisions+5 || revs[len(revs)-1].Version != 6 {
+			t.Fatalf("%s: %d revisions from %d to %d", name, len(revs), revs[0].Version, revs[len(revs)-1].Version)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package apis
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -15,138 +21,536 @@ type UserProfile struct {
 	Username  string `json:"username"`
 	Avatar    string `json:"avatar,omitempty"`
 	Bio       string `json:"bio,omitempty"`
-	CreatedAt string `json:"createdAt"`
-	IsPrivate bool
+	CreatedAt string `json:"createdAt,omitempty"`
+	IsPrivate bool   `json:"is_private"`
+}
+
+// UpdateProfileRequest là body của PATCH /me; mọi field là con trỏ để phân biệt
+// "không gửi" (hoặc null, giữ nguyên) với giá trị rỗng ("" xoá bio/avatar)
+type UpdateProfileRequest struct {
+	Username  *string `json:"username"`
+	Avatar    *string `json:"avatar"`
+	Bio       *string `json:"bio"`
+	IsPrivate *bool   `json:"is_private"`
+}
+
+// usernamePattern: 3-30 ký tự chữ, số, dấu chấm hoặc gạch dưới
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]{3,30}$`)
+
+// UsersResponse là một trang kết quả tìm user
+type UsersResponse struct {
+	Users []UserProfile `json:"users"`
+	PageMeta
+}
+
+// ProfileSummary là profile kèm các con số cần để hiển thị trang cá nhân
+type ProfileSummary struct {
+	UserProfile
+	FollowerCount  int `json:"follower_count"`
+	FollowingCount int `json:"following_count"`
+	PostCount      int `json:"post_count"` // chỉ các post người xem được thấy
 }
 
 // ProfileHandler quản lý profile
 type ProfileHandler struct {
-	Users map[int]UserProfile // key = user_id
+	mu      sync.RWMutex
+	Users   map[int]UserProfile // key = user_id
+	Tokens  *TokenService
+	Follows *FollowsHandler // follower được xem profile private
+	Blocks  *BlocksHandler  // user đã chặn nhau không thấy profile của nhau
+	Posts   Store           // lưu profiles, đếm post cho GET /users/{user_id}/summary
+	Auth    *AuthHandler    // đổi username qua index của auth để login theo tên mới; nil thì chỉ kiểm tra trùng trong profiles
+
+	// CacheTTL là thời gian cache một profile khi đọc theo user_id; <= 0 thì tắt cache
+	CacheTTL time.Duration
+	cache    profileCache
+
+	DefaultAvatar string // avatar gán cho user mới đăng ký, rỗng thì không có avatar
+}
+
+// NewProfileHandler constructor
+func NewProfileHandler() *ProfileHandler {
+	return &ProfileHandler{
+		Users:    make(map[int]UserProfile),
+		CacheTTL: DefaultProfileCacheTTL,
+	}
 }
 
 // RegisterRoutes đăng ký các endpoint profile
 func (h *ProfileHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/users/{user_id}", h.GetProfile).Methods("GET")
-	router.HandleFunc("/me", h.UpdateProfile).Methods("PATCH")
-	router.HandleFunc("/users", h.SearchUsers).Methods("GET")
+	router.Handle("/users/{user_id}", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetProfile))).Methods("GET")
+	router.Handle("/users/{user_id}/summary", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetProfileSummary))).Methods("GET")
+	router.Handle("/me", h.Tokens.RequireAuth(http.HandlerFunc(h.UpdateProfile))).Methods("PATCH")
+	router.Handle("/users", h.Tokens.OptionalAuth(http.HandlerFunc(h.SearchUsers))).Methods("GET")
 }
 
 // GetProfile godoc
 // @Summary Get user profile
-// @Description Get profile of a user by user_id
+// @Description Get profile of a user by user_id; private profiles show only username and avatar
+// @Description unless the requester is the owner or a follower
 // @Tags profile
 // @Produce json
 // @Param user_id path int true "User ID"
 // @Param Authorization header string false "Bearer token"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} UserProfile
-// @Failure 403 {object} map[string]string
-// @Failure 404 {object} map[string]string
+// @Success 304 "Not Modified"
+// @Failure 400 {object} APIError
+// @Failure 404 {object} APIError
 // @Router /users/{user_id} [get]
 func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["user_id"]
 	userID, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, `{"error":"Invalid user ID"}`, http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
-	user, exists := h.Users[userID]
-	if !exists {
-		http.Error(w, `{"error":"User not found"}`, http.StatusNotFound)
+	requesterID, _ := UserIDFromContext(r.Context())
+	user, ok := h.visibleProfile(requesterID, userID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// nội dung phụ thuộc người xem (private) nên cache phải tách theo token
+	w.Header().Set("Vary", "Authorization")
+	writeJSONWithETag(w, r, user)
+}
+
+// GetProfileSummary godoc
+// @Summary Get user profile summary
+// @Description Get a profile with follower, following and post counts in one call. The profile follows
+// @Description the same privacy rules as GET /users/{user_id}; post_count only counts posts the requester can see
+// @Tags profile
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param Authorization header string false "Bearer token"
+// @Success 200 {object} ProfileSummary
+// @Failure 400 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /users/{user_id}/summary [get]
+func (h *ProfileHandler) GetProfileSummary(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
-	// Demo: nếu profile private và không phải chính chủ
-	if user.IsPrivate {
-		http.Error(w, `{"error":"Private profile"}`, http.StatusForbidden)
+	requesterID, _ := UserIDFromContext(r.Context())
+	user, ok := h.visibleProfile(requesterID, userID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "User not found")
 		return
 	}
 
-	json.NewEncoder(w).Encode(user)
+	summary := ProfileSummary{UserProfile: user}
+	summary.FollowerCount, summary.FollowingCount = h.Follows.followCounts(userID)
+	if h.Posts != nil {
+		posts, err := h.Posts.ListUserPosts(r.Context(), userID)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Cannot load posts")
+			return
+		}
+		for _, p := range posts {
+			if !p.IsDeleted && canSeePost(h.Follows, requesterID, p) {
+				summary.PostCount++
+			}
+		}
+	}
+
+	w.Header().Set("Vary", "Authorization")
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// visibleProfile trả về profile userID như requesterID được thấy; false khi không
+// tồn tại hoặc hai người đã chặn nhau. Profile private chỉ chính chủ và follower xem được đầy đủ
+func (h *ProfileHandler) visibleProfile(requesterID, userID int) (UserProfile, bool) {
+	if h == nil {
+		return UserProfile{}, false
+	}
+	user, exists := h.profile(userID)
+	if !exists || h.Blocks.isBlocked(requesterID, userID) {
+		return UserProfile{}, false
+	}
+	if user.IsPrivate && !h.canViewPrivate(requesterID, userID) {
+		user = UserProfile{
+			UserID:    user.UserID,
+			Username:  user.Username,
+			Avatar:    user.Avatar,
+			IsPrivate: true,
+		}
+	}
+	return user, true
 }
 
 // UpdateProfile godoc
 // @Summary Update own profile
-// @Description Update your own profile
+// @Description Update your own profile. Omitted or null fields are left unchanged;
+// @Description an empty bio or avatar clears it, an empty username is rejected
 // @Tags profile
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Bearer token"
-// @Param body body UserProfile true "Profile data"
+// @Security BearerAuth
+// @Param body body UpdateProfileRequest true "Profile data"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 409 {object} APIError
 // @Router /me [patch]
 func (h *ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
-	var req UserProfile
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"Invalid data"}`, http.StatusBadRequest)
+	var req UpdateProfileRequest
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.Username != nil && *req.Username == "" {
+		writeJSONError(w, http.StatusBadRequest, "Username cannot be empty")
+		return
+	}
+	if req.Username != nil && !usernamePattern.MatchString(*req.Username) {
+		writeJSONError(w, http.StatusBadRequest, "Username must be 3-30 letters, digits, '.' or '_'")
+		return
+	}
+	if req.Avatar != nil && *req.Avatar != "" && !isHTTPURL(*req.Avatar) {
+		writeJSONError(w, http.StatusBadRequest, "Avatar must be an http(s) URL")
+		return
+	}
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+	if _, exists := h.profile(currentUserID); !exists {
+		writeJSONError(w, http.StatusForbidden, "Unauthorized")
 		return
 	}
 
-	// Demo: giả sử user hiện tại là user_id=1
-	currentUser, exists := h.Users[1]
+	// đổi tên trước, không giữ h.mu vì auth sẽ gọi lại setUsername
+	if req.Username != nil {
+		err := h.renameUser(r.Context(), currentUserID, *req.Username)
+		if errors.Is(err, errUsernameTaken) {
+			writeJSONError(w, http.StatusConflict, "Username already taken")
+			return
+		}
+		if errors.Is(err, errUserNotFound) {
+			writeJSONError(w, http.StatusForbidden, "Unauthorized")
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Cannot save profile")
+			return
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	currentUser, exists := h.Users[currentUserID]
 	if !exists {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusForbidden)
+		writeJSONError(w, http.StatusForbidden, "Unauthorized")
 		return
 	}
 
-	if req.Username != "" {
-		currentUser.Username = req.Username
+	if req.Avatar != nil {
+		currentUser.Avatar = *req.Avatar
 	}
-	if req.Avatar != "" {
-		currentUser.Avatar = req.Avatar
+	if req.Bio != nil {
+		currentUser.Bio = *req.Bio
 	}
-	if req.Bio != "" {
-		currentUser.Bio = req.Bio
+	if req.IsPrivate != nil {
+		currentUser.IsPrivate = *req.IsPrivate
+	}
+	if err := h.saveProfile(r.Context(), currentUser); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save profile")
+		return
 	}
 
-	h.Users[1] = currentUser
-	json.NewEncoder(w).Encode(map[string]string{"message": "Profile updated"})
+	h.Users[currentUserID] = currentUser
+	h.cache.invalidate(currentUserID)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Profile updated"})
 }
 
 // SearchUsers godoc
 // @Summary Search users
-// @Description Search users by query
+// @Description Search users by username and/or bio; private profiles only show up for their followers
 // @Tags profile
 // @Produce json
 // @Param search query string false "Search query"
+// @Param fields query string false "Comma-separated fields to search: username, bio (default both)"
 // @Param offset query int false "Offset"
-// @Param limit query int false "Limit"
-// @Param sort query string false "Sort field"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Param sort query string false "Sort field: username (default) or created_at"
+// @Param order query string false "Sort order: asc (default) or desc"
 // @Param Authorization header string false "Bearer token"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
+// @Success 200 {object} UsersResponse
+// @Failure 400 {object} APIError
 // @Router /users [get]
 func (h *ProfileHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query().Get("search")
-	offsetStr := r.URL.Query().Get("offset")
-	limitStr := r.URL.Query().Get("limit")
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
 
-	offset, _ := strconv.Atoi(offsetStr)
-	limit, _ := strconv.Atoi(limitStr)
+	sortField := r.URL.Query().Get("sort")
+	if sortField == "" {
+		sortField = "username"
+	}
+	if sortField != "username" && sortField != "created_at" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid sort, use username or created_at")
+		return
+	}
+	order := r.URL.Query().Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid order, use asc or desc")
+		return
+	}
 
-	usersList := []UserProfile{}
+	searchUsername, searchBio := true, true
+	if fields := r.URL.Query().Get("fields"); fields != "" {
+		searchUsername, searchBio = false, false
+		for _, f := range strings.Split(fields, ",") {
+			switch strings.TrimSpace(f) {
+			case "username":
+				searchUsername = true
+			case "bio":
+				searchBio = true
+			default:
+				writeJSONError(w, http.StatusBadRequest, "Invalid fields, use username and/or bio")
+				return
+			}
+		}
+	}
+
+	matches := []UserProfile{}
+	h.mu.RLock()
 	for _, u := range h.Users {
-		if q == "" || containsIgnoreCase(u.Username, q) {
-			usersList = append(usersList, u)
+		if q == "" || (searchUsername && containsIgnoreCase(u.Username, q)) || (searchBio && containsIgnoreCase(u.Bio, q)) {
+			matches = append(matches, u)
 		}
 	}
+	h.mu.RUnlock()
 
-	// áp limit, offset
-	end := offset + limit
-	if end > len(usersList) {
-		end = len(usersList)
+	// kiểm tra follow sau khi nhả lock để không giữ hai lock cùng lúc
+	requesterID, _ := UserIDFromContext(r.Context())
+	usersList := []UserProfile{}
+	for _, u := range matches {
+		if u.IsPrivate && !h.canViewPrivate(requesterID, u.UserID) {
+			continue
+		}
+		if h.Blocks.isBlocked(requesterID, u.UserID) {
+			continue
+		}
+		usersList = append(usersList, u)
+	}
+
+	// map không có thứ tự nên sort rồi mới cắt trang; user_id để phá hoà
+	sort.Slice(usersList, func(i, j int) bool {
+		a, b := usersList[i], usersList[j]
+		if order == "desc" {
+			a, b = b, a
+		}
+		if sortField == "created_at" {
+			// so sánh thời điểm chứ không so chuỗi; cùng giây thì user_id (tăng dần theo lúc đăng ký) quyết định
+			ta, _ := time.Parse(time.RFC3339, a.CreatedAt)
+			tb, _ := time.Parse(time.RFC3339, b.CreatedAt)
+			if !ta.Equal(tb) {
+				return ta.Before(tb)
+			}
+			return a.UserID < b.UserID
+		}
+		ka, kb := strings.ToLower(a.Username), strings.ToLower(b.Username)
+		if ka != kb {
+			return ka < kb
+		}
+		return a.UserID < b.UserID
+	})
+
+	offset, end := pageBounds(len(usersList), offset, limit)
+
+	writeJSON(w, http.StatusOK, UsersResponse{
+		Users:    usersList[offset:end],
+		PageMeta: newPageMeta(len(usersList), offset, end, limit),
+	})
+}
+
+// profile trả về profile theo user_id, dùng được từ handler khác; đọc qua cache khi CacheTTL > 0
+func (h *ProfileHandler) profile(userID int) (UserProfile, bool) {
+	if h.CacheTTL > 0 {
+		if p, ok := h.cache.get(userID); ok {
+			return p, true
+		}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	p, ok := h.Users[userID]
+	// put khi còn giữ RLock: writer giữ Lock lúc invalidate nên không thể ghi đè bằng bản cũ
+	if ok && h.CacheTTL > 0 {
+		h.cache.put(userID, p, h.CacheTTL)
+	}
+	return p, ok
+}
+
+// userIDByUsername tìm user theo username không phân biệt hoa thường; an toàn khi h là nil
+func (h *ProfileHandler) userIDByUsername(username string) (int, bool) {
+	if h == nil {
+		return 0, false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for id, u := range h.Users {
+		if strings.EqualFold(u.Username, username) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// renameUser đổi username của userID qua Auth; không có Auth thì chỉ kiểm tra trùng trong profiles
+func (h *ProfileHandler) renameUser(ctx context.Context, userID int, username string) error {
+	if h.Auth != nil {
+		return h.Auth.renameUser(ctx, userID, username)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, u := range h.Users {
+		if id != userID && strings.EqualFold(u.Username, username) {
+			return errUsernameTaken
+		}
+	}
+	p, ok := h.Users[userID]
+	if !ok {
+		return errUserNotFound
+	}
+	p.Username = username
+	if err := h.saveProfile(ctx, p); err != nil {
+		return err
+	}
+	h.Users[userID] = p
+	h.cache.invalidate(userID)
+	return nil
+}
+
+// setUsername đổi username trên profile của userID; bỏ qua khi h là nil hoặc không có profile
+func (h *ProfileHandler) setUsername(ctx context.Context, userID int, username string) error {
+	if h == nil {
+		return nil
 	}
-	if offset > len(usersList) {
-		offset = len(usersList)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p, ok := h.Users[userID]
+	if !ok {
+		return nil
+	}
+	p.Username = username
+	if err := h.saveProfile(ctx, p); err != nil {
+		return err
+	}
+	h.Users[userID] = p
+	h.cache.invalidate(userID)
+	return nil
+}
+
+// setAvatar đổi avatar của userID; trả về errUserNotFound khi không có profile hoặc h là nil
+func (h *ProfileHandler) setAvatar(ctx context.Context, userID int, url string) error {
+	if h == nil {
+		return errUserNotFound
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p, ok := h.Users[userID]
+	if !ok {
+		return errUserNotFound
+	}
+	p.Avatar = url
+	if err := h.saveProfile(ctx, p); err != nil {
+		return err
+	}
+	h.Users[userID] = p
+	h.cache.invalidate(userID)
+	return nil
+}
+
+// canViewPrivate cho biết requester có được xem profile private của ownerID không
+func (h *ProfileHandler) canViewPrivate(requesterID, ownerID int) bool {
+	if requesterID == 0 {
+		return false
+	}
+	if requesterID == ownerID {
+		return true
+	}
+	return h.Follows.isFollowing(requesterID, ownerID)
+}
+
+// createProfile tạo profile mặc định cho user mới đăng ký; bỏ qua khi h là nil.
+// CreatedAt lấy từ user để profile và tài khoản có cùng thời điểm tạo. Lưu không được thì
+// profile vẫn có trong bộ nhớ nhưng mất khi khởi động lại
+func (h *ProfileHandler) createProfile(ctx context.Context, user User) error {
+	if h == nil {
+		return nil
+	}
+	createdAt := user.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
 	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	resp := map[string]interface{}{
-		"users": usersList[offset:end],
-		"total": len(usersList),
+	if h.Users == nil {
+		h.Users = make(map[int]UserProfile)
 	}
-	json.NewEncoder(w).Encode(resp)
+	p := UserProfile{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Avatar:    h.DefaultAvatar,
+		CreatedAt: formatRFC3339(createdAt),
+	}
+	h.Users[user.ID] = p
+	h.cache.invalidate(user.ID)
+	return h.saveProfile(ctx, p)
+}
+
+// saveProfile ghi p xuống Posts; không có store thì profile chỉ nằm trong bộ nhớ
+func (h *ProfileHandler) saveProfile(ctx context.Context, p UserProfile) error {
+	if h.Posts == nil {
+		return nil
+	}
+	return h.Posts.SaveProfile(ctx, p)
+}
+
+// Load nạp các profile đã lưu trong Posts; gọi một lần lúc khởi động, trước khi phục vụ request
+func (h *ProfileHandler) Load(ctx context.Context) error {
+	if h.Posts == nil {
+		return nil
+	}
+	profiles, err := h.Posts.ListProfiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.Users == nil {
+		h.Users = make(map[int]UserProfile)
+	}
+	for _, p := range profiles {
+		h.Users[p.UserID] = p
+		h.cache.invalidate(p.UserID)
+	}
+	return nil
+}
+
+// isHTTPURL kiểm tra s là URL tuyệt đối dùng http hoặc https
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
 }
 
 // containsIgnoreCase kiểm tra substring không phân biệt hoa thường
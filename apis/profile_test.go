@@ -0,0 +1,290 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateProfileRename(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	a.register("bob")
+
+	a.expect(http.StatusConflict, "PATCH", "/me", alice, map[string]string{"username": "BOB"})
+	// đổi hoa thường tên của chính mình không phải trùng
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]string{"username": "Alice"})
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]string{"username": "carol"})
+
+	a.expect(http.StatusUnauthorized, "POST", "/login", "", LoginRequest{Login: "alice", Password: "password1"})
+	a.expect(http.StatusOK, "POST", "/login", "", LoginRequest{Login: "Carol", Password: "password1"})
+
+	var profile UserProfile
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/users/1", alice, nil), &profile)
+	if profile.Username != "carol" {
+		t.Fatalf("profile username = %q", profile.Username)
+	}
+
+	// tên cũ đã được giải phóng, tên mới thì không
+	a.expect(http.StatusOK, "POST", "/register", "", map[string]string{
+		"username": "alice", "email": "alice2@example.com", "password": "password1",
+	})
+	a.expect(http.StatusConflict, "POST", "/register", "", map[string]string{
+		"username": "CAROL", "email": "carol2@example.com", "password": "password1",
+	})
+}
+
+func TestPrivateProfileVisibility(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]any{"bio": "hello", "is_private": true})
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+
+	path := fmt.Sprintf("/users/%d", aliceID)
+	for name, token := range map[string]string{"owner": alice, "follower": bob} {
+		var profile UserProfile
+		decodeBody(t, a.expect(http.StatusOK, "GET", path, token, nil), &profile)
+		if profile.Bio != "hello" || profile.CreatedAt == "" {
+			t.Fatalf("%s sees %+v, want the full profile", name, profile)
+		}
+	}
+
+	// người lạ và khách chỉ thấy bản rút gọn
+	for name, token := range map[string]string{"stranger": carol, "anonymous": ""} {
+		var profile UserProfile
+		decodeBody(t, a.expect(http.StatusOK, "GET", path, token, nil), &profile)
+		want := UserProfile{UserID: aliceID, Username: "alice", IsPrivate: true}
+		if profile != want {
+			t.Fatalf("%s sees %+v, want %+v", name, profile, want)
+		}
+	}
+}
+
+// searchUsers gọi GET /users?query và trả về username theo thứ tự
+func (a *testApp) searchUsers(token, query string) []string {
+	a.t.Helper()
+	var resp UsersResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", "/users?"+query, token, nil), &resp)
+	names := make([]string, len(resp.Users))
+	for i, u := range resp.Users {
+		names[i] = u.Username
+	}
+	return names
+}
+
+func TestSearchUsersSort(t *testing.T) {
+	a := newTestApp(t, nil)
+	for _, name := range []string{"carol", "alice", "Bob"} {
+		a.register(name)
+	}
+
+	for query, want := range map[string][]string{
+		"":                           {"alice", "Bob", "carol"},
+		"sort=username&order=desc":   {"carol", "Bob", "alice"},
+		"sort=created_at":            {"carol", "alice", "Bob"},
+		"sort=created_at&order=desc": {"Bob", "alice", "carol"},
+		"sort=username&limit=2":      {"alice", "Bob"},
+	} {
+		if got := a.searchUsers("", query); !slices.Equal(got, want) {
+			t.Fatalf("%q: %v, want %v", query, got, want)
+		}
+	}
+	a.expect(http.StatusBadRequest, "GET", "/users?sort=email", "", nil)
+	a.expect(http.StatusBadRequest, "GET", "/users?order=up", "", nil)
+}
+
+func TestSearchUsersFields(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, gopher := a.register("gopher")
+	bobID, bob := a.register("bob")
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]string{"bio": "I love Go and hiking"})
+	a.expect(http.StatusOK, "PATCH", "/me", bob, map[string]any{"bio": "go go go", "is_private": true})
+
+	for query, want := range map[string][]string{
+		"search=HIKING":                 {"alice"},
+		"search=go":                     {"alice", "gopher"},
+		"search=go&fields=username":     {"gopher"},
+		"search=go&fields=bio":          {"alice"},
+		"search=go&fields=bio,username": {"alice", "gopher"},
+	} {
+		if got := a.searchUsers("", query); !slices.Equal(got, want) {
+			t.Fatalf("%q: %v, want %v", query, got, want)
+		}
+	}
+	a.expect(http.StatusBadRequest, "GET", "/users?search=go&fields=email", "", nil)
+
+	// profile private chỉ hiện với follower của nó
+	if got := a.searchUsers(gopher, "search=go&fields=bio"); !slices.Equal(got, []string{"alice"}) {
+		t.Fatalf("stranger sees %v", got)
+	}
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", bobID), gopher, nil)
+	if got := a.searchUsers(gopher, "search=go&fields=bio"); !slices.Equal(got, []string{"alice", "bob"}) {
+		t.Fatalf("follower sees %v", got)
+	}
+}
+
+func TestUpdateProfilePrivacyAndValidation(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	path := fmt.Sprintf("/users/%d", aliceID)
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]any{"bio": "hi", "avatar": "https://cdn.example.com/a.png"})
+
+	profile := func() UserProfile {
+		var p UserProfile
+		decodeBody(t, a.expect(http.StatusOK, "GET", path, alice, nil), &p)
+		return p
+	}
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]any{"is_private": true})
+	if p := profile(); !p.IsPrivate || p.Bio != "hi" {
+		t.Fatalf("after making private = %+v", p)
+	}
+	// false gửi tường minh vẫn được áp dụng, field bỏ trống thì giữ nguyên
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]any{"is_private": false})
+	if p := profile(); p.IsPrivate || p.Bio != "hi" || p.Avatar != "https://cdn.example.com/a.png" {
+		t.Fatalf("after making public = %+v", p)
+	}
+
+	for _, avatar := range []string{"javascript:alert(1)", "ftp://example.com/a.png", "not a url"} {
+		a.expect(http.StatusBadRequest, "PATCH", "/me", alice, map[string]string{"avatar": avatar})
+	}
+	for _, name := range []string{"al", "has space", strings.Repeat("a", 31)} {
+		a.expect(http.StatusBadRequest, "PATCH", "/me", alice, map[string]string{"username": name})
+	}
+	if p := profile(); p.Avatar != "https://cdn.example.com/a.png" || p.Username != "alice" {
+		t.Fatalf("after rejected updates = %+v", p)
+	}
+}
+
+func TestSearchUsersSortByCreatedAt(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, _ := a.register("alice")
+	bobID, _ := a.register("bob")
+
+	user, _ := a.auth.userByID(aliceID)
+	a.profiles.mu.RLock()
+	profile := a.profiles.Users[aliceID]
+	a.profiles.mu.RUnlock()
+	if user.CreatedAt.IsZero() || profile.CreatedAt != formatRFC3339(user.CreatedAt) {
+		t.Fatalf("user created %v, profile created %q", user.CreatedAt, profile.CreatedAt)
+	}
+
+	// bob đăng ký sau nhưng lùi thời điểm tạo về hôm qua: phải đứng trước dù user_id lớn hơn
+	a.profiles.mu.Lock()
+	p := a.profiles.Users[bobID]
+	p.CreatedAt = formatRFC3339(time.Now().Add(-24 * time.Hour))
+	a.profiles.Users[bobID] = p
+	a.profiles.mu.Unlock()
+
+	if got := a.searchUsers("", "sort=created_at"); !slices.Equal(got, []string{"bob", "alice"}) {
+		t.Fatalf("created_at asc = %v", got)
+	}
+	if got := a.searchUsers("", "sort=created_at&order=desc"); !slices.Equal(got, []string{"alice", "bob"}) {
+		t.Fatalf("created_at desc = %v", got)
+	}
+}
+
+func TestUpdateProfileClearVersusOmit(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	path := fmt.Sprintf("/users/%d", aliceID)
+	avatar := "https://cdn.example.com/a.png"
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]any{"bio": "hi", "avatar": avatar})
+	profile := func() UserProfile {
+		var p UserProfile
+		decodeBody(t, a.expect(http.StatusOK, "GET", path, alice, nil), &p)
+		return p
+	}
+
+	// bio rỗng là xoá bio; avatar không gửi (hoặc null) thì giữ nguyên
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]any{"bio": ""})
+	if p := profile(); p.Bio != "" || p.Avatar != avatar {
+		t.Fatalf("after clearing bio = %+v", p)
+	}
+	a.expect(http.StatusOK, "PATCH", "/me", alice, []byte(`{"bio": "back", "avatar": null}`))
+	if p := profile(); p.Bio != "back" || p.Avatar != avatar {
+		t.Fatalf("after null avatar = %+v", p)
+	}
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]any{"avatar": ""})
+	if p := profile(); p.Avatar != "" || p.Bio != "back" {
+		t.Fatalf("after clearing avatar = %+v", p)
+	}
+
+	rec := a.expect(http.StatusBadRequest, "PATCH", "/me", alice, map[string]any{"username": ""})
+	var apiErr APIError
+	decodeBody(t, rec, &apiErr)
+	if apiErr.Error != "Username cannot be empty" {
+		t.Fatalf("empty username error = %+v", apiErr)
+	}
+	if p := profile(); p.Username != "alice" {
+		t.Fatalf("username after rejected clear = %q", p.Username)
+	}
+}
+
+func TestProfileSummary(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+	_, carol := a.register("carol")
+	_, dave := a.register("dave")
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]any{"bio": "hello"})
+	for _, token := range []string{bob, carol} {
+		a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), token, nil)
+	}
+	a.expect(http.StatusCreated, "POST", "/users/4/follow", alice, nil)
+	a.createPost(alice, map[string]any{"content": "public"}, "")
+	a.createPost(alice, map[string]any{"content": "followers", "visibility": "followers"}, "")
+	a.createPost(alice, map[string]any{"content": "private", "visibility": "private"}, "")
+	gone := a.createPost(alice, map[string]any{"content": "gone"}, "")
+	a.expect(http.StatusNoContent, "DELETE", postPath(gone, ""), alice, nil)
+
+	summary := func(token string) ProfileSummary {
+		var s ProfileSummary
+		decodeBody(t, a.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d/summary", aliceID), token, nil), &s)
+		return s
+	}
+	// số post chỉ đếm những post người xem được thấy
+	for name, tc := range map[string]struct {
+		token string
+		posts int
+	}{
+		"owner":     {alice, 3},
+		"follower":  {bob, 2},
+		"stranger":  {dave, 1},
+		"anonymous": {"", 1},
+	} {
+		s := summary(tc.token)
+		if s.Username != "alice" || s.Bio != "hello" || s.FollowerCount != 2 || s.FollowingCount != 1 || s.PostCount != tc.posts {
+			t.Fatalf("%s: summary = %+v, want 2 followers, 1 following, %d posts", name, s, tc.posts)
+		}
+	}
+	a.expect(http.StatusNotFound, "GET", "/users/99/summary", "", nil)
+	a.expect(http.StatusBadRequest, "GET", "/users/abc/summary", "", nil)
+}
+
+func TestSearchUsersLargeOffset(t *testing.T) {
+	a := newTestApp(t, nil)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		a.register(name)
+	}
+
+	var page UsersResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/users?offset=50&limit=2", "", nil), &page)
+	if page.Users == nil || len(page.Users) != 0 || page.Total != 3 || page.HasMore {
+		t.Fatalf("page past the end = %+v", page)
+	}
+	if got := a.searchUsers("", "offset=2&limit=5"); !slices.Equal(got, []string{"carol"}) {
+		t.Fatalf("last page = %v", got)
+	}
+	var defaults UsersResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/users", "", nil), &defaults)
+	if defaults.Limit != DefaultPageLimit || len(defaults.Users) != 3 {
+		t.Fatalf("default page = %+v", defaults.PageMeta)
+	}
+	a.expect(http.StatusBadRequest, "GET", fmt.Sprintf("/users?limit=%d", MaxPageLimit+1), "", nil)
+}
@@ -0,0 +1,56 @@
+package apis
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultProfileCacheTTL là thời gian giữ một profile trong cache khi không cấu hình
+const DefaultProfileCacheTTL = 30 * time.Second
+
+// cachedProfile là một profile trong cache cùng thời điểm hết hạn
+type cachedProfile struct {
+	profile UserProfile
+	expires time.Time
+}
+
+// profileCache is a TTL cache of profiles keyed by user_id; the zero value is ready to use
+type profileCache struct {
+	mu      sync.Mutex
+	entries map[int]cachedProfile
+}
+
+// get returns the cached profile of userID if it has not expired
+func (c *profileCache) get(userID int) (UserProfile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[userID]
+	if !ok {
+		return UserProfile{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, userID)
+		return UserProfile{}, false
+	}
+	return e.profile, true
+}
+
+// put stores p for ttl; the cache never holds more entries than there are profiles
+func (c *profileCache) put(userID int, p UserProfile, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[int]cachedProfile)
+	}
+	c.entries[userID] = cachedProfile{profile: p, expires: time.Now().Add(ttl)}
+}
+
+// invalidate drops userID so the next read goes to the store
+func (c *profileCache) invalidate(userID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, userID)
+}
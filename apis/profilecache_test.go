@@ -0,0 +1,62 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// setBioBehindCache sửa bio trong map profile mà không qua handler, để biết lần đọc sau
+// có đi tới map hay lấy từ cache
+func (a *testApp) setBioBehindCache(userID int, bio string) {
+	a.profiles.mu.Lock()
+	defer a.profiles.mu.Unlock()
+	p := a.profiles.Users[userID]
+	p.Bio = bio
+	a.profiles.Users[userID] = p
+}
+
+func TestProfileCache(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.profiles.CacheTTL = time.Minute
+	aliceID, alice := a.register("alice")
+	path := fmt.Sprintf("/users/%d", aliceID)
+	bio := func() string {
+		var p UserProfile
+		decodeBody(t, a.expect(http.StatusOK, "GET", path, "", nil), &p)
+		return p.Bio
+	}
+
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]string{"bio": "first"})
+	if got := bio(); got != "first" {
+		t.Fatalf("first read = %q", got)
+	}
+	a.setBioBehindCache(aliceID, "changed behind the cache")
+	if got := bio(); got != "first" {
+		t.Fatalf("second read = %q, want the cached profile", got)
+	}
+
+	// cập nhật qua handler thì xoá cache
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]string{"bio": "second"})
+	if got := bio(); got != "second" {
+		t.Fatalf("read after update = %q", got)
+	}
+
+	// hết hạn thì đọc lại từ map
+	a.profiles.CacheTTL = time.Millisecond
+	a.profiles.cache.invalidate(aliceID)
+	bio()
+	a.setBioBehindCache(aliceID, "after expiry")
+	time.Sleep(5 * time.Millisecond)
+	if got := bio(); got != "after expiry" {
+		t.Fatalf("read after expiry = %q", got)
+	}
+
+	// CacheTTL = 0 tắt cache
+	a.profiles.CacheTTL = 0
+	a.setBioBehindCache(aliceID, "uncached")
+	if got := bio(); got != "uncached" {
+		t.Fatalf("read with cache disabled = %q", got)
+	}
+}
@@ -0,0 +1,105 @@
+package apis
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Các action được ghi vào reaction event log
+const (
+	ReactionAdded   = "add"
+	ReactionChanged = "change" // đổi sang loại reaction khác
+	ReactionRemoved = "remove"
+)
+
+// ReactionEvent is one entry of the append-only reaction event log
+type ReactionEvent struct {
+	PostID int    `json:"post_id"`
+	UserID int    `json:"user_id"`
+	Type   string `json:"type"` // loại reaction sau khi add/change, loại bị bỏ khi remove
+	Action string `json:"action"`
+	At     string `json:"at"`
+}
+
+// ReactionEventsResponse represents response for GET /admin/reactions/events
+type ReactionEventsResponse struct {
+	Enabled bool            `json:"enabled"` // false khi server chạy không bật event log
+	Events  []ReactionEvent `json:"events"`
+	PageMeta
+}
+
+// logEvent appends to the event log when it is enabled; the caller must hold h.mu
+func (h *ReactionsHandler) logEvent(postID, userID int, reactionType, action string) {
+	if !h.LogEvents {
+		return
+	}
+	h.events = append(h.events, ReactionEvent{
+		PostID: postID,
+		UserID: userID,
+		Type:   reactionType,
+		Action: action,
+		At:     nowRFC3339(),
+	})
+}
+
+// @Summary List Reaction Events
+// @Description Every reaction added, changed or removed, oldest first; admin only.
+// @Description Events are only recorded while the server runs with REACTION_EVENT_LOG=true
+// @Tags reactions
+// @Produce json
+// @Security BearerAuth
+// @Param post_id query int false "Only events of this post"
+// @Param user_id query int false "Only events of this user"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Success 200 {object} ReactionEventsResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Router /admin/reactions/events [get]
+func (h *ReactionsHandler) ListReactionEvents(w http.ResponseWriter, r *http.Request) {
+	postID, ok := optionalIDParam(w, r, "post_id")
+	if !ok {
+		return
+	}
+	userID, ok := optionalIDParam(w, r, "user_id")
+	if !ok {
+		return
+	}
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	events := []ReactionEvent{}
+	for _, e := range h.events {
+		if (postID == 0 || e.PostID == postID) && (userID == 0 || e.UserID == userID) {
+			events = append(events, e)
+		}
+	}
+	enabled := h.LogEvents
+	h.mu.Unlock()
+
+	start, end := pageBounds(len(events), offset, limit)
+	writeJSON(w, http.StatusOK, ReactionEventsResponse{
+		Enabled:  enabled,
+		Events:   events[start:end],
+		PageMeta: newPageMeta(len(events), start, end, limit),
+	})
+}
+
+// optionalIDParam parses an optional positive ID query parameter (0 when absent);
+// writes 400 and returns false when it is invalid
+func optionalIDParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil || id <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "Invalid "+name)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,71 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// reactionEvents đọc GET /admin/reactions/events?query bằng token admin
+func (a *testApp) reactionEvents(admin, query string) ReactionEventsResponse {
+	a.t.Helper()
+	var resp ReactionEventsResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", "/admin/reactions/events?"+query, admin, nil), &resp)
+	return resp
+}
+
+func TestReactionEventLog(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.reacts.LogEvents = true
+	adminID, admin := a.register("admin")
+	a.tokens.Admins[adminID] = true
+	_, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	other := a.createPost(alice, map[string]any{"content": "other"}, "")
+
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "love"})
+	a.expect(http.StatusNoContent, "DELETE", postPath(postID, "/reactions"), bob, nil)
+	a.expect(http.StatusCreated, "POST", postPath(other, "/reactions"), alice, map[string]string{"reaction_type": "wow"})
+
+	// trạng thái hiện tại vẫn về 0, còn log giữ lại toàn bộ lịch sử
+	var live GetReactionsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/reactions"), "", nil), &live)
+	if live.Count != 0 {
+		t.Fatalf("live reactions = %+v", live)
+	}
+
+	resp := a.reactionEvents(admin, fmt.Sprintf("post_id=%d", postID))
+	var got []string
+	for _, e := range resp.Events {
+		if e.UserID != bobID || e.At == "" {
+			t.Fatalf("event = %+v", e)
+		}
+		got = append(got, e.Action+":"+e.Type)
+	}
+	want := []string{"add:like", "change:love", "remove:love"}
+	if !resp.Enabled || !slices.Equal(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	if resp := a.reactionEvents(admin, "limit=2&offset=2"); resp.Total != 4 || len(resp.Events) != 2 || resp.Events[1].PostID != other {
+		t.Fatalf("second page = %+v", resp)
+	}
+
+	a.expect(http.StatusForbidden, "GET", "/admin/reactions/events", bob, nil)
+	a.expect(http.StatusBadRequest, "GET", "/admin/reactions/events?post_id=x", admin, nil)
+}
+
+func TestReactionEventLogDisabled(t *testing.T) {
+	a := newTestApp(t, nil)
+	adminID, admin := a.register("admin")
+	a.tokens.Admins[adminID] = true
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), alice, map[string]string{"reaction_type": "like"})
+
+	if resp := a.reactionEvents(admin, ""); resp.Enabled || len(resp.Events) != 0 {
+		t.Fatalf("events without the log = %+v", resp)
+	}
+}
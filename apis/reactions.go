@@ -1,8 +1,13 @@
 package apis
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -17,7 +22,6 @@ type ReactionRequest struct {
 // ReactionResponse represents generic response
 type ReactionResponse struct {
 	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
 }
 
 // GetReactionsResponse represents response for GET /posts/{post_id}/reactions
@@ -28,61 +32,152 @@ type GetReactionsResponse struct {
 	Total int                 `json:"total"`
 }
 
+// ReactedUser is a user who reacted to a post
+type ReactedUser struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar,omitempty"`
+}
+
+// ReactionTypeUsersResponse represents response for GET /posts/{post_id}/reactions/{type}
+type ReactionTypeUsersResponse struct {
+	Type  string        `json:"type"`
+	Users []ReactedUser `json:"users"`
+	PageMeta
+}
+
+// ReactionTypes lists the accepted reaction_type values
+var ReactionTypes = []string{"like", "love", "haha", "wow", "sad", "angry"}
+
+// isValidReaction reports whether t is in ReactionTypes
+func isValidReaction(t string) bool {
+	for _, v := range ReactionTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// LikeToggleResponse represents response for POST /posts/{post_id}/like/toggle
+type LikeToggleResponse struct {
+	Liked     bool `json:"liked"`
+	LikeCount int  `json:"like_count"`
+}
+
+// ReactionStatesRequest represents the request body for POST /posts/reaction-states
+type ReactionStatesRequest struct {
+	PostIDs []int `json:"post_ids"`
+}
+
+// UserReaction is one entry of a user's reaction history
+type UserReaction struct {
+	PostID       int    `json:"post_id"`
+	ReactionType string `json:"reaction_type"`
+	ReactedAt    string `json:"reacted_at"` // lần cuối user react hoặc đổi reaction
+}
+
+// UserReactionsResponse represents response for GET /users/{user_id}/reactions
+type UserReactionsResponse struct {
+	Reactions []UserReaction `json:"reactions"`
+	PageMeta
+}
+
+// maxReactionStatesBatch caps the number of post IDs per reaction-states request
+const maxReactionStatesBatch = 100
+
 // ReactionsHandler handles reactions endpoints
 type ReactionsHandler struct {
-	mu        sync.Mutex
-	reactions map[string]map[string]string // post_id -> user_id -> reaction_type
+	mu            sync.Mutex
+	reactions     map[int]map[int]string       // post_id -> user_id -> reaction_type
+	byUser        map[int]map[int]UserReaction // user_id -> post_id -> reaction, index of reactions
+	Tokens        *TokenService
+	Posts         Store                // who to notify, and rejects reactions on missing or deleted posts
+	Notifications *NotificationHandler // optional
+	Blocks        *BlocksHandler       // optional
+	Follows       *FollowsHandler      // post followers-only chỉ follower mới thấy, optional
+	Profiles      *ProfileHandler      // username/avatar cho danh sách user theo loại reaction, optional
+	Feeds         *FeedsHandler        // is_liked trong feed đã cache của người react, optional
+
+	// LogEvents bật event log append-only (GET /admin/reactions/events); reactions vẫn chỉ giữ trạng thái hiện tại
+	LogEvents bool
+	events    []ReactionEvent
 }
 
 // NewReactionsHandler constructor
 func NewReactionsHandler() *ReactionsHandler {
 	return &ReactionsHandler{
-		reactions: make(map[string]map[string]string),
+		reactions: make(map[int]map[int]string),
+		byUser:    make(map[int]map[int]UserReaction),
 	}
 }
 
 // RegisterRoutes register routes with mux
 func (h *ReactionsHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/posts/{post_id}/reactions", h.GetReactions).Methods("GET")
-	router.HandleFunc("/posts/{post_id}/reactions", h.ReactToPost).Methods("POST")
-	router.HandleFunc("/posts/{post_id}/reactions", h.RemoveReaction).Methods("DELETE")
+	router.Handle("/posts/{post_id}/reactions", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetReactions))).Methods("GET")
+	router.Handle("/posts/{post_id}/reactions/{type}", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetReactionTypeUsers))).Methods("GET")
+	router.Handle("/posts/{post_id}/reactions", h.Tokens.RequireAuth(http.HandlerFunc(h.ReactToPost))).Methods("POST")
+	router.Handle("/posts/{post_id}/reactions", h.Tokens.RequireAuth(http.HandlerFunc(h.RemoveReaction))).Methods("DELETE")
+	router.Handle("/posts/{post_id}/like/toggle", h.Tokens.RequireAuth(http.HandlerFunc(h.ToggleLike))).Methods("POST")
+	router.Handle("/users/{user_id}/reactions", h.Tokens.OptionalAuth(http.HandlerFunc(h.GetUserReactions))).Methods("GET")
+	router.Handle("/posts/reaction-states", h.Tokens.RequireAuth(http.HandlerFunc(h.GetReactionStates))).Methods("POST")
+	router.Handle("/admin/reactions/events", h.Tokens.RequireAdmin(http.HandlerFunc(h.ListReactionEvents))).Methods("GET")
 }
 
 // @Summary Get Reactions
-// @Description Get reactions of a post
+// @Description Get reactions of a post; types follow the order like, love, haha, wow, sad, angry and users are sorted by user_id
 // @Tags reactions
 // @Accept json
 // @Produce json
-// @Param post_id path string true "Post ID"
+// @Param post_id path int true "Post ID"
 // @Param Authorization header string false "Bearer token"
 // @Success 200 {object} GetReactionsResponse
-// @Failure 404 {object} ReactionResponse
+// @Failure 404 {object} APIError
+// @Failure 410 {object} APIError
 // @Router /posts/{post_id}/reactions [get]
 func (h *ReactionsHandler) GetReactions(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	postID := vars["post_id"]
+	postID, ok := reactionPostID(w, r)
+	if !ok {
+		return
+	}
+
+	viewerID, _ := UserIDFromContext(r.Context())
+	if status, msg := h.postStatus(r.Context(), viewerID, postID); status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	postReactions, ok := h.reactions[postID]
-	if !ok {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(ReactionResponse{Error: "Post not found"})
+	// có store thì post tồn tại nghĩa là chỉ chưa có reaction nào
+	if !ok && h.Posts == nil {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
 		return
 	}
 
 	count := len(postReactions)
 	typeSet := make(map[string]struct{})
-	users := []map[string]string{}
+	userIDs := make([]int, 0, count)
 	for userID, react := range postReactions {
 		typeSet[react] = struct{}{}
-		users = append(users, map[string]string{"user_id": userID, "username": userID})
+		userIDs = append(userIDs, userID)
 	}
 
+	// map không có thứ tự: types theo thứ tự của ReactionTypes, users theo user_id,
+	// để hai lần gọi trên cùng dữ liệu trả về cùng một body
 	types := []string{}
-	for t := range typeSet {
-		types = append(types, t)
+	for _, t := range ReactionTypes {
+		if _, ok := typeSet[t]; ok {
+			types = append(types, t)
+		}
+	}
+	sort.Ints(userIDs)
+	users := []map[string]string{}
+	for _, userID := range userIDs {
+		uid := strconv.Itoa(userID)
+		users = append(users, map[string]string{"user_id": uid, "username": uid})
 	}
 
 	resp := GetReactionsResponse{
@@ -91,43 +186,130 @@ func (h *ReactionsHandler) GetReactions(w http.ResponseWriter, r *http.Request)
 		Users: users,
 		Total: count,
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// @Summary Get Users By Reaction Type
+// @Description Users who reacted to a post with exactly this type, sorted by user_id
+// @Tags reactions
+// @Produce json
+// @Param post_id path int true "Post ID"
+// @Param type path string true "Reaction type" Enums(like, love, haha, wow, sad, angry)
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Param Authorization header string false "Bearer token"
+// @Success 200 {object} ReactionTypeUsersResponse
+// @Failure 400 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 410 {object} APIError
+// @Router /posts/{post_id}/reactions/{type} [get]
+func (h *ReactionsHandler) GetReactionTypeUsers(w http.ResponseWriter, r *http.Request) {
+	postID, ok := reactionPostID(w, r)
+	if !ok {
+		return
+	}
+	reactionType := strings.ToLower(mux.Vars(r)["type"])
+	if !isValidReaction(reactionType) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid reaction type, valid types: "+strings.Join(ReactionTypes, ", "))
+		return
+	}
+	viewerID, _ := UserIDFromContext(r.Context())
+	if status, msg := h.postStatus(r.Context(), viewerID, postID); status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	postReactions, exists := h.reactions[postID]
+	userIDs := []int{}
+	for userID, react := range postReactions {
+		if react == reactionType {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	h.mu.Unlock()
+
+	// như GetReactions: không có store thì chỉ biết post qua reactions của nó
+	if !exists && h.Posts == nil {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	sort.Ints(userIDs)
+	start, end := pageBounds(len(userIDs), offset, limit)
+	users := make([]ReactedUser, 0, end-start)
+	for _, userID := range userIDs[start:end] {
+		users = append(users, h.reactedUser(userID))
+	}
+	writeJSON(w, http.StatusOK, ReactionTypeUsersResponse{
+		Type:     reactionType,
+		Users:    users,
+		PageMeta: newPageMeta(len(userIDs), start, end, limit),
+	})
+}
+
+// reactedUser fills username and avatar from the profile, falling back to the ID like GetReactions
+func (h *ReactionsHandler) reactedUser(userID int) ReactedUser {
+	if h.Profiles != nil {
+		if p, ok := h.Profiles.profile(userID); ok {
+			return ReactedUser{UserID: userID, Username: p.Username, Avatar: p.Avatar}
+		}
+	}
+	return ReactedUser{UserID: userID, Username: strconv.Itoa(userID)}
 }
 
 // @Summary React to Post
-// @Description Add reaction to a post
+// @Description Add reaction to a post (like, love, haha, wow, sad, angry); reacting again replaces the previous type
 // @Tags reactions
 // @Accept json
 // @Produce json
-// @Param post_id path string true "Post ID"
-// @Param Authorization header string true "Bearer token"
+// @Param post_id path int true "Post ID"
+// @Security BearerAuth
 // @Param body body ReactionRequest true "Reaction body"
 // @Success 201 {object} ReactionResponse
-// @Failure 400 {object} ReactionResponse
+// @Failure 400 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 410 {object} APIError
 // @Router /posts/{post_id}/reactions [post]
 func (h *ReactionsHandler) ReactToPost(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	postID := vars["post_id"]
+	postID, ok := reactionPostID(w, r)
+	if !ok {
+		return
+	}
 
 	var req ReactionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.ReactionType) == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ReactionResponse{Error: "Invalid reaction type"})
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	req.ReactionType = strings.ToLower(strings.TrimSpace(req.ReactionType))
+	if req.ReactionType == "" {
+		writeJSONError(w, http.StatusBadRequest, "reaction_type must not be empty or whitespace only")
+		return
+	}
+	if !isValidReaction(req.ReactionType) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid reaction type, valid types: "+strings.Join(ReactionTypes, ", "))
 		return
 	}
 
-	userID := "user1" // giả lập user
+	currentUserID, _ := UserIDFromContext(r.Context())
+	if status, msg := h.postStatus(r.Context(), currentUserID, postID); status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if _, ok := h.reactions[postID]; !ok {
-		h.reactions[postID] = make(map[string]string)
+	// đổi loại reaction không báo lại cho tác giả; notify tự bỏ qua khi tự react bài của mình
+	if h.setReaction(postID, currentUserID, req.ReactionType) {
+		h.Notifications.notify(postAuthor(r.Context(), h.Posts, postID), currentUserID, NotifReaction, postID)
 	}
-	h.reactions[postID][userID] = req.ReactionType
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(ReactionResponse{Message: "Reaction added"})
+	writeJSON(w, http.StatusCreated, ReactionResponse{Message: "Reaction added"})
 }
 
 // @Summary Remove Reaction
@@ -135,31 +317,295 @@ func (h *ReactionsHandler) ReactToPost(w http.ResponseWriter, r *http.Request) {
 // @Tags reactions
 // @Accept json
 // @Produce json
-// @Param post_id path string true "Post ID"
-// @Param Authorization header string true "Bearer token"
+// @Param post_id path int true "Post ID"
+// @Security BearerAuth
 // @Param body body ReactionRequest false "Reaction body (optional if only 1 type)"
-// @Success 200 {object} ReactionResponse
-// @Failure 404 {object} ReactionResponse
+// @Param strict query bool false "Return 404 instead of a no-op when there is no reaction"
+// @Success 204 "No Content"
+// @Failure 404 {object} APIError
 // @Router /posts/{post_id}/reactions [delete]
 func (h *ReactionsHandler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	postID := vars["post_id"]
+	postID, ok := reactionPostID(w, r)
+	if !ok {
+		return
+	}
 
 	var req ReactionRequest
-	_ = json.NewDecoder(r.Body).Decode(&req)
+	// body không bắt buộc
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil && !errors.Is(err, io.EOF) {
+		writeDecodeError(w, err)
+		return
+	}
 
-	userID := "user1" // giả lập user
+	currentUserID, _ := UserIDFromContext(r.Context())
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	postReactions, ok := h.reactions[postID]
-	if !ok || postReactions[userID] == "" {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(ReactionResponse{Error: "Reaction not found"})
+	removed := h.reactions[postID][currentUserID]
+	if removed == "" {
+		if r.URL.Query().Get("strict") == "true" {
+			writeJSONError(w, http.StatusNotFound, "Reaction not found")
+			return
+		}
+		// bấm bỏ reaction hai lần vẫn coi là thành công
+		writeNoContent(w)
+		return
+	}
+
+	h.removeReaction(postID, currentUserID)
+	writeNoContent(w)
+}
+
+// @Summary Toggle Like
+// @Description Like the post, or unlike it if the current user already liked it; any other reaction is replaced by like
+// @Tags reactions
+// @Produce json
+// @Param post_id path int true "Post ID"
+// @Security BearerAuth
+// @Success 200 {object} LikeToggleResponse
+// @Failure 401 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 410 {object} APIError
+// @Router /posts/{post_id}/like/toggle [post]
+func (h *ReactionsHandler) ToggleLike(w http.ResponseWriter, r *http.Request) {
+	postID, ok := reactionPostID(w, r)
+	if !ok {
 		return
 	}
 
-	delete(postReactions, userID)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(ReactionResponse{Message: "Reaction removed"})
+	currentUserID, _ := UserIDFromContext(r.Context())
+	if status, msg := h.postStatus(r.Context(), currentUserID, postID); status != 0 {
+		writeJSONError(w, status, msg)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	liked := h.reactions[postID][currentUserID] != "like"
+	if liked {
+		if h.setReaction(postID, currentUserID, "like") {
+			h.Notifications.notify(postAuthor(r.Context(), h.Posts, postID), currentUserID, NotifReaction, postID)
+		}
+	} else {
+		h.removeReaction(postID, currentUserID)
+	}
+
+	likeCount := 0
+	for _, react := range h.reactions[postID] {
+		if react == "like" {
+			likeCount++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, LikeToggleResponse{Liked: liked, LikeCount: likeCount})
+}
+
+// @Summary Get Reaction States
+// @Description Get the current user's reaction for each post in a batch ("" when not reacted, or the post is deleted or hidden from the user)
+// @Tags reactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body ReactionStatesRequest true "Post IDs"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} APIError
+// @Router /posts/reaction-states [post]
+func (h *ReactionsHandler) GetReactionStates(w http.ResponseWriter, r *http.Request) {
+	var req ReactionStatesRequest
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(req.PostIDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "post_ids is required")
+		return
+	}
+	if len(req.PostIDs) > maxReactionStatesBatch {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d post_ids per request", maxReactionStatesBatch))
+		return
+	}
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	// tra store trước khi giữ h.mu
+	live := make(map[int]bool, len(req.PostIDs))
+	for _, id := range req.PostIDs {
+		live[id] = !h.postHidden(r.Context(), currentUserID, id)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	states := make(map[string]string, len(req.PostIDs))
+	for _, id := range req.PostIDs {
+		if !live[id] {
+			states[strconv.Itoa(id)] = ""
+			continue
+		}
+		states[strconv.Itoa(id)] = h.reactions[id][currentUserID]
+	}
+
+	writeJSON(w, http.StatusOK, states)
+}
+
+// @Summary Get User Reactions
+// @Description List the posts a user has reacted to and with which reaction, newest post first;
+// @Description deleted posts and posts the requester may not see are left out
+// @Tags reactions
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Param Authorization header string false "Bearer token"
+// @Success 200 {object} UserReactionsResponse
+// @Failure 400 {object} APIError
+// @Router /users/{user_id}/reactions [get]
+func (h *ReactionsHandler) GetUserReactions(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	history := make([]UserReaction, 0, len(h.byUser[userID]))
+	for _, ur := range h.byUser[userID] {
+		history = append(history, ur)
+	}
+	h.mu.Unlock()
+
+	viewerID, _ := UserIDFromContext(r.Context())
+	kept := history[:0]
+	for _, ur := range history {
+		if !h.postHidden(r.Context(), viewerID, ur.PostID) {
+			kept = append(kept, ur)
+		}
+	}
+	history = kept
+
+	sort.Slice(history, func(i, j int) bool { return history[i].PostID > history[j].PostID })
+	start, end := pageBounds(len(history), offset, limit)
+
+	writeJSON(w, http.StatusOK, UserReactionsResponse{
+		Reactions: history[start:end],
+		PageMeta:  newPageMeta(len(history), start, end, limit),
+	})
+}
+
+// postStatus checks postID in the posts store: 0 when viewerID can see and react to it, otherwise
+// the status and message to reply with (404 missing, hidden from viewerID or blocked, 410 soft-deleted).
+// Without a store every ID passes
+func (h *ReactionsHandler) postStatus(ctx context.Context, viewerID, postID int) (int, string) {
+	if h.Posts == nil {
+		return 0, ""
+	}
+	post, err := h.Posts.GetPost(ctx, postID)
+	if errors.Is(err, ErrPostNotFound) {
+		return http.StatusNotFound, "Post not found"
+	}
+	if err != nil {
+		return http.StatusInternalServerError, "Cannot load post"
+	}
+	// post không được xem thì coi như không tồn tại, kể cả khi đã xoá
+	if !canSeePost(h.Follows, viewerID, post) || h.Blocks.isBlocked(viewerID, post.UserID) {
+		return http.StatusNotFound, "Post not found"
+	}
+	if post.IsDeleted {
+		return http.StatusGone, "Post has been deleted"
+	}
+	return 0, ""
+}
+
+// postHidden reports whether postID is missing, soft-deleted or not visible to viewerID;
+// store errors count as visible
+func (h *ReactionsHandler) postHidden(ctx context.Context, viewerID, postID int) bool {
+	status, _ := h.postStatus(ctx, viewerID, postID)
+	return status == http.StatusNotFound || status == http.StatusGone
+}
+
+// setReaction records a reaction in both maps and reports whether the user had no
+// reaction on the post before; the caller must hold h.mu
+func (h *ReactionsHandler) setReaction(postID, userID int, reactionType string) (isNew bool) {
+	if h.reactions == nil {
+		h.reactions = make(map[int]map[int]string)
+	}
+	if _, ok := h.reactions[postID]; !ok {
+		h.reactions[postID] = make(map[int]string)
+	}
+	previous, existed := h.reactions[postID][userID]
+	h.reactions[postID][userID] = reactionType
+	switch {
+	case !existed:
+		h.logEvent(postID, userID, reactionType, ReactionAdded)
+	case previous != reactionType:
+		h.logEvent(postID, userID, reactionType, ReactionChanged)
+	}
+
+	if h.byUser == nil {
+		h.byUser = make(map[int]map[int]UserReaction)
+	}
+	if _, ok := h.byUser[userID]; !ok {
+		h.byUser[userID] = make(map[int]UserReaction)
+	}
+	h.byUser[userID][postID] = UserReaction{PostID: postID, ReactionType: reactionType, ReactedAt: nowRFC3339()}
+	h.Feeds.invalidateFeed(userID)
+	return !existed
+}
+
+// removeReaction drops a reaction from both maps; the caller must hold h.mu
+func (h *ReactionsHandler) removeReaction(postID, userID int) {
+	if removed, ok := h.reactions[postID][userID]; ok {
+		h.logEvent(postID, userID, removed, ReactionRemoved)
+	}
+	delete(h.reactions[postID], userID)
+	delete(h.byUser[userID], postID)
+	if len(h.byUser[userID]) == 0 {
+		delete(h.byUser, userID)
+	}
+	h.Feeds.invalidateFeed(userID)
+}
+
+// reactionPostID parses the post_id path variable so "7" and "007" share one bucket;
+// writes 400 and returns false when it is not a positive integer
+func reactionPostID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	postID, err := strconv.Atoi(mux.Vars(r)["post_id"])
+	if err != nil || postID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "Invalid post ID")
+		return 0, false
+	}
+	return postID, true
+}
+
+// activityOf lists the reactions userID gave, for GET /users/{user_id}/activity; a nil handler has none
+func (h *ReactionsHandler) activityOf(userID int) []ActivityItem {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	items := make([]ActivityItem, 0, len(h.byUser[userID]))
+	for _, ur := range h.byUser[userID] {
+		items = append(items, ActivityItem{Type: ActivityReaction, CreatedAt: ur.ReactedAt, PostID: ur.PostID, ReactionType: ur.ReactionType})
+	}
+	return items
+}
+
+// reactionsFor returns a copy of user_id -> reaction_type for a post
+func (h *ReactionsHandler) reactionsFor(postID int) map[int]string {
+	if h == nil {
+		return map[int]string{}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := make(map[int]string, len(h.reactions[postID]))
+	for userID, react := range h.reactions[postID] {
+		result[userID] = react
+	}
+	return result
 }
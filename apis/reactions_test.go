@@ -0,0 +1,317 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReactionsHiddenPost(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	private := a.createPost(alice, map[string]any{"content": "secret", "visibility": "private"}, "")
+	followers := a.createPost(alice, map[string]any{"content": "friends", "visibility": "followers"}, "")
+
+	for _, postID := range []int{private, followers} {
+		a.expect(http.StatusNotFound, "GET", postPath(postID, "/reactions"), "", nil)
+		a.expect(http.StatusNotFound, "GET", postPath(postID, "/reactions/like"), bob, nil)
+		a.expect(http.StatusNotFound, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+		a.expect(http.StatusNotFound, "POST", postPath(postID, "/like/toggle"), bob, nil)
+		a.expect(http.StatusOK, "GET", postPath(postID, "/reactions"), alice, nil)
+	}
+
+	// follower thấy post followers-only, vẫn không thấy post private
+	a.expect(http.StatusCreated, "POST", "/users/1/follow", bob, nil)
+	a.expect(http.StatusOK, "POST", postPath(followers, "/like/toggle"), bob, nil)
+	a.expect(http.StatusNotFound, "POST", postPath(private, "/like/toggle"), bob, nil)
+
+	var states map[string]string
+	decodeBody(t, a.expect(http.StatusOK, "POST", "/posts/reaction-states", bob, map[string]any{"post_ids": []int{private, followers}}), &states)
+	if states["2"] != "like" || states["1"] != "" {
+		t.Fatalf("states = %v", states)
+	}
+
+	// người lạ không thấy reaction của bob trên post followers-only
+	var history UserReactionsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/users/2/reactions", "", nil), &history)
+	if history.Total != 0 {
+		t.Fatalf("anonymous sees %d reactions of user %d", history.Total, bobID)
+	}
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/users/2/reactions", bob, nil), &history)
+	if history.Total != 1 {
+		t.Fatalf("bob sees %d of his reactions, want 1", history.Total)
+	}
+}
+
+func TestReactionsBlockedUser(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+
+	a.expect(http.StatusCreated, "POST", "/users/2/block", alice, nil)
+	a.expect(http.StatusNotFound, "GET", postPath(postID, "/reactions"), bob, nil)
+	a.expect(http.StatusNotFound, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "love"})
+	a.expect(http.StatusOK, "GET", postPath(postID, "/reactions"), "", nil)
+}
+
+func TestReactionStates(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	liked := a.createPost(alice, map[string]any{"content": "one"}, "")
+	loved := a.createPost(alice, map[string]any{"content": "two"}, "")
+	untouched := a.createPost(alice, map[string]any{"content": "three"}, "")
+	a.expect(http.StatusCreated, "POST", postPath(liked, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+	a.expect(http.StatusCreated, "POST", postPath(loved, "/reactions"), bob, map[string]string{"reaction_type": "love"})
+
+	var states map[string]string
+	decodeBody(t, a.expect(http.StatusOK, "POST", "/posts/reaction-states", bob, map[string]any{"post_ids": []int{liked, loved, untouched}}), &states)
+	want := map[string]string{"1": "like", "2": "love", "3": ""}
+	if !reflect.DeepEqual(states, want) {
+		t.Fatalf("states = %v, want %v", states, want)
+	}
+	// alice chưa react bài nào
+	decodeBody(t, a.expect(http.StatusOK, "POST", "/posts/reaction-states", alice, map[string]any{"post_ids": []int{liked}}), &states)
+	if states["1"] != "" {
+		t.Fatalf("alice's states = %v", states)
+	}
+
+	a.expect(http.StatusBadRequest, "POST", "/posts/reaction-states", bob, map[string]any{"post_ids": []int{}})
+	a.expect(http.StatusBadRequest, "POST", "/posts/reaction-states", bob, map[string]any{"post_ids": make([]int, maxReactionStatesBatch+1)})
+}
+
+func TestReactionTypes(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+
+	var apiErr APIError
+	decodeBody(t, a.expect(http.StatusBadRequest, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "meh"}), &apiErr)
+	if !strings.Contains(apiErr.Error, strings.Join(ReactionTypes, ", ")) {
+		t.Fatalf("error %q does not list the valid types", apiErr.Error)
+	}
+
+	// react lại thì thay reaction cũ của cùng user
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "LOVE"})
+	var resp GetReactionsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/reactions"), "", nil), &resp)
+	if resp.Count != 1 || !reflect.DeepEqual(resp.Types, []string{"love"}) {
+		t.Fatalf("reactions = %+v", resp)
+	}
+}
+
+func TestRemoveReaction(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "love"})
+	a.expect(http.StatusNoContent, "DELETE", postPath(postID, "/reactions"), bob, nil)
+	var reactions GetReactionsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/reactions"), "", nil), &reactions)
+	if reactions.Count != 0 {
+		t.Fatalf("reactions after remove = %+v", reactions)
+	}
+
+	// bỏ lần hai là no-op, chỉ strict=true mới trả 404
+	a.expect(http.StatusNoContent, "DELETE", postPath(postID, "/reactions"), bob, nil)
+	a.expect(http.StatusNotFound, "DELETE", postPath(postID, "/reactions?strict=true"), bob, nil)
+}
+
+func TestToggleLike(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	a.expect(http.StatusOK, "POST", postPath(postID, "/like/toggle"), alice, nil)
+
+	for _, want := range []LikeToggleResponse{
+		{Liked: true, LikeCount: 2},
+		{Liked: false, LikeCount: 1},
+		{Liked: true, LikeCount: 2},
+	} {
+		var got LikeToggleResponse
+		decodeBody(t, a.expect(http.StatusOK, "POST", postPath(postID, "/like/toggle"), bob, nil), &got)
+		if got != want {
+			t.Fatalf("toggle = %+v, want %+v", got, want)
+		}
+	}
+
+	// reaction khác được thay bằng like
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), alice, map[string]string{"reaction_type": "love"})
+	var got LikeToggleResponse
+	decodeBody(t, a.expect(http.StatusOK, "POST", postPath(postID, "/like/toggle"), alice, nil), &got)
+	if got != (LikeToggleResponse{Liked: true, LikeCount: 2}) {
+		t.Fatalf("toggle over love = %+v", got)
+	}
+	a.expect(http.StatusUnauthorized, "POST", postPath(postID, "/like/toggle"), "", nil)
+}
+
+func TestUserReactionHistory(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	var posts []int
+	for _, typ := range []string{"like", "love", "haha"} {
+		postID := a.createPost(alice, map[string]any{"content": typ}, "")
+		a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": typ})
+		posts = append(posts, postID)
+	}
+	a.createPost(alice, map[string]any{"content": "not reacted"}, "")
+
+	path := fmt.Sprintf("/users/%d/reactions", bobID)
+	var history UserReactionsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", path, "", nil), &history)
+	want := []UserReaction{
+		{PostID: posts[2], ReactionType: "haha"},
+		{PostID: posts[1], ReactionType: "love"},
+		{PostID: posts[0], ReactionType: "like"},
+	}
+	if history.Total != 3 || len(history.Reactions) != 3 {
+		t.Fatalf("history = %+v", history)
+	}
+	for i, r := range history.Reactions {
+		if r.PostID != want[i].PostID || r.ReactionType != want[i].ReactionType || r.ReactedAt == "" {
+			t.Fatalf("reaction %d = %+v, want %+v", i, r, want[i])
+		}
+	}
+
+	decodeBody(t, a.expect(http.StatusOK, "GET", path+"?offset=1&limit=1", "", nil), &history)
+	if len(history.Reactions) != 1 || history.Reactions[0].PostID != posts[1] || !history.HasMore {
+		t.Fatalf("second page = %+v", history)
+	}
+}
+
+func TestReactionsOfDeletedPost(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	other := a.createPost(alice, map[string]any{"content": "still here"}, "")
+	like := map[string]string{"reaction_type": "like"}
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, like)
+	a.expect(http.StatusCreated, "POST", postPath(other, "/reactions"), bob, like)
+
+	a.expect(http.StatusNoContent, "DELETE", postPath(postID, ""), alice, nil)
+	a.expect(http.StatusGone, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "love"})
+	a.expect(http.StatusGone, "POST", postPath(postID, "/like/toggle"), bob, nil)
+	a.expect(http.StatusGone, "GET", postPath(postID, "/reactions"), "", nil)
+	a.expect(http.StatusNotFound, "POST", postPath(99, "/reactions"), bob, like)
+
+	// reaction trên post đã xoá không còn xuất hiện ở các tổng hợp
+	var states map[string]string
+	decodeBody(t, a.expect(http.StatusOK, "POST", "/posts/reaction-states", bob, map[string]any{"post_ids": []int{postID, other}}), &states)
+	if want := map[string]string{fmt.Sprint(postID): "", fmt.Sprint(other): "like"}; !reflect.DeepEqual(states, want) {
+		t.Fatalf("states = %v, want %v", states, want)
+	}
+	var history UserReactionsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/users/2/reactions", bob, nil), &history)
+	if history.Total != 1 || history.Reactions[0].PostID != other {
+		t.Fatalf("history = %+v", history)
+	}
+}
+
+func TestReactionPostIDNormalized(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	for range 6 {
+		a.createPost(alice, map[string]any{"content": "filler"}, "")
+	}
+	postID := a.createPost(alice, map[string]any{"content": "seven"}, "")
+	if postID != 7 {
+		t.Fatalf("post ID %d, want 7", postID)
+	}
+
+	a.expect(http.StatusCreated, "POST", "/posts/007/reactions", bob, map[string]string{"reaction_type": "love"})
+	a.expect(http.StatusCreated, "POST", "/posts/7/reactions", alice, map[string]string{"reaction_type": "like"})
+	var resp GetReactionsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/posts/7/reactions", "", nil), &resp)
+	if resp.Count != 2 {
+		t.Fatalf("reactions of post 7 = %+v", resp)
+	}
+	// bob đổi reaction qua "7": cùng bucket với "007" nên vẫn chỉ một reaction của bob
+	a.expect(http.StatusCreated, "POST", "/posts/7/reactions", bob, map[string]string{"reaction_type": "like"})
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/posts/0007/reactions", "", nil), &resp)
+	if resp.Count != 2 || !reflect.DeepEqual(resp.Types, []string{"like"}) {
+		t.Fatalf("reactions after change = %+v", resp)
+	}
+
+	for _, id := range []string{"abc", "7x", "-7", "0"} {
+		a.expect(http.StatusBadRequest, "POST", "/posts/"+id+"/reactions", bob, map[string]string{"reaction_type": "like"})
+		a.expect(http.StatusBadRequest, "GET", "/posts/"+id+"/reactions", "", nil)
+	}
+}
+
+func TestGetReactionsStableOrder(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	// đủ nhiều user và loại để thứ tự duyệt map gần như chắc chắn khác nhau giữa các lần
+	types := []string{"angry", "sad", "wow", "haha", "love", "like"}
+	for i := range 12 {
+		_, token := a.register(fmt.Sprintf("user%d", i))
+		a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), token, map[string]string{"reaction_type": types[i%len(types)]})
+	}
+
+	first := a.expect(http.StatusOK, "GET", postPath(postID, "/reactions"), "", nil).Body.String()
+	for range 10 {
+		if again := a.expect(http.StatusOK, "GET", postPath(postID, "/reactions"), "", nil).Body.String(); again != first {
+			t.Fatalf("body changed between calls:\n%s\n%s", first, again)
+		}
+	}
+
+	var resp GetReactionsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/reactions"), "", nil), &resp)
+	if !reflect.DeepEqual(resp.Types, ReactionTypes) {
+		t.Fatalf("types = %v, want %v", resp.Types, ReactionTypes)
+	}
+	ids := make([]int, 0, len(resp.Users))
+	for _, u := range resp.Users {
+		id, _ := strconv.Atoi(u["user_id"])
+		ids = append(ids, id)
+	}
+	if len(ids) != 12 || !slices.IsSorted(ids) {
+		t.Fatalf("users not sorted by user_id: %v", resp.Users)
+	}
+}
+
+func TestReactionTypeUsers(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	carolID, carol := a.register("carol")
+	_, dave := a.register("dave")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	for token, reaction := range map[string]string{carol: "love", bob: "love", dave: "like"} {
+		a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), token, map[string]string{"reaction_type": reaction})
+	}
+
+	var resp ReactionTypeUsersResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/reactions/LOVE"), "", nil), &resp)
+	want := []ReactedUser{{UserID: bobID, Username: "bob"}, {UserID: carolID, Username: "carol"}}
+	if resp.Type != "love" || resp.Total != 2 || !reflect.DeepEqual(resp.Users, want) {
+		t.Fatalf("love = %+v", resp)
+	}
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/reactions/love?limit=1&offset=1"), "", nil), &resp)
+	if !reflect.DeepEqual(resp.Users, want[1:]) || resp.HasMore {
+		t.Fatalf("love page 2 = %+v", resp)
+	}
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/reactions/sad"), "", nil), &resp)
+	if resp.Total != 0 || len(resp.Users) != 0 {
+		t.Fatalf("sad = %+v", resp)
+	}
+
+	a.expect(http.StatusBadRequest, "GET", postPath(postID, "/reactions/meh"), "", nil)
+	a.expect(http.StatusNotFound, "GET", postPath(999, "/reactions/like"), "", nil)
+}
@@ -0,0 +1,254 @@
+package apis
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// Loại nội dung có thể bị report
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+)
+
+// maxReportReasonLength giới hạn độ dài (ký tự) của lý do report
+const maxReportReasonLength = 500
+
+// ReportRequest represents request body for reporting a post or comment
+type ReportRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReportResponse represents response for report endpoints
+type ReportResponse struct {
+	Message string `json:"message,omitempty"`
+}
+
+// Report is one user's report of a piece of content
+type Report struct {
+	ReporterID int    `json:"reporter_id"`
+	Reason     string `json:"reason"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// ReportedContent groups the reports of one post or comment
+type ReportedContent struct {
+	TargetType string   `json:"target_type"` // post or comment
+	TargetID   int      `json:"target_id"`
+	Count      int      `json:"count"`
+	Reports    []Report `json:"reports"` // oldest first
+}
+
+// ReportsResponse represents response for GET /admin/reports
+type ReportsResponse struct {
+	Reports []ReportedContent `json:"reports"`
+	PageMeta
+}
+
+// reportTarget identifies reported content
+type reportTarget struct {
+	Type string
+	ID   int
+}
+
+// ReportsHandler handles content reports
+type ReportsHandler struct {
+	mu      sync.Mutex
+	reports map[reportTarget][]Report // mỗi reporter tối đa một report cho mỗi target
+	Tokens  *TokenService
+
+	Posts    Store            // kiểm tra post tồn tại và tác giả
+	Comments *CommentsHandler // như Posts, cho comments
+	Follows  *FollowsHandler  // post followers-only chỉ follower mới report được, optional
+}
+
+// NewReportsHandler constructor
+func NewReportsHandler() *ReportsHandler {
+	return &ReportsHandler{
+		reports: make(map[reportTarget][]Report),
+	}
+}
+
+// RegisterRoutes register report routes
+func (h *ReportsHandler) RegisterRoutes(router *mux.Router) {
+	router.Handle("/posts/{post_id}/report", h.Tokens.RequireAuth(http.HandlerFunc(h.ReportPost))).Methods("POST")
+	router.Handle("/comments/{comment_id}/report", h.Tokens.RequireAuth(http.HandlerFunc(h.ReportComment))).Methods("POST")
+	router.Handle("/admin/reports", h.Tokens.RequireAdmin(http.HandlerFunc(h.ListReports))).Methods("GET")
+}
+
+// @Summary Report Post
+// @Description Flag a post for moderators; reporting the same post again does nothing
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Param post_id path int true "Post ID"
+// @Param body body ReportRequest true "Reason (at most 500 characters)"
+// @Security BearerAuth
+// @Success 201 {object} ReportResponse
+// @Success 200 {object} ReportResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /posts/{post_id}/report [post]
+func (h *ReportsHandler) ReportPost(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Posts) {
+		return
+	}
+
+	postID, _ := strconv.Atoi(mux.Vars(r)["post_id"])
+	reason, ok := readReportReason(w, r)
+	if !ok {
+		return
+	}
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+	post, err := h.Posts.GetPost(r.Context(), postID)
+	// draft và post không được xem thì coi như không tồn tại
+	if errors.Is(err, ErrPostNotFound) || (err == nil && (post.IsDeleted || !canSeePost(h.Follows, currentUserID, post))) {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return
+	}
+
+	if post.UserID == currentUserID {
+		writeJSONError(w, http.StatusBadRequest, "Cannot report your own post")
+		return
+	}
+	h.addReport(w, reportTarget{ReportTargetPost, postID}, currentUserID, reason)
+}
+
+// @Summary Report Comment
+// @Description Flag a comment for moderators; reporting the same comment again does nothing
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Param comment_id path int true "Comment ID"
+// @Param body body ReportRequest true "Reason (at most 500 characters)"
+// @Security BearerAuth
+// @Success 201 {object} ReportResponse
+// @Success 200 {object} ReportResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /comments/{comment_id}/report [post]
+func (h *ReportsHandler) ReportComment(w http.ResponseWriter, r *http.Request) {
+	commentID, _ := strconv.Atoi(mux.Vars(r)["comment_id"])
+	reason, ok := readReportReason(w, r)
+	if !ok {
+		return
+	}
+
+	authorID, exists := h.Comments.commentAuthor(commentID)
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	currentUserID, _ := UserIDFromContext(r.Context())
+	if authorID == currentUserID {
+		writeJSONError(w, http.StatusBadRequest, "Cannot report your own comment")
+		return
+	}
+	h.addReport(w, reportTarget{ReportTargetComment, commentID}, currentUserID, reason)
+}
+
+// readReportReason decodes and validates the report body; writes the error response when it returns false
+func readReportReason(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req ReportRequest
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return "", false
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		writeJSONError(w, http.StatusBadRequest, "Reason is required")
+		return "", false
+	}
+	if utf8.RuneCountInString(reason) > maxReportReasonLength {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Reason exceeds %d characters", maxReportReasonLength))
+		return "", false
+	}
+	return contentPolicy.Sanitize(reason), true
+}
+
+// addReport stores the report unless reporterID already reported target
+func (h *ReportsHandler) addReport(w http.ResponseWriter, target reportTarget, reporterID int, reason string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, rep := range h.reports[target] {
+		if rep.ReporterID == reporterID {
+			writeJSON(w, http.StatusOK, ReportResponse{Message: "Already reported"})
+			return
+		}
+	}
+	if h.reports == nil {
+		h.reports = make(map[reportTarget][]Report)
+	}
+	h.reports[target] = append(h.reports[target], Report{
+		ReporterID: reporterID,
+		Reason:     reason,
+		CreatedAt:  nowRFC3339(),
+	})
+	writeJSON(w, http.StatusCreated, ReportResponse{Message: "Reported"})
+}
+
+// @Summary List Reports
+// @Description Reported posts and comments, most reported first; admin only
+// @Tags reports
+// @Produce json
+// @Security BearerAuth
+// @Param offset query int false "Offset"
+// @Param limit query int false "Limit (default 20, max 100)"
+// @Success 200 {object} ReportsResponse
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Router /admin/reports [get]
+func (h *ReportsHandler) ListReports(w http.ResponseWriter, r *http.Request) {
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	reported := make([]ReportedContent, 0, len(h.reports))
+	for target, reports := range h.reports {
+		reported = append(reported, ReportedContent{
+			TargetType: target.Type,
+			TargetID:   target.ID,
+			Count:      len(reports),
+			Reports:    append([]Report{}, reports...),
+		})
+	}
+	h.mu.Unlock()
+
+	// cùng số report thì sắp theo loại rồi ID cho thứ tự ổn định
+	sort.Slice(reported, func(i, j int) bool {
+		a, b := reported[i], reported[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.TargetType != b.TargetType {
+			return a.TargetType < b.TargetType
+		}
+		return a.TargetID < b.TargetID
+	})
+
+	start, end := pageBounds(len(reported), offset, limit)
+	writeJSON(w, http.StatusOK, ReportsResponse{
+		Reports:  reported[start:end],
+		PageMeta: newPageMeta(len(reported), start, end, limit),
+	})
+}
@@ -0,0 +1,49 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestReportContent(t *testing.T) {
+	a := newTestApp(t, nil)
+	adminID, admin := a.register("admin")
+	a.tokens.Admins[adminID] = true
+	_, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	_, carol := a.register("carol")
+	quiet := a.createPost(alice, map[string]any{"content": "fine"}, "")
+	loud := a.createPost(alice, map[string]any{"content": "spam"}, "")
+	commentID := a.comment(alice, quiet, 0, "rude")
+	commentPath := fmt.Sprintf("/comments/%d/report", commentID)
+	reason := ReportRequest{Reason: "abuse"}
+
+	a.expect(http.StatusCreated, "POST", postPath(loud, "/report"), bob, reason)
+	a.expect(http.StatusCreated, "POST", postPath(loud, "/report"), carol, reason)
+	a.expect(http.StatusCreated, "POST", commentPath, bob, reason)
+	// lần report thứ hai của cùng user không được tính
+	a.expect(http.StatusOK, "POST", commentPath, bob, ReportRequest{Reason: "again"})
+
+	a.expect(http.StatusBadRequest, "POST", postPath(quiet, "/report"), alice, reason)
+	a.expect(http.StatusBadRequest, "POST", commentPath, alice, reason)
+	a.expect(http.StatusBadRequest, "POST", postPath(quiet, "/report"), bob, ReportRequest{Reason: "  "})
+	a.expect(http.StatusNotFound, "POST", postPath(999, "/report"), bob, reason)
+	a.expect(http.StatusNotFound, "POST", "/comments/999/report", bob, reason)
+
+	a.expect(http.StatusForbidden, "GET", "/admin/reports", bob, nil)
+	var resp ReportsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/admin/reports", admin, nil), &resp)
+	var got []string
+	for _, rc := range resp.Reports {
+		got = append(got, fmt.Sprintf("%s:%d:%d", rc.TargetType, rc.TargetID, rc.Count))
+	}
+	want := []string{fmt.Sprintf("post:%d:2", loud), fmt.Sprintf("comment:%d:1", commentID)}
+	if !slices.Equal(got, want) {
+		t.Fatalf("reports = %v, want %v", got, want)
+	}
+	if r := resp.Reports[1].Reports[0]; r.ReporterID != bobID || r.Reason != "abuse" {
+		t.Fatalf("comment report = %+v", r)
+	}
+}
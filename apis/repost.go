@@ -0,0 +1,106 @@
+package apis
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// RepostRequest is the body of POST /posts/{post_id}/repost; content is optional commentary
+type RepostRequest struct {
+	Content    string `json:"content"`
+	Visibility string `json:"visibility"` // mặc định public
+}
+
+// RepostPost godoc
+// @Summary Repost a post
+// @Description Create a new post that references post_id, with optional commentary.
+// @Description Only posts the requester can see can be reposted; nothing of the original is copied
+// @Tags posts
+// @Accept json
+// @Produce json
+// @Param post_id path int true "Post ID"
+// @Security BearerAuth
+// @Param body body RepostRequest false "Commentary and visibility"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 404 {object} APIError
+// @Failure 422 {object} APIError
+// @Router /posts/{post_id}/repost [post]
+func (h *PostsHandler) RepostPost(w http.ResponseWriter, r *http.Request) {
+	if !requireStore(w, h.Store) {
+		return
+	}
+
+	originalID, _ := strconv.Atoi(mux.Vars(r)["post_id"])
+	currentUserID, _ := UserIDFromContext(r.Context())
+
+	var req RepostRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+	}
+
+	// post đã xoá hoặc không được xem thì coi như không tồn tại
+	original, err := h.Store.GetPost(r.Context(), originalID)
+	if err != nil && !errors.Is(err, ErrPostNotFound) {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot load post")
+		return
+	}
+	if err != nil || original.IsDeleted || !h.canSee(currentUserID, original) {
+		writeJSONError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	if original.Status == PostDraft {
+		writeJSONError(w, http.StatusBadRequest, "Cannot repost a draft")
+		return
+	}
+
+	repost := Post{
+		UserID:     currentUserID,
+		RepostOfID: original.PostID,
+		Visibility: req.Visibility,
+		Status:     PostPublished,
+	}
+	if repost.Visibility == "" {
+		repost.Visibility = VisibilityPublic
+	}
+	if !validVisibility(repost.Visibility) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid visibility, use public, followers or private")
+		return
+	}
+	if req.Content != "" {
+		content, reason := cleanContent(req.Content, maxPostLength)
+		if reason != "" {
+			writeJSONError(w, http.StatusBadRequest, reason)
+			return
+		}
+		if ok, reason := moderate(r.Context(), h.Moderator, content); !ok {
+			writeJSONError(w, http.StatusUnprocessableEntity, reason)
+			return
+		}
+		repost.Content = content
+		repost.Tags = extractHashtags(content)
+	}
+
+	repost.CreatedAt = nowRFC3339()
+	newID, err := h.Store.CreatePost(r.Context(), repost)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save post")
+		return
+	}
+	repost.PostID = newID
+	h.Feeds.invalidateAuthor(currentUserID)
+	h.Webhooks.publish(EventPostCreated, repost)
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"post_id":      newID,
+		"repost_of_id": original.PostID,
+		"message":      "Post reposted",
+	})
+}
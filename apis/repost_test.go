@@ -0,0 +1,70 @@
+package apis
+
+import (
+	"net/http"
+	"testing"
+)
+
+// repost gọi POST /posts/{post_id}/repost và trả về ID của repost
+func (a *testApp) repost(token string, postID int, body any) int {
+	a.t.Helper()
+	var resp struct {
+		PostID     int `json:"post_id"`
+		RepostOfID int `json:"repost_of_id"`
+	}
+	decodeBody(a.t, a.expect(http.StatusCreated, "POST", postPath(postID, "/repost"), token, body), &resp)
+	if resp.RepostOfID != postID {
+		a.t.Fatalf("repost_of_id = %d, want %d", resp.RepostOfID, postID)
+	}
+	return resp.PostID
+}
+
+func TestRepost(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	original := a.createPost(alice, map[string]any{"content": "original"}, "")
+
+	plain := a.repost(bob, original, nil)
+	quoted := a.repost(bob, original, RepostRequest{Content: "so true #go"})
+
+	detail := a.postDetail("", quoted)
+	if detail.RepostOfID != original || detail.Content != "so true #go" || len(detail.Tags) != 1 {
+		t.Fatalf("quoted repost = %+v", detail)
+	}
+	if detail.RepostOf == nil || detail.RepostOf.PostID != original || detail.RepostOf.Content != "original" {
+		t.Fatalf("repost_of = %+v", detail.RepostOf)
+	}
+	detail = a.postDetail("", plain)
+	if detail.Content != "" || detail.RepostOf == nil {
+		t.Fatalf("plain repost = %+v", detail)
+	}
+
+	// repost_of_id trong body của POST /posts bị bỏ qua
+	own := a.createPost(bob, map[string]any{"content": "mine", "repost_of_id": original}, "")
+	detail = a.postDetail("", own)
+	if detail.RepostOfID != 0 {
+		t.Fatalf("POST /posts set repost_of_id: %+v", detail)
+	}
+
+	// post gốc bị xoá: không repost được nữa, repost cũ không còn hiện post gốc
+	a.expect(http.StatusNoContent, "DELETE", postPath(original, ""), alice, nil)
+	a.expect(http.StatusNotFound, "POST", postPath(original, "/repost"), bob, nil)
+	detail = a.postDetail("", quoted)
+	if detail.RepostOf != nil {
+		t.Fatalf("repost still shows deleted original: %+v", detail.RepostOf)
+	}
+}
+
+func TestRepostHiddenOrMissingPost(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	_, bob := a.register("bob")
+	private := a.createPost(alice, map[string]any{"content": "only me", "visibility": "private"}, "")
+	draft := a.createPost(alice, map[string]any{"content": "later"}, "status=draft")
+
+	a.expect(http.StatusNotFound, "POST", postPath(private, "/repost"), bob, nil)
+	a.expect(http.StatusNotFound, "POST", postPath(999, "/repost"), bob, nil)
+	a.expect(http.StatusBadRequest, "POST", postPath(draft, "/repost"), alice, nil)
+	a.expect(http.StatusUnauthorized, "POST", postPath(private, "/repost"), "", nil)
+}
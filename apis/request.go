@@ -0,0 +1,92 @@
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// maxJSONBody là kích thước tối đa mặc định của body JSON
+const maxJSONBody = 1 << 20 // 1 MB
+
+// DecodeError describes why a JSON request body was rejected
+type DecodeError struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *DecodeError) Error() string { return e.Message }
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// decodeJSON đọc body JSON vào dst, giới hạn maxBytes và không chấp nhận field lạ;
+// lỗi trả về luôn là *DecodeError
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil {
+		return nil
+	}
+
+	var tooLarge *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		return &DecodeError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", maxBytes), err}
+	case errors.Is(err, io.EOF):
+		return &DecodeError{http.StatusBadRequest, "Request body is required", err}
+	case errors.As(err, &syntaxErr):
+		return &DecodeError{http.StatusBadRequest, fmt.Sprintf("Malformed JSON at byte %d", syntaxErr.Offset), err}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &DecodeError{http.StatusBadRequest, "Malformed JSON: unexpected end of body", err}
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return &DecodeError{http.StatusBadRequest, fmt.Sprintf("Request body must be %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value), err}
+		}
+		return &DecodeError{http.StatusBadRequest,
+			fmt.Sprintf("Field %q must be %s, got %s", typeErr.Field, jsonTypeName(typeErr.Type), typeErr.Value), err}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return &DecodeError{http.StatusBadRequest, "Unknown field " + field, err}
+	}
+	return &DecodeError{http.StatusBadRequest, "Invalid data", err}
+}
+
+// jsonTypeName describes a Go type the way a JSON client sees it
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	}
+	return "an object"
+}
+
+// writeDecodeError writes the response for an error returned by decodeJSON
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var de *DecodeError
+	if errors.As(err, &de) {
+		writeJSONError(w, de.Status, de.Message)
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, "Invalid data")
+}
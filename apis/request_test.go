@@ -0,0 +1,58 @@
+package apis
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSONErrors(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+
+	huge := []byte(`{"content":"` + strings.Repeat("a", maxJSONBody) + `"}`)
+	for name, tc := range map[string]struct {
+		body   []byte
+		status int
+		msg    string
+	}{
+		"oversized":     {huge, http.StatusRequestEntityTooLarge, "Request body exceeds"},
+		"unknown field": {[]byte(`{"content":"hi","colour":"red"}`), http.StatusBadRequest, `Unknown field "colour"`},
+	} {
+		var apiErr APIError
+		decodeBody(t, a.expect(tc.status, "POST", "/posts", alice, tc.body), &apiErr)
+		if !strings.HasPrefix(apiErr.Error, tc.msg) {
+			t.Fatalf("%s: error %q, want prefix %q", name, apiErr.Error, tc.msg)
+		}
+	}
+}
+
+func TestDecodeJSONMessages(t *testing.T) {
+	var dst struct {
+		Name    string `json:"name"`
+		Age     int    `json:"age"`
+		Private *bool  `json:"private"`
+		Tags    []int  `json:"tags"`
+	}
+	for body, want := range map[string]string{
+		`{"name":`:           "Malformed JSON: unexpected end of body",
+		`{"name":"a",}`:      "Malformed JSON at byte 13",
+		``:                   "Request body is required",
+		`[]`:                 "Request body must be an object, got array",
+		`{"name":123}`:       `Field "name" must be a string, got number`,
+		`{"age":"ten"}`:      `Field "age" must be an integer, got string`,
+		`{"age":1.5}`:        `Field "age" must be an integer, got number 1.5`,
+		`{"private":"yes"}`:  `Field "private" must be a boolean, got string`,
+		`{"tags":[1,"two"]}`: `Field "tags.1" must be an integer, got string`,
+		`{"tags":{}}`:        `Field "tags" must be an array, got object`,
+	} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		err := decodeJSON(httptest.NewRecorder(), req, &dst, maxJSONBody)
+		var de *DecodeError
+		if !errors.As(err, &de) || de.Status != http.StatusBadRequest || de.Message != want {
+			t.Fatalf("%s: error %v, want 400 %q", body, err, want)
+		}
+	}
+}
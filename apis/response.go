@@ -0,0 +1,136 @@
+package apis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// APIError is the body of every error response
+type APIError struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ProblemJSON forces every error response into RFC 7807 problem+json format
+var ProblemJSON bool
+
+// Problem represents an RFC 7807 error body
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"` // extension member
+}
+
+// problemWriter marks a response whose client negotiated problem+json
+type problemWriter struct {
+	http.ResponseWriter
+	instance string
+}
+
+// ProblemDetails switches error responses to problem+json when the client
+// sends "Accept: application/problem+json"
+func ProblemDetails(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept"), "application/problem+json") {
+			w = &problemWriter{ResponseWriter: w, instance: r.URL.Path}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON is the success response of every JSON endpoint: the Content-Type
+// header and status are set before the body so clients never have to sniff it
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONWithETag writes v as JSON with a weak ETag computed from the body,
+// or 304 Not Modified when the request's If-None-Match already has it
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot encode response")
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header lists etag (weak comparison)
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNoContent is the success response of every DELETE endpoint: 204 with no body
+func writeNoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findProblemWriter looks through wrapping writers (e.g. Metrics) for the problemWriter
+func findProblemWriter(w http.ResponseWriter) (*problemWriter, bool) {
+	for {
+		if pw, ok := w.(*problemWriter); ok {
+			return pw, true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil, false
+		}
+		w = u.Unwrap()
+	}
+}
+
+// writeJSONError writes an error response in the default or problem+json format
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	// RequestID middleware đã đặt header này, nếu có
+	requestID := w.Header().Get(RequestIDHeader)
+	pw, negotiated := findProblemWriter(w)
+	if negotiated || ProblemJSON {
+		problem := Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    msg,
+			RequestID: requestID,
+		}
+		if negotiated {
+			problem.Instance = pw.instance
+		}
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(problem)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(APIError{Error: msg, Code: errorCode(status), RequestID: requestID})
+}
+
+// errorCode turns a status into a machine-readable code, e.g. 404 -> "not_found"
+func errorCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
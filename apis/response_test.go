@@ -0,0 +1,174 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblemJSONNegotiated(t *testing.T) {
+	a := newTestApp(t, nil)
+	h := ProblemDetails(a.router)
+
+	req := httptest.NewRequest("GET", "/posts/999", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	var problem Problem
+	decodeBody(t, rec, &problem)
+	want := Problem{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound, Detail: "Post not found", Instance: "/posts/999"}
+	if problem != want {
+		t.Fatalf("problem = %+v, want %+v", problem, want)
+	}
+
+	// không yêu cầu problem+json thì giữ dạng mặc định
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/posts/999", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("default Content-Type = %q", ct)
+	}
+	var fields map[string]any
+	decodeBody(t, rec, &fields)
+	if _, ok := fields["status"]; ok || fields["error"] != "Post not found" {
+		t.Fatalf("default error body = %v", fields)
+	}
+}
+
+func TestErrorsShareAPIError(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+
+	for _, tc := range []struct {
+		rec  *httptest.ResponseRecorder
+		code string
+	}{
+		{a.expect(http.StatusNotFound, "GET", "/posts/999", "", nil), "not_found"},
+		{a.expect(http.StatusBadRequest, "POST", postPath(postID, "/comments"), alice, map[string]string{"content": ""}), "bad_request"},
+	} {
+		if ct := tc.rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("Content-Type = %q", ct)
+		}
+		var apiErr APIError
+		decodeBody(t, tc.rec, &apiErr)
+		if apiErr.Code != tc.code || apiErr.Error == "" {
+			t.Fatalf("error = %+v, want code %q", apiErr, tc.code)
+		}
+	}
+}
+
+func TestDeletesReturnNoContent(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	_, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	commentID := a.comment(bob, postID, 0, "hi")
+	a.expect(http.StatusCreated, "POST", fmt.Sprintf("/users/%d/follow", aliceID), bob, nil)
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "like"})
+
+	for _, del := range []struct{ path, token string }{
+		{fmt.Sprintf("/comments/%d", commentID), bob},
+		{postPath(postID, "/reactions"), bob},
+		{fmt.Sprintf("/users/%d/follow", aliceID), bob},
+		{postPath(postID, ""), alice},
+		{"/me", bob},
+	} {
+		rec := a.expect(http.StatusNoContent, "DELETE", del.path, del.token, nil)
+		if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+			t.Fatalf("DELETE %s: body %q, Content-Type %q", del.path, rec.Body.String(), rec.Header().Get("Content-Type"))
+		}
+	}
+
+	// lỗi vẫn là JSON
+	var apiErr APIError
+	decodeBody(t, a.expect(http.StatusForbidden, "DELETE", postPath(999, ""), alice, nil), &apiErr)
+	if apiErr.Code != "forbidden" {
+		t.Fatalf("error = %+v", apiErr)
+	}
+}
+
+// conditionalGet gửi GET path kèm If-None-Match nếu etag khác rỗng
+func (a *testApp) conditionalGet(path, etag string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestConditionalGet(t *testing.T) {
+	a := newTestApp(t, nil)
+	aliceID, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "v1"}, "")
+
+	for path, change := range map[string]func(){
+		postPath(postID, ""): func() {
+			a.expect(http.StatusOK, "PATCH", postPath(postID, ""), alice, map[string]string{"content": "v2"})
+		},
+		fmt.Sprintf("/users/%d", aliceID): func() {
+			a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]string{"bio": "new bio"})
+		},
+	} {
+		rec := a.conditionalGet(path, "")
+		etag := rec.Header().Get("ETag")
+		if rec.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+			t.Fatalf("%s: status %d, ETag %q", path, rec.Code, etag)
+		}
+		if rec := a.conditionalGet(path, etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Fatalf("%s with If-None-Match: status %d, body %q", path, rec.Code, rec.Body.String())
+		}
+
+		change()
+		rec = a.conditionalGet(path, etag)
+		if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+			t.Fatalf("%s after change: status %d, ETag %q", path, rec.Code, rec.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestSuccessResponsesAreJSON(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	bobID, bob := a.register("bob")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/comments"), bob, map[string]string{"content": "hi"})
+
+	for _, tc := range []struct {
+		method, path, token string
+		body                any
+		status              int
+	}{
+		{"POST", "/register", "", map[string]string{"username": "carol", "email": "carol@example.com", "password": "password1"}, http.StatusOK},
+		{"POST", "/login", "", LoginRequest{Login: "alice", Password: "password1"}, http.StatusOK},
+		{"GET", "/me", alice, nil, http.StatusOK},
+		{"POST", "/posts", alice, map[string]string{"content": "another"}, http.StatusCreated},
+		{"GET", "/posts", "", nil, http.StatusOK},
+		{"GET", postPath(postID, ""), "", nil, http.StatusOK},
+		{"PATCH", postPath(postID, ""), alice, map[string]string{"content": "edited"}, http.StatusOK},
+		{"GET", postPath(postID, "/comments"), "", nil, http.StatusOK},
+		{"POST", postPath(postID, "/reactions"), bob, map[string]string{"reaction_type": "like"}, http.StatusCreated},
+		{"GET", postPath(postID, "/reactions"), "", nil, http.StatusOK},
+		{"POST", "/users/1/follow", bob, nil, http.StatusCreated},
+		{"GET", "/users/1/followers", "", nil, http.StatusOK},
+		{"GET", fmt.Sprintf("/users/%d/following", bobID), "", nil, http.StatusOK},
+		{"GET", "/users/1/follow/counts", "", nil, http.StatusOK},
+		{"GET", "/users/1/follow/status", bob, nil, http.StatusOK},
+		{"GET", "/users/1", "", nil, http.StatusOK},
+		{"GET", "/users", "", nil, http.StatusOK},
+		{"GET", "/feeds", bob, nil, http.StatusOK},
+		{"GET", "/notifications", alice, nil, http.StatusOK},
+	} {
+		rec := a.expect(tc.status, tc.method, tc.path, tc.token, tc.body)
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: Content-Type = %q", tc.method, tc.path, ct)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package apis
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestSwaggerMatchesRoutes so từng path + method trong docs/swagger.json với route đã
+// đăng ký, theo cả hai chiều: không có endpoint chỉ có trong tài liệu (như /username/ cũ)
+func TestSwaggerMatchesRoutes(t *testing.T) {
+	a := newTestApp(t, nil)
+	// các handler newTestApp không gắn sẵn
+	a.withWebhooks()
+	audit := NewAuditLog()
+	audit.Tokens = a.tokens
+	audit.RegisterRoutes(a.router)
+	(&MigrateHandler{Source: a.store, Tokens: a.tokens}).RegisterRoutes(a.router)
+
+	live := map[string]bool{}
+	err := a.router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, m := range methods {
+			live[strings.ToLower(m)+" "+tpl] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile("../docs/swagger.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		t.Fatal(err)
+	}
+	documented := map[string]bool{}
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			documented[method+" "+path] = true
+		}
+	}
+	var missing, undocumented []string
+	for r := range documented {
+		if !live[r] {
+			missing = append(missing, r)
+		}
+	}
+	for r := range live {
+		if !documented[r] {
+			undocumented = append(undocumented, r)
+		}
+	}
+	slices.Sort(missing)
+	slices.Sort(undocumented)
+	if len(missing) > 0 {
+		t.Errorf("documented but not routed: %v", missing)
+	}
+	if len(undocumented) > 0 {
+		t.Errorf("routed but not documented: %v", undocumented)
+	}
+	if len(documented) == 0 {
+		t.Fatal("swagger.json has no paths")
+	}
+}
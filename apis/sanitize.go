@@ -0,0 +1,31 @@
+package apis
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Độ dài tối đa (tính theo ký tự) của nội dung người dùng gửi lên
+const (
+	maxPostLength    = 5000
+	maxCommentLength = 1000
+)
+
+// contentPolicy bỏ toàn bộ thẻ HTML, chỉ giữ lại text
+var contentPolicy = bluemonday.StrictPolicy()
+
+// cleanContent kiểm tra độ dài rồi loại HTML khỏi content; reason khác rỗng khi content không hợp lệ.
+// Khoảng trắng được giữ nguyên, chỉ từ chối content toàn khoảng trắng
+func cleanContent(content string, maxLen int) (cleaned string, reason string) {
+	if utf8.RuneCountInString(content) > maxLen {
+		return "", fmt.Sprintf("Content exceeds %d characters", maxLen)
+	}
+	cleaned = contentPolicy.Sanitize(content)
+	if strings.TrimSpace(cleaned) == "" {
+		return "", "Content must not be empty or whitespace only"
+	}
+	return cleaned, ""
+}
@@ -0,0 +1,70 @@
+package apis
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCleanContent(t *testing.T) {
+	for name, tc := range map[string]struct {
+		content, want string
+		rejected      bool
+	}{
+		"plain":          {"hello  world\n", "hello  world\n", false},
+		"script":         {`hi <script>alert(1)</script>there`, "hi there", false},
+		"attributes":     {`<b onclick="x()">bold</b>`, "bold", false},
+		"only tags":      {"<script>alert(1)</script>", "", true},
+		"whitespace":     {" \n\t", "", true},
+		"at limit":       {strings.Repeat("é", 40), strings.Repeat("é", 40), false},
+		"over limit":     {strings.Repeat("a", 41), "", true},
+		"escapes entity": {"a < b", "a &lt; b", false},
+	} {
+		got, reason := cleanContent(tc.content, 40)
+		if (reason != "") != tc.rejected || got != tc.want {
+			t.Fatalf("%s: cleanContent = %q, %q", name, got, reason)
+		}
+	}
+}
+
+func TestContentLimitsAndSanitizing(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+
+	a.expect(http.StatusBadRequest, "POST", "/posts", alice, map[string]string{"content": strings.Repeat("a", maxPostLength+1)})
+	postID := a.createPost(alice, map[string]any{"content": `<script>alert("x")</script><b>bold</b> text`}, "")
+	a.expect(http.StatusBadRequest, "POST", postPath(postID, "/comments"), alice, map[string]string{"content": strings.Repeat("a", maxCommentLength+1)})
+
+	var post PostDetail
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, ""), "", nil), &post)
+	if post.Content != "bold text" {
+		t.Fatalf("stored content = %q", post.Content)
+	}
+
+	a.expect(http.StatusCreated, "POST", postPath(postID, "/comments"), alice, map[string]string{"content": `<img src=x onerror=alert(1)>nice`})
+	var comments GetCommentsResponse
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, "/comments"), "", nil), &comments)
+	if len(comments.Comments) != 1 || comments.Comments[0].Content != "nice" {
+		t.Fatalf("comments = %+v", comments.Comments)
+	}
+}
+
+func TestWhitespaceOnlyContentRejected(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "line one\n\n  indented"}, "")
+
+	for _, blank := range []string{"   ", "\n\n", "\t \r\n"} {
+		a.expect(http.StatusBadRequest, "POST", "/posts", alice, map[string]string{"content": blank})
+		a.expect(http.StatusBadRequest, "PATCH", postPath(postID, ""), alice, map[string]string{"content": blank})
+		a.expect(http.StatusBadRequest, "POST", postPath(postID, "/comments"), alice, map[string]string{"content": blank})
+		a.expect(http.StatusBadRequest, "POST", postPath(postID, "/reactions"), alice, map[string]string{"reaction_type": blank})
+	}
+
+	// khoảng trắng bên trong được giữ nguyên
+	var post PostDetail
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, ""), alice, nil), &post)
+	if post.Content != "line one\n\n  indented" {
+		t.Fatalf("stored content = %q", post.Content)
+	}
+}
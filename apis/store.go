@@ -0,0 +1,381 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPostNotFound là lỗi Store trả về khi không có post nào với ID đó
+var ErrPostNotFound = errors.New("post not found")
+
+// PostFilter thu hẹp ListPosts; giá trị zero nghĩa là "không lọc"
+type PostFilter struct {
+	UserID         int
+	Query          string // chuỗi con của content, không phân biệt hoa thường
+	Tag            string // hashtag đã chuẩn hoá, xem extractHashtags
+	IncludeDeleted bool
+}
+
+// Store lưu trữ post cùng user, profile, comment và media. Mọi method nhận context của caller
+// (thường là r.Context()) để client ngắt kết nối thì query bị huỷ thay vì tiếp tục chạy
+type Store interface {
+	UserStore
+	CommentStore
+	MediaStore
+
+	// CreatePost lưu post mới và trả về ID được cấp
+	CreatePost(ctx context.Context, p Post) (int, error)
+	// GetPost trả về post theo ID, kể cả post đã xoá mềm
+	GetPost(ctx context.Context, id int) (Post, error)
+	// ListUserPosts trả về mọi post của một user, kể cả post đã xoá mềm
+	ListUserPosts(ctx context.Context, userID int) ([]Post, error)
+	// ListPosts trả về các post khớp f, mới nhất (post_id lớn nhất) trước
+	ListPosts(ctx context.Context, f PostFilter) ([]Post, error)
+	// UpdatePost ghi đè một post đã có
+	UpdatePost(ctx context.Context, p Post) error
+	// SoftDeletePost đánh dấu post đã xoá và ghi lại thời điểm xoá
+	SoftDeletePost(ctx context.Context, id int) error
+	// DeletePost xoá hẳn một post cùng lịch sử sửa của nó
+	DeletePost(ctx context.Context, id int) error
+	// PurgeDeleted xoá hẳn các post bị xoá mềm trước cutoff (cùng lịch sử sửa) và trả về số post đã xoá
+	PurgeDeleted(ctx context.Context, cutoff time.Time) (int, error)
+
+	// AddPostRevision ghi thêm rev cho post với version kế tiếp, bỏ Version của rev;
+	// chỉ giữ maxPostRevisions revision mới nhất
+	AddPostRevision(ctx context.Context, postID int, rev PostRevision) error
+	// ListPostRevisions trả về các revision của post, mới nhất trước
+	ListPostRevisions(ctx context.Context, postID int) ([]PostRevision, error)
+}
+
+// UserStore lưu tài khoản và profile để user_id không bị cấp lại sau khi server khởi động lại
+type UserStore interface {
+	// SaveUser thêm hoặc ghi đè user theo ID
+	SaveUser(ctx context.Context, u User) error
+	// ListUsers trả về mọi user, kể cả user đã xoá, theo thứ tự ID
+	ListUsers(ctx context.Context) ([]User, error)
+	// SaveProfile thêm hoặc ghi đè profile theo user_id
+	SaveProfile(ctx context.Context, p UserProfile) error
+	// ListProfiles trả về mọi profile theo thứ tự user_id
+	ListProfiles(ctx context.Context) ([]UserProfile, error)
+}
+
+// CommentStore lưu comment của các post
+type CommentStore interface {
+	// SaveComment thêm hoặc ghi đè comment theo comment_id
+	SaveComment(ctx context.Context, postID int, c Comment) error
+	// ListComments trả về mọi comment theo post_id, mỗi post theo thứ tự comment_id
+	ListComments(ctx context.Context) (map[int][]Comment, error)
+}
+
+// MediaStore lưu thông tin media đã upload, kể cả đường dẫn file trên đĩa
+type MediaStore interface {
+	// SaveMedia thêm hoặc ghi đè media theo ID
+	SaveMedia(ctx context.Context, m Media) error
+	// ListMedia trả về mọi media theo thứ tự ID
+	ListMedia(ctx context.Context) ([]Media, error)
+	// DeleteMedia xoá media; không có media đó thì không làm gì
+	DeleteMedia(ctx context.Context, id int) error
+}
+
+// requireStore ghi 500 và trả về false khi handler được tạo mà không có Store
+// (vd &PostsHandler{} thay vì NewPostsHandler), thay vì để panic
+func requireStore(w http.ResponseWriter, s Store) bool {
+	if s == nil {
+		writeJSONError(w, http.StatusInternalServerError, "Post store is not configured")
+		return false
+	}
+	return true
+}
+
+// MemoryStore là Store lưu trong bộ nhớ, dùng cho test và demo
+type MemoryStore struct {
+	mu      sync.RWMutex
+	posts   map[int]Post // key = post_id
+	ids     IDGenerator
+	history postHistory
+
+	users    map[int]User          // key = user_id
+	profiles map[int]UserProfile   // key = user_id
+	comments map[int]storedComment // key = comment_id
+	media    map[int]Media         // key = media_id
+}
+
+// storedComment là comment kèm post_id của nó
+type storedComment struct {
+	postID int
+	Comment
+}
+
+// NewMemoryStore constructor
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		posts: make(map[int]Post),
+	}
+}
+
+// CreatePost implements Store
+func (s *MemoryStore) CreatePost(_ context.Context, p Post) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.posts == nil {
+		s.posts = make(map[int]Post)
+	}
+	newID := s.ids.Next()
+	p.PostID = newID
+	s.posts[newID] = clonePost(p)
+	return newID, nil
+}
+
+// GetPost implements Store
+func (s *MemoryStore) GetPost(_ context.Context, id int) (Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.posts[id]
+	if !ok {
+		return Post{}, ErrPostNotFound
+	}
+	return clonePost(p), nil
+}
+
+// ListUserPosts implements Store
+func (s *MemoryStore) ListUserPosts(_ context.Context, userID int) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := []Post{}
+	for _, p := range s.posts {
+		if p.UserID == userID {
+			posts = append(posts, clonePost(p))
+		}
+	}
+	return posts, nil
+}
+
+// ListPosts implements Store
+func (s *MemoryStore) ListPosts(_ context.Context, f PostFilter) ([]Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := strings.ToLower(f.Query)
+	posts := []Post{}
+	for _, p := range s.posts {
+		if f.UserID != 0 && p.UserID != f.UserID {
+			continue
+		}
+		if p.IsDeleted && !f.IncludeDeleted {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(p.Content), query) {
+			continue
+		}
+		if f.Tag != "" && !slices.Contains(p.Tags, f.Tag) {
+			continue
+		}
+		posts = append(posts, clonePost(p))
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].PostID > posts[j].PostID })
+	return posts, nil
+}
+
+// UpdatePost implements Store
+func (s *MemoryStore) UpdatePost(_ context.Context, p Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[p.PostID]; !ok {
+		return ErrPostNotFound
+	}
+	s.posts[p.PostID] = clonePost(p)
+	return nil
+}
+
+// clonePost copy MediaIDs và Tags để post trong store không dùng chung mảng với caller,
+// vd append(post.MediaIDs, ...) của handler không được ghi vào bản đang lưu
+func clonePost(p Post) Post {
+	p.MediaIDs = slices.Clone(p.MediaIDs)
+	p.Tags = slices.Clone(p.Tags)
+	return p
+}
+
+// SoftDeletePost implements Store
+func (s *MemoryStore) SoftDeletePost(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.posts[id]
+	if !ok {
+		return ErrPostNotFound
+	}
+	p.IsDeleted = true
+	p.DeletedAt = nowRFC3339()
+	s.posts[id] = p
+	return nil
+}
+
+// DeletePost implements Store
+func (s *MemoryStore) DeletePost(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[id]; !ok {
+		return ErrPostNotFound
+	}
+	delete(s.posts, id)
+	s.history.forget(id)
+	return nil
+}
+
+// PurgeDeleted implements Store
+func (s *MemoryStore) PurgeDeleted(_ context.Context, cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, p := range s.posts {
+		if !p.IsDeleted {
+			continue
+		}
+		// không rõ thời điểm xoá thì coi như đã quá hạn
+		deletedAt, err := time.Parse(time.RFC3339, p.DeletedAt)
+		if err == nil && !deletedAt.Before(cutoff) {
+			continue
+		}
+		delete(s.posts, id)
+		s.history.forget(id)
+		removed++
+	}
+	return removed, nil
+}
+
+// AddPostRevision implements Store
+func (s *MemoryStore) AddPostRevision(_ context.Context, postID int, rev PostRevision) error {
+	s.history.record(postID, rev)
+	return nil
+}
+
+// ListPostRevisions implements Store
+func (s *MemoryStore) ListPostRevisions(_ context.Context, postID int) ([]PostRevision, error) {
+	return s.history.list(postID), nil
+}
+
+// SaveUser implements Store
+func (s *MemoryStore) SaveUser(_ context.Context, u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.users == nil {
+		s.users = make(map[int]User)
+	}
+	s.users[u.ID] = u
+	return nil
+}
+
+// ListUsers implements Store
+func (s *MemoryStore) ListUsers(_ context.Context) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+// SaveProfile implements Store
+func (s *MemoryStore) SaveProfile(_ context.Context, p UserProfile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.profiles == nil {
+		s.profiles = make(map[int]UserProfile)
+	}
+	s.profiles[p.UserID] = p
+	return nil
+}
+
+// ListProfiles implements Store
+func (s *MemoryStore) ListProfiles(_ context.Context) ([]UserProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	profiles := make([]UserProfile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].UserID < profiles[j].UserID })
+	return profiles, nil
+}
+
+// SaveComment implements Store
+func (s *MemoryStore) SaveComment(_ context.Context, postID int, c Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.comments == nil {
+		s.comments = make(map[int]storedComment)
+	}
+	s.comments[c.CommentID] = storedComment{postID: postID, Comment: c}
+	return nil
+}
+
+// ListComments implements Store
+func (s *MemoryStore) ListComments(_ context.Context) (map[int][]Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]int, 0, len(s.comments))
+	for id := range s.comments {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	comments := make(map[int][]Comment)
+	for _, id := range ids {
+		c := s.comments[id]
+		comments[c.postID] = append(comments[c.postID], c.Comment)
+	}
+	return comments, nil
+}
+
+// SaveMedia implements Store
+func (s *MemoryStore) SaveMedia(_ context.Context, m Media) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.media == nil {
+		s.media = make(map[int]Media)
+	}
+	s.media[m.ID] = m
+	return nil
+}
+
+// ListMedia implements Store
+func (s *MemoryStore) ListMedia(_ context.Context) ([]Media, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	media := make([]Media, 0, len(s.media))
+	for _, m := range s.media {
+		media = append(media, m)
+	}
+	sort.Slice(media, func(i, j int) bool { return media[i].ID < media[j].ID })
+	return media, nil
+}
+
+// DeleteMedia implements Store
+func (s *MemoryStore) DeleteMedia(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.media, id)
+	return nil
+}
@@ -0,0 +1,602 @@
+package apis
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// migrations được chạy theo thứ tự; PRAGMA user_version ghi số migration đã chạy
+var migrations = []string{
+	// AUTOINCREMENT để post_id không bao giờ bị dùng lại
+	`CREATE TABLE posts (
+		post_id      INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id      INTEGER NOT NULL,
+		content      TEXT NOT NULL,
+		created_at   TEXT NOT NULL,
+		media_ids    TEXT NOT NULL DEFAULT 'null',
+		is_deleted   INTEGER NOT NULL DEFAULT 0,
+		deleted_at   TEXT NOT NULL DEFAULT '',
+		visibility   TEXT NOT NULL DEFAULT 'public',
+		tags         TEXT NOT NULL DEFAULT 'null',
+		repost_of_id INTEGER NOT NULL DEFAULT 0,
+		status       TEXT NOT NULL DEFAULT 'published'
+	);
+	CREATE INDEX idx_posts_user_id ON posts (user_id)`,
+	`CREATE TABLE post_revisions (
+		post_id   INTEGER NOT NULL,
+		version   INTEGER NOT NULL,
+		content   TEXT NOT NULL,
+		media_ids TEXT NOT NULL DEFAULT 'null',
+		edited_at TEXT NOT NULL,
+		editor_id INTEGER NOT NULL,
+		PRIMARY KEY (post_id, version)
+	)`,
+	// user_id được lưu lại để không cấp lại cho người đăng ký sau khi khởi động lại
+	`CREATE TABLE users (
+		user_id        INTEGER PRIMARY KEY,
+		username       TEXT NOT NULL,
+		email          TEXT NOT NULL,
+		password       TEXT NOT NULL,
+		created_at     TEXT NOT NULL,
+		is_deleted     INTEGER NOT NULL DEFAULT 0,
+		deleted_at     TEXT NOT NULL DEFAULT '',
+		email_verified INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE profiles (
+		user_id    INTEGER PRIMARY KEY,
+		username   TEXT NOT NULL,
+		avatar     TEXT NOT NULL DEFAULT '',
+		bio        TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT '',
+		is_private INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE comments (
+		comment_id INTEGER PRIMARY KEY,
+		post_id    INTEGER NOT NULL,
+		parent_id  INTEGER NOT NULL DEFAULT 0,
+		user_id    INTEGER NOT NULL,
+		username   TEXT NOT NULL DEFAULT '',
+		content    TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL,
+		edited     INTEGER NOT NULL DEFAULT 0,
+		is_deleted INTEGER NOT NULL DEFAULT 0,
+		deleted_at TEXT NOT NULL DEFAULT '',
+		deleted_by INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX idx_comments_post_id ON comments (post_id)`,
+	`CREATE TABLE media (
+		media_id      INTEGER PRIMARY KEY,
+		type          TEXT NOT NULL,
+		post_id       INTEGER NOT NULL DEFAULT 0,
+		user_id       INTEGER NOT NULL,
+		filename      TEXT NOT NULL,
+		url           TEXT NOT NULL,
+		thumbnail_url TEXT NOT NULL DEFAULT '',
+		status        TEXT NOT NULL,
+		created_at    TEXT NOT NULL,
+		path          TEXT NOT NULL,
+		thumb_path    TEXT NOT NULL DEFAULT ''
+	)`,
+}
+
+const postColumns = `post_id, user_id, content, created_at, media_ids, is_deleted, deleted_at, visibility, tags, repost_of_id, status`
+
+// SQLiteStore là Store lưu trong một file database SQLite
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore mở database tại path và chạy các migration còn thiếu
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite chỉ cho một writer tại một thời điểm
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close đóng database bên dưới
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// migrate chạy mọi migration mới hơn user_version của database
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowScanner là interface chung của *sql.Row và *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPost(row rowScanner) (Post, error) {
+	var p Post
+	var mediaIDs, tags string
+	if err := row.Scan(&p.PostID, &p.UserID, &p.Content, &p.CreatedAt, &mediaIDs, &p.IsDeleted, &p.DeletedAt, &p.Visibility, &tags, &p.RepostOfID, &p.Status); err != nil {
+		return Post{}, err
+	}
+	if err := json.Unmarshal([]byte(mediaIDs), &p.MediaIDs); err != nil {
+		return Post{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &p.Tags); err != nil {
+		return Post{}, err
+	}
+	return p, nil
+}
+
+// CreatePost implements Store
+func (s *SQLiteStore) CreatePost(ctx context.Context, p Post) (int, error) {
+	mediaIDs, err := json.Marshal(p.MediaIDs)
+	if err != nil {
+		return 0, err
+	}
+	tags, err := json.Marshal(p.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := s.db.ExecContext(ctx, `INSERT INTO posts (user_id, content, created_at, media_ids, is_deleted, deleted_at, visibility, tags, repost_of_id, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.UserID, p.Content, p.CreatedAt, string(mediaIDs), p.IsDeleted, p.DeletedAt, p.Visibility, string(tags), p.RepostOfID, p.Status)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetPost implements Store
+func (s *SQLiteStore) GetPost(ctx context.Context, id int) (Post, error) {
+	p, err := scanPost(s.db.QueryRowContext(ctx, `SELECT `+postColumns+` FROM posts WHERE post_id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Post{}, ErrPostNotFound
+	}
+	return p, err
+}
+
+// ListUserPosts implements Store
+func (s *SQLiteStore) ListUserPosts(ctx context.Context, userID int) ([]Post, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+postColumns+` FROM posts WHERE user_id = ? ORDER BY post_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+// ListPosts implements Store
+func (s *SQLiteStore) ListPosts(ctx context.Context, f PostFilter) ([]Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE 1 = 1`
+	args := []any{}
+	if f.UserID != 0 {
+		query += ` AND user_id = ?`
+		args = append(args, f.UserID)
+	}
+	if !f.IncludeDeleted {
+		query += ` AND is_deleted = 0`
+	}
+	if f.Query != "" {
+		// instr thay cho LIKE để không phải escape % và _
+		query += ` AND instr(lower(content), lower(?)) > 0`
+		args = append(args, f.Query)
+	}
+	if f.Tag != "" {
+		query += ` AND EXISTS (SELECT 1 FROM json_each(posts.tags) WHERE json_each.value = ?)`
+		args = append(args, f.Tag)
+	}
+	query += ` ORDER BY post_id DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+// UpdatePost implements Store
+func (s *SQLiteStore) UpdatePost(ctx context.Context, p Post) error {
+	mediaIDs, err := json.Marshal(p.MediaIDs)
+	if err != nil {
+		return err
+	}
+	tags, err := json.Marshal(p.Tags)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, `UPDATE posts SET user_id = ?, content = ?, created_at = ?, media_ids = ?, is_deleted = ?, deleted_at = ?, visibility = ?, tags = ?, status = ? WHERE post_id = ?`,
+		p.UserID, p.Content, p.CreatedAt, string(mediaIDs), p.IsDeleted, p.DeletedAt, p.Visibility, string(tags), p.Status, p.PostID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// SoftDeletePost implements Store
+func (s *SQLiteStore) SoftDeletePost(ctx context.Context, id int) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE posts SET is_deleted = 1, deleted_at = ? WHERE post_id = ?`,
+		nowRFC3339(), id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// DeletePost implements Store
+func (s *SQLiteStore) DeletePost(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE post_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_revisions WHERE post_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PurgeDeleted implements Store; chuỗi RFC3339 UTC so sánh được theo thời gian
+func (s *SQLiteStore) PurgeDeleted(ctx context.Context, cutoff time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	before := formatRFC3339(cutoff)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_revisions WHERE post_id IN (SELECT post_id FROM posts WHERE is_deleted = 1 AND deleted_at < ?)`, before); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE is_deleted = 1 AND deleted_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// AddPostRevision implements Store
+func (s *SQLiteStore) AddPostRevision(ctx context.Context, postID int, rev PostRevision) error {
+	mediaIDs, err := json.Marshal(rev.MediaIDs)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) + 1 FROM post_revisions WHERE post_id = ?`, postID).Scan(&version); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO post_revisions (post_id, version, content, media_ids, edited_at, editor_id) VALUES (?, ?, ?, ?, ?, ?)`,
+		postID, version, rev.Content, string(mediaIDs), rev.EditedAt, rev.EditorID); err != nil {
+		return err
+	}
+	// version tăng liên tục nên chỉ cần bỏ các version quá xa version mới
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_revisions WHERE post_id = ? AND version <= ?`, postID, version-maxPostRevisions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListPostRevisions implements Store
+func (s *SQLiteStore) ListPostRevisions(ctx context.Context, postID int) ([]PostRevision, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT version, content, media_ids, edited_at, editor_id FROM post_revisions WHERE post_id = ? ORDER BY version DESC`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revs := []PostRevision{}
+	for rows.Next() {
+		var rev PostRevision
+		var mediaIDs string
+		if err := rows.Scan(&rev.Version, &rev.Content, &mediaIDs, &rev.EditedAt, &rev.EditorID); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(mediaIDs), &rev.MediaIDs); err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+	return revs, rows.Err()
+}
+
+// checkAffected trả về ErrPostNotFound khi câu update không chạm dòng nào
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+// formatTime định dạng t cho cột TEXT; thời điểm zero (vd chưa xoá) thành chuỗi rỗng
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseTime đọc lại giá trị của formatTime
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// SaveUser implements Store
+func (s *SQLiteStore) SaveUser(ctx context.Context, u User) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO users (user_id, username, email, password, created_at, is_deleted, deleted_at, email_verified) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		u.ID, u.Username, u.Email, u.Password, formatTime(u.CreatedAt), u.IsDeleted, formatTime(u.DeletedAt), u.EmailVerified)
+	return err
+}
+
+// ListUsers implements Store
+func (s *SQLiteStore) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id, username, email, password, created_at, is_deleted, deleted_at, email_verified FROM users ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		var createdAt, deletedAt string
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &createdAt, &u.IsDeleted, &deletedAt, &u.EmailVerified); err != nil {
+			return nil, err
+		}
+		if u.CreatedAt, err = parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		if u.DeletedAt, err = parseTime(deletedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// SaveProfile implements Store
+func (s *SQLiteStore) SaveProfile(ctx context.Context, p UserProfile) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO profiles (user_id, username, avatar, bio, created_at, is_private) VALUES (?, ?, ?, ?, ?, ?)`,
+		p.UserID, p.Username, p.Avatar, p.Bio, p.CreatedAt, p.IsPrivate)
+	return err
+}
+
+// ListProfiles implements Store
+func (s *SQLiteStore) ListProfiles(ctx context.Context) ([]UserProfile, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id, username, avatar, bio, created_at, is_private FROM profiles ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []UserProfile{}
+	for rows.Next() {
+		var p UserProfile
+		if err := rows.Scan(&p.UserID, &p.Username, &p.Avatar, &p.Bio, &p.CreatedAt, &p.IsPrivate); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+// SaveComment implements Store
+func (s *SQLiteStore) SaveComment(ctx context.Context, postID int, c Comment) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO comments (comment_id, post_id, parent_id, user_id, username, content, created_at, updated_at, edited, is_deleted, deleted_at, deleted_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.CommentID, postID, c.ParentID, c.UserID, c.Username, c.Content, c.CreatedAt, c.UpdatedAt, c.Edited, c.IsDeleted, c.DeletedAt, c.DeletedBy)
+	return err
+}
+
+// ListComments implements Store
+func (s *SQLiteStore) ListComments(ctx context.Context) (map[int][]Comment, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT comment_id, post_id, parent_id, user_id, username, content, created_at, updated_at, edited, is_deleted, deleted_at, deleted_by FROM comments ORDER BY comment_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make(map[int][]Comment)
+	for rows.Next() {
+		var c Comment
+		var postID int
+		if err := rows.Scan(&c.CommentID, &postID, &c.ParentID, &c.UserID, &c.Username, &c.Content, &c.CreatedAt, &c.UpdatedAt, &c.Edited, &c.IsDeleted, &c.DeletedAt, &c.DeletedBy); err != nil {
+			return nil, err
+		}
+		comments[postID] = append(comments[postID], c)
+	}
+	return comments, rows.Err()
+}
+
+// SaveMedia implements Store
+func (s *SQLiteStore) SaveMedia(ctx context.Context, m Media) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO media (media_id, type, post_id, user_id, filename, url, thumbnail_url, status, created_at, path, thumb_path) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.ID, m.Type, m.PostID, m.UserID, m.Filename, m.URL, m.ThumbnailURL, m.Status, m.CreatedAt, m.path, m.thumbPath)
+	return err
+}
+
+// ListMedia implements Store
+func (s *SQLiteStore) ListMedia(ctx context.Context) ([]Media, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT media_id, type, post_id, user_id, filename, url, thumbnail_url, status, created_at, path, thumb_path FROM media ORDER BY media_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := []Media{}
+	for rows.Next() {
+		var m Media
+		if err := rows.Scan(&m.ID, &m.Type, &m.PostID, &m.UserID, &m.Filename, &m.URL, &m.ThumbnailURL, &m.Status, &m.CreatedAt, &m.path, &m.thumbPath); err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+	return media, rows.Err()
+}
+
+// DeleteMedia implements Store
+func (s *SQLiteStore) DeleteMedia(ctx context.Context, id int) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM media WHERE media_id = ?`, id)
+	return err
+}
+
+// migratedTables là các bảng POST /admin/migrate ghi vào
+var migratedTables = []string{"posts", "post_revisions", "users", "profiles", "comments", "media"}
+
+// importSnapshot ghi snap vào database trong một transaction, giữ nguyên ID. Database đã có
+// dữ liệu thì trả về errTargetNotEmpty, trừ khi replace thì xoá dữ liệu cũ trước
+func (s *SQLiteStore) importSnapshot(ctx context.Context, snap storeSnapshot, replace bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range migratedTables {
+		if replace {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table); err != nil {
+				return err
+			}
+			continue
+		}
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+table+`)`).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return errTargetNotEmpty
+		}
+	}
+
+	for _, p := range snap.posts {
+		mediaIDs, err := json.Marshal(p.MediaIDs)
+		if err != nil {
+			return err
+		}
+		tags, err := json.Marshal(p.Tags)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO posts (`+postColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.PostID, p.UserID, p.Content, p.CreatedAt, string(mediaIDs), p.IsDeleted, p.DeletedAt, p.Visibility, string(tags), p.RepostOfID, p.Status); err != nil {
+			return err
+		}
+	}
+	for postID, revs := range snap.revisions {
+		for _, rev := range revs {
+			mediaIDs, err := json.Marshal(rev.MediaIDs)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO post_revisions (post_id, version, content, media_ids, edited_at, editor_id) VALUES (?, ?, ?, ?, ?, ?)`,
+				postID, rev.Version, rev.Content, string(mediaIDs), rev.EditedAt, rev.EditorID); err != nil {
+				return err
+			}
+		}
+	}
+	for _, u := range snap.users {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO users (user_id, username, email, password, created_at, is_deleted, deleted_at, email_verified) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			u.ID, u.Username, u.Email, u.Password, formatTime(u.CreatedAt), u.IsDeleted, formatTime(u.DeletedAt), u.EmailVerified); err != nil {
+			return err
+		}
+	}
+	for _, p := range snap.profiles {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO profiles (user_id, username, avatar, bio, created_at, is_private) VALUES (?, ?, ?, ?, ?, ?)`,
+			p.UserID, p.Username, p.Avatar, p.Bio, p.CreatedAt, p.IsPrivate); err != nil {
+			return err
+		}
+	}
+	for postID, comments := range snap.comments {
+		for _, c := range comments {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO comments (comment_id, post_id, parent_id, user_id, username, content, created_at, updated_at, edited, is_deleted, deleted_at, deleted_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				c.CommentID, postID, c.ParentID, c.UserID, c.Username, c.Content, c.CreatedAt, c.UpdatedAt, c.Edited, c.IsDeleted, c.DeletedAt, c.DeletedBy); err != nil {
+				return err
+			}
+		}
+	}
+	for _, m := range snap.media {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO media (media_id, type, post_id, user_id, filename, url, thumbnail_url, status, created_at, path, thumb_path) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			m.ID, m.Type, m.PostID, m.UserID, m.Filename, m.URL, m.ThumbnailURL, m.Status, m.CreatedAt, m.path, m.thumbPath); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,296 @@
+package apis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testStores trả về MemoryStore và một SQLiteStore mới trong thư mục tạm
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	sqlite, err := NewSQLiteStore(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	return map[string]Store{"memory": NewMemoryStore(), "sqlite": sqlite}
+}
+
+func TestStorePostRoundTrip(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			want := Post{
+				UserID:     1,
+				Content:    "hello #go",
+				CreatedAt:  nowRFC3339(),
+				MediaIDs:   []int{3, 4},
+				Visibility: VisibilityFollowers,
+				Tags:       []string{"go"},
+				Status:     PostPublished,
+			}
+			id, err := store.CreatePost(ctx, want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want.PostID = id
+
+			got, err := store.GetPost(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("GetPost = %+v, want %+v", got, want)
+			}
+			if posts, _ := store.ListPosts(ctx, PostFilter{Tag: "go"}); len(posts) != 1 {
+				t.Fatalf("ListPosts by tag = %+v", posts)
+			}
+
+			if err := store.SoftDeletePost(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+			got, err = store.GetPost(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.IsDeleted || got.DeletedAt == "" {
+				t.Fatalf("soft-deleted post = %+v", got)
+			}
+			if posts, _ := store.ListPosts(ctx, PostFilter{}); len(posts) != 0 {
+				t.Fatalf("ListPosts shows soft-deleted post: %+v", posts)
+			}
+			if posts, _ := store.ListPosts(ctx, PostFilter{IncludeDeleted: true}); len(posts) != 1 {
+				t.Fatalf("ListPosts with IncludeDeleted = %+v", posts)
+			}
+
+			if err := store.DeletePost(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.GetPost(ctx, id); !errors.Is(err, ErrPostNotFound) {
+				t.Fatalf("GetPost after DeletePost: %v", err)
+			}
+		})
+	}
+}
+
+func TestStorePostsDoNotShareSlices(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			mediaIDs := []int{1}
+			id, err := store.CreatePost(ctx, Post{UserID: 1, Content: "#go", MediaIDs: mediaIDs, Tags: []string{"go"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mediaIDs[0] = 99
+
+			got, _ := store.GetPost(ctx, id)
+			got.Tags[0] = "changed"
+			listed, _ := store.ListPosts(ctx, PostFilter{})
+			listed[0].MediaIDs[0] = 98
+			byUser, _ := store.ListUserPosts(ctx, 1)
+			byUser[0].Tags[0] = "changed"
+
+			got, _ = store.GetPost(ctx, id)
+			if !reflect.DeepEqual(got.MediaIDs, []int{1}) || !reflect.DeepEqual(got.Tags, []string{"go"}) {
+				t.Fatalf("stored post changed through a returned slice: %v %v", got.MediaIDs, got.Tags)
+			}
+		})
+	}
+}
+
+func TestStoreUserRoundTrip(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			user := User{ID: 7, Username: "alice", Email: "alice@example.com", Password: "hash", CreatedAt: time.Now().UTC()}
+			profile := UserProfile{UserID: 7, Username: "alice", Bio: "hi", CreatedAt: nowRFC3339(), IsPrivate: true}
+			if err := store.SaveUser(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.SaveProfile(ctx, profile); err != nil {
+				t.Fatal(err)
+			}
+
+			// xoá mềm tài khoản là ghi đè user với IsDeleted
+			user.IsDeleted = true
+			user.DeletedAt = time.Now().UTC()
+			if err := store.SaveUser(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			users, err := store.ListUsers(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != 1 || !users[0].CreatedAt.Equal(user.CreatedAt) || !users[0].DeletedAt.Equal(user.DeletedAt) {
+				t.Fatalf("ListUsers = %+v, want %+v", users, user)
+			}
+			users[0].CreatedAt, users[0].DeletedAt = user.CreatedAt, user.DeletedAt
+			if users[0] != user {
+				t.Fatalf("ListUsers = %+v, want %+v", users[0], user)
+			}
+
+			profiles, err := store.ListProfiles(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(profiles) != 1 || profiles[0] != profile {
+				t.Fatalf("ListProfiles = %+v, want %+v", profiles, profile)
+			}
+		})
+	}
+}
+
+func TestStoreCommentAndMediaRoundTrip(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			now := nowRFC3339()
+			first := Comment{CommentID: 1, UserID: 2, Username: "bob", Content: "first", CreatedAt: now, UpdatedAt: now}
+			reply := Comment{CommentID: 2, ParentID: 1, UserID: 3, Username: "carol", Content: "reply", CreatedAt: now, UpdatedAt: now}
+			for _, c := range []Comment{reply, first} {
+				if err := store.SaveComment(ctx, 10, c); err != nil {
+					t.Fatal(err)
+				}
+			}
+			first.IsDeleted, first.DeletedAt, first.DeletedBy = true, now, 2
+			if err := store.SaveComment(ctx, 10, first); err != nil {
+				t.Fatal(err)
+			}
+			comments, err := store.ListComments(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[int][]Comment{10: {first, reply}}; !reflect.DeepEqual(comments, want) {
+				t.Fatalf("ListComments = %+v, want %+v", comments, want)
+			}
+
+			m := Media{ID: 5, Type: "image", PostID: 10, UserID: 2, Filename: "a.png", URL: "/uploads/a.png", Status: MediaReady, CreatedAt: now, path: "uploads/a.png", thumbPath: "uploads/a_thumb.png"}
+			if err := store.SaveMedia(ctx, m); err != nil {
+				t.Fatal(err)
+			}
+			media, err := store.ListMedia(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(media) != 1 || media[0] != m {
+				t.Fatalf("ListMedia = %+v, want %+v", media, m)
+			}
+			if err := store.DeleteMedia(ctx, m.ID); err != nil {
+				t.Fatal(err)
+			}
+			if media, _ := store.ListMedia(ctx); len(media) != 0 {
+				t.Fatalf("ListMedia after DeleteMedia = %+v", media)
+			}
+		})
+	}
+}
+
+func TestSQLiteRestartKeepsIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newTestApp(t, store)
+	aliceID, alice := a.register("alice")
+	private := a.createPost(alice, map[string]any{"content": "only me", "visibility": "private"}, "")
+	a.expect(http.StatusCreated, "POST", postPath(private, "/comments"), alice, map[string]string{"content": "note"})
+	mediaID := a.uploadImage(alice, private)
+	a.expect(http.StatusOK, "PATCH", "/me", alice, map[string]any{"bio": "hello"})
+	store.Close()
+
+	// khởi động lại: store mở lại từ file, handler nạp lại từ store
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	b := newTestApp(t, store)
+
+	malloryID, mallory := b.register("mallory")
+	if malloryID == aliceID {
+		t.Fatalf("new user got alice's ID %d", aliceID)
+	}
+	b.expect(http.StatusNotFound, "GET", postPath(private, ""), mallory, nil)
+	b.expect(http.StatusConflict, "POST", "/register", "", map[string]string{
+		"username": "alice", "email": "other@example.com", "password": "password1",
+	})
+	b.expect(http.StatusOK, "POST", "/login", "", LoginRequest{Login: "alice", Password: "password1"})
+
+	var profile UserProfile
+	decodeBody(t, b.expect(http.StatusOK, "GET", fmt.Sprintf("/users/%d", aliceID), "", nil), &profile)
+	if profile.Username != "alice" || profile.Bio != "hello" {
+		t.Fatalf("profile after restart = %+v", profile)
+	}
+
+	var comments GetCommentsResponse
+	decodeBody(t, b.expect(http.StatusOK, "GET", postPath(private, "/comments"), alice, nil), &comments)
+	if len(comments.Comments) != 1 || comments.Comments[0].Content != "note" {
+		t.Fatalf("comments after restart = %+v", comments.Comments)
+	}
+	var created CommentResponse
+	decodeBody(t, b.expect(http.StatusCreated, "POST", postPath(private, "/comments"), alice, map[string]string{"content": "again"}), &created)
+	if created.CommentID <= comments.Comments[0].CommentID {
+		t.Fatalf("new comment reused ID %d", created.CommentID)
+	}
+
+	b.expect(http.StatusOK, "GET", fmt.Sprintf("/media/%d", mediaID), alice, nil)
+	b.expect(http.StatusNotFound, "GET", fmt.Sprintf("/media/%d", mediaID), mallory, nil)
+	if id := b.uploadImage(alice, private); id <= mediaID {
+		t.Fatalf("new media reused ID %d", id)
+	}
+}
+
+// blockingStore chặn GetPost và ListPosts tới khi context của request bị huỷ
+type blockingStore struct {
+	Store
+	called chan struct{}
+}
+
+func (s blockingStore) GetPost(ctx context.Context, _ int) (Post, error) {
+	s.called <- struct{}{}
+	<-ctx.Done()
+	return Post{}, ctx.Err()
+}
+
+func (s blockingStore) ListPosts(ctx context.Context, _ PostFilter) ([]Post, error) {
+	s.called <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestHandlersStopWhenRequestCancelled(t *testing.T) {
+	store := blockingStore{Store: NewMemoryStore(), called: make(chan struct{}, 1)}
+	a := newTestApp(t, store)
+
+	for _, path := range []string{"/posts/1", "/posts"} {
+		ctx, cancel := context.WithCancel(t.Context())
+		req := httptest.NewRequest("GET", path, nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			a.router.ServeHTTP(rec, req)
+			close(done)
+		}()
+
+		// client ngắt kết nối khi store đang chạy
+		<-store.called
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("GET %s still running after the request was cancelled", path)
+		}
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("GET %s: status %d", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package apis
+
+import (
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// thumbnailMaxSize is the longest edge of generated thumbnails, in pixels
+const thumbnailMaxSize = 320
+
+// thumbnailPath puts the thumbnail next to the original: "<name>_thumb<ext>"
+func thumbnailPath(path, format string) string {
+	ext := ".jpg"
+	if format == "png" {
+		ext = ".png" // giữ nền trong suốt
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "_thumb" + ext
+}
+
+// writeThumbnail decodes the image at src, scales it down to fit thumbnailMaxSize and
+// saves it to path; images already small enough are re-encoded as is
+func writeThumbnail(src, format, path string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	img, _, err := image.Decode(in)
+	in.Close()
+	if err != nil {
+		return err
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > thumbnailMaxSize || h > thumbnailMaxSize {
+		if w >= h {
+			w, h = thumbnailMaxSize, max(1, h*thumbnailMaxSize/w)
+		} else {
+			w, h = max(1, w*thumbnailMaxSize/h), thumbnailMaxSize
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+		img = dst
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if format == "png" {
+		err = png.Encode(f, img)
+	} else {
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: 80})
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package apis
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// jpegBytes mã hoá một ảnh JPEG w x h
+func jpegBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uploadedMedia upload một file vào post và trả về bản ghi media đã lưu
+func (a *testApp) uploadedMedia(token string, postID int, mediaType, filename string, data []byte) Media {
+	a.t.Helper()
+	rec := a.uploadNamed(token, map[string]string{"type": mediaType, "post_id": fmt.Sprint(postID)}, filename, data)
+	if rec.Code != http.StatusCreated {
+		a.t.Fatalf("upload %s: status %d, body %s", filename, rec.Code, rec.Body.String())
+	}
+	var resp MediaResponse
+	decodeBody(a.t, rec, &resp)
+	media, _ := a.media.lookup(fmt.Sprint(resp.MediaID))
+	return media
+}
+
+func TestUploadMakesThumbnail(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+
+	for name, tc := range map[string]struct {
+		data         []byte
+		wantW, wantH int
+	}{
+		"wide.jpg": {jpegBytes(t, 1000, 500), 320, 160},
+		"tall.png": {pngBytes(t, 100, 640), 50, 320},
+		"tiny.png": {pngBytes(t, 8, 4), 8, 4},
+	} {
+		media := a.uploadedMedia(alice, postID, "image", name, tc.data)
+		if media.Status != MediaReady || media.ThumbnailURL == "" || media.thumbPath == "" {
+			t.Fatalf("%s: media = %+v", name, media)
+		}
+		f, err := os.Open(media.thumbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, _, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil || cfg.Width != tc.wantW || cfg.Height != tc.wantH {
+			t.Fatalf("%s: thumbnail %dx%d, %v, want %dx%d", name, cfg.Width, cfg.Height, err, tc.wantW, tc.wantH)
+		}
+	}
+
+	// video không có thumbnail nhưng vẫn ready
+	video := a.uploadedMedia(alice, postID, "video", "clip.webm", []byte("\x1a\x45\xdf\xa3 not really a video"))
+	if video.Status != MediaReady || video.ThumbnailURL != "" {
+		t.Fatalf("video = %+v", video)
+	}
+}
+
+func TestUploadRejectsBadImages(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "pics"}, "")
+	fields := map[string]string{"type": "image", "post_id": fmt.Sprint(postID)}
+
+	corrupt := jpegBytes(t, 16, 16)[:20]
+	for name, data := range map[string][]byte{
+		"notes.txt":   []byte("just some text"),
+		"corrupt.jpg": corrupt,
+	} {
+		if rec := a.uploadNamed(alice, fields, name, data); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d, body %s", name, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package apis
+
+import "time"
+
+// Mọi created_at/updated_at/deleted_at trả về client đều là RFC3339 theo UTC,
+// nhờ vậy so sánh chuỗi cũng đúng thứ tự thời gian
+
+// nowRFC3339 is the current time as a UTC RFC3339 timestamp
+func nowRFC3339() string {
+	return formatRFC3339(time.Now())
+}
+
+// formatRFC3339 formats t as a UTC RFC3339 timestamp
+func formatRFC3339(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,33 @@
+package apis
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFormatRFC3339UsesUTC(t *testing.T) {
+	local := time.Date(2024, 5, 1, 9, 30, 0, 0, time.FixedZone("ICT", 7*3600))
+	if got := formatRFC3339(local); got != "2024-05-01T02:30:00Z" {
+		t.Fatalf("formatRFC3339 = %q", got)
+	}
+}
+
+func TestCreatedPostTimestampIsUTC(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "hello"}, "")
+
+	var post PostDetail
+	decodeBody(t, a.expect(http.StatusOK, "GET", postPath(postID, ""), "", nil), &post)
+	created, err := time.Parse(time.RFC3339, post.CreatedAt)
+	if err != nil {
+		t.Fatalf("created_at %q: %v", post.CreatedAt, err)
+	}
+	if _, offset := created.Zone(); offset != 0 || post.CreatedAt[len(post.CreatedAt)-1] != 'Z' {
+		t.Fatalf("created_at %q is not UTC", post.CreatedAt)
+	}
+	if time.Since(created) > time.Minute {
+		t.Fatalf("created_at %q is not now", post.CreatedAt)
+	}
+}
@@ -0,0 +1,125 @@
+package apis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+)
+
+// EmailVerificationTTL là thời gian một verification token còn dùng được
+const EmailVerificationTTL = 24 * time.Hour
+
+// VerificationResendInterval là khoảng cách tối thiểu giữa hai token gửi lại khi login chưa xác nhận
+const VerificationResendInterval = 10 * time.Minute
+
+// VerificationSender delivers the email verification token of a new account,
+// e.g. as a link to GET /auth/verify?token=...
+type VerificationSender interface {
+	SendVerification(email, token string)
+}
+
+// LogVerificationSender writes the verification path to the server log; it is
+// the default when AuthHandler.Verifications is nil (no mail server configured)
+type LogVerificationSender struct{}
+
+// SendVerification implements VerificationSender
+func (LogVerificationSender) SendVerification(email, token string) {
+	log.Printf("verify %s: /auth/verify?token=%s", email, token)
+}
+
+// emailVerification là một token chờ xác nhận
+type emailVerification struct {
+	userID  int
+	issued  time.Time
+	expires time.Time
+}
+
+// issueVerification creates a fresh token for user, replacing any earlier one,
+// and hands it to the sender. The caller must not hold h.mu
+func (h *AuthHandler) issueVerification(user User) error {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(buf)
+
+	h.mu.Lock()
+	if h.verifications == nil {
+		h.verifications = make(map[string]emailVerification)
+	}
+	// mỗi user chỉ có một token còn hiệu lực; dọn luôn token đã hết hạn
+	now := time.Now()
+	for t, v := range h.verifications {
+		if v.userID == user.ID || now.After(v.expires) {
+			delete(h.verifications, t)
+		}
+	}
+	h.verifications[token] = emailVerification{userID: user.ID, issued: now, expires: now.Add(EmailVerificationTTL)}
+	h.mu.Unlock()
+
+	var sender VerificationSender = LogVerificationSender{}
+	if h.Verifications != nil {
+		sender = h.Verifications
+	}
+	sender.SendVerification(user.Email, token)
+	return nil
+}
+
+// resendVerification issues a new token for a blocked login unless the live one was
+// sent less than VerificationResendInterval ago, so repeated logins cannot flood the
+// inbox; it reports whether a token was sent. The caller must not hold h.mu
+func (h *AuthHandler) resendVerification(user User) (bool, error) {
+	h.mu.Lock()
+	for _, v := range h.verifications {
+		if v.userID == user.ID && time.Since(v.issued) < VerificationResendInterval && time.Now().Before(v.expires) {
+			h.mu.Unlock()
+			return false, nil
+		}
+	}
+	h.mu.Unlock()
+	return true, h.issueVerification(user)
+}
+
+// VerifyEmail godoc
+// @Summary Verify email
+// @Description Confirm the email of an account with the token sent at registration.
+// @Description A token works once and expires after 24 hours; logging in unverified sends a new one,
+// @Description at most once every 10 minutes
+// @Tags auth
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} APIError
+// @Failure 410 {object} APIError
+// @Router /auth/verify [get]
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	v, ok := h.verifications[token]
+	if !ok || token == "" {
+		writeJSONError(w, http.StatusBadRequest, "Invalid or already used verification token")
+		return
+	}
+	// dùng một lần: xoá dù thành công hay hết hạn
+	delete(h.verifications, token)
+	if time.Now().After(v.expires) {
+		writeJSONError(w, http.StatusGone, "Verification token has expired")
+		return
+	}
+
+	user, exists := h.Users[v.userID]
+	if !exists || user.IsDeleted {
+		writeJSONError(w, http.StatusBadRequest, "Invalid or already used verification token")
+		return
+	}
+	if err := h.updateUser(r.Context(), user.ID, func(u *User) { u.EmailVerified = true }); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Cannot save user")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Email verified"})
+}
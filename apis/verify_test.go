@@ -0,0 +1,147 @@
+package apis
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// emailVerified đọc email_verified qua GET /me
+func (a *testApp) emailVerified(token string) bool {
+	a.t.Helper()
+	var me AccountResponse
+	decodeBody(a.t, a.expect(http.StatusOK, "GET", "/me", token, nil), &me)
+	return me.EmailVerified
+}
+
+func TestVerifyEmail(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, alice := a.register("alice")
+	if a.emailVerified(alice) {
+		t.Fatal("new account already verified")
+	}
+	token := a.outbox.token("alice@example.com")
+	if token == "" {
+		t.Fatal("no verification token sent")
+	}
+
+	a.expect(http.StatusOK, "GET", "/auth/verify?token="+token, "", nil)
+	if !a.emailVerified(alice) {
+		t.Fatal("account not verified")
+	}
+	// token chỉ dùng được một lần
+	a.expect(http.StatusBadRequest, "GET", "/auth/verify?token="+token, "", nil)
+	a.expect(http.StatusBadRequest, "GET", "/auth/verify?token=unknown", "", nil)
+	a.expect(http.StatusBadRequest, "GET", "/auth/verify", "", nil)
+}
+
+func TestVerifyEmailExpiredToken(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, bob := a.register("bob")
+	token := a.outbox.token("bob@example.com")
+
+	a.auth.mu.Lock()
+	v := a.auth.verifications[token]
+	v.expires = time.Now().Add(-time.Minute)
+	a.auth.verifications[token] = v
+	a.auth.mu.Unlock()
+
+	a.expect(http.StatusGone, "GET", "/auth/verify?token="+token, "", nil)
+	if a.emailVerified(bob) {
+		t.Fatal("expired token verified the account")
+	}
+	// token hết hạn cũng bị xoá
+	a.expect(http.StatusBadRequest, "GET", "/auth/verify?token="+token, "", nil)
+}
+
+// backdateVerification lùi thời điểm gửi của verification token đi d
+func (a *testApp) backdateVerification(token string, d time.Duration) {
+	a.t.Helper()
+	a.auth.mu.Lock()
+	defer a.auth.mu.Unlock()
+	v, ok := a.auth.verifications[token]
+	if !ok {
+		a.t.Fatalf("no verification token %q", token)
+	}
+	v.issued = v.issued.Add(-d)
+	a.auth.verifications[token] = v
+}
+
+func TestRequireVerifiedLogin(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.auth.RequireVerified = true
+
+	rec := a.expect(http.StatusOK, "POST", "/register", "", map[string]string{
+		"username": "carol", "email": "carol@example.com", "password": "password1",
+	})
+	var resp map[string]any
+	decodeBody(t, rec, &resp)
+	if _, ok := resp["token"]; ok {
+		t.Fatalf("register issued a JWT before verification: %v", resp)
+	}
+	first := a.outbox.token("carol@example.com")
+
+	// token vừa gửi lúc đăng ký: login bị chặn nhưng không gửi thêm
+	if code := a.login("carol", "password1"); code != http.StatusForbidden {
+		t.Fatalf("unverified login: status %d", code)
+	}
+	if a.outbox.token("carol@example.com") != first {
+		t.Fatal("login right after register sent another token")
+	}
+
+	// quá VerificationResendInterval thì login gửi token mới, token cũ hết hiệu lực
+	a.backdateVerification(first, VerificationResendInterval)
+	if code := a.login("carol", "password1"); code != http.StatusForbidden {
+		t.Fatalf("unverified login: status %d", code)
+	}
+	second := a.outbox.token("carol@example.com")
+	if second == first {
+		t.Fatal("login did not send a new verification token")
+	}
+	a.expect(http.StatusBadRequest, "GET", "/auth/verify?token="+first, "", nil)
+	a.expect(http.StatusOK, "GET", "/auth/verify?token="+second, "", nil)
+
+	if code := a.login("carol", "password1"); code != http.StatusOK {
+		t.Fatalf("verified login: status %d", code)
+	}
+}
+
+func TestRequireVerifiedLoginResendLimited(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.auth.RequireVerified = true
+	a.expect(http.StatusOK, "POST", "/register", "", map[string]string{
+		"username": "dave", "email": "dave@example.com", "password": "password1",
+	})
+	first := a.outbox.token("dave@example.com")
+
+	// nhiều lần login liền nhau không gửi thêm token nào
+	for range 5 {
+		rec := a.expect(http.StatusForbidden, "POST", "/login", "", LoginRequest{Login: "dave", Password: "password1"})
+		var apiErr APIError
+		decodeBody(t, rec, &apiErr)
+		if apiErr.Error != "Email not verified, use the verification link sent recently" {
+			t.Fatalf("error = %q", apiErr.Error)
+		}
+	}
+	if a.outbox.token("dave@example.com") != first {
+		t.Fatal("repeated logins sent new tokens")
+	}
+
+	// sai mật khẩu không bao giờ gửi token
+	a.backdateVerification(first, VerificationResendInterval)
+	a.login("dave", "wrong-password")
+	if a.outbox.token("dave@example.com") != first {
+		t.Fatal("failed login sent a token")
+	}
+
+	// token cũ hết hạn thì gửi ngay dù chưa hết khoảng chờ
+	a.auth.mu.Lock()
+	v := a.auth.verifications[first]
+	v.issued, v.expires = time.Now(), time.Now().Add(-time.Minute)
+	a.auth.verifications[first] = v
+	a.auth.mu.Unlock()
+	a.expect(http.StatusForbidden, "POST", "/login", "", LoginRequest{Login: "dave", Password: "password1"})
+	if second := a.outbox.token("dave@example.com"); second == first {
+		t.Fatal("expired token not replaced")
+	}
+}
@@ -0,0 +1,267 @@
+package apis
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Các loại event gửi tới webhook
+const (
+	EventPostCreated = "post.created"
+	EventPostDeleted = "post.deleted"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []string{EventPostCreated, EventPostDeleted}
+
+// Header của mỗi lần gửi; receiver tính HMAC-SHA256 của body bằng secret để so với WebhookSignatureHeader
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// Gửi tối đa maxWebhookAttempts lần, lần thứ n chờ n*webhookRetryDelay
+const (
+	maxWebhookAttempts = 3
+	webhookRetryDelay  = 2 * time.Second
+	webhookTimeout     = 5 * time.Second
+)
+
+// Webhook is a registered callback URL
+type Webhook struct {
+	ID        int      `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"created_at"`
+	Secret    string   `json:"secret,omitempty"` // chỉ trả về một lần khi tạo
+}
+
+// WebhookRequest is the body of POST /webhooks; an empty secret is generated
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// WebhookEvent is the JSON body POSTed to a webhook
+type WebhookEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	CreatedAt string `json:"created_at"`
+	Data      any    `json:"data"`
+}
+
+// WebhookRegistry stores webhooks and delivers events to them in the background
+type WebhookRegistry struct {
+	mu     sync.Mutex
+	hooks  map[int]Webhook // key = id, Secret luôn được giữ ở đây
+	ids    IDGenerator
+	Tokens *TokenService
+	Client *http.Client // nil thì dùng client có timeout webhookTimeout
+}
+
+// NewWebhookRegistry constructor
+func NewWebhookRegistry() *WebhookRegistry {
+	return &WebhookRegistry{
+		hooks: make(map[int]Webhook),
+	}
+}
+
+// RegisterRoutes đăng ký các endpoint webhook; chỉ admin vì server sẽ gọi tới URL được đăng ký
+func (h *WebhookRegistry) RegisterRoutes(router *mux.Router) {
+	router.Handle("/webhooks", h.Tokens.RequireAdmin(http.HandlerFunc(h.CreateWebhook))).Methods("POST")
+	router.Handle("/webhooks", h.Tokens.RequireAdmin(http.HandlerFunc(h.ListWebhooks))).Methods("GET")
+	router.Handle("/webhooks/{webhook_id}", h.Tokens.RequireAdmin(http.HandlerFunc(h.DeleteWebhook))).Methods("DELETE")
+}
+
+// @Summary Register Webhook
+// @Description Register a URL that receives post.created / post.deleted events as a JSON POST.
+// @Description Each delivery carries X-Webhook-Signature: sha256=<hex HMAC-SHA256 of the body with the secret>;
+// @Description the secret is generated when omitted and only returned here
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body WebhookRequest true "Webhook"
+// @Success 201 {object} Webhook
+// @Failure 400 {object} APIError
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Router /webhooks [post]
+func (h *WebhookRegistry) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req WebhookRequest
+	if err := decodeJSON(w, r, &req, maxJSONBody); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if !isHTTPURL(req.URL) {
+		writeJSONError(w, http.StatusBadRequest, "url must be an http(s) URL")
+		return
+	}
+	if len(req.Events) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "events is required")
+		return
+	}
+	for _, e := range req.Events {
+		if !slices.Contains(WebhookEvents, e) {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid event %q, valid events: post.created, post.deleted", e))
+			return
+		}
+	}
+	if req.Secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Cannot generate secret")
+			return
+		}
+		req.Secret = hex.EncodeToString(buf)
+	}
+
+	h.mu.Lock()
+	hook := Webhook{
+		ID:        h.ids.Next(),
+		URL:       req.URL,
+		Events:    slices.Compact(slices.Sorted(slices.Values(req.Events))),
+		CreatedAt: nowRFC3339(),
+		Secret:    req.Secret,
+	}
+	if h.hooks == nil {
+		h.hooks = make(map[int]Webhook)
+	}
+	h.hooks[hook.ID] = hook
+	h.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+// @Summary List Webhooks
+// @Description List registered webhooks, without their secrets
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} Webhook
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Router /webhooks [get]
+func (h *WebhookRegistry) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	hooks := make([]Webhook, 0, len(h.hooks))
+	for _, hook := range h.hooks {
+		hook.Secret = ""
+		hooks = append(hooks, hook)
+	}
+	h.mu.Unlock()
+
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+// @Summary Delete Webhook
+// @Description Stop sending events to a webhook
+// @Tags webhooks
+// @Security BearerAuth
+// @Param webhook_id path int true "Webhook ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError
+// @Failure 403 {object} APIError
+// @Failure 404 {object} APIError
+// @Router /webhooks/{webhook_id} [delete]
+func (h *WebhookRegistry) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["webhook_id"])
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.hooks[id]; !ok {
+		writeJSONError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	delete(h.hooks, id)
+	writeNoContent(w)
+}
+
+// publish sends an event to every webhook subscribed to eventType without blocking the
+// caller; a nil registry does nothing
+func (h *WebhookRegistry) publish(eventType string, data any) {
+	if h == nil {
+		return
+	}
+	body, err := json.Marshal(WebhookEvent{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		CreatedAt: nowRFC3339(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Println("webhook", eventType, ": encode event:", err)
+		return
+	}
+
+	h.mu.Lock()
+	var targets []Webhook
+	for _, hook := range h.hooks {
+		if slices.Contains(hook.Events, eventType) {
+			targets = append(targets, hook)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, hook := range targets {
+		go h.deliver(hook, eventType, body)
+	}
+}
+
+// deliver POSTs body to hook, retrying on network errors and non-2xx responses
+func (h *WebhookRegistry) deliver(hook Webhook, eventType string, body []byte) {
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+	signature := SignWebhook([]byte(hook.Secret), body)
+
+	for attempt := 1; attempt <= maxWebhookAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * webhookRetryDelay)
+		}
+		req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+		if err != nil {
+			log.Println("webhook", hook.ID, ":", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(WebhookEventHeader, eventType)
+		req.Header.Set(WebhookSignatureHeader, signature)
+
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		log.Printf("webhook %d %s attempt %d/%d: %v", hook.ID, eventType, attempt, maxWebhookAttempts, err)
+	}
+}
+
+// SignWebhook returns the X-Webhook-Signature value for body: "sha256=" + hex HMAC-SHA256
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
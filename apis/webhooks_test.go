@@ -0,0 +1,171 @@
+package apis
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// webhookDelivery là một request receiver nhận được
+type webhookDelivery struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+// webhookReceiver dựng httptest.Server ghi lại mọi request; status trả về lần lượt theo
+// statuses, hết danh sách thì trả 200
+func webhookReceiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan webhookDelivery) {
+	t.Helper()
+	deliveries := make(chan webhookDelivery, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- webhookDelivery{
+			event:     r.Header.Get(WebhookEventHeader),
+			signature: r.Header.Get(WebhookSignatureHeader),
+			body:      body,
+		}
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, deliveries
+}
+
+// nextDelivery chờ request tiếp theo tới receiver
+func nextDelivery(t *testing.T, deliveries <-chan webhookDelivery, wait time.Duration) webhookDelivery {
+	t.Helper()
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(wait):
+		t.Fatal("no webhook delivery")
+		return webhookDelivery{}
+	}
+}
+
+// withWebhooks gắn một WebhookRegistry vào app và trả về token admin
+func (a *testApp) withWebhooks() (*WebhookRegistry, string) {
+	a.t.Helper()
+	hooks := NewWebhookRegistry()
+	hooks.Tokens = a.tokens
+	hooks.RegisterRoutes(a.router)
+	a.posts.Webhooks = hooks
+	adminID, admin := a.register("admin")
+	a.tokens.Admins[adminID] = true
+	return hooks, admin
+}
+
+func TestWebhookDeliversSignedPostCreated(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, admin := a.withWebhooks()
+	srv, deliveries := webhookReceiver(t)
+	secret := "shared-secret"
+
+	var hook Webhook
+	decodeBody(t, a.expect(http.StatusCreated, "POST", "/webhooks", admin,
+		WebhookRequest{URL: srv.URL, Events: []string{EventPostCreated}, Secret: secret}), &hook)
+	if hook.Secret != secret {
+		t.Fatalf("created webhook = %+v", hook)
+	}
+
+	aliceID, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "hello hooks"}, "")
+	d := nextDelivery(t, deliveries, 5*time.Second)
+
+	// receiver tự tính HMAC-SHA256 của body để xác thực
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(d.body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); !hmac.Equal([]byte(d.signature), []byte(want)) {
+		t.Fatalf("signature %q does not verify, want %q", d.signature, want)
+	}
+	var event struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Data Post   `json:"data"`
+	}
+	if err := json.Unmarshal(d.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if d.event != EventPostCreated || event.Type != EventPostCreated || event.ID == "" {
+		t.Fatalf("event header %q, body %+v", d.event, event)
+	}
+	if event.Data.PostID != postID || event.Data.UserID != aliceID || event.Data.Content != "hello hooks" {
+		t.Fatalf("event data = %+v", event.Data)
+	}
+
+	// không đăng ký post.deleted nên xoá post không gửi gì; draft chỉ gửi khi publish
+	a.expect(http.StatusNoContent, "DELETE", postPath(postID, ""), alice, nil)
+	a.createPost(alice, map[string]any{"content": "later"}, "status=draft")
+	select {
+	case d := <-deliveries:
+		t.Fatalf("unexpected delivery %q: %s", d.event, d.body)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWebhookRetriesFailedDelivery(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, admin := a.withWebhooks()
+	srv, deliveries := webhookReceiver(t, http.StatusInternalServerError)
+	a.expect(http.StatusCreated, "POST", "/webhooks", admin, WebhookRequest{URL: srv.URL, Events: []string{EventPostDeleted}})
+
+	_, alice := a.register("alice")
+	postID := a.createPost(alice, map[string]any{"content": "bye"}, "")
+	a.expect(http.StatusNoContent, "DELETE", postPath(postID, ""), alice, nil)
+
+	first := nextDelivery(t, deliveries, 5*time.Second)
+	retry := nextDelivery(t, deliveries, webhookRetryDelay+5*time.Second)
+	if first.event != EventPostDeleted || string(retry.body) != string(first.body) || retry.signature != first.signature {
+		t.Fatalf("retry differs from the first attempt: %+v vs %+v", retry, first)
+	}
+	var event struct {
+		Data PostDeletedEvent `json:"data"`
+	}
+	if err := json.Unmarshal(retry.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Data.PostID != postID || event.Data.Permanent {
+		t.Fatalf("event data = %+v", event.Data)
+	}
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	a := newTestApp(t, nil)
+	_, admin := a.withWebhooks()
+	_, alice := a.register("alice")
+
+	a.expect(http.StatusForbidden, "POST", "/webhooks", alice, WebhookRequest{URL: "https://example.com/hook", Events: []string{EventPostCreated}})
+	for name, req := range map[string]WebhookRequest{
+		"bad url":       {URL: "ftp://example.com/hook", Events: []string{EventPostCreated}},
+		"no events":     {URL: "https://example.com/hook"},
+		"unknown event": {URL: "https://example.com/hook", Events: []string{"post.liked"}},
+	} {
+		if code := a.do("POST", "/webhooks", admin, req).Code; code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, code)
+		}
+	}
+
+	// secret sinh tự động chỉ trả về khi tạo, không lộ khi liệt kê
+	var hook Webhook
+	decodeBody(t, a.expect(http.StatusCreated, "POST", "/webhooks", admin, WebhookRequest{URL: "https://example.com/hook", Events: []string{EventPostCreated}}), &hook)
+	if len(hook.Secret) != 64 {
+		t.Fatalf("generated secret = %q", hook.Secret)
+	}
+	var hooks []Webhook
+	decodeBody(t, a.expect(http.StatusOK, "GET", "/webhooks", admin, nil), &hooks)
+	if len(hooks) != 1 || hooks[0].Secret != "" {
+		t.Fatalf("listed webhooks = %+v", hooks)
+	}
+	a.expect(http.StatusNoContent, "DELETE", "/webhooks/1", admin, nil)
+	a.expect(http.StatusNotFound, "DELETE", "/webhooks/1", admin, nil)
+}
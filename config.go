@@ -0,0 +1,182 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"http-swagger-app/apis"
+)
+
+// Config là cấu hình chạy server, đọc từ biến môi trường
+type Config struct {
+	Addr      string // ADDR
+	UploadDir string // UPLOAD_DIR
+	JWTSecret string // JWT_SECRET
+	BaseURL   string // BASE_URL, địa chỉ public dùng cho link media
+
+	Store         string // STORE, "sqlite" (mặc định) hoặc "memory" cho bản demo, dữ liệu mất khi tắt server
+	DBPath        string // DB_PATH, file SQLite khi STORE=sqlite
+	MigrateDBPath string // MIGRATE_DB_PATH, file SQLite mà POST /admin/migrate chép dữ liệu sang; rỗng thì tắt
+
+	MaxUploadSize int64 // MAX_UPLOAD_SIZE, số byte tối đa của một file upload
+	AdminUserIDs  []int // ADMIN_USER_IDS, danh sách user_id cách nhau bởi dấu phẩy
+
+	BannedWords []string // BANNED_WORDS, các từ cấm cách nhau bởi dấu phẩy
+
+	ProfileCacheTTL time.Duration // PROFILE_CACHE_TTL, vd "30s"; "0" tắt cache profile
+
+	MediaWorkers   int // MEDIA_WORKERS, số goroutine sinh thumbnail
+	MediaQueueSize int // MEDIA_QUEUE_SIZE, số ảnh chờ tối đa; đầy thì upload trả 503
+
+	CommentEditWindow time.Duration // COMMENT_EDIT_WINDOW, vd "15m"; "0" cho sửa comment bất cứ lúc nào
+
+	DefaultAvatar string // DEFAULT_AVATAR_URL, avatar của user mới đăng ký; rỗng thì không có
+
+	AvatarMaxWidth      int  // AVATAR_MAX_WIDTH, số pixel tối đa của ảnh avatar; không đặt thì không giới hạn
+	AvatarMaxHeight     int  // AVATAR_MAX_HEIGHT, như AVATAR_MAX_WIDTH cho chiều cao
+	AvatarRequireSquare bool // AVATAR_REQUIRE_SQUARE, "true" thì avatar phải vuông
+
+	TrendingWindow time.Duration // TRENDING_WINDOW, vd "24h"; khoảng thời gian tính hashtag trending
+
+	ReactionEventLog bool // REACTION_EVENT_LOG, "true" thì ghi lại mọi lần react/bỏ react cho admin
+
+	RequestTimeout time.Duration // REQUEST_TIMEOUT, vd "30s"; "0" tắt, các endpoint upload không bị giới hạn
+
+	RequireEmailVerification bool // REQUIRE_EMAIL_VERIFICATION, "true" thì phải xác nhận email mới login được
+
+	DefaultPageLimit int // DEFAULT_PAGE_LIMIT, limit của danh sách khi request không truyền limit
+	MaxPageLimit     int // MAX_PAGE_LIMIT, limit lớn hơn bị trả 400
+
+	FeedCacheTTL time.Duration // FEED_CACHE_TTL, vd "10s"; "0" tắt cache feed
+
+	CORSOrigins []string // CORS_ORIGINS, các origin cách nhau bởi dấu phẩy; rỗng thì cho mọi origin
+}
+
+// loadConfig đọc Config từ env, thiếu biến nào thì dùng giá trị mặc định
+func loadConfig() Config {
+	return Config{
+		Addr:      getenv("ADDR", ":8080"),
+		UploadDir: getenv("UPLOAD_DIR", "./uploads"),
+		JWTSecret: getenv("JWT_SECRET", "dev-secret-change-me"),
+		BaseURL:   getenv("BASE_URL", "http://localhost:8080"),
+
+		Store:         getenv("STORE", "sqlite"),
+		DBPath:        getenv("DB_PATH", "app.db"),
+		MigrateDBPath: getenv("MIGRATE_DB_PATH", ""),
+
+		MaxUploadSize: getenvInt64("MAX_UPLOAD_SIZE", 10<<20),
+		AdminUserIDs:  getenvIDs("ADMIN_USER_IDS"),
+
+		BannedWords: getenvList("BANNED_WORDS"),
+
+		ProfileCacheTTL: getenvDuration("PROFILE_CACHE_TTL", apis.DefaultProfileCacheTTL),
+
+		MediaWorkers:   int(getenvInt64("MEDIA_WORKERS", apis.DefaultMediaWorkers)),
+		MediaQueueSize: int(getenvInt64("MEDIA_QUEUE_SIZE", apis.DefaultMediaQueueSize)),
+
+		CommentEditWindow: getenvDuration("COMMENT_EDIT_WINDOW", apis.DefaultCommentEditWindow),
+
+		DefaultAvatar: getenv("DEFAULT_AVATAR_URL", ""),
+
+		AvatarMaxWidth:      int(getenvInt64("AVATAR_MAX_WIDTH", 0)),
+		AvatarMaxHeight:     int(getenvInt64("AVATAR_MAX_HEIGHT", 0)),
+		AvatarRequireSquare: getenvBool("AVATAR_REQUIRE_SQUARE", false),
+
+		TrendingWindow: getenvDuration("TRENDING_WINDOW", apis.DefaultTrendingWindow),
+
+		ReactionEventLog: getenvBool("REACTION_EVENT_LOG", false),
+
+		RequestTimeout: getenvDuration("REQUEST_TIMEOUT", apis.DefaultRequestTimeout),
+
+		RequireEmailVerification: getenvBool("REQUIRE_EMAIL_VERIFICATION", false),
+
+		DefaultPageLimit: int(getenvInt64("DEFAULT_PAGE_LIMIT", int64(apis.DefaultPageLimit))),
+		MaxPageLimit:     int(getenvInt64("MAX_PAGE_LIMIT", int64(apis.MaxPageLimit))),
+
+		FeedCacheTTL: getenvDuration("FEED_CACHE_TTL", apis.DefaultFeedCacheTTL),
+
+		CORSOrigins: getenvList("CORS_ORIGINS"),
+	}
+}
+
+// getenv trả về biến môi trường key, hoặc fallback nếu không đặt
+func getenv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getenvInt64 như getenv nhưng parse số nguyên dương; giá trị sai thì log và dùng fallback
+func getenvInt64(key string, fallback int64) int64 {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		log.Printf("invalid %s=%q, using %d", key, v, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getenvDuration parse duration kiểu "30s", "5m"; "0" hợp lệ, giá trị sai thì log và dùng fallback
+func getenvDuration(key string, fallback time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("invalid %s=%q, using %s", key, v, fallback)
+		return fallback
+	}
+	return d
+}
+
+// getenvBool parse "true"/"false" (và các dạng strconv.ParseBool nhận); giá trị sai thì log và dùng fallback
+func getenvBool(key string, fallback bool) bool {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("invalid %s=%q, using %t", key, v, fallback)
+		return fallback
+	}
+	return b
+}
+
+// getenvList tách biến môi trường dạng "a,b,c", bỏ phần tử rỗng
+func getenvList(key string) []string {
+	var list []string
+	for _, s := range strings.Split(os.Getenv(key), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// getenvIDs parse danh sách ID dạng "1,2,3"; phần tử sai được log và bỏ qua
+func getenvIDs(key string) []int {
+	var ids []int
+	for _, s := range strings.Split(os.Getenv(key), ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			log.Printf("invalid user ID %q in %s, ignored", s, key)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLoadConfigCORSOrigins(t *testing.T) {
+	t.Setenv("CORS_ORIGINS", "https://app.example.com, ,http://localhost:3000")
+	cfg := loadConfig()
+	want := []string{"https://app.example.com", "http://localhost:3000"}
+	if !slices.Equal(cfg.CORSOrigins, want) {
+		t.Fatalf("CORSOrigins = %v, want %v", cfg.CORSOrigins, want)
+	}
+
+	t.Setenv("CORS_ORIGINS", "")
+	if cfg := loadConfig(); cfg.CORSOrigins != nil {
+		t.Fatalf("CORSOrigins = %v, want any origin", cfg.CORSOrigins)
+	}
+}
+
+func TestLoadConfigStore(t *testing.T) {
+	t.Setenv("STORE", "")
+	t.Setenv("DB_PATH", "")
+	t.Setenv("MIGRATE_DB_PATH", "")
+	cfg := loadConfig()
+	if cfg.Store != "sqlite" || cfg.DBPath != "app.db" || cfg.MigrateDBPath != "" {
+		t.Fatalf("store config = %q %q %q, want sqlite app.db and no migration target", cfg.Store, cfg.DBPath, cfg.MigrateDBPath)
+	}
+
+	t.Setenv("STORE", "memory")
+	t.Setenv("MIGRATE_DB_PATH", "data/app.db")
+	if cfg := loadConfig(); cfg.Store != "memory" || cfg.MigrateDBPath != "data/app.db" {
+		t.Fatalf("store config = %q %q", cfg.Store, cfg.MigrateDBPath)
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	for _, key := range []string{"ADDR", "UPLOAD_DIR", "JWT_SECRET"} {
+		t.Setenv(key, "")
+	}
+	cfg := loadConfig()
+	if cfg.Addr != ":8080" || cfg.UploadDir != "./uploads" || cfg.JWTSecret != "dev-secret-change-me" {
+		t.Fatalf("defaults = %q %q %q", cfg.Addr, cfg.UploadDir, cfg.JWTSecret)
+	}
+
+	t.Setenv("ADDR", ":9090")
+	t.Setenv("UPLOAD_DIR", "/var/lib/app/uploads")
+	t.Setenv("JWT_SECRET", "s3cret")
+	cfg = loadConfig()
+	if cfg.Addr != ":9090" || cfg.UploadDir != "/var/lib/app/uploads" || cfg.JWTSecret != "s3cret" {
+		t.Fatalf("from env = %q %q %q", cfg.Addr, cfg.UploadDir, cfg.JWTSecret)
+	}
+}
+
+func TestLoadConfigAvatarRules(t *testing.T) {
+	for _, key := range []string{"AVATAR_MAX_WIDTH", "AVATAR_MAX_HEIGHT", "AVATAR_REQUIRE_SQUARE"} {
+		t.Setenv(key, "")
+	}
+	cfg := loadConfig()
+	if cfg.AvatarMaxWidth != 0 || cfg.AvatarMaxHeight != 0 || cfg.AvatarRequireSquare {
+		t.Fatalf("defaults = %d %d %t, want no avatar limits", cfg.AvatarMaxWidth, cfg.AvatarMaxHeight, cfg.AvatarRequireSquare)
+	}
+
+	t.Setenv("AVATAR_MAX_WIDTH", "512")
+	t.Setenv("AVATAR_MAX_HEIGHT", "256")
+	t.Setenv("AVATAR_REQUIRE_SQUARE", "true")
+	cfg = loadConfig()
+	if cfg.AvatarMaxWidth != 512 || cfg.AvatarMaxHeight != 256 || !cfg.AvatarRequireSquare {
+		t.Fatalf("from env = %d %d %t", cfg.AvatarMaxWidth, cfg.AvatarMaxHeight, cfg.AvatarRequireSquare)
+	}
+}
@@ -1,50 +1,286 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"http-swagger-app/apis"
 
 	_ "http-swagger-app/docs"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+// shutdownTimeout là thời gian tối đa chờ các request đang chạy khi tắt server
+const shutdownTimeout = 10 * time.Second
+
 // @title Swagger with net/http
 // @version 1.0
 // @description This is a sample Swagger API with net/http
 // @host localhost:8080
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and the JWT token.
+// timeoutExempt là các route không qua Timeout: upload cần lâu hơn, còn download file
+// phải stream thẳng ra client thay vì nằm hết trong buffer của Timeout
+var timeoutExempt = []string{"/media", "/media/batch", "/me/avatar", "/media/{media_id}/file", "/uploads/"}
+
 func main() {
+	cfg := loadConfig()
+
+	// SQLite lưu posts, users, profiles, comments và media; STORE=memory chỉ giữ trong bộ nhớ
+	var store apis.Store
+	switch cfg.Store {
+	case "memory":
+		store = apis.NewMemoryStore()
+	case "sqlite":
+		db, err := apis.NewSQLiteStore(cfg.DBPath)
+		if err != nil {
+			fmt.Println("Cannot open database:", err)
+			return
+		}
+		defer db.Close()
+		store = db
+	default:
+		fmt.Println("Unknown STORE:", cfg.Store)
+		return
+	}
+
+	// Giới hạn phân trang dùng chung cho mọi handler
+	apis.DefaultPageLimit = cfg.DefaultPageLimit
+	apis.MaxPageLimit = cfg.MaxPageLimit
+
 	// Dùng gorilla/mux router
 	router := mux.NewRouter()
+	router.NotFoundHandler = apis.NotFound()
+	router.MethodNotAllowedHandler = apis.MethodNotAllowed(router)
 
-	// Auth Handler
-	authHandler := &apis.AuthHandler{
-		Users: make(map[string]apis.User),
+	// Token service
+	tokens := apis.NewTokenService([]byte(cfg.JWTSecret))
+	tokens.Admins = make(map[int]bool)
+	for _, id := range cfg.AdminUserIDs {
+		tokens.Admins[id] = true
 	}
-	authHandler.RegisterRoutes(router)
 
 	// Profile Handler
-	profileHandler := &apis.ProfileHandler{}
+	profileHandler := apis.NewProfileHandler()
+	profileHandler.Tokens = tokens
+	profileHandler.Posts = store
+	profileHandler.CacheTTL = cfg.ProfileCacheTTL
+	profileHandler.DefaultAvatar = cfg.DefaultAvatar
 	profileHandler.RegisterRoutes(router)
 
+	// Auth dễ bị dò mật khẩu nên giới hạn số request theo IP
+	limited := router.NewRoute().Subrouter()
+	limited.Use(apis.RateLimit(30))
+
+	// Audit log cho các thao tác auth nhạy cảm, admin xem qua /admin/audit
+	auditLog := apis.NewAuditLog()
+	auditLog.Tokens = tokens
+	auditLog.RegisterRoutes(router)
+
+	// Auth Handler
+	authHandler := apis.NewAuthHandler(tokens)
+	tokens.Accounts = authHandler
+	authHandler.Profiles = profileHandler
+	profileHandler.Auth = authHandler
+	authHandler.Audit = auditLog
+	authHandler.RequireVerified = cfg.RequireEmailVerification
+	authHandler.RegisterRoutes(limited)
+
+	// Kiểm duyệt nội dung post/comment theo danh sách từ cấm, nếu có
+	var moderator apis.Moderator = apis.NopModerator{}
+	if len(cfg.BannedWords) > 0 {
+		moderator = apis.WordListModerator{Words: cfg.BannedWords}
+	}
+
 	// Posts Handler
-	postHandler := &apis.PostsHandler{}
+	postHandler := apis.NewPostsHandler(store)
+	postHandler.Tokens = tokens
+	postHandler.Moderator = moderator
+	postHandler.TrendingWindow = cfg.TrendingWindow
 	postHandler.RegisterRoutes(router)
 
-	// Posts Handler
-	reactHandler := &apis.ReactionsHandler{}
+	// Webhooks cho integrator, chỉ admin đăng ký được
+	webhooks := apis.NewWebhookRegistry()
+	webhooks.Tokens = tokens
+	postHandler.Webhooks = webhooks
+	webhooks.RegisterRoutes(router)
+
+	// Notification Handler
+	notificationHandler := apis.NewNotificationHandler()
+	notificationHandler.Tokens = tokens
+	notificationHandler.RegisterRoutes(router)
+
+	// Follows Handler
+	followHandler := apis.NewFollowsHandler()
+	followHandler.Tokens = tokens
+	followHandler.Notifications = notificationHandler
+	followHandler.Profiles = profileHandler
+	profileHandler.Follows = followHandler
+	postHandler.Follows = followHandler
+	followHandler.RegisterRoutes(router)
+
+	// Blocks Handler
+	blockHandler := apis.NewBlocksHandler()
+	blockHandler.Tokens = tokens
+	blockHandler.Follows = followHandler
+	followHandler.Blocks = blockHandler
+	profileHandler.Blocks = blockHandler
+	postHandler.Blocks = blockHandler
+	blockHandler.RegisterRoutes(router)
+
+	// Reactions Handler
+	reactHandler := apis.NewReactionsHandler()
+	reactHandler.Tokens = tokens
+	reactHandler.Posts = store
+	reactHandler.Notifications = notificationHandler
+	reactHandler.Blocks = blockHandler
+	reactHandler.Follows = followHandler
+	reactHandler.LogEvents = cfg.ReactionEventLog
+	reactHandler.Profiles = profileHandler
+	postHandler.Reactions = reactHandler
 	reactHandler.RegisterRoutes(router)
 
+	// Comments Handler
+	commentHandler := apis.NewCommentsHandler()
+	commentHandler.Tokens = tokens
+	commentHandler.Moderator = moderator
+	commentHandler.EditWindow = cfg.CommentEditWindow
+	commentHandler.Posts = store
+	commentHandler.Notifications = notificationHandler
+	commentHandler.Profiles = profileHandler
+	commentHandler.Blocks = blockHandler
+	commentHandler.Follows = followHandler
+	postHandler.Comments = commentHandler
+	authHandler.Posts = store
+	authHandler.Comments = commentHandler
+	commentHandler.RegisterRoutes(router)
+
+	// Reports Handler: user báo cáo post/comment, admin xem danh sách
+	reportHandler := apis.NewReportsHandler()
+	reportHandler.Tokens = tokens
+	reportHandler.Posts = store
+	reportHandler.Comments = commentHandler
+	reportHandler.Follows = followHandler
+	reportHandler.RegisterRoutes(router)
+
+	// Media Handler
+	mediaHandler := apis.NewMediaHandler(store, cfg.UploadDir)
+	mediaHandler.BaseURL = cfg.BaseURL
+	mediaHandler.MaxFileSize = cfg.MaxUploadSize
+	mediaHandler.AvatarRules = apis.AvatarRules{
+		MaxWidth:      cfg.AvatarMaxWidth,
+		MaxHeight:     cfg.AvatarMaxHeight,
+		RequireSquare: cfg.AvatarRequireSquare,
+	}
+	mediaHandler.Tokens = tokens
+	mediaHandler.Profiles = profileHandler
+	mediaHandler.Follows = followHandler
+	mediaHandler.Blocks = blockHandler
+	mediaHandler.StartProcessing(cfg.MediaWorkers, cfg.MediaQueueSize)
+	postHandler.Media = mediaHandler
+	mediaHandler.RegisterRoutes(router)
+	// upload tốn đĩa và CPU sinh thumbnail nên cũng giới hạn theo IP, tách khỏi giới hạn của auth
+	uploads := router.NewRoute().Subrouter()
+	uploads.Use(apis.RateLimit(30))
+	mediaHandler.RegisterUploadRoutes(uploads)
+	router.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", mediaHandler.FileServer())).Methods("GET", "HEAD")
+
+	// Feeds Handler
+	feedHandler := apis.NewFeedsHandler(store, followHandler, reactHandler, commentHandler)
+	feedHandler.Tokens = tokens
+	feedHandler.Blocks = blockHandler
+	feedHandler.Profiles = profileHandler
+	feedHandler.Media = mediaHandler
+	feedHandler.CacheTTL = cfg.FeedCacheTTL
+	postHandler.Feeds = feedHandler
+	followHandler.Feeds = feedHandler
+	reactHandler.Feeds = feedHandler
+	feedHandler.RegisterRoutes(router)
+
+	// Chép dữ liệu đang chạy sang MIGRATE_DB_PATH, vd để chuyển bản demo sang SQLite
+	migrateHandler := &apis.MigrateHandler{Source: store, Tokens: tokens}
+	if cfg.MigrateDBPath != "" {
+		target, err := apis.NewSQLiteStore(cfg.MigrateDBPath)
+		if err != nil {
+			fmt.Println("Cannot open migration database:", err)
+			return
+		}
+		defer target.Close()
+		migrateHandler.Target = target
+	}
+	migrateHandler.RegisterRoutes(router)
+
+	// Nạp dữ liệu đã lưu trước khi nhận request để ID tiếp tục sau ID cũ
+	for _, loader := range []interface{ Load(context.Context) error }{authHandler, profileHandler, commentHandler, mediaHandler} {
+		if err := loader.Load(context.Background()); err != nil {
+			fmt.Println("Cannot load data:", err)
+			return
+		}
+	}
+
+	// Lỗi dạng problem+json khi client yêu cầu
+	router.Use(apis.ProblemDetails)
+
+	// Body của các endpoint ghi phải là JSON, trừ các endpoint upload multipart
+	router.Use(apis.RequireJSON("/media", "/media/batch", "/me/avatar"))
+
+	// Prometheus metrics
+	router.Use(apis.Metrics)
+
+	// Sau Metrics để request bị cắt được đếm là 503
+	router.Use(apis.Timeout(cfg.RequestTimeout, timeoutExempt...))
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
 	// Swagger
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
-	fmt.Println("Server started at :8080")
-	fmt.Println("Swagger: http://localhost:8080/swagger/index.html")
-	if err := http.ListenAndServe(":8080", router); err != nil {
+	fmt.Println("Server started at", cfg.Addr)
+	fmt.Println("Swagger: " + cfg.BaseURL + "/swagger/index.html")
+	// CORS bọc ngoài router để preflight OPTIONS không bị 405
+	handler := apis.RequestID(apis.Logging(apis.CORS(cfg.CORSOrigins)(apis.Versioning(router))))
+	server := &http.Server{Addr: cfg.Addr, Handler: handler}
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		fmt.Println("Cannot listen:", err)
+		return
+	}
+
+	// Dừng server khi nhận SIGINT/SIGTERM, chờ các request đang chạy xong
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := serve(ctx, server, ln); err != nil {
 		fmt.Println("Server stopped:", err)
 	}
 }
+
+// serve chạy server trên ln cho tới khi ctx bị huỷ rồi shutdown, chờ tối đa
+// shutdownTimeout cho các request đang chạy; trả về lỗi nếu server dừng vì lý do khác
+func serve(ctx context.Context, server *http.Server, ln net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		fmt.Println("Shutting down:", context.Cause(ctx))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"http-swagger-app/apis"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServeShutsDownGracefully(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, server, ln) }()
+
+	// một request đang chạy lúc nhận tín hiệu dừng vẫn phải trả lời xong
+	resp := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			resp <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		resp <- string(body)
+	}()
+	<-started
+	cancel()
+	close(release)
+
+	if got := <-resp; got != "done" {
+		t.Fatalf("in-flight response = %q, want done", got)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("serve returned %v", err)
+	}
+}
+
+func TestFileDownloadsNotBuffered(t *testing.T) {
+	release := make(chan struct{})
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first")
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		<-release
+		io.WriteString(w, "rest")
+	})
+
+	router := mux.NewRouter()
+	router.Handle("/media/{media_id}/file", stream).Methods("GET")
+	router.PathPrefix("/uploads/").Handler(stream).Methods("GET")
+	router.Use(apis.Timeout(time.Minute, timeoutExempt...))
+	server := httptest.NewServer(router)
+	defer server.Close()
+	defer close(release) // trước server.Close để handler đang chờ trả về
+
+	// phần đầu của file tới client trong khi handler vẫn còn đang ghi
+	for _, path := range []string{"/media/1/file", "/uploads/1_a.png"} {
+		first := make(chan string, 1)
+		go func() {
+			res, err := http.Get(server.URL + path)
+			if err != nil {
+				first <- err.Error()
+				return
+			}
+			defer res.Body.Close()
+			buf := make([]byte, len("first"))
+			n, _ := io.ReadFull(res.Body, buf)
+			first <- string(buf[:n])
+		}()
+		select {
+		case got := <-first:
+			if got != "first" {
+				t.Fatalf("GET %s: first chunk %q", path, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("GET %s: response buffered until the handler returned", path)
+		}
+	}
+}
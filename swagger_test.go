@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestSwaggerBearerAuth(t *testing.T) {
+	raw, err := os.ReadFile("docs/swagger.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec struct {
+		SecurityDefinitions map[string]struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+			In   string `json:"in"`
+		} `json:"securityDefinitions"`
+		Paths map[string]map[string]struct {
+			Security []map[string][]string `json:"security"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	scheme, ok := spec.SecurityDefinitions["BearerAuth"]
+	if !ok || scheme.Type != "apiKey" || scheme.Name != "Authorization" || scheme.In != "header" {
+		t.Fatalf("BearerAuth = %+v, %t", scheme, ok)
+	}
+
+	// route cần đăng nhập phải tham chiếu scheme, route public thì không
+	for _, route := range []struct {
+		path, method string
+		protected    bool
+	}{
+		{"/me", "get", true},
+		{"/posts", "post", true},
+		{"/users/{target_user_id}/follow", "post", true},
+		{"/posts/{post_id}", "get", false},
+		{"/login", "post", false},
+	} {
+		op, ok := spec.Paths[route.path][route.method]
+		if !ok {
+			t.Fatalf("%s %s missing from swagger.json", route.method, route.path)
+		}
+		protected := false
+		for _, req := range op.Security {
+			if _, ok := req["BearerAuth"]; ok {
+				protected = true
+			}
+		}
+		if protected != route.protected {
+			t.Fatalf("%s %s: security %v, want protected=%t", route.method, route.path, op.Security, route.protected)
+		}
+	}
+}